@@ -0,0 +1,133 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "log/slog"
+    "net/http"
+    "net/url"
+    "time"
+)
+
+// activityEndpoint is the researcher's activity feed: what the platform
+// recorded for the account (claims, releases, signups, submissions,
+// payouts). The path and fields mirror the web UI's activity page and are
+// unverified against the API.
+var activityEndpoint = "https://platform.synack.com/api/researcher/activity"
+
+// activityPageSize is how many of the latest entries each sync reads.
+const activityPageSize = 50
+
+// activitySettle is how old an entry must be before it is reconciled, so
+// a claim the bot just made has reached the audit log.
+const activitySettle = time.Minute
+
+// platformActivity is one entry of the activity feed.
+type platformActivity struct {
+    ID string `json:"id"`
+    // Type is "claim", "release", "signup", "submission" or "payout".
+    Type      string       `json:"type"`
+    TaskID    string       `json:"taskId"`
+    Slug      string       `json:"listingSlug"`
+    CreatedAt platformTime `json:"createdAt"`
+}
+
+// botAction returns the audit log key of the bot action that would have
+// caused the entry; submissions and payouts are never the bot's and are
+// not checked.
+func (a platformActivity) botAction() (string, bool) {
+    switch a.Type {
+    case "claim", "release":
+        return a.Type + " " + a.TaskID, true
+    case "signup":
+        return "signup " + a.Slug, true
+    }
+    return "", false
+}
+
+// getActivity retrieves the latest entries of the activity feed.
+func getActivity(token string) ([]platformActivity, error) {
+    u := activityEndpoint + "?" + url.Values{"page": {"1"}, "per_page": {fmt.Sprint(activityPageSize)}}.Encode()
+    resp, err := doWithRetry("activity", defaultRetryPolicy, func() (*http.Request, error) {
+        return newAPIRequest("GET", u, token, nil)
+    })
+    if err != nil {
+        return nil, err
+    }
+    defer closeBody(resp)
+    logResponse("activity", resp)
+
+    switch resp.StatusCode {
+    case http.StatusOK:
+        var entries []platformActivity
+        if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+            return nil, err
+        }
+        return entries, nil
+    case http.StatusUnauthorized:
+        return nil, fmt.Errorf("unauthorized (401)")
+    default:
+        return nil, unexpectedStatus("activity", resp.StatusCode, fmt.Errorf("failed to retrieve activity, status code: %d", resp.StatusCode))
+    }
+}
+
+// syncActivity reconciles the account's activity feed with the audit log
+// every interval (-activity-sync).
+func (b *bot) syncActivity(interval time.Duration) {
+    for {
+        b.reconcileActivity()
+        time.Sleep(jittered(interval, b.jitter))
+    }
+}
+
+// reconcileActivity flags the claims, releases and signups in the activity
+// feed that the audit log has no successful API call for: someone else
+// acted with the account, by hand or with a stolen session. Only entries
+// after the last reconciled one (or, the first time, after the audit log
+// began) are looked at.
+func (b *bot) reconcileActivity() {
+    entries, err := getActivity(b.tokens.Get())
+    if err != nil {
+        slog.Warn("failed to retrieve account activity", "endpoint", "activity", "err", err)
+        return
+    }
+    own, started, err := audit.BotActions()
+    if err != nil {
+        slog.Error("failed to read the audit log for the activity sync", "err", err)
+        return
+    }
+    cursor := b.store.ActivityCursor()
+    if cursor.IsZero() {
+        cursor = started
+    }
+    settled := time.Now().Add(-activitySettle)
+    newest := cursor
+    for _, e := range entries {
+        at := e.CreatedAt.Time
+        if !at.After(cursor) || at.After(settled) {
+            continue
+        }
+        if at.After(newest) {
+            newest = at
+        }
+        action, checked := e.botAction()
+        if !checked || own[action] {
+            continue
+        }
+        slog.Warn("account activity not originated by the bot", "type", e.Type, "task_id", e.TaskID, "slug", e.Slug, "at", at)
+        subject := "task " + e.TaskID
+        if e.Type == "signup" {
+            subject = "target " + e.Slug
+        }
+        notifyEvent(Event{
+            Type:    EventForeignActivity,
+            Message: fmt.Sprintf("Account activity the bot didn't do: %s of %s at %s. If that wasn't you, log out all sessions and change your password.", e.Type, subject, at.Local().Format("2006-01-02 15:04")),
+            Target:  e.Slug,
+        })
+    }
+    if newest.After(cursor) {
+        if err := b.store.SetActivityCursor(newest); err != nil {
+            slog.Error("failed to record activity sync", "err", err)
+        }
+    }
+}
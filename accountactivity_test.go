@@ -0,0 +1,60 @@
+package main
+
+import (
+    "path/filepath"
+    "slices"
+    "testing"
+    "time"
+)
+
+func TestReconcileActivityFlagsForeignActivity(t *testing.T) {
+    fp := newFakePlatform(t, "token")
+    rec := recordNotifications(t)
+    b := newTestBot(t, "token")
+    log, err := openAuditLog(filepath.Join(t.TempDir(), "audit.jsonl"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { log.f.Close() })
+    saved := audit
+    audit = log
+    t.Cleanup(func() { audit = saved })
+
+    audit.Record(AuditAPICall, "endpoint", "claim", "status", 201, "task_id", "own")
+    audit.Record(AuditAPICall, "endpoint", "claim", "status", 412, "task_id", "lost")
+    audit.Record(AuditAPICall, "endpoint", "signup", "status", 200, "slug", "a")
+    now := time.Now()
+    if err := b.store.SetActivityCursor(now.Add(-time.Hour)); err != nil {
+        t.Fatal(err)
+    }
+    ago := func(d time.Duration) platformTime { return platformTime{now.Add(-d)} }
+    fp.AddActivity(
+        platformActivity{ID: "1", Type: "claim", TaskID: "recent", CreatedAt: ago(10 * time.Second)},
+        platformActivity{ID: "2", Type: "submission", TaskID: "own", CreatedAt: ago(10 * time.Minute)},
+        platformActivity{ID: "3", Type: "release", TaskID: "other", Slug: "b", CreatedAt: ago(15 * time.Minute)},
+        platformActivity{ID: "4", Type: "signup", Slug: "a", CreatedAt: ago(20 * time.Minute)},
+        platformActivity{ID: "5", Type: "claim", TaskID: "lost", CreatedAt: ago(25 * time.Minute)},
+        platformActivity{ID: "6", Type: "claim", TaskID: "own", CreatedAt: ago(30 * time.Minute)},
+        platformActivity{ID: "7", Type: "claim", TaskID: "old", CreatedAt: ago(2 * time.Hour)},
+    )
+
+    b.reconcileActivity()
+
+    // The bot's failed claim and the release it never made are foreign;
+    // entries not settled yet or before the cursor aren't looked at.
+    if got := rec.Types(); !slices.Equal(got, []string{EventForeignActivity, EventForeignActivity}) {
+        t.Fatalf("events %v, want two %s", got, EventForeignActivity)
+    }
+    if got := b.store.ActivityCursor(); !got.Equal(now.Add(-10 * time.Minute)) {
+        t.Errorf("activity cursor %s, want the newest settled entry", got)
+    }
+
+    // Actions the bot logs later are read from where the last sync
+    // stopped, and entries already reconciled aren't flagged again.
+    audit.Record(AuditAPICall, "endpoint", "claim", "status", 201, "task_id", "later")
+    fp.AddActivity(platformActivity{ID: "8", Type: "claim", TaskID: "later", CreatedAt: ago(5 * time.Minute)})
+    b.reconcileActivity()
+    if got := rec.Types(); len(got) != 2 {
+        t.Errorf("events %v after the bot's own claim, want no new ones", got)
+    }
+}
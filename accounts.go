@@ -0,0 +1,276 @@
+package main
+
+import (
+    "expvar"
+    "fmt"
+    "io"
+    "log/slog"
+    "os"
+    "os/exec"
+    "os/signal"
+    "slices"
+    "strings"
+    "sync"
+    "time"
+)
+
+// AccountConfig is one Synack account from the "accounts" list of the config
+// file. Every account runs in its own bot process with its own token source,
+// polling loops and state.
+type AccountConfig struct {
+    // Name tags the account's logs, metrics and notifications.
+    Name string `json:"name"`
+    // Args are the run flags of this account, added to the supervisor's
+    // own, e.g. ["-token-file", "eu.token", "-db", "eu.db"].
+    Args []string `json:"args"`
+}
+
+// Restart backoff of crashed account processes.
+const (
+    accountRestartBase = 5 * time.Second
+    accountRestartMax  = 5 * time.Minute
+)
+
+// accountName is the account this process runs (-account); empty when
+// running a single account.
+var accountName string
+
+// perAccountFlags name files, listen addresses and token sources that two
+// bot processes can't share. The supervisor doesn't pass them on; each
+// account sets its own in args.
+var perAccountFlags = []string{
+    "control-addr", "token-listener", "health-addr", "debug-addr", "db", "audit-log",
+    "t", "token-file", "spare-tokens", "token-socket",
+}
+
+// perAccountBoolFlags are the boolean token sources. They aren't passed on
+// either, but can be set by several accounts: their keychain entries are
+// kept per account.
+var perAccountBoolFlags = []string{"keychain", "auto-login"}
+
+// validateAccounts checks the accounts list. No two accounts may use the
+// same value for one of the perAccountFlags.
+func validateAccounts(accounts []AccountConfig) error {
+    var names []string
+    used := map[string]string{}
+    for i, a := range accounts {
+        switch {
+        case a.Name == "":
+            return fmt.Errorf("accounts[%d] has no name", i)
+        case slices.Contains(names, a.Name):
+            return fmt.Errorf("accounts[%d]: duplicate name %q", i, a.Name)
+        case hasFlag(a.Args, "account"):
+            return fmt.Errorf("accounts[%d]: args must not set -account", i)
+        }
+        names = append(names, a.Name)
+        for _, name := range perAccountFlags {
+            v, ok := flagValue(a.Args, name)
+            if !ok || v == "" {
+                continue
+            }
+            key := name + "=" + v
+            if other, ok := used[key]; ok {
+                return fmt.Errorf("accounts[%d]: -%s %s is also used by account %q", i, name, v, other)
+            }
+            used[key] = a.Name
+        }
+    }
+    return nil
+}
+
+// accountArgs returns the shared run flags without the perAccountFlags and
+// perAccountBoolFlags. Each of those given to the supervisor must be set by
+// every account, so none silently runs without its database, listener or
+// token source.
+func accountArgs(accounts []AccountConfig, args []string) ([]string, error) {
+    for _, name := range slices.Concat(perAccountFlags, perAccountBoolFlags) {
+        if !hasFlag(args, name) {
+            continue
+        }
+        for _, a := range accounts {
+            if !hasFlag(a.Args, name) {
+                return nil, fmt.Errorf("account %q: -%s is set for all accounts; give each account its own in args", a.Name, name)
+            }
+        }
+    }
+    return stripFlags(args, perAccountFlags, perAccountBoolFlags), nil
+}
+
+// flagValue returns the value of the last -name or --name flag in args,
+// written as "-name value" or "-name=value".
+func flagValue(args []string, name string) (string, bool) {
+    var value string
+    found := false
+    for i := 0; i < len(args); i++ {
+        arg, ok := flagArg(args[i], name)
+        if !ok {
+            continue
+        }
+        found = true
+        if v, isInline := strings.CutPrefix(arg, "="); isInline {
+            value = v
+        } else if i+1 < len(args) {
+            i++
+            value = args[i]
+        }
+    }
+    return value, found
+}
+
+// hasFlag reports whether args set the -name or --name flag.
+func hasFlag(args []string, name string) bool {
+    _, ok := flagValue(args, name)
+    return ok
+}
+
+// stripFlags removes the named value flags, with their values, and the
+// named boolean flags from args.
+func stripFlags(args []string, names, boolNames []string) []string {
+    var out []string
+    for i := 0; i < len(args); i++ {
+        stripped := false
+        for _, name := range names {
+            if arg, ok := flagArg(args[i], name); ok {
+                if arg == "" {
+                    i++ // the value is the next argument
+                }
+                stripped = true
+                break
+            }
+        }
+        if !stripped && !slices.ContainsFunc(boolNames, func(name string) bool {
+            _, ok := flagArg(args[i], name)
+            return ok
+        }) {
+            out = append(out, args[i])
+        }
+    }
+    return out
+}
+
+// flagArg reports whether arg is the -name or --name flag and returns what
+// follows the name: "" or "=value".
+func flagArg(arg, name string) (string, bool) {
+    rest, ok := strings.CutPrefix(arg, "-")
+    if !ok {
+        return "", false
+    }
+    rest = strings.TrimPrefix(rest, "-")
+    rest, ok = strings.CutPrefix(rest, name)
+    if !ok || (rest != "" && rest[0] != '=') {
+        return "", false
+    }
+    return rest, true
+}
+
+// setAccount makes this process run the named account: logs get an account
+// attribute, notifications a "[name]" prefix and expvar an "account" var.
+func setAccount(name string) {
+    accountName = name
+    slog.SetDefault(slog.Default().With("account", name))
+    expvar.NewString("account").Set(name)
+}
+
+// runAccounts supervises one bot process per account until all of them have
+// stopped. args are the supervisor's own run flags, which every account
+// inherits except for the perAccountFlags and perAccountBoolFlags. An
+// account process that fails is restarted with backoff; one that exits
+// cleanly (e.g. after -run-for) stays stopped. Stop signals are passed on
+// to the account processes, and so are reload signals.
+func runAccounts(accounts []AccountConfig, args []string, logOutput io.Writer) error {
+    args, err := accountArgs(accounts, args)
+    if err != nil {
+        return err
+    }
+    exe, err := os.Executable()
+    if err != nil {
+        return err
+    }
+    var (
+        mu       sync.Mutex
+        running  = map[string]*os.Process{}
+        stopping bool
+    )
+    sigs, reloads := make(chan os.Signal, 1), make(chan os.Signal, 1)
+    signal.Notify(sigs, stopSignals...)
+    defer signal.Stop(sigs)
+    if len(reloadSignals) > 0 {
+        signal.Notify(reloads, reloadSignals...)
+        defer signal.Stop(reloads)
+    }
+    goSafe(func() {
+        for {
+            select {
+            case sig := <-sigs:
+                slog.Info("stopping accounts", "signal", sig)
+                mu.Lock()
+                stopping = true
+                for _, p := range running {
+                    p.Signal(sig)
+                }
+                mu.Unlock()
+            case sig := <-reloads:
+                if err := logFile.Reopen(); err != nil {
+                    slog.Error("log file could not be reopened", "err", err)
+                }
+                slog.Info("reloading accounts", "signal", sig)
+                mu.Lock()
+                for _, p := range running {
+                    p.Signal(sig)
+                }
+                mu.Unlock()
+            }
+        }
+    })
+
+    var wg sync.WaitGroup
+    for _, a := range accounts {
+        wg.Add(1)
+        goSafe(func() {
+            defer wg.Done()
+            delay := accountRestartBase
+            for {
+                // The supervisor keeps the PID file and log file; the accounts
+                // log through it unless they have their own.
+                cmd := exec.Command(exe, slices.Concat([]string{"run"}, args, []string{"-pid-file=", "-log-file="}, a.Args, []string{"-account", a.Name})...)
+                cmd.Stdout, cmd.Stderr = os.Stdout, logOutput
+                cmd.Env = sdChildEnv()
+                mu.Lock()
+                if stopping {
+                    mu.Unlock()
+                    return
+                }
+                err := cmd.Start()
+                if err == nil {
+                    running[a.Name] = cmd.Process
+                }
+                mu.Unlock()
+                if err != nil {
+                    slog.Error("failed to start account", "account", a.Name, "err", err)
+                    return
+                }
+                slog.Info("account started", "account", a.Name, "pid", cmd.Process.Pid)
+                started := time.Now()
+                err = cmd.Wait()
+
+                mu.Lock()
+                delete(running, a.Name)
+                stop := stopping
+                mu.Unlock()
+                if err == nil || stop {
+                    slog.Info("account stopped", "account", a.Name)
+                    return
+                }
+                if time.Since(started) > accountRestartMax {
+                    delay = accountRestartBase
+                }
+                slog.Error("account failed, restarting", "account", a.Name, "err", err, "wait", delay)
+                time.Sleep(delay)
+                delay = min(2*delay, accountRestartMax)
+            }
+        })
+    }
+    sdNotify("READY=1")
+    wg.Wait()
+    return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+    "slices"
+    "strings"
+    "testing"
+)
+
+func TestCheckDaemonToken(t *testing.T) {
+    accounts := &Config{Accounts: []AccountConfig{
+        {Name: "us", Args: []string{"-token-file", "us.token"}},
+        {Name: "eu", Args: []string{"-db", "eu.db"}},
+    }}
+    tests := []struct {
+        args []string
+        cfg  *Config
+        ok   bool
+    }{
+        {args: []string{"-t", "jwt", "-log-file", "bot.log"}},
+        {args: []string{"-token-file", "token.txt"}, ok: true},
+        {args: []string{"--token-listener=127.0.0.1:8777"}, ok: true},
+        {args: []string{"-token-socket", "/run/token.sock"}, ok: true},
+        {args: []string{"-auto-login"}, ok: true},
+        {args: []string{"-t", "jwt"}, cfg: &Config{Telegram: TelegramConfig{BotToken: "123:abc"}}, ok: true},
+        {args: []string{"-t", "jwt"}, cfg: &Config{}},
+        {args: nil, cfg: accounts},
+        {args: []string{"-auto-login"}, cfg: accounts, ok: true},
+    }
+    for _, tt := range tests {
+        if err := checkDaemonToken(tt.args, tt.cfg); (err == nil) != tt.ok {
+            t.Errorf("checkDaemonToken(%q) error = %v, want ok = %v", tt.args, err, tt.ok)
+        }
+    }
+    accounts.Accounts[1].Args = append(accounts.Accounts[1].Args, "-token-listener", "127.0.0.1:8778")
+    if err := checkDaemonToken(nil, accounts); err != nil {
+        t.Errorf("checkDaemonToken() with a source per account: %v", err)
+    }
+}
+
+func TestAccountArgs(t *testing.T) {
+    accounts := []AccountConfig{
+        {Name: "us", Args: []string{"-db", "us.db", "--control-addr=127.0.0.1:8001"}},
+        {Name: "eu", Args: []string{"-db=eu.db", "-control-addr", "127.0.0.1:8002"}},
+    }
+    args, err := accountArgs(accounts, []string{"-db", "shared.db", "-control-addr=127.0.0.1:8000", "-dbx", "-mission-dir", "m", "-debug"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if want := []string{"-dbx", "-mission-dir", "m", "-debug"}; !slices.Equal(args, want) {
+        t.Errorf("accountArgs() = %q, want %q", args, want)
+    }
+
+    for _, shared := range [][]string{{"-t", "jwt"}, {"-token-file", "t"}, {"-spare-tokens=spares"}, {"-token-socket", "s"}, {"-keychain"}, {"--auto-login"}} {
+        if _, err := accountArgs(accounts, shared); err == nil {
+            t.Errorf("accountArgs(%q) shares a token source no account sets", shared)
+        }
+    }
+    keychain := []AccountConfig{{Name: "us", Args: []string{"-keychain"}}, {Name: "eu", Args: []string{"-keychain", "-db", "eu.db"}}}
+    args, err = accountArgs(keychain, []string{"-keychain", "-debug", "-auto-login=false", "-v"})
+    if err == nil {
+        t.Errorf("accountArgs() with -auto-login no account sets: %q", args)
+    }
+    args, err = accountArgs(keychain, []string{"-keychain", "-debug", "-v"})
+    if want := []string{"-debug", "-v"}; err != nil || !slices.Equal(args, want) {
+        t.Errorf("accountArgs() = %q, %v, want %q", args, err, want)
+    }
+
+    _, err = accountArgs(accounts, []string{"-audit-log", "audit.log"})
+    if err == nil || !strings.Contains(err.Error(), "-audit-log") {
+        t.Errorf("accountArgs() with a shared -audit-log no account sets: error = %v", err)
+    }
+}
+
+func TestValidateAccounts(t *testing.T) {
+    tests := []struct {
+        name     string
+        accounts []AccountConfig
+        wantErr  string
+    }{
+        {name: "valid", accounts: []AccountConfig{{Name: "us", Args: []string{"-db", "us.db"}}, {Name: "eu", Args: []string{"-db", "eu.db"}}}},
+        {name: "no name", accounts: []AccountConfig{{}}, wantErr: "has no name"},
+        {name: "duplicate name", accounts: []AccountConfig{{Name: "us"}, {Name: "us"}}, wantErr: "duplicate name"},
+        {name: "sets -account", accounts: []AccountConfig{{Name: "us", Args: []string{"--account=eu"}}}, wantErr: "must not set -account"},
+        {
+            name:     "shared database",
+            accounts: []AccountConfig{{Name: "us", Args: []string{"-db", "x.db"}}, {Name: "eu", Args: []string{"-db=x.db"}}},
+            wantErr:  `-db x.db is also used by account "us"`,
+        },
+        {
+            name:     "shared token file",
+            accounts: []AccountConfig{{Name: "us", Args: []string{"-token-file", "t"}}, {Name: "eu", Args: []string{"--token-file=t"}}},
+            wantErr:  `-token-file t is also used by account "us"`,
+        },
+    }
+    for _, tt := range tests {
+        err := validateAccounts(tt.accounts)
+        if tt.wantErr == "" && err != nil {
+            t.Errorf("%s: validateAccounts() error = %v", tt.name, err)
+        } else if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+            t.Errorf("%s: validateAccounts() error = %v, want one containing %q", tt.name, err, tt.wantErr)
+        }
+    }
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+    "encoding/csv"
+    "io"
+    "strconv"
+    "time"
+)
+
+// claimTiming is the anonymized timing of one claim attempt. It holds no task
+// IDs or titles, only intervals, so it can be shared for analysis.
+type claimTiming struct {
+    // TaskHash is a truncated hash of the task ID, for de-duplicating rows only.
+    TaskHash    string    `json:"taskHash"`
+    Won         bool      `json:"won"`
+    PublishedAt time.Time `json:"publishedAt,omitempty"`
+    SeenAt      time.Time `json:"seenAt"`
+    AttemptedAt time.Time `json:"attemptedAt"`
+    RespondedAt time.Time `json:"respondedAt"`
+}
+
+// newClaimTiming builds a timing record for a claim attempt on task.
+func newClaimTiming(task Task, won bool, seenAt, attemptedAt, respondedAt time.Time) claimTiming {
+    return claimTiming{
+        TaskHash:    anonymizeID(task.ID),
+        Won:         won,
+        PublishedAt: task.PublishedOn.Time,
+        SeenAt:      seenAt,
+        AttemptedAt: attemptedAt,
+        RespondedAt: respondedAt,
+    }
+}
+
+// writeClaimTimingsCSV exports timings as CSV with all intervals in milliseconds.
+// published_to_response_ms of lost claims (412) approximates how quickly other
+// researchers claimed the mission; claim_rtt_ms shows how much of our own time
+// went to the network round trip.
+func writeClaimTimingsCSV(w io.Writer, timings []claimTiming) error {
+    cw := csv.NewWriter(w)
+    cw.Write([]string{
+        "task_hash", "outcome", "hour_utc",
+        "published_to_seen_ms", "published_to_response_ms", "seen_to_attempt_ms", "claim_rtt_ms",
+    })
+    for _, t := range timings {
+        outcome := "lost"
+        if t.Won {
+            outcome = "won"
+        }
+        cw.Write([]string{
+            t.TaskHash,
+            outcome,
+            strconv.Itoa(t.AttemptedAt.UTC().Hour()),
+            intervalMillis(t.PublishedAt, t.SeenAt),
+            intervalMillis(t.PublishedAt, t.RespondedAt),
+            intervalMillis(t.SeenAt, t.AttemptedAt),
+            intervalMillis(t.AttemptedAt, t.RespondedAt),
+        })
+    }
+    cw.Flush()
+    return cw.Error()
+}
+
+// intervalMillis formats to-from in milliseconds, or "" if from is unknown.
+func intervalMillis(from, to time.Time) string {
+    if from.IsZero() || to.IsZero() {
+        return ""
+    }
+    return strconv.FormatInt(to.Sub(from).Milliseconds(), 10)
+}
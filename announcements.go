@@ -0,0 +1,80 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "log/slog"
+    "net/http"
+    "time"
+)
+
+// announcementsEndpoint is the platform's announcements feed (target launches,
+// rule changes, ...).
+var announcementsEndpoint = "https://platform.synack.com/api/announcements"
+
+// announcement is one entry of the announcements feed.
+type announcement struct {
+    ID          string       `json:"id"`
+    Title       string       `json:"title"`
+    Body        string       `json:"body"`
+    Category    string       `json:"category"`
+    PublishedAt platformTime `json:"publishedAt"`
+}
+
+// getAnnouncements retrieves the current announcements. Overlapping calls
+// share one request.
+func getAnnouncements(token string) ([]announcement, error) {
+    return sharedRead("announcements", announcementsEndpoint, token, func() ([]announcement, error) { return fetchAnnouncements(token) })
+}
+
+// fetchAnnouncements requests the current announcements.
+func fetchAnnouncements(token string) ([]announcement, error) {
+    resp, err := doWithRetry("announcements", defaultRetryPolicy, func() (*http.Request, error) {
+        return newAPIRequest("GET", announcementsEndpoint, token, nil)
+    })
+    if err != nil {
+        return nil, err
+    }
+    defer closeBody(resp)
+    logResponse("announcements", resp)
+
+    switch resp.StatusCode {
+    case http.StatusOK:
+        var items []announcement
+        if err := json.NewDecoder(resp.Body).Decode(&items); err != nil {
+            return nil, err
+        }
+        return items, nil
+    case http.StatusUnauthorized:
+        return nil, fmt.Errorf("unauthorized (401)")
+    default:
+        return nil, unexpectedStatus("announcements", resp.StatusCode, fmt.Errorf("failed to retrieve announcements, status code: %d", resp.StatusCode))
+    }
+}
+
+// pollAnnouncements mirrors the announcements feed into the database every
+// interval and notifies about entries not seen before. When the database has
+// no announcements yet, the current feed is stored without notifying, so the
+// first run doesn't replay the whole history.
+func (b *bot) pollAnnouncements(interval time.Duration) {
+    for {
+        items, err := getAnnouncements(b.tokens.Get())
+        if err != nil {
+            slog.Warn("failed to retrieve announcements", "endpoint", "announcements", "err", err)
+        } else {
+            first := b.store.AnnouncementCount() == 0
+            added, err := b.store.AddAnnouncements(items)
+            if err != nil {
+                slog.Error("failed to record announcements", "err", err)
+            }
+            for _, a := range added {
+                // Scope changes are announced; don't serve stale scopes.
+                b.targetCache.InvalidateMentioned(a.Title + "\n" + a.Body)
+                if !first {
+                    notify(EventAnnouncement, "Announcement: %s\n%s", a.Title, a.Body)
+                }
+            }
+        }
+        time.Sleep(jittered(interval, b.jitter))
+    }
+}
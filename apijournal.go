@@ -0,0 +1,235 @@
+package main
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "log/slog"
+    "maps"
+    "os"
+    "slices"
+    "sync"
+    "time"
+)
+
+// Kinds of journal entries: the fields an endpoint answered with when first
+// seen, and the changes noticed since.
+const (
+    ChangeBaseline     = "baseline"
+    ChangeNewField     = "new_field"
+    ChangeRemovedField = "removed_field"
+    ChangeNewStatus    = "new_status"
+)
+
+// removedFieldResponses is how many responses in a row must lack a known
+// field for it to count as removed, as some fields are only sent for some
+// objects.
+const removedFieldResponses = 20
+
+// apiChange is one line of the API change journal. It holds no values,
+// IDs or tokens: only which endpoint changed and how, so it can be shared.
+type apiChange struct {
+    Time     time.Time `json:"time"`
+    Endpoint string    `json:"endpoint"`
+    Kind     string    `json:"kind"`
+    // Field is the JSON name of a new or removed field, and Type the JSON
+    // type of a new one (string, number, bool, object, array or null).
+    Field string `json:"field,omitempty"`
+    Type  string `json:"type,omitempty"`
+    // Status is a status code the bot doesn't handle.
+    Status int `json:"status,omitempty"`
+    // Fields are the field names of a baseline.
+    Fields []string `json:"fields,omitempty"`
+}
+
+// key identifies a change, so each one is journaled only once.
+func (c apiChange) key() string {
+    return fmt.Sprintf("%s %s %s %d", c.Endpoint, c.Kind, c.Field, c.Status)
+}
+
+// apiJournal appends every API change the bot notices to a JSON lines file
+// (-api-journal), and optionally reports it to an aggregation endpoint
+// (-api-journal-report) so changes on the platform side are noticed and
+// fixed quickly across users. Only the endpoints the bot calls are watched;
+// new endpoints can't be observed from the responses it gets.
+type apiJournal struct {
+    reportURL string
+
+    mu   sync.Mutex
+    f    *os.File
+    seen map[string]bool
+    // fields are the known fields of each endpoint's objects.
+    fields map[string]map[string]bool
+    // missing counts, per endpoint and known field, the responses in a
+    // row without it.
+    missing map[string]map[string]int
+}
+
+// journal is where API changes are recorded; nil disables the journal.
+var journal *apiJournal
+
+// openAPIJournal opens (or creates) the journal at path for appending and
+// loads the changes already in it.
+func openAPIJournal(path, reportURL string) (*apiJournal, error) {
+    j := &apiJournal{reportURL: reportURL, seen: map[string]bool{}, fields: map[string]map[string]bool{}, missing: map[string]map[string]int{}}
+    if data, err := os.ReadFile(path); err == nil {
+        sc := bufio.NewScanner(bytes.NewReader(data))
+        sc.Buffer(nil, 1<<20)
+        for sc.Scan() {
+            var c apiChange
+            if json.Unmarshal(sc.Bytes(), &c) == nil {
+                j.seen[c.key()] = true
+                j.addFields(c.Endpoint, append(c.Fields, c.Field)...)
+            }
+        }
+    }
+    f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+    if err != nil {
+        return nil, err
+    }
+    j.f = f
+    return j, nil
+}
+
+// addFields adds known fields of an endpoint. The caller holds j.mu, or
+// j isn't shared yet.
+func (j *apiJournal) addFields(endpoint string, names ...string) {
+    if j.fields[endpoint] == nil {
+        j.fields[endpoint] = map[string]bool{}
+    }
+    for _, name := range names {
+        if name != "" {
+            j.fields[endpoint][name] = true
+        }
+    }
+}
+
+// write appends an entry. The caller holds j.mu.
+func (j *apiJournal) write(c apiChange) {
+    line, _ := json.Marshal(c)
+    if _, err := j.f.Write(append(line, '\n')); err != nil {
+        slog.Warn("failed to write API journal", "endpoint", c.Endpoint, "err", err)
+    }
+}
+
+// Record journals a change unless it was journaled before. A nil journal
+// records nothing.
+func (j *apiJournal) Record(c apiChange) {
+    if j == nil {
+        return
+    }
+    c.Time = time.Now().UTC()
+    j.mu.Lock()
+    if j.seen[c.key()] {
+        j.mu.Unlock()
+        return
+    }
+    j.seen[c.key()] = true
+    j.addFields(c.Endpoint, c.Field)
+    j.write(c)
+    j.mu.Unlock()
+    slog.Warn("API change detected", "endpoint", c.Endpoint, "kind", c.Kind, "field", c.Field, "type", c.Type, "status", c.Status)
+    if j.reportURL != "" {
+        goSafe(func() { j.report(c) })
+    }
+}
+
+// report sends a change to the aggregation endpoint.
+func (j *apiJournal) report(c apiChange) {
+    c.Time = c.Time.Truncate(time.Hour) // no more precision than needed
+    if err := postNotification("api journal", j.reportURL, c); err != nil {
+        slog.Warn("failed to report API change", "err", err)
+    }
+}
+
+// RecordStatus journals a status code an endpoint answered with that the
+// bot doesn't handle.
+func (j *apiJournal) RecordStatus(endpoint string, code int) {
+    j.Record(apiChange{Endpoint: endpoint, Kind: ChangeNewStatus, Status: code})
+}
+
+// CheckFields journals the fields of the JSON objects in body (an object or
+// a list of objects) that the endpoint didn't answer with before, and the
+// known fields missing from removedFieldResponses answers in a row. The
+// first answer of an endpoint only records its fields as the baseline.
+func (j *apiJournal) CheckFields(endpoint string, body []byte) {
+    if j == nil {
+        return
+    }
+    var items []map[string]json.RawMessage
+    if err := json.Unmarshal(body, &items); err != nil {
+        var item map[string]json.RawMessage
+        if json.Unmarshal(body, &item) != nil {
+            return
+        }
+        items = append(items, item)
+    }
+    if len(items) == 0 {
+        return
+    }
+
+    j.mu.Lock()
+    known, ok := j.fields[endpoint]
+    if !ok {
+        baseline := map[string]bool{}
+        for _, item := range items {
+            for name := range item {
+                baseline[name] = true
+            }
+        }
+        j.addFields(endpoint, slices.Collect(maps.Keys(baseline))...)
+        j.write(apiChange{Time: time.Now().UTC(), Endpoint: endpoint, Kind: ChangeBaseline, Fields: slices.Sorted(maps.Keys(baseline))})
+        j.mu.Unlock()
+        return
+    }
+    var changes []apiChange
+    present := map[string]bool{}
+    for _, item := range items {
+        for name, raw := range item {
+            if !known[name] && !present[name] {
+                changes = append(changes, apiChange{Endpoint: endpoint, Kind: ChangeNewField, Field: name, Type: jsonType(raw)})
+            }
+            present[name] = true
+        }
+    }
+    if j.missing[endpoint] == nil {
+        j.missing[endpoint] = map[string]int{}
+    }
+    missing := j.missing[endpoint]
+    for name := range known {
+        if present[name] {
+            delete(missing, name)
+            continue
+        }
+        missing[name]++
+        if missing[name] == removedFieldResponses {
+            changes = append(changes, apiChange{Endpoint: endpoint, Kind: ChangeRemovedField, Field: name})
+        }
+    }
+    j.mu.Unlock()
+    for _, c := range changes {
+        j.Record(c)
+    }
+}
+
+// jsonType returns the JSON type of a raw value.
+func jsonType(raw json.RawMessage) string {
+    raw = bytes.TrimSpace(raw)
+    if len(raw) == 0 {
+        return ""
+    }
+    switch raw[0] {
+    case '"':
+        return "string"
+    case '{':
+        return "object"
+    case '[':
+        return "array"
+    case 't', 'f':
+        return "bool"
+    case 'n':
+        return "null"
+    }
+    return "number"
+}
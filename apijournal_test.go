@@ -0,0 +1,62 @@
+package main
+
+import (
+    "bufio"
+    "encoding/json"
+    "os"
+    "path/filepath"
+    "slices"
+    "testing"
+)
+
+// journalEntries reads the kinds and fields journaled at path.
+func journalEntries(t *testing.T, path string) []string {
+    t.Helper()
+    f, err := os.Open(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer f.Close()
+    var got []string
+    sc := bufio.NewScanner(f)
+    for sc.Scan() {
+        var c apiChange
+        if err := json.Unmarshal(sc.Bytes(), &c); err != nil {
+            t.Fatal(err)
+        }
+        got = append(got, c.Kind+" "+c.Field)
+    }
+    return got
+}
+
+func TestAPIJournalCheckFields(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "journal.jsonl")
+    j, err := openAPIJournal(path, "")
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    j.CheckFields("tasks", []byte(`[{"id":"1","payout":{"amount":50}}]`))
+    j.CheckFields("tasks", []byte(`[{"id":"2","payout":{"amount":50},"cwe":"79"},{"id":"3","payout":null,"cwe":"89"}]`))
+    j.CheckFields("tasks", []byte(`[]`))
+    for i := 0; i < removedFieldResponses+5; i++ {
+        j.CheckFields("tasks", []byte(`[{"id":"4","cwe":"79"}]`))
+    }
+    want := []string{"baseline ", "new_field cwe", "removed_field payout"}
+    if got := journalEntries(t, path); !slices.Equal(got, want) {
+        t.Fatalf("journal %q, want %q", got, want)
+    }
+
+    // A reopened journal knows the fields and changes journaled before.
+    j.f.Close()
+    if j, err = openAPIJournal(path, ""); err != nil {
+        t.Fatal(err)
+    }
+    defer j.f.Close()
+    for i := 0; i < removedFieldResponses; i++ {
+        j.CheckFields("tasks", []byte(`[{"id":"5","cwe":"79"}]`))
+    }
+    if got := journalEntries(t, path); !slices.Equal(got, want) {
+        t.Errorf("journal after reopening %q, want %q", got, want)
+    }
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+    "bufio"
+    "crypto/hmac"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/csv"
+    "encoding/hex"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "io"
+    "log/slog"
+    "os"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "sync"
+    "time"
+)
+
+// archiveRecord is one raw API response as stored in the archive.
+type archiveRecord struct {
+    Time     time.Time       `json:"time"`
+    Endpoint string          `json:"endpoint"`
+    Status   int             `json:"status"`
+    Body     json.RawMessage `json:"body"`
+}
+
+// responseArchive appends raw API responses to one JSON lines file per day.
+type responseArchive struct {
+    dir string
+    mu  sync.Mutex
+}
+
+// archive is where raw responses are recorded; nil disables archiving.
+var archive *responseArchive
+
+// Record appends a response body to today's archive file. A nil archive
+// records nothing.
+func (a *responseArchive) Record(endpoint string, status int, body []byte) error {
+    if a == nil {
+        return nil
+    }
+    if !json.Valid(body) {
+        b, _ := json.Marshal(string(body))
+        body = b
+    }
+    line, err := json.Marshal(archiveRecord{Time: time.Now().UTC(), Endpoint: endpoint, Status: status, Body: body})
+    if err != nil {
+        return err
+    }
+
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    if err := os.MkdirAll(a.dir, 0700); err != nil {
+        return err
+    }
+    name := filepath.Join(a.dir, "responses-"+time.Now().UTC().Format("2006-01-02")+".jsonl")
+    f, err := os.OpenFile(name, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+    if err != nil {
+        return err
+    }
+    if _, err := f.Write(append(line, '\n')); err != nil {
+        f.Close()
+        return err
+    }
+    return f.Close()
+}
+
+// runArchive implements the archive subcommand.
+func runArchive(args []string) error {
+    if len(args) == 0 || args[0] != "compact" {
+        return fmt.Errorf("usage: %s archive compact -dir <archive dir> -out <dataset.csv>", os.Args[0])
+    }
+    fs := flag.NewFlagSet("archive compact", flag.ExitOnError)
+    dirFlag := fs.String("dir", "", "Archive directory written with -archive-dir")
+    outFlag := fs.String("out", "", "Dataset CSV file to write")
+    fs.Parse(args[1:])
+    if *dirFlag == "" || *outFlag == "" {
+        fs.Usage()
+        return fmt.Errorf("-dir and -out are required")
+    }
+
+    observations, err := compactArchive(*dirFlag)
+    if err != nil {
+        return err
+    }
+    f, err := os.Create(*outFlag)
+    if err != nil {
+        return err
+    }
+    if err := writeObservationsCSV(f, observations); err != nil {
+        f.Close()
+        return err
+    }
+    return f.Close()
+}
+
+// missionObservation is the anonymized lifetime of one mission as seen in the
+// archive: no IDs, titles, codenames or brief text, only payout, category and timing.
+type missionObservation struct {
+    TaskHash    string
+    Category    string
+    Payout      TaskPayout
+    PublishedAt time.Time
+    FirstSeen   time.Time
+    LastSeen    time.Time
+}
+
+// compactArchive folds every archived task poll into one observation per mission.
+func compactArchive(dir string) ([]missionObservation, error) {
+    files, err := filepath.Glob(filepath.Join(dir, "responses-*.jsonl"))
+    if err != nil {
+        return nil, err
+    }
+    sort.Strings(files)
+
+    byHash := map[string]*missionObservation{}
+    for _, name := range files {
+        f, err := os.Open(name)
+        if err != nil {
+            return nil, err
+        }
+        sc := bufio.NewScanner(f)
+        sc.Buffer(make([]byte, 1<<20), 64<<20)
+        for sc.Scan() {
+            var rec archiveRecord
+            if err := json.Unmarshal(sc.Bytes(), &rec); err != nil || rec.Endpoint != "tasks" || rec.Status != 200 {
+                continue
+            }
+            var tasks []Task
+            if err := json.Unmarshal(rec.Body, &tasks); err != nil {
+                continue
+            }
+            for _, t := range tasks {
+                h := anonymizeID(t.ID)
+                obs, ok := byHash[h]
+                if !ok {
+                    obs = &missionObservation{
+                        TaskHash:    h,
+                        Category:    t.Category,
+                        Payout:      t.Payout,
+                        PublishedAt: t.PublishedOn.Time,
+                        FirstSeen:   rec.Time,
+                    }
+                    byHash[h] = obs
+                }
+                obs.LastSeen = rec.Time
+            }
+        }
+        f.Close()
+        if err := sc.Err(); err != nil {
+            return nil, fmt.Errorf("reading %s: %v", name, err)
+        }
+    }
+
+    observations := make([]missionObservation, 0, len(byHash))
+    for _, obs := range byHash {
+        observations = append(observations, *obs)
+    }
+    sort.Slice(observations, func(i, j int) bool {
+        return observations[i].FirstSeen.Before(observations[j].FirstSeen)
+    })
+    return observations, nil
+}
+
+// writeObservationsCSV writes the shareable dataset.
+func writeObservationsCSV(w io.Writer, observations []missionObservation) error {
+    cw := csv.NewWriter(w)
+    cw.Write([]string{
+        "task_hash", "category", "payout", "currency",
+        "published_at", "first_seen", "visible_seconds", "weekday_utc", "hour_utc",
+    })
+    for _, o := range observations {
+        published := ""
+        if !o.PublishedAt.IsZero() {
+            published = o.PublishedAt.UTC().Format(time.RFC3339)
+        }
+        cw.Write([]string{
+            o.TaskHash,
+            o.Category,
+            strconv.FormatFloat(o.Payout.Amount, 'f', -1, 64),
+            o.Payout.Currency,
+            published,
+            o.FirstSeen.UTC().Format(time.RFC3339),
+            strconv.Itoa(int(o.LastSeen.Sub(o.FirstSeen).Seconds())),
+            o.FirstSeen.UTC().Weekday().String(),
+            strconv.Itoa(o.FirstSeen.UTC().Hour()),
+        })
+    }
+    cw.Flush()
+    return cw.Error()
+}
+
+// anonymizeKeySize is the length of the per-install secret in bytes.
+const anonymizeKeySize = 32
+
+var (
+    anonymizeKey     []byte
+    anonymizeKeyOnce sync.Once
+)
+
+// anonymizeID returns a short, non-reversible identifier for de-duplication.
+// It is keyed with a per-install secret, so it can't be reversed by hashing
+// known task IDs and doesn't link shared datasets from different installs.
+func anonymizeID(id string) string {
+    anonymizeKeyOnce.Do(func() {
+        anonymizeKey = loadAnonymizeKey()
+    })
+    mac := hmac.New(sha256.New, anonymizeKey)
+    mac.Write([]byte(id))
+    return hex.EncodeToString(mac.Sum(nil)[:6])
+}
+
+// loadAnonymizeKey reads the per-install secret from the user's config
+// directory, creating it on first use. If that fails, a secret for this run
+// only is used, so hashes from this run won't match earlier ones.
+func loadAnonymizeKey() []byte {
+    key, err := readOrCreateAnonymizeKey()
+    if err != nil {
+        slog.Warn("per-install secret for anonymized IDs unavailable, using one for this run only", "err", err)
+        key = make([]byte, anonymizeKeySize)
+        rand.Read(key)
+    }
+    return key
+}
+
+// readOrCreateAnonymizeKey returns the secret in anonymize.key, writing a new
+// random one if the file doesn't exist yet.
+func readOrCreateAnonymizeKey() ([]byte, error) {
+    dir, err := os.UserConfigDir()
+    if err != nil {
+        return nil, err
+    }
+    path := filepath.Join(dir, "synack-mission-bot", "anonymize.key")
+    key, err := os.ReadFile(path)
+    if err == nil {
+        if len(key) < anonymizeKeySize {
+            return nil, fmt.Errorf("%s: secret too short", path)
+        }
+        return key, nil
+    }
+    if !os.IsNotExist(err) {
+        return nil, err
+    }
+    if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+        return nil, err
+    }
+    key = make([]byte, anonymizeKeySize)
+    if _, err := rand.Read(key); err != nil {
+        return nil, err
+    }
+    // O_EXCL: another bot process (e.g. of an accounts setup) may have
+    // created it meanwhile; then its secret is used.
+    f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+    if os.IsExist(err) {
+        return readOrCreateAnonymizeKey()
+    }
+    if err != nil {
+        return nil, err
+    }
+    if _, err := f.Write(key); err != nil {
+        f.Close()
+        return nil, err
+    }
+    return key, f.Close()
+}
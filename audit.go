@@ -0,0 +1,165 @@
+package main
+
+import (
+    "bufio"
+    "encoding/json"
+    "fmt"
+    "io"
+    "log/slog"
+    "maps"
+    "os"
+    "sync"
+    "time"
+)
+
+// Audit log actions.
+const (
+    AuditAPICall      = "api_call"
+    AuditEvent        = "event"
+    AuditTokenChanged = "token_changed"
+    AuditQuietHours   = "quiet_hours"
+)
+
+// auditRecord is one line of the audit log. Fields holds the
+// action-specific details, e.g. endpoint, status and task_id of API calls.
+type auditRecord struct {
+    Time   time.Time      `json:"time"`
+    Action string         `json:"action"`
+    Fields map[string]any `json:"fields,omitempty"`
+}
+
+// auditLog appends every action of the bot to a JSON lines file that is
+// only ever appended to, so what the bot did on the user's behalf can be
+// reconstructed later.
+type auditLog struct {
+    path string
+
+    mu sync.Mutex
+    f  *os.File
+
+    // readMu guards what BotActions has read back so far: the bot actions
+    // and start of the log, and the offset of the first line not read.
+    readMu  sync.Mutex
+    read    int64
+    actions map[string]bool
+    started time.Time
+}
+
+// audit is where actions are recorded; nil disables the audit log.
+var audit *auditLog
+
+// openAuditLog opens (or creates) the audit log at path for appending.
+func openAuditLog(path string) (*auditLog, error) {
+    f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+    if err != nil {
+        return nil, err
+    }
+    return &auditLog{path: path, f: f}, nil
+}
+
+// Record appends an action with key/value pairs, as passed to slog, as its
+// fields. A nil audit log records nothing.
+func (a *auditLog) Record(action string, args ...any) {
+    if a == nil {
+        return
+    }
+    rec := auditRecord{Time: time.Now().UTC(), Action: action}
+    if len(args) > 0 {
+        rec.Fields = map[string]any{}
+        for i := 0; i+1 < len(args); i += 2 {
+            v := args[i+1]
+            if err, ok := v.(error); ok {
+                v = err.Error()
+            }
+            rec.Fields[fmt.Sprint(args[i])] = v
+        }
+    }
+    line, err := json.Marshal(rec)
+    if err != nil {
+        slog.Warn("failed to encode audit record", "action", action, "err", err)
+        return
+    }
+
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    if _, err := a.f.Write(append(line, '\n')); err != nil {
+        slog.Warn("failed to write audit log", "action", action, "err", err)
+    }
+}
+
+// BotActions reads the audit log back and returns its successful claims,
+// releases and signups, as "claim <task id>", "release <task id>" and
+// "signup <slug>", and when the log began (now for an empty log). Only the
+// lines appended since the last call are read; a line still being written
+// is left for the next one.
+func (a *auditLog) BotActions() (map[string]bool, time.Time, error) {
+    a.readMu.Lock()
+    defer a.readMu.Unlock()
+    if a.actions == nil {
+        a.actions = map[string]bool{}
+    }
+    f, err := os.Open(a.path)
+    if err != nil {
+        return nil, time.Time{}, err
+    }
+    defer f.Close()
+    if _, err := f.Seek(a.read, io.SeekStart); err != nil {
+        return nil, time.Time{}, err
+    }
+    r := bufio.NewReader(f)
+    for {
+        line, err := r.ReadBytes('\n')
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            return nil, time.Time{}, err
+        }
+        a.read += int64(len(line))
+        var rec auditRecord
+        if json.Unmarshal(line, &rec) != nil {
+            continue
+        }
+        if a.started.IsZero() {
+            a.started = rec.Time
+        }
+        status, _ := rec.Fields["status"].(float64)
+        if rec.Action != AuditAPICall || status < 200 || status > 299 {
+            continue
+        }
+        switch endpoint, _ := rec.Fields["endpoint"].(string); endpoint {
+        case "claim", "release":
+            a.actions[fmt.Sprint(endpoint, " ", rec.Fields["task_id"])] = true
+        case "signup":
+            a.actions[fmt.Sprint(endpoint, " ", rec.Fields["slug"])] = true
+        }
+    }
+    started := a.started
+    if started.IsZero() {
+        started = time.Now()
+    }
+    return maps.Clone(a.actions), started, nil
+}
+
+// auditNotifier records every event (claims, signups, pauses, ...) in the
+// audit log.
+type auditNotifier struct{}
+
+func (auditNotifier) Notify(ev Event) error {
+    audit.Record(AuditEvent, "event", ev.Type, "message", ev.Message)
+    return nil
+}
+
+// auditTokens records every change of the session token, with its expiry
+// but never the token itself.
+func auditTokens(tokens TokenProvider) {
+    token := tokens.Get()
+    for {
+        token = tokens.WaitChange(token)
+        if exp, ok := tokenExpiry(token); ok {
+            audit.Record(AuditTokenChanged, "expires", exp.UTC())
+        } else {
+            audit.Record(AuditTokenChanged)
+        }
+    }
+}
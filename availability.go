@@ -0,0 +1,163 @@
+package main
+
+import (
+    "fmt"
+    "log/slog"
+    "slices"
+    "time"
+)
+
+// AvailabilityConfig describes when the researcher can't work on missions,
+// so missions whose completion window falls into those times are not
+// claimed only to be forfeited.
+type AvailabilityConfig struct {
+    // Blackouts are the times you can't work, written like quiet_hours
+    // windows: "23:00-07:00", "mon-fri 09:00-17:00", "sun".
+    Blackouts []string `json:"blackouts"`
+    // Timezone is an IANA name like "Europe/Berlin" (default local time).
+    Timezone string `json:"timezone"`
+    // MinAvailable is the least time outside the blackouts a mission's
+    // completion window must leave (default: any at all).
+    MinAvailable Duration `json:"min_available"`
+    // Action is "skip" (the default) to only log and count the mission,
+    // or "notify" to also send an outside_availability notification, so
+    // it can still be claimed by hand.
+    Action string `json:"action"`
+}
+
+// blackouts parses the blackout windows; they work like quiet hours. It
+// returns nil when none are configured.
+func (c AvailabilityConfig) blackouts() (*quietHours, error) {
+    if len(c.Blackouts) == 0 {
+        return nil, nil
+    }
+    q := &quietHours{loc: time.Local}
+    if c.Timezone != "" {
+        loc, err := time.LoadLocation(c.Timezone)
+        if err != nil {
+            return nil, fmt.Errorf("availability.timezone: %v", err)
+        }
+        q.loc = loc
+    }
+    for _, s := range c.Blackouts {
+        w, err := parseQuietWindow(s)
+        if err != nil {
+            return nil, fmt.Errorf("availability.blackouts: %q: %v", s, err)
+        }
+        q.windows = append(q.windows, w)
+    }
+    return q, nil
+}
+
+// validate checks the availability settings.
+func (c AvailabilityConfig) validate() error {
+    if _, err := c.blackouts(); err != nil {
+        return err
+    }
+    switch c.Action {
+    case "", "skip", "notify":
+    default:
+        return fmt.Errorf("availability.action: invalid action %q (want skip or notify)", c.Action)
+    }
+    if c.MinAvailable.Duration < 0 {
+        return fmt.Errorf("availability.min_available must not be negative")
+    }
+    return nil
+}
+
+// freeTime returns how much of [from, to) falls outside the windows. It
+// clips each window's occurrence on every day the range touches, starting
+// the day before for windows past midnight, and subtracts their union.
+func (q *quietHours) freeTime(from, to time.Time) time.Duration {
+    if !from.Before(to) {
+        return 0
+    }
+    type span struct{ start, end time.Time }
+    var busy []span
+    first := from.In(q.loc)
+    for day := time.Date(first.Year(), first.Month(), first.Day()-1, 0, 0, 0, 0, q.loc); day.Before(to); day = day.AddDate(0, 0, 1) {
+        clock := func(days, minute int) time.Time {
+            return time.Date(day.Year(), day.Month(), day.Day()+days, minute/60, minute%60, 0, 0, q.loc)
+        }
+        for _, w := range q.windows {
+            if !w.days[day.Weekday()] {
+                continue
+            }
+            s := span{clock(0, w.start), clock(0, w.end)}
+            if w.end <= w.start {
+                s.end = clock(1, w.end)
+            }
+            if s.start.Before(from) {
+                s.start = from
+            }
+            if s.end.After(to) {
+                s.end = to
+            }
+            if s.start.Before(s.end) {
+                busy = append(busy, s)
+            }
+        }
+    }
+    slices.SortFunc(busy, func(a, b span) int { return a.start.Compare(b.start) })
+    free := to.Sub(from)
+    var end time.Time
+    for _, s := range busy {
+        if s.start.Before(end) {
+            s.start = end
+        }
+        if s.start.Before(s.end) {
+            free -= s.end.Sub(s.start)
+            end = s.end
+        }
+    }
+    return free
+}
+
+// available returns how much of the task's completion window, if claimed
+// at now, falls outside the blackouts, and whether that is enough. Tasks
+// without a known completion time and all tasks without blackouts are.
+func (c AvailabilityConfig) available(blackouts *quietHours, task Task, now time.Time) (time.Duration, bool) {
+    window := time.Duration(task.MaxCompletionTimeInSecs) * time.Second
+    if blackouts == nil || window <= 0 {
+        return window, true
+    }
+    free := blackouts.freeTime(now, now.Add(window))
+    return free, free > 0 && free >= c.MinAvailable.Duration
+}
+
+// dropUnavailable removes the missions whose completion window, if claimed
+// now, leaves less than the configured time outside the blackouts.
+// Missions without a known completion time are kept.
+func (b *bot) dropUnavailable(tasks []Task) []Task {
+    cfg := b.config().Availability
+    // loadConfig has validated the blackouts.
+    blackouts, _ := cfg.blackouts()
+    if blackouts == nil {
+        return tasks
+    }
+    now := time.Now()
+    kept := tasks[:0:0]
+    for _, task := range tasks {
+        free, ok := cfg.available(blackouts, task, now)
+        if ok {
+            kept = append(kept, task)
+            continue
+        }
+        window := time.Duration(task.MaxCompletionTimeInSecs) * time.Second
+        slog.Info("skipping task, completion window outside availability", "task_id", task.ID, "title", task.Title, "window", window, "available", free)
+        b.state.RecordSkips(SkipAvailability, []Task{task})
+        if cfg.Action == "notify" && !b.availabilityNotified[task.ID] {
+            if b.availabilityNotified == nil {
+                b.availabilityNotified = map[string]bool{}
+            }
+            b.availabilityNotified[task.ID] = true
+            notifyEvent(Event{
+                Type:    EventOutsideAvailability,
+                Message: fmt.Sprintf("Not claiming task %s (%s, %s): only %s of its %s completion window is outside your blackouts.", task.ID, task.Title, task.Payout, free, window),
+                Task:    &task,
+                Target:  task.ListingCodename,
+            })
+        }
+    }
+    return kept
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+    "slices"
+    "testing"
+    "time"
+)
+
+func TestFreeTime(t *testing.T) {
+    blackouts := func(windows ...string) *quietHours {
+        t.Helper()
+        q, err := AvailabilityConfig{Blackouts: windows, Timezone: "UTC"}.blackouts()
+        if err != nil {
+            t.Fatal(err)
+        }
+        return q
+    }
+    // 2026-10-16 is a Friday.
+    at := func(day, hour, min, sec int) time.Time {
+        return time.Date(2026, time.October, day, hour, min, sec, 0, time.UTC)
+    }
+    tests := []struct {
+        name     string
+        windows  []string
+        from, to time.Time
+        want     time.Duration
+    }{
+        {"overnight window", []string{"23:00-07:00"}, at(16, 20, 0, 0), at(17, 10, 0, 0), 6 * time.Hour},
+        {"starting in yesterday's window", []string{"23:00-07:00"}, at(17, 1, 0, 0), at(17, 8, 0, 0), time.Hour},
+        {"all blacked out", []string{"23:00-07:00"}, at(17, 1, 0, 0), at(17, 6, 0, 0), 0},
+        {"to the second", []string{"23:00-07:00"}, at(16, 22, 59, 30), at(16, 23, 0, 30), 30 * time.Second},
+        {"overlapping windows", []string{"12:00-14:00", "13:00-15:00"}, at(16, 11, 0, 0), at(16, 16, 0, 0), 2 * time.Hour},
+        {"nested windows", []string{"12:00-18:00", "13:00-14:00"}, at(16, 11, 0, 0), at(16, 19, 0, 0), 2 * time.Hour},
+        {"whole day", []string{"sun"}, at(17, 12, 0, 0), at(19, 12, 0, 0), 24 * time.Hour},
+        {"other days only", []string{"mon-tue 09:00-17:00"}, at(16, 0, 0, 0), at(18, 0, 0, 0), 48 * time.Hour},
+        {"over a week", []string{"sat-sun"}, at(16, 0, 0, 0), at(30, 0, 0, 0), 10 * 24 * time.Hour},
+        {"empty range", []string{"sun"}, at(16, 0, 0, 0), at(16, 0, 0, 0), 0},
+    }
+    for _, tt := range tests {
+        if got := blackouts(tt.windows...).freeTime(tt.from, tt.to); got != tt.want {
+            t.Errorf("%s: freeTime() = %s, want %s", tt.name, got, tt.want)
+        }
+    }
+}
+
+func TestFreeTimeMatchesActiveAcrossDST(t *testing.T) {
+    q, err := AvailabilityConfig{Blackouts: []string{"22:00-07:00", "sat-sun 01:00-03:00", "wed"}, Timezone: "Europe/Berlin"}.blackouts()
+    if err != nil {
+        t.Skip(err)
+    }
+    // Summer time ends in Berlin on 2026-10-25.
+    from := time.Date(2026, time.October, 21, 12, 0, 0, 0, q.loc)
+    to := from.Add(7 * 24 * time.Hour)
+    var want time.Duration
+    for t := from; t.Before(to); t = t.Add(time.Minute) {
+        if !q.Active(t) {
+            want += time.Minute
+        }
+    }
+    if got := q.freeTime(from, to); got != want {
+        t.Errorf("freeTime() = %s, want %s as counted by Active", got, want)
+    }
+}
+
+func TestDropUnavailable(t *testing.T) {
+    rec := recordNotifications(t)
+    b := newTestBot(t, "token")
+    short, long, unknown := testTask("short"), testTask("long"), testTask("unknown")
+    short.MaxCompletionTimeInSecs = 3600
+    long.MaxCompletionTimeInSecs = 4 * 24 * 3600
+    ids := func(tasks []Task) (ids []string) {
+        for _, task := range tasks {
+            ids = append(ids, task.ID)
+        }
+        return ids
+    }
+
+    // Missions without a completion time are kept even when always
+    // blacked out, and the others notified about once.
+    b.cfg.Availability = AvailabilityConfig{Blackouts: []string{"00:00-24:00"}, Action: "notify"}
+    for range 2 {
+        if kept := ids(b.dropUnavailable([]Task{short, long, unknown})); !slices.Equal(kept, []string{"unknown"}) {
+            t.Fatalf("kept %v, want [unknown]", kept)
+        }
+    }
+    if got := b.state.TakeSkips()[SkipAvailability]; got != 2 {
+        t.Errorf("%d availability skips, want 2", got)
+    }
+    if got := rec.Types(); !slices.Equal(got, []string{EventOutsideAvailability, EventOutsideAvailability}) {
+        t.Errorf("events %v, want one %s per mission", got, EventOutsideAvailability)
+    }
+
+    // Only the day after tomorrow is blacked out: the short mission's
+    // hour is free but less than asked, the long one leaves three days.
+    blackedOut := time.Now().AddDate(0, 0, 2).Weekday().String()[:3]
+    b.cfg.Availability = AvailabilityConfig{Blackouts: []string{blackedOut}, MinAvailable: Duration{2 * time.Hour}}
+    if kept := ids(b.dropUnavailable([]Task{short, long})); !slices.Equal(kept, []string{"long"}) {
+        t.Errorf("kept %v, want [long]", kept)
+    }
+
+    b.cfg.Availability = AvailabilityConfig{}
+    if kept := ids(b.dropUnavailable([]Task{short, long})); !slices.Equal(kept, []string{"short", "long"}) {
+        t.Errorf("kept %v without blackouts, want both", kept)
+    }
+}
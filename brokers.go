@@ -0,0 +1,228 @@
+package main
+
+import (
+    "bufio"
+    "crypto/rand"
+    "crypto/tls"
+    "encoding/binary"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "maps"
+    "net"
+    "net/url"
+    "slices"
+    "strings"
+    "time"
+)
+
+// defaultBrokerTopic prefixes the topic (MQTT) or subject (NATS) events are
+// published to when a broker channel sets none.
+const defaultBrokerTopic = "synack-mission-bot"
+
+// brokerSchemes are the URL schemes of each broker channel type, with the
+// default port of each scheme.
+var brokerSchemes = map[string]map[string]string{
+    "mqtt": {"mqtt": "1883", "tcp": "1883", "mqtts": "8883", "ssl": "8883"},
+    "nats": {"nats": "4222", "tls": "4222"},
+}
+
+// validateBroker checks the URL and topic of an mqtt or nats channel.
+func validateBroker(i int, n NotificationConfig) error {
+    u, err := url.Parse(n.URL)
+    if err != nil || u.Host == "" {
+        return fmt.Errorf("notifications[%d]: url must be a broker URL such as %s://host", i, n.Type)
+    }
+    if _, ok := brokerSchemes[n.Type][u.Scheme]; !ok {
+        schemes := slices.Sorted(maps.Keys(brokerSchemes[n.Type]))
+        return fmt.Errorf("notifications[%d]: unknown %s url scheme %q (want one of %s)", i, n.Type, u.Scheme, strings.Join(schemes, ", "))
+    }
+    if strings.ContainsAny(n.Topic, "+#* \t\r\n>") {
+        return fmt.Errorf("notifications[%d]: topic must not contain wildcards or spaces", i)
+    }
+    return nil
+}
+
+// brokerTopic returns the topic of an event: the channel's topic followed by
+// the event type, joined with sep.
+func brokerTopic(n NotificationConfig, sep string, ev Event) string {
+    topic := n.Topic
+    if topic == "" {
+        topic = defaultBrokerTopic
+    }
+    return strings.TrimSuffix(topic, sep) + sep + ev.Type
+}
+
+// dialBroker connects to the broker of a channel, with TLS for the secure
+// schemes. The whole exchange must finish within notifyTimeout.
+func dialBroker(n NotificationConfig) (net.Conn, *url.URL, error) {
+    u, err := url.Parse(n.URL)
+    if err != nil {
+        return nil, nil, err
+    }
+    addr := u.Host
+    if u.Port() == "" {
+        addr = net.JoinHostPort(u.Hostname(), brokerSchemes[n.Type][u.Scheme])
+    }
+    conn, err := net.DialTimeout("tcp", addr, notifyTimeout)
+    if err != nil {
+        return nil, nil, err
+    }
+    conn.SetDeadline(time.Now().Add(notifyTimeout))
+    if u.Scheme == "mqtts" || u.Scheme == "ssl" {
+        conn = tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+    }
+    return conn, u, nil
+}
+
+// mqttNotifier publishes every event as JSON to <topic>/<event type> on an
+// MQTT broker, connecting for each event (MQTT 3.1.1, QoS 0).
+type mqttNotifier struct {
+    cfg NotificationConfig
+}
+
+func (n mqttNotifier) Notify(ev Event) error {
+    payload, err := json.Marshal(eventPayload(ev))
+    if err != nil {
+        return err
+    }
+    conn, u, err := dialBroker(n.cfg)
+    if err != nil {
+        return fmt.Errorf("mqtt: %v", err)
+    }
+    defer conn.Close()
+
+    if _, err := conn.Write(mqttConnect(u.User)); err != nil {
+        return fmt.Errorf("mqtt: %v", err)
+    }
+    var ack [4]byte
+    if _, err := io.ReadFull(conn, ack[:]); err != nil {
+        return fmt.Errorf("mqtt: reading CONNACK: %v", err)
+    }
+    if ack[0] != 0x20 || ack[1] != 2 {
+        return errors.New("mqtt: unexpected reply to CONNECT")
+    }
+    if ack[3] != 0 {
+        return fmt.Errorf("mqtt: connection refused (return code %d)", ack[3])
+    }
+    publish := mqttPacket(0x30, mqttString(brokerTopic(n.cfg, "/", ev)), payload)
+    if _, err := conn.Write(append(publish, 0xe0, 0)); err != nil { // PUBLISH, DISCONNECT
+        return fmt.Errorf("mqtt: %v", err)
+    }
+    return nil
+}
+
+// mqttConnect builds a clean session CONNECT packet with a random client ID
+// and the URL's credentials, if any.
+func mqttConnect(user *url.Userinfo) []byte {
+    id := make([]byte, 6)
+    rand.Read(id)
+    flags := byte(0x02) // clean session
+    payload := mqttString(defaultBrokerTopic + "-" + hex.EncodeToString(id))
+    if user != nil {
+        flags |= 0x80
+        payload = append(payload, mqttString(user.Username())...)
+        if pass, ok := user.Password(); ok {
+            flags |= 0x40
+            payload = append(payload, mqttString(pass)...)
+        }
+    }
+    header := append(mqttString("MQTT"), 4, flags, 0, 60) // protocol level 4, keep alive 60s
+    return mqttPacket(0x10, header, payload)
+}
+
+// mqttPacket builds a packet of the given type and flags from its parts.
+func mqttPacket(kind byte, parts ...[]byte) []byte {
+    n := 0
+    for _, p := range parts {
+        n += len(p)
+    }
+    packet := []byte{kind}
+    for { // remaining length, 7 bits per byte
+        b := byte(n % 128)
+        n /= 128
+        if n > 0 {
+            b |= 0x80
+        }
+        packet = append(packet, b)
+        if n == 0 {
+            break
+        }
+    }
+    for _, p := range parts {
+        packet = append(packet, p...)
+    }
+    return packet
+}
+
+// mqttString encodes s with its 2-byte length prefix.
+func mqttString(s string) []byte {
+    return append(binary.BigEndian.AppendUint16(nil, uint16(len(s))), s...)
+}
+
+// natsNotifier publishes every event as JSON to <topic>.<event type> on a
+// NATS server, connecting for each event. The URL's user and password, or
+// the channel's token, authenticate the connection.
+type natsNotifier struct {
+    cfg NotificationConfig
+}
+
+func (n natsNotifier) Notify(ev Event) error {
+    payload, err := json.Marshal(eventPayload(ev))
+    if err != nil {
+        return err
+    }
+    conn, u, err := dialBroker(n.cfg)
+    if err != nil {
+        return fmt.Errorf("nats: %v", err)
+    }
+    defer conn.Close()
+
+    r := bufio.NewReader(conn)
+    if line, err := r.ReadString('\n'); err != nil {
+        return fmt.Errorf("nats: reading INFO: %v", err)
+    } else if !strings.HasPrefix(line, "INFO ") {
+        return errors.New("nats: server did not send INFO")
+    }
+    if u.Scheme == "tls" {
+        tc := tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+        conn, r = tc, bufio.NewReader(tc)
+        defer tc.Close()
+    }
+
+    opts := map[string]any{"verbose": false, "pedantic": false, "name": defaultBrokerTopic, "lang": "go"}
+    if u.User != nil {
+        opts["user"] = u.User.Username()
+        opts["pass"], _ = u.User.Password()
+    }
+    if n.cfg.Token != "" {
+        opts["auth_token"] = n.cfg.Token
+    }
+    connect, err := json.Marshal(opts)
+    if err != nil {
+        return err
+    }
+    subject := brokerTopic(n.cfg, ".", ev)
+    msg := fmt.Sprintf("CONNECT %s\r\nPUB %s %d\r\n%s\r\nPING\r\n", connect, subject, len(payload), payload)
+    if _, err := conn.Write([]byte(msg)); err != nil {
+        return fmt.Errorf("nats: %v", err)
+    }
+    // The PONG confirms the server has processed the CONNECT and PUB.
+    for {
+        line, err := r.ReadString('\n')
+        if err != nil {
+            return fmt.Errorf("nats: %v", err)
+        }
+        line = strings.TrimSpace(line)
+        switch {
+        case line == "PONG":
+            return nil
+        case line == "PING":
+            conn.Write([]byte("PONG\r\n"))
+        case strings.HasPrefix(line, "-ERR"):
+            return fmt.Errorf("nats: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+        }
+    }
+}
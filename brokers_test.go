@@ -0,0 +1,236 @@
+package main
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/json"
+    "io"
+    "net"
+    "net/url"
+    "strconv"
+    "strings"
+    "testing"
+)
+
+func TestMQTTPacketRemainingLength(t *testing.T) {
+    tests := []struct {
+        n    int
+        want []byte
+    }{
+        {0, []byte{0x00}},
+        {1, []byte{0x01}},
+        {127, []byte{0x7f}},
+        {128, []byte{0x80, 0x01}},
+        {16383, []byte{0xff, 0x7f}},
+        {16384, []byte{0x80, 0x80, 0x01}},
+        {2097151, []byte{0xff, 0xff, 0x7f}},
+        {2097152, []byte{0x80, 0x80, 0x80, 0x01}},
+    }
+    for _, tt := range tests {
+        // Split the body in two to check the parts are concatenated.
+        body := bytes.Repeat([]byte{'x'}, tt.n)
+        packet := mqttPacket(0x30, body[:tt.n/2], body[tt.n/2:])
+        want := append([]byte{0x30}, tt.want...)
+        if !bytes.Equal(packet[:len(want)], want) {
+            t.Errorf("mqttPacket(%d bytes) header = % x, want % x", tt.n, packet[:len(want)], want)
+        }
+        if len(packet) != len(want)+tt.n {
+            t.Errorf("mqttPacket(%d bytes) is %d bytes long, want %d", tt.n, len(packet), len(want)+tt.n)
+        }
+    }
+}
+
+func TestMQTTConnect(t *testing.T) {
+    clientID := len(defaultBrokerTopic) + 1 + 12 // "<prefix>-" and 6 random bytes in hex
+    header := []byte{0x00, 0x04, 'M', 'Q', 'T', 'T', 0x04}
+
+    packet := mqttConnect(nil)
+    remaining := len(header) + 3 + 2 + clientID
+    want := append([]byte{0x10, byte(remaining)}, header...)
+    want = append(want, 0x02, 0x00, 0x3c, 0x00, byte(clientID)) // clean session, keep alive 60s
+    if !bytes.Equal(packet[:len(want)], want) {
+        t.Errorf("CONNECT = % x, want it to start with % x", packet, want)
+    }
+    if len(packet) != 2+remaining {
+        t.Errorf("CONNECT is %d bytes long, want %d", len(packet), 2+remaining)
+    }
+
+    packet = mqttConnect(url.UserPassword("bob", "secret"))
+    if flags := packet[2+len(header)]; flags != 0xc2 {
+        t.Errorf("CONNECT flags with credentials = %#x, want 0xc2", flags)
+    }
+    credentials := []byte{0x00, 0x03, 'b', 'o', 'b', 0x00, 0x06, 's', 'e', 'c', 'r', 'e', 't'}
+    if !bytes.HasSuffix(packet, credentials) {
+        t.Errorf("CONNECT = % x, want it to end with % x", packet, credentials)
+    }
+    if packet[1] != byte(remaining+len(credentials)) {
+        t.Errorf("CONNECT remaining length = %d, want %d", packet[1], remaining+len(credentials))
+    }
+
+    packet = mqttConnect(url.User("bob"))
+    if flags := packet[2+len(header)]; flags != 0x82 {
+        t.Errorf("CONNECT flags with a user name only = %#x, want 0x82", flags)
+    }
+}
+
+// listenBroker accepts one connection on a local port and runs serve on it.
+func listenBroker(t *testing.T, serve func(net.Conn)) string {
+    t.Helper()
+    ln, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { ln.Close() })
+    go func() {
+        conn, err := ln.Accept()
+        if err != nil {
+            return
+        }
+        defer conn.Close()
+        serve(conn)
+    }()
+    return ln.Addr().String()
+}
+
+func TestMQTTNotifierPublishes(t *testing.T) {
+    got := make(chan []byte, 1)
+    addr := listenBroker(t, func(conn net.Conn) {
+        defer close(got)
+        r := bufio.NewReader(conn)
+        if _, err := readMQTTPacket(r); err != nil {
+            t.Error(err)
+            return
+        }
+        conn.Write([]byte{0x20, 0x02, 0x00, 0x00}) // CONNACK, accepted
+        publish, err := readMQTTPacket(r)
+        if err != nil {
+            t.Error(err)
+            return
+        }
+        disconnect, _ := readMQTTPacket(r)
+        if !bytes.Equal(disconnect, []byte{0xe0, 0x00}) {
+            t.Errorf("after PUBLISH got % x, want DISCONNECT", disconnect)
+        }
+        got <- publish
+    })
+
+    n := mqttNotifier{cfg: NotificationConfig{Type: "mqtt", URL: "mqtt://" + addr, Topic: "bots/"}}
+    if err := n.Notify(Event{Type: EventMissionClaimed, Message: "Claimed task 1."}); err != nil {
+        t.Fatal(err)
+    }
+    publish, ok := <-got
+    if !ok {
+        t.FailNow()
+    }
+    if publish[0] != 0x30 {
+        t.Fatalf("packet type %#x, want PUBLISH (0x30)", publish[0])
+    }
+    body := publish[2:]
+    topic := "bots/" + EventMissionClaimed
+    if string(body[2:2+len(topic)]) != topic || int(body[1]) != len(topic) {
+        t.Errorf("PUBLISH = % x, want topic %q", publish, topic)
+    }
+    var payload map[string]any
+    if err := json.Unmarshal(body[2+len(topic):], &payload); err != nil || payload["message"] != "Claimed task 1." {
+        t.Errorf("payload %v (%v), want the event", payload, err)
+    }
+}
+
+func TestMQTTNotifierReportsRefusedConnection(t *testing.T) {
+    addr := listenBroker(t, func(conn net.Conn) {
+        readMQTTPacket(bufio.NewReader(conn))
+        conn.Write([]byte{0x20, 0x02, 0x00, 0x05}) // not authorized
+    })
+    n := mqttNotifier{cfg: NotificationConfig{Type: "mqtt", URL: "mqtt://" + addr}}
+    if err := n.Notify(Event{Type: EventMissionClaimed}); err == nil || !strings.Contains(err.Error(), "return code 5") {
+        t.Errorf("Notify() error = %v, want the refusal", err)
+    }
+}
+
+// readMQTTPacket reads one packet, decoding its remaining length.
+func readMQTTPacket(r *bufio.Reader) ([]byte, error) {
+    packet := make([]byte, 1, 2)
+    if _, err := io.ReadFull(r, packet); err != nil {
+        return nil, err
+    }
+    n, mult := 0, 1
+    for {
+        b, err := r.ReadByte()
+        if err != nil {
+            return nil, err
+        }
+        packet = append(packet, b)
+        n += int(b&0x7f) * mult
+        mult *= 128
+        if b&0x80 == 0 {
+            break
+        }
+    }
+    body := make([]byte, n)
+    if _, err := io.ReadFull(r, body); err != nil {
+        return nil, err
+    }
+    return append(packet, body...), nil
+}
+
+func TestNATSNotifierPublishes(t *testing.T) {
+    got := make(chan string, 1)
+    addr := listenBroker(t, func(conn net.Conn) {
+        defer close(got)
+        conn.Write([]byte("INFO {\"server_id\":\"test\"}\r\n"))
+        r := bufio.NewReader(conn)
+        var lines []string
+        for {
+            line, err := r.ReadString('\n')
+            if err != nil {
+                t.Error(err)
+                return
+            }
+            lines = append(lines, line)
+            if line == "PING\r\n" {
+                break
+            }
+        }
+        conn.Write([]byte("PONG\r\n"))
+        got <- strings.Join(lines, "")
+    })
+
+    n := natsNotifier{cfg: NotificationConfig{Type: "nats", URL: "nats://alice:pw@" + addr, Topic: "bots"}}
+    if err := n.Notify(Event{Type: EventMissionClaimed, Message: "Claimed task 1."}); err != nil {
+        t.Fatal(err)
+    }
+    sent, ok := <-got
+    if !ok {
+        t.FailNow()
+    }
+    lines := strings.Split(sent, "\r\n")
+    if len(lines) != 5 || !strings.HasPrefix(lines[0], "CONNECT ") || lines[3] != "PING" {
+        t.Fatalf("client sent %q, want CONNECT, PUB, the payload and PING", lines)
+    }
+    var opts map[string]any
+    if err := json.Unmarshal([]byte(strings.TrimPrefix(lines[0], "CONNECT ")), &opts); err != nil {
+        t.Fatal(err)
+    }
+    if opts["user"] != "alice" || opts["pass"] != "pw" || opts["verbose"] != false {
+        t.Errorf("CONNECT options %v, want the URL's credentials and verbose off", opts)
+    }
+    payload := lines[2]
+    if want := "PUB bots." + EventMissionClaimed + " " + strconv.Itoa(len(payload)); lines[1] != want {
+        t.Errorf("PUB line %q, want %q", lines[1], want)
+    }
+    if !strings.Contains(payload, `"message":"Claimed task 1."`) {
+        t.Errorf("payload %s, want the event", payload)
+    }
+}
+
+func TestNATSNotifierReportsErrors(t *testing.T) {
+    addr := listenBroker(t, func(conn net.Conn) {
+        conn.Write([]byte("INFO {}\r\n"))
+        bufio.NewReader(conn).ReadString('\n')
+        conn.Write([]byte("-ERR 'Authorization Violation'\r\n"))
+    })
+    n := natsNotifier{cfg: NotificationConfig{Type: "nats", URL: "nats://" + addr, Token: "wrong"}}
+    if err := n.Notify(Event{Type: EventMissionClaimed}); err == nil || !strings.Contains(err.Error(), "Authorization Violation") {
+        t.Errorf("Notify() error = %v, want the server's error", err)
+    }
+}
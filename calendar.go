@@ -0,0 +1,67 @@
+package main
+
+import (
+    "net/http"
+    "strconv"
+    "time"
+)
+
+// Calendar range defaults: weeks shown before and after the current one.
+const (
+    defaultCalendarWeeks = 8
+    maxCalendarWeeks     = 52
+    calendarWeeksAhead   = 4
+)
+
+// calendarDay is one day of the claims calendar.
+type calendarDay struct {
+    Date string `json:"date"`
+    // Deadlines counts the claimed missions due that day and not completed.
+    Deadlines int `json:"deadlines"`
+    // Completed counts the missions submitted that day.
+    Completed int `json:"completed"`
+}
+
+// claimsCalendar returns one calendarDay per day from the Monday weeks
+// weeks before now to the Sunday calendarWeeksAhead weeks after it, in loc.
+func claimsCalendar(claims []claimRecord, now time.Time, weeks int, loc *time.Location) []calendarDay {
+    today := startOfDay(now, loc)
+    monday := today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+    from := monday.AddDate(0, 0, -7*weeks)
+    n := 7 * (weeks + 1 + calendarWeeksAhead)
+
+    days := make([]calendarDay, n)
+    index := map[string]int{}
+    for i := range days {
+        date := from.AddDate(0, 0, i).Format(time.DateOnly)
+        days[i].Date = date
+        index[date] = i
+    }
+    for _, c := range claims {
+        if !c.CompletedAt.IsZero() {
+            if i, ok := index[c.CompletedAt.In(loc).Format(time.DateOnly)]; ok {
+                days[i].Completed++
+            }
+        } else if deadline, ok := c.Deadline(); ok {
+            if i, ok := index[deadline.In(loc).Format(time.DateOnly)]; ok {
+                days[i].Deadlines++
+            }
+        }
+    }
+    return days
+}
+
+// serveCalendar returns the claims calendar as JSON. ?weeks= sets how many
+// past weeks are included (default 8).
+func (b *bot) serveCalendar(w http.ResponseWriter, r *http.Request) {
+    weeks := defaultCalendarWeeks
+    if v := r.URL.Query().Get("weeks"); v != "" {
+        n, err := strconv.Atoi(v)
+        if err != nil || n < 0 || n > maxCalendarWeeks {
+            http.Error(w, "invalid weeks", http.StatusBadRequest)
+            return
+        }
+        weeks = n
+    }
+    writeJSON(w, claimsCalendar(b.store.Data().Claims, time.Now(), weeks, time.Local))
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "log/slog"
+    "net/http"
+    "net/url"
+    "slices"
+    "strconv"
+    "time"
+)
+
+// claimedTasksPerPage is the page size used to list the claimed missions.
+const claimedTasksPerPage = 50
+
+// getClaimedTasks retrieves the missions currently claimed by the researcher,
+// reading pages until a short one. Overlapping calls share the requests.
+func getClaimedTasks(token string) ([]Task, error) {
+    return sharedRead("claimed_tasks", claimedTasksURL(1), token, func() ([]Task, error) {
+        all := []Task{}
+        for page := 1; ; page++ {
+            tasks, err := fetchClaimedTasks(claimedTasksURL(page), token)
+            if err != nil {
+                return nil, err
+            }
+            all = append(all, tasks...)
+            if len(tasks) < claimedTasksPerPage {
+                return all, nil
+            }
+        }
+    })
+}
+
+// claimedTasksURL returns the URL of a page of the claimed missions.
+func claimedTasksURL(page int) string {
+    q := url.Values{}
+    q.Add("perPage", strconv.Itoa(claimedTasksPerPage))
+    q.Add("page", strconv.Itoa(page))
+    q.Add("status", "CLAIMED")
+    q.Add("includeAssignedBySynackUser", "false")
+    return tasksEndpoint + "?" + q.Encode()
+}
+
+// fetchClaimedTasks requests the claimed missions from u.
+func fetchClaimedTasks(u, token string) ([]Task, error) {
+    resp, err := doWithRetry("claimed_tasks", defaultRetryPolicy, func() (*http.Request, error) {
+        return newAPIRequest("GET", u, token, nil)
+    })
+    if err != nil {
+        return nil, err
+    }
+    defer closeBody(resp)
+    logResponse("claimed_tasks", resp)
+
+    switch resp.StatusCode {
+    case http.StatusOK:
+        var tasks []Task
+        if err := json.NewDecoder(resp.Body).Decode(&tasks); err != nil {
+            return nil, err
+        }
+        if tasks == nil {
+            // Not nil for a null body either, so callers can tell a fetched
+            // empty list from one that wasn't fetched.
+            tasks = []Task{}
+        }
+        return tasks, nil
+    case http.StatusUnauthorized:
+        return nil, fmt.Errorf("unauthorized (401)")
+    default:
+        return nil, unexpectedStatus("claimed_tasks", resp.StatusCode, fmt.Errorf("failed to retrieve claimed tasks, status code: %d", resp.StatusCode))
+    }
+}
+
+// updateClaimCapacity compares the number of currently claimed missions with
+// the claim limit and returns how many more may be claimed, along with the
+// claimed missions it fetched (nil if it didn't), for the rest of the cycle.
+// Claiming is paused while the limit is reached and resumed once a slot frees
+// up. Without a limit, or if the count cannot be fetched, it returns -1 (no
+// restriction).
+func (b *bot) updateClaimCapacity(token string) (int, []Task) {
+    if b.claimLimit <= 0 {
+        return -1, nil
+    }
+    claimed, err := getClaimedTasks(token)
+    if err != nil {
+        slog.Warn("failed to check claimed mission count", "endpoint", "claimed_tasks", "err", err)
+        return -1, nil
+    }
+
+    free := b.claimLimit - len(claimed)
+    p := b.state.Paused()
+    switch {
+    case free <= 0 && p == nil:
+        b.state.Pause(PauseClaimLimit, ResumeSlotFree, time.Time{}, fmt.Sprintf("%d of %d missions claimed", len(claimed), b.claimLimit))
+    case free > 0 && p != nil && p.Resume == ResumeSlotFree:
+        b.state.Resume(fmt.Sprintf("%d claim slots free", free))
+    }
+    return free, claimed
+}
+
+// Claim verification timing: the platform occasionally rolls back claims
+// shortly after answering 201, so the claimed list is checked a few times.
+const (
+    claimVerifyDelay    = 5 * time.Second
+    claimVerifyAttempts = 3
+)
+
+// verifyClaim checks that a task reported as claimed actually shows up among
+// the researcher's claimed missions, and alerts if it does not.
+func verifyClaim(token string, task Task) {
+    for attempt := 1; attempt <= claimVerifyAttempts; attempt++ {
+        time.Sleep(claimVerifyDelay)
+        claimed, err := getClaimedTasks(token)
+        if err != nil {
+            slog.Warn("claim verification failed", "endpoint", "claimed_tasks", "task_id", task.ID, "attempt", attempt, "err", err)
+            continue
+        }
+        for _, t := range claimed {
+            if t.ID == task.ID {
+                slog.Debug("claim verified", "task_id", task.ID)
+                return
+            }
+        }
+    }
+    slog.Error("claimed task missing from claimed missions", "task_id", task.ID)
+    notifyEvent(Event{
+        Type:    EventClaimUnverified,
+        Message: fmt.Sprintf("Task %s (%s) was claimed but does not appear in your claimed missions. Check the platform before working on it.", task.ID, task.Title),
+        Task:    &task,
+        Target:  task.ListingCodename,
+    })
+}
+
+// enoughTasks returns a getTasksUntil check that is satisfied once the
+// tasks hold as many claimable tasks as there were claim slots free after
+// the last cycle, filtered like the main loop does: claim rules (with the
+// target details cached so far), re-published missions, availability and
+// the per-campaign and per-target caps and dry runs.
+// It returns nil, reading every page, while the number of free slots is
+// unknown or zero, so a freed slot is still noticed, and while a strategy
+// orders the missions, the workload caps them or a decision webhook
+// decides, as a mission on a later page could then be claimed first.
+func (b *bot) enoughTasks(slots int) func([]Task) bool {
+    cfg := b.config()
+    if slots <= 0 || len(cfg.Strategy.Order) > 0 || cfg.Strategy.MinAcceptanceRate > 0 ||
+        cfg.Workload.Capacity.Duration > 0 || cfg.DecisionWebhook.URL != "" {
+        return nil
+    }
+    // loadConfig has validated the blackouts.
+    blackouts, _ := cfg.Availability.blackouts()
+    return func(tasks []Task) bool {
+        now := time.Now()
+        caps := b.newCapCounter()
+        seen := map[string]bool{}
+        n := 0
+        for _, task := range tasks {
+            if seen[task.ID] {
+                continue
+            }
+            seen[task.ID] = true
+            if r, ok := b.store.Republished(task); ok && slices.Contains(b.skipRepublished, r.Outcome) {
+                continue
+            }
+            if _, ok := cfg.Availability.available(blackouts, task, now); !ok {
+                continue
+            }
+            if claim, _ := b.claimRules.Decide(task, func() (string, error) {
+                detail, ok := b.targetCache.CachedDetail(task.ListingUid)
+                if !ok {
+                    return "", fmt.Errorf("details of target %s not cached yet", task.ListingUid)
+                }
+                return detail.Category.Name, nil
+            }); !claim {
+                continue
+            }
+            if !caps.claimable(task, b.settingsFor(task)) {
+                continue
+            }
+            caps.add(task)
+            n++
+        }
+        return n >= slots
+    }
+}
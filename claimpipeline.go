@@ -0,0 +1,119 @@
+package main
+
+import (
+    "log/slog"
+    "net/http"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+// claimResult is the outcome of a claim request sent ahead of the claim loop.
+type claimResult struct {
+    task        Task
+    err         error
+    attemptedAt time.Time
+}
+
+// prefetchClaims claims the tasks with a pool of b.claimPipeline workers,
+// best tasks first. The shared client multiplexes the requests over a single
+// HTTP/2 connection, so consecutive claims don't wait for each other's round
+// trip. Only tasks the claim loop would attempt are sent: not claimed or
+// refused with 403 before, not in dry run, under the campaign and target
+// caps and within the free claim slots (-1 = unlimited).
+//
+// Once a claim is answered with 429, 401 or 403, the workers stop taking
+// tasks: the ones not sent yet are left to the claim loop, which paces them
+// with -claim-delay, refreshes the token or remembers the refused campaign
+// first. Each worker also checks again that a task's campaign wasn't
+// refused in the meantime.
+//
+// The platform has no batch claim transition, so this is the closest
+// equivalent to claiming a group of tasks in one request.
+func (b *bot) prefetchClaims(token string, tasks []Task, claimSlots int) map[string]claimResult {
+    limit := len(tasks)
+    if claimSlots >= 0 {
+        limit = min(limit, claimSlots)
+    }
+    // The batch's own claims count towards the caps too.
+    caps := b.newCapCounter()
+    claimedToday, earnedToday := b.store.ClaimsSince(caps.today)
+    if b.dailyCaps.maxClaims > 0 {
+        limit = min(limit, b.dailyCaps.maxClaims-claimedToday)
+    }
+    var batch []Task
+    for _, task := range tasks {
+        if len(batch) >= limit {
+            break
+        }
+        // Like the claim loop, stop once the claims so far reach the
+        // daily earnings cap.
+        if b.dailyCaps.maxEarnings > 0 && earnedToday >= b.dailyCaps.maxEarnings {
+            break
+        }
+        if !caps.claimable(task, b.settingsFor(task)) {
+            continue
+        }
+        caps.add(task)
+        earnedToday += task.Payout.Amount
+        batch = append(batch, task)
+    }
+    if len(batch) < 2 {
+        return nil
+    }
+
+    workers := min(b.claimPipeline, len(batch))
+    slog.Debug("sending pipelined claims", "tasks", len(batch), "workers", workers)
+    queue := make(chan Task, len(batch))
+    for _, task := range batch {
+        queue <- task
+    }
+    close(queue)
+    results := make(map[string]claimResult, len(batch))
+    var mu sync.Mutex
+    var stopped atomic.Bool
+    var wg sync.WaitGroup
+    for range workers {
+        wg.Go(func() {
+            defer crashOnPanic()
+            for task := range queue {
+                if stopped.Load() {
+                    return
+                }
+                if _, ok := b.store.Forbidden(task); ok {
+                    continue
+                }
+                attemptedAt := time.Now()
+                err := postClaimTask(token, task)
+                timings.Since(PhaseClaim, attemptedAt)
+                switch status := errorStatus(err); status {
+                case http.StatusTooManyRequests, http.StatusUnauthorized, http.StatusForbidden:
+                    if !stopped.Swap(true) {
+                        slog.Warn("claim refused, claiming the remaining tasks one at a time", "task_id", task.ID, "status", status)
+                    }
+                }
+                mu.Lock()
+                results[task.ID] = claimResult{task: task, err: err, attemptedAt: attemptedAt}
+                mu.Unlock()
+            }
+        })
+    }
+    wg.Wait()
+    return results
+}
+
+// dedupeTasks drops repeated tasks, keeping the first of each ID, so a
+// mission listed twice (on two pages, or by the hot target poller and a
+// page) is filtered, counted and claimed only once.
+func dedupeTasks(tasks []Task) []Task {
+    seen := make(map[string]bool, len(tasks))
+    out := tasks[:0:0]
+    for _, task := range tasks {
+        if seen[task.ID] {
+            continue
+        }
+        seen[task.ID] = true
+        out = append(out, task)
+    }
+    return out
+}
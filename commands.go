@@ -0,0 +1,233 @@
+package main
+
+import (
+    "errors"
+    "flag"
+    "fmt"
+    "log/slog"
+    "os"
+    "text/tabwriter"
+    "time"
+)
+
+// subcommands maps each subcommand to its implementation. Without a
+// subcommand, or with "run", the bot itself runs.
+var subcommands = map[string]func(args []string) error{
+    "missions":     runMissions,
+    "targets":      runTargets,
+    "status":       runStatus,
+    "history":      runHistory,
+    "portfolio":    runPortfolio,
+    "release":      runRelease,
+    "mark-viewed":  runMarkViewed,
+    "archive":      runArchive,
+    "state":        runState,
+    "notify":       runNotify,
+    "keychain":     runKeychain,
+    "token":        runToken,
+    "token-daemon": runTokenDaemon,
+    "stats":        runStats,
+    "claims":       runClaims,
+    "healthcheck":  runHealthcheck,
+    "native-host":  runNativeHost,
+}
+
+// commandToken registers the token flags of a one-off subcommand and returns
+// a function resolving the token after parsing: -t, -token-file or the OS
+// keychain (-keychain).
+func commandToken(fs *flag.FlagSet) func() (string, error) {
+    tokenFlag := fs.String("t", "", "Session token for authentication")
+    tokenFileFlag := fs.String("token-file", "", "File to read the token from")
+    keychainFlag := fs.Bool("keychain", false, "Load the token from the OS keychain")
+    return func() (string, error) {
+        switch {
+        case *tokenFlag != "":
+            return *tokenFlag, nil
+        case *tokenFileFlag != "":
+            return readTokenFile(*tokenFileFlag)
+        case *keychainFlag:
+            return loadKeychainToken()
+        }
+        return "", fmt.Errorf("a token is required: use -t, -token-file or -keychain")
+    }
+}
+
+// newTable returns a writer that aligns tab separated columns on stdout.
+func newTable() *tabwriter.Writer {
+    return tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+}
+
+// runMissions implements "missions list" and "missions claim <task id>".
+func runMissions(args []string) error {
+    usage := fmt.Errorf("usage: %s missions list|claim [-t <token>] [<task id>]", os.Args[0])
+    if len(args) == 0 {
+        return usage
+    }
+    fs := flag.NewFlagSet("missions "+args[0], flag.ExitOnError)
+    token := commandToken(fs)
+    pagesFlag := fs.Int("max-pages", 10, "Read at most this many pages of missions")
+    fs.Parse(args[1:])
+    tok, err := token()
+    if err != nil {
+        return err
+    }
+
+    tasks, err := getTasks(tok, *pagesFlag)
+    if errors.As(err, new(partialTasksError)) {
+        slog.Warn("only the missions of the pages before the error are used", "err", err)
+    } else if err != nil {
+        return err
+    }
+    switch args[0] {
+    case "list":
+        w := newTable()
+        fmt.Fprintln(w, "ID\tPAYOUT\tTARGET\tCATEGORY\tTITLE")
+        for _, t := range tasks {
+            fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", t.ID, t.Payout, t.ListingCodename, t.Category, t.Title)
+        }
+        return w.Flush()
+    case "claim":
+        if fs.NArg() != 1 {
+            return usage
+        }
+        for _, t := range tasks {
+            if t.ID == fs.Arg(0) {
+                if err := postClaimTask(tok, t); err != nil {
+                    return err
+                }
+                slog.Info("task claimed", "task_id", t.ID, "title", t.Title, "payout", t.Payout.String())
+                return nil
+            }
+        }
+        return fmt.Errorf("task %s is not among the available missions", fs.Arg(0))
+    default:
+        return usage
+    }
+}
+
+// runTargets implements "targets list" and "targets signup <slug>".
+func runTargets(args []string) error {
+    usage := fmt.Errorf("usage: %s targets list|signup [-t <token>] [<slug>]", os.Args[0])
+    if len(args) == 0 {
+        return usage
+    }
+    fs := flag.NewFlagSet("targets "+args[0], flag.ExitOnError)
+    token := commandToken(fs)
+    fs.Parse(args[1:])
+    tok, err := token()
+    if err != nil {
+        return err
+    }
+
+    switch args[0] {
+    case "list":
+        targets, err := getUnregisteredTargets(tok)
+        if err != nil {
+            return err
+        }
+        w := newTable()
+        fmt.Fprintln(w, "SLUG\tCODENAME\tCATEGORY\tORGANIZATION")
+        for _, t := range targets {
+            fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", t.Slug, t.Codename, t.Category.Name, t.OrganizationID)
+        }
+        return w.Flush()
+    case "signup":
+        if fs.NArg() != 1 {
+            return usage
+        }
+        if err := signupTarget(tok, fs.Arg(0)); err != nil {
+            return err
+        }
+        slog.Info("signed up for target", "slug", fs.Arg(0))
+        return nil
+    default:
+        return usage
+    }
+}
+
+// runStatus implements "status": the token's lifetime and roles, and the
+// claimed missions with their deadlines.
+func runStatus(args []string) error {
+    fs := flag.NewFlagSet("status", flag.ExitOnError)
+    token := commandToken(fs)
+    htmlFlag := fs.Bool("html", false, "Write a self-contained HTML status page to stdout")
+    dbFlag := fs.String("db", "", "Database file, for claim statistics on the HTML page")
+    passphraseFlag := fs.String("db-passphrase-file", "", "File containing the database passphrase")
+    auditFlag := fs.String("audit-log", "", "Audit log file, for recent events on the HTML page")
+    fs.Parse(args)
+    tok, err := token()
+    if err != nil {
+        return err
+    }
+    if *htmlFlag {
+        claimed, err := getClaimedTasks(tok)
+        if err != nil {
+            return err
+        }
+        var db *store
+        if *dbFlag != "" {
+            passphrase, err := readPassphrase(*passphraseFlag)
+            if err != nil {
+                return err
+            }
+            if db, err = openStore(*dbFlag, passphrase); err != nil {
+                return err
+            }
+        }
+        return writeStatusPage(os.Stdout, newStatusPage(tok, claimed, time.Now()), db, *auditFlag)
+    }
+
+    if exp, ok := tokenExpiry(tok); !ok {
+        fmt.Println("Token expiry: unknown")
+    } else if left := time.Until(exp); left > 0 {
+        fmt.Printf("Token expires in %s (%s)\n", left.Round(time.Minute), exp.Local().Format("2006-01-02 15:04"))
+    } else {
+        fmt.Println("Token has EXPIRED")
+    }
+    if claims, err := decodeTokenClaims(tok); err == nil {
+        fmt.Printf("Token roles: %v\n", tokenRoles(claims))
+    }
+
+    claimed, err := getClaimedTasks(tok)
+    if err != nil {
+        return err
+    }
+    fmt.Printf("\nClaimed missions: %d\n", len(claimed))
+    w := newTable()
+    fmt.Fprintln(w, "ID\tPAYOUT\tTARGET\tDUE\tTITLE")
+    for _, t := range claimed {
+        due := "-"
+        if deadline, ok := missionDeadline(t); ok {
+            due = deadline.Local().Format("2006-01-02 15:04")
+        }
+        fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", t.ID, t.Payout, t.ListingCodename, due, t.Title)
+    }
+    return w.Flush()
+}
+
+// runHistory implements "history": the missions recorded in the database.
+func runHistory(args []string) error {
+    fs := flag.NewFlagSet("history", flag.ExitOnError)
+    dbFlag := fs.String("db", "", "Database file")
+    passphraseFlag := fs.String("db-passphrase-file", "", "File containing the database passphrase")
+    fs.Parse(args)
+    if *dbFlag == "" {
+        fs.Usage()
+        return fmt.Errorf("-db is required")
+    }
+    passphrase, err := readPassphrase(*passphraseFlag)
+    if err != nil {
+        return err
+    }
+    db, err := openStore(*dbFlag, passphrase)
+    if err != nil {
+        return err
+    }
+
+    w := newTable()
+    fmt.Fprintln(w, "CLAIMED\tID\tPAYOUT\tTARGET\tTITLE")
+    for _, c := range db.Data().Claims {
+        fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", c.ClaimedAt.Local().Format("2006-01-02 15:04"), c.Task.ID, c.Task.Payout, c.Task.ListingCodename, c.Task.Title)
+    }
+    return w.Flush()
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/url"
+    "os"
+    "slices"
+    "strings"
+    "time"
+)
+
+// Config holds the optional settings loaded from the file given with -config.
+type Config struct {
+    Targets   TargetFilter   `json:"targets"`
+    RoleGates RoleGates      `json:"role_gates"`
+    Strategy  StrategyConfig `json:"strategy"`
+    // StatusPolicies decides, per endpoint or "default", how unknown status codes are handled.
+    StatusPolicies map[string]StatusPolicy `json:"status_policies"`
+    Telegram       TelegramConfig          `json:"telegram"`
+    Notifications  []NotificationConfig    `json:"notifications"`
+    SignupHooks    SignupHooksConfig       `json:"signup_hooks"`
+    EventHooks     EventHooksConfig        `json:"event_hooks"`
+    HotTargets     []HotTarget             `json:"hot_targets"`
+    // Workload forecasts the claimed missions' work and can cap it.
+    Workload    WorkloadConfig    `json:"workload"`
+    TargetCache TargetCacheConfig `json:"target_cache"`
+    // ClaimRules decide per mission whether it is claimed; see ClaimRule.
+    ClaimRules      []ClaimRule           `json:"claim_rules"`
+    DecisionWebhook DecisionWebhookConfig `json:"decision_webhook"`
+    QuietHours      QuietHoursConfig      `json:"quiet_hours"`
+    // Accounts, if set, run one bot process per account; see AccountConfig.
+    Accounts []AccountConfig `json:"accounts"`
+    // TargetOverrides change settings per target; see TargetOverride.
+    TargetOverrides []TargetOverride `json:"target_overrides"`
+    // Availability skips missions that can't be worked on in time.
+    Availability AvailabilityConfig `json:"availability"`
+    // DeepLinks sets the platform links added to notifications.
+    DeepLinks DeepLinksConfig `json:"deep_links"`
+}
+
+// TargetCacheConfig sets how long target details and scopes are cached.
+type TargetCacheConfig struct {
+    // DetailTTL defaults to 6h.
+    DetailTTL Duration `json:"detail_ttl"`
+    // ScopeTTL defaults to 30m.
+    ScopeTTL Duration `json:"scope_ttl"`
+}
+
+// HotTarget is a listing whose missions are polled separately and more often
+// than the general task poll, for targets where missions are known to drop.
+type HotTarget struct {
+    // ListingUid is the listing the task query is scoped to.
+    ListingUid string `json:"listing_uid"`
+    // Interval between polls of this listing (default 5s, at least 1s).
+    Interval Duration `json:"interval"`
+}
+
+// Hot target polling defaults.
+const (
+    defaultHotInterval = 5 * time.Second
+    minHotInterval     = time.Second
+)
+
+// SignupHooksConfig lists commands run after each successful target signup.
+type SignupHooksConfig struct {
+    Hooks []HookConfig `json:"hooks"`
+    // MaxConcurrent limits how many hook commands run at once (default 2).
+    MaxConcurrent int `json:"max_concurrent"`
+    // OutputDir receives one log file per hook run (default: a temp directory).
+    OutputDir string `json:"output_dir"`
+}
+
+// HookConfig is an external command run by the bot.
+type HookConfig struct {
+    Name    string   `json:"name"`
+    Command []string `json:"command"`
+    // Timeout kills the command (and its children) after this long (default 30m).
+    Timeout Duration `json:"timeout"`
+    // Retries is how many times a failed or timed out run is retried.
+    Retries int `json:"retries"`
+    // Env are environment variables the command needs besides the run's
+    // own, e.g. the file of a sound hook.
+    Env []string `json:"-"`
+}
+
+// Duration is a time.Duration read from a string such as "90s" or "5m".
+type Duration struct {
+    time.Duration
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+    var s string
+    if err := json.Unmarshal(data, &s); err != nil {
+        return fmt.Errorf("duration must be a string like \"5m\": %s", data)
+    }
+    v, err := time.ParseDuration(s)
+    if err != nil {
+        return err
+    }
+    d.Duration = v
+    return nil
+}
+
+// TelegramConfig enables Telegram notifications and chat control. Commands
+// are only accepted from ChatID.
+type TelegramConfig struct {
+    BotToken string `json:"bot_token"`
+    ChatID   int64  `json:"chat_id"`
+    // Events, if set, limits notifications to these event types.
+    Events []string `json:"events"`
+}
+
+// StrategyConfig tunes the order in which claimable missions are attempted.
+type StrategyConfig struct {
+    // UseCategoryStats fetches the researcher's per-category acceptance rates hourly.
+    UseCategoryStats bool `json:"use_category_stats"`
+    // MinAcceptanceRate (0-1) moves missions in categories below it to the back of the queue.
+    MinAcceptanceRate float64 `json:"min_acceptance_rate"`
+    // MinReviewed ignores categories with fewer reviewed missions than this.
+    MinReviewed int `json:"min_reviewed"`
+    // Order lists the priorities applied in turn to missions of one poll:
+    // "payout" (highest first), "shortest" (least completion time first),
+    // "targets" (PreferTargets first) and "newest" (latest published first).
+    Order []string `json:"order"`
+    // PreferTargets are target codenames or listing UIDs claimed first with
+    // the "targets" order.
+    PreferTargets []string `json:"prefer_targets"`
+}
+
+// strategyOrders are the valid StrategyConfig.Order keys.
+var strategyOrders = []string{"payout", "shortest", "targets", "newest"}
+
+// RoleGates lists, per feature, the token roles of which at least one is required.
+// Features whose roles the token lacks are disabled instead of producing 403s.
+type RoleGates struct {
+    Missions []string `json:"missions"`
+    Targets  []string `json:"targets"`
+}
+
+// TargetFilter decides which unregistered targets may be signed up for automatically.
+// Deny entries always win. When any allow list is set, a target must match at least
+// one of them. Matching is case-insensitive.
+type TargetFilter struct {
+    AllowSlugs         []string `json:"allow_slugs"`
+    DenySlugs          []string `json:"deny_slugs"`
+    AllowCodenames     []string `json:"allow_codenames"`
+    DenyCodenames      []string `json:"deny_codenames"`
+    AllowOrganizations []string `json:"allow_organizations"`
+    DenyOrganizations  []string `json:"deny_organizations"`
+    // AllowCategories, if set, restricts signups to these categories (e.g. "Web Application").
+    AllowCategories []string `json:"allow_categories"`
+    // Permitted targets matching an ask entry (or any, with AskAll) are only
+    // signed up for once the user approves a preview of their scope.
+    AskAll           bool     `json:"ask_all"`
+    AskSlugs         []string `json:"ask_slugs"`
+    AskCodenames     []string `json:"ask_codenames"`
+    AskOrganizations []string `json:"ask_organizations"`
+    AskCategories    []string `json:"ask_categories"`
+    // AskExpiry is how long a signup waits for a decision (default 12h).
+    AskExpiry Duration `json:"ask_expiry"`
+}
+
+// loadConfig reads a JSON config file. An empty path returns the zero Config.
+func loadConfig(path string) (Config, error) {
+    var cfg Config
+    if path == "" {
+        return cfg, nil
+    }
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return cfg, err
+    }
+    if err := json.Unmarshal(data, &cfg); err != nil {
+        return cfg, fmt.Errorf("parsing config %s: %v", path, err)
+    }
+    registerSecret(cfg.Telegram.BotToken)
+    for _, n := range cfg.Notifications {
+        // Webhook URLs carry their credentials.
+        registerSecret(n.URL)
+        registerSecret(n.Token)
+        registerSecret(n.SMTP.Password)
+        if u, err := url.Parse(n.URL); err == nil {
+            pass, _ := u.User.Password()
+            registerSecret(pass)
+        }
+    }
+    if err := validateStatusPolicies(cfg.StatusPolicies); err != nil {
+        return cfg, fmt.Errorf("config %s: %v", path, err)
+    }
+    if err := validateNotifications(cfg); err != nil {
+        return cfg, fmt.Errorf("config %s: %v", path, err)
+    }
+    for i, h := range cfg.SignupHooks.Hooks {
+        if len(h.Command) == 0 {
+            return cfg, fmt.Errorf("config %s: signup_hooks.hooks[%d] has no command", path, i)
+        }
+    }
+    if err := cfg.EventHooks.validate(); err != nil {
+        return cfg, fmt.Errorf("config %s: %v", path, err)
+    }
+    for i, t := range cfg.HotTargets {
+        if t.ListingUid == "" {
+            return cfg, fmt.Errorf("config %s: hot_targets[%d] has no listing_uid", path, i)
+        }
+        if t.Interval.Duration == 0 {
+            cfg.HotTargets[i].Interval.Duration = defaultHotInterval
+        } else if t.Interval.Duration < minHotInterval {
+            return cfg, fmt.Errorf("config %s: hot_targets[%d] interval must be at least %s", path, i, minHotInterval)
+        }
+    }
+    if err := cfg.Workload.validate(); err != nil {
+        return cfg, fmt.Errorf("config %s: %v", path, err)
+    }
+    for _, o := range cfg.Strategy.Order {
+        if !slices.Contains(strategyOrders, o) {
+            return cfg, fmt.Errorf("config %s: strategy.order: unknown order %q (want %s)", path, o, strings.Join(strategyOrders, ", "))
+        }
+    }
+    if err := cfg.DecisionWebhook.validate(); err != nil {
+        return cfg, fmt.Errorf("config %s: %v", path, err)
+    }
+    registerSecret(cfg.DecisionWebhook.Secret)
+    if _, err := newQuietHours(cfg.QuietHours); err != nil {
+        return cfg, fmt.Errorf("config %s: %v", path, err)
+    }
+    if _, err := compileClaimRules(cfg.ClaimRules); err != nil {
+        return cfg, fmt.Errorf("config %s: %v", path, err)
+    }
+    if cfg.Targets.AskExpiry.Duration < 0 {
+        return cfg, fmt.Errorf("config %s: targets.ask_expiry must not be negative", path)
+    }
+    if cfg.TargetCache.DetailTTL.Duration < 0 || cfg.TargetCache.ScopeTTL.Duration < 0 {
+        return cfg, fmt.Errorf("config %s: target_cache TTLs must not be negative", path)
+    }
+    if err := validateAccounts(cfg.Accounts); err != nil {
+        return cfg, fmt.Errorf("config %s: %v", path, err)
+    }
+    if err := validateTargetOverrides(cfg); err != nil {
+        return cfg, fmt.Errorf("config %s: %v", path, err)
+    }
+    if err := cfg.Availability.validate(); err != nil {
+        return cfg, fmt.Errorf("config %s: %v", path, err)
+    }
+    if err := cfg.DeepLinks.validate(); err != nil {
+        return cfg, fmt.Errorf("config %s: %v", path, err)
+    }
+    return cfg, nil
+}
+
+// Permits reports whether the target may be signed up for, and if not, why.
+func (f TargetFilter) Permits(t Target) (bool, string) {
+    switch {
+    case containsFold(f.DenySlugs, t.Slug):
+        return false, "slug is denylisted"
+    case containsFold(f.DenyCodenames, t.Codename):
+        return false, "codename is denylisted"
+    case containsFold(f.DenyOrganizations, t.OrganizationID):
+        return false, "organization is denylisted"
+    }
+
+    if len(f.AllowCategories) > 0 && !containsFold(f.AllowCategories, t.Category.Name) {
+        return false, fmt.Sprintf("category %q is not allowlisted", t.Category.Name)
+    }
+
+    if len(f.AllowSlugs)+len(f.AllowCodenames)+len(f.AllowOrganizations) == 0 {
+        return true, ""
+    }
+    if containsFold(f.AllowSlugs, t.Slug) ||
+        containsFold(f.AllowCodenames, t.Codename) ||
+        containsFold(f.AllowOrganizations, t.OrganizationID) {
+        return true, ""
+    }
+    return false, "not on any allowlist"
+}
+
+// AsksFirst reports whether signing up for t needs the user's approval.
+func (f TargetFilter) AsksFirst(t Target) bool {
+    return f.AskAll ||
+        containsFold(f.AskSlugs, t.Slug) ||
+        containsFold(f.AskCodenames, t.Codename) ||
+        containsFold(f.AskOrganizations, t.OrganizationID) ||
+        containsFold(f.AskCategories, t.Category.Name)
+}
+
+// askExpiry returns how long a signup waits for approval.
+func (f TargetFilter) askExpiry() time.Duration {
+    if f.AskExpiry.Duration > 0 {
+        return f.AskExpiry.Duration
+    }
+    return defaultAskExpiry
+}
+
+// containsFold reports whether s is in list, ignoring case. Empty s never matches.
+func containsFold(list []string, s string) bool {
+    if s == "" {
+        return false
+    }
+    for _, v := range list {
+        if strings.EqualFold(v, s) {
+            return true
+        }
+    }
+    return false
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+    "crypto/subtle"
+    "encoding/json"
+    "errors"
+    "io"
+    "log/slog"
+    "net"
+    "net/http"
+    "net/url"
+    "os"
+    "strings"
+    "time"
+)
+
+// controlHeader must be set on every POST to the control API. Browsers only
+// let a page send it to its own origin, so a web page can't forge requests
+// with the HTTP Basic credentials the browser cached for the dashboard.
+const controlHeader = "X-Mission-Bot"
+
+// controlServer is the optional local HTTP server for controlling and
+// inspecting a running bot. Every request must carry the control token as
+// "Authorization: Bearer <token>", or, for the dashboard in a browser, as the
+// HTTP Basic password. Requests must name a loopback host or one of hosts,
+// so a web page can't reach the API through DNS rebinding, cross-site
+// requests are refused, and POSTs must carry the controlHeader.
+type controlServer struct {
+    token string
+    hosts []string
+    mux   *http.ServeMux
+}
+
+// readControlToken reads the control API token from path, falling back to
+// the MISSION_BOT_CONTROL_TOKEN environment variable.
+func readControlToken(path string) (string, error) {
+    if path != "" {
+        data, err := os.ReadFile(path)
+        if err != nil {
+            return "", err
+        }
+        token := strings.TrimSpace(string(data))
+        registerSecret(token)
+        return token, nil
+    }
+    return os.Getenv("MISSION_BOT_CONTROL_TOKEN"), nil
+}
+
+// parseControlHosts parses the comma separated host names accepted by the
+// control API besides loopback ones.
+func parseControlHosts(s string) []string {
+    var hosts []string
+    for _, h := range strings.Split(s, ",") {
+        if h = strings.TrimSpace(h); h != "" {
+            hosts = append(hosts, h)
+        }
+    }
+    return hosts
+}
+
+// newControlServer sets up the control server with the endpoints controlling
+// b and the debugging endpoints (see registerDebugHandlers). Besides loopback
+// names, requests may name one of hosts.
+func newControlServer(token string, hosts []string, b *bot) *controlServer {
+    c := &controlServer{token: token, hosts: hosts, mux: http.NewServeMux()}
+    c.mux.HandleFunc("/", getOnly(serveDashboard))
+    c.mux.HandleFunc("/events", getOnly(b.serveEvents))
+    c.mux.HandleFunc("/state", getOnly(b.serveState))
+    c.mux.HandleFunc("/calendar", getOnly(b.serveCalendar))
+    c.mux.HandleFunc("/claims/pause", postOnly(b.servePauseClaims))
+    c.mux.HandleFunc("/claims/resume", postOnly(b.serveResumeClaims))
+    c.mux.HandleFunc("/signups/pause", postOnly(b.serveSignupsPaused(true)))
+    c.mux.HandleFunc("/signups/resume", postOnly(b.serveSignupsPaused(false)))
+    c.mux.HandleFunc("/signups/approve", postOnly(b.serveSignupDecision(true)))
+    c.mux.HandleFunc("/signups/deny", postOnly(b.serveSignupDecision(false)))
+    c.mux.HandleFunc("/token", postOnly(b.serveSetToken))
+    c.mux.HandleFunc("/poll", postOnly(b.servePoll))
+    c.mux.HandleFunc("/release", postOnly(b.serveRelease))
+    registerDebugHandlers(c.mux)
+    return c
+}
+
+// ServeHTTP checks the host, origin and control token and dispatches the
+// request.
+func (c *controlServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    if !isLoopbackHost(r.Host) && !c.allowedHost(r.Host) {
+        http.Error(w, "host not allowed", http.StatusForbidden)
+        return
+    }
+    if isCrossSite(r) {
+        http.Error(w, "cross-site request refused", http.StatusForbidden)
+        return
+    }
+    got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+    if !ok {
+        _, got, ok = r.BasicAuth()
+    }
+    if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(c.token)) != 1 {
+        w.Header().Set("WWW-Authenticate", `Basic realm="synack-mission-bot"`)
+        http.Error(w, "unauthorized", http.StatusUnauthorized)
+        return
+    }
+    if r.Method == http.MethodPost && r.Header.Get(controlHeader) != "1" {
+        http.Error(w, "missing "+controlHeader+" header", http.StatusForbidden)
+        return
+    }
+    c.mux.ServeHTTP(w, r)
+}
+
+// allowedHost reports whether a request's Host header names one of the
+// configured hosts.
+func (c *controlServer) allowedHost(host string) bool {
+    if h, _, err := net.SplitHostPort(host); err == nil {
+        host = h
+    }
+    host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+    for _, h := range c.hosts {
+        if strings.EqualFold(h, host) {
+            return true
+        }
+    }
+    return false
+}
+
+// isCrossSite reports whether a browser sent r from another site: its
+// Sec-Fetch-Site says so, or its Origin differs from the requested host.
+// Clients other than browsers send neither header.
+func isCrossSite(r *http.Request) bool {
+    switch r.Header.Get("Sec-Fetch-Site") {
+    case "", "same-origin", "none":
+    default:
+        return true
+    }
+    origin := r.Header.Get("Origin")
+    if origin == "" {
+        return false
+    }
+    u, err := url.Parse(origin)
+    return err != nil || !strings.EqualFold(u.Host, r.Host)
+}
+
+// Serve serves the control API on ln until the listener fails, over HTTPS
+// when a certificate and key are given.
+func (c *controlServer) Serve(ln net.Listener, certFile, keyFile string) {
+    srv := &http.Server{
+        Handler:           c,
+        ReadHeaderTimeout: 10 * time.Second,
+        ErrorLog:          redactedErrorLog(),
+    }
+    slog.Info("control API started", "addr", ln.Addr().String(), "tls", certFile != "")
+    var err error
+    if certFile != "" {
+        err = srv.ServeTLS(ln, certFile, keyFile)
+    } else {
+        err = srv.Serve(ln)
+    }
+    if err != nil {
+        slog.Error("control API stopped", "addr", ln.Addr().String(), "err", err)
+    }
+}
+
+// getOnly and postOnly reject requests with another method.
+func getOnly(h http.HandlerFunc) http.HandlerFunc  { return methodOnly(http.MethodGet, h) }
+func postOnly(h http.HandlerFunc) http.HandlerFunc { return methodOnly(http.MethodPost, h) }
+
+func methodOnly(method string, h http.HandlerFunc) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if r.Method != method {
+            w.Header().Set("Allow", method)
+            http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+            return
+        }
+        h(w, r)
+    }
+}
+
+// controlState is the bot state returned by GET /state.
+type controlState struct {
+    Claiming      bool       `json:"claiming"`
+    Pause         *pauseInfo `json:"pause,omitempty"`
+    SignupsPaused bool       `json:"signupsPaused"`
+    // PendingSignups are the signups awaiting approval.
+    PendingSignups []pendingSignup `json:"pendingSignups"`
+    Pending        int             `json:"pending"`
+    Claimed        []controlClaim  `json:"claimed"`
+    Targets        int             `json:"unregisteredTargets"`
+    TokenExpiry    *time.Time      `json:"tokenExpiry,omitempty"`
+    LastPoll       *time.Time      `json:"lastPoll,omitempty"`
+    Session        sessionStats    `json:"session"`
+    Skipped        skipCounts      `json:"skipped,omitempty"`
+    RecentErrors   []string        `json:"recentErrors"`
+}
+
+// controlClaim is a mission claimed in this session.
+type controlClaim struct {
+    ID        string    `json:"id"`
+    Title     string    `json:"title"`
+    Payout    string    `json:"payout"`
+    ClaimedAt time.Time `json:"claimedAt"`
+}
+
+// writeJSON writes v as the JSON response.
+func writeJSON(w http.ResponseWriter, v any) {
+    w.Header().Set("Content-Type", "application/json")
+    if err := json.NewEncoder(w).Encode(v); err != nil {
+        slog.Debug("failed to write control API response", "err", err)
+    }
+}
+
+// serveState returns the current state as JSON.
+func (b *bot) serveState(w http.ResponseWriter, r *http.Request) {
+    writeJSON(w, b.controlState())
+}
+
+// controlState assembles the state reported by the control API.
+func (b *bot) controlState() controlState {
+    snap := b.state.Snapshot()
+    st := controlState{
+        Claiming:       snap.Pause == nil,
+        Pause:          snap.Pause,
+        SignupsPaused:  snap.SignupsPaused,
+        PendingSignups: b.approvals.List(),
+        Pending:        len(snap.Pending),
+        Claimed:        []controlClaim{},
+        Targets:        len(snap.Targets),
+        Session:        b.state.Session(),
+        Skipped:        snap.Skipped,
+        RecentErrors:   snap.RecentErrors,
+    }
+    for _, c := range snap.Claimed {
+        st.Claimed = append(st.Claimed, controlClaim{ID: c.Task.ID, Title: c.Task.Title, Payout: c.Task.Payout.String(), ClaimedAt: c.At})
+    }
+    if !snap.TokenExpiry.IsZero() {
+        st.TokenExpiry = &snap.TokenExpiry
+    }
+    if !snap.LastPoll.IsZero() {
+        st.LastPoll = &snap.LastPoll
+    }
+    return st
+}
+
+// servePauseClaims pauses claiming, until resumed or, with ?for=<duration>,
+// for that long.
+func (b *bot) servePauseClaims(w http.ResponseWriter, r *http.Request) {
+    if v := r.URL.Query().Get("for"); v != "" {
+        d, err := time.ParseDuration(v)
+        if err != nil || d <= 0 {
+            http.Error(w, "invalid duration", http.StatusBadRequest)
+            return
+        }
+        b.state.Pause(PauseManual, ResumeTimer, time.Now().Add(d), "paused via control API")
+    } else {
+        b.state.Pause(PauseManual, ResumeManual, time.Time{}, "paused via control API")
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// serveResumeClaims ends any pause of claiming.
+func (b *bot) serveResumeClaims(w http.ResponseWriter, r *http.Request) {
+    b.state.Resume("resumed via control API")
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// serveSignupsPaused returns a handler pausing or resuming target signups.
+func (b *bot) serveSignupsPaused(paused bool) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        b.state.SetSignupsPaused(paused)
+        w.WriteHeader(http.StatusNoContent)
+    }
+}
+
+// serveSignupDecision returns a handler approving or denying the pending
+// signup of ?slug=<target slug>.
+func (b *bot) serveSignupDecision(approve bool) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        slug := r.URL.Query().Get("slug")
+        if slug == "" {
+            http.Error(w, "missing slug", http.StatusBadRequest)
+            return
+        }
+        if _, err := b.decideSignup(slug, approve); errors.Is(err, errNoPendingSignup) {
+            http.Error(w, err.Error(), http.StatusNotFound)
+            return
+        } else if errors.Is(err, errSignupBlocked) {
+            http.Error(w, err.Error(), http.StatusConflict)
+            return
+        } else if err != nil {
+            http.Error(w, err.Error(), http.StatusBadGateway)
+            return
+        }
+        w.WriteHeader(http.StatusNoContent)
+    }
+}
+
+// serveSetToken replaces the session token with the request body.
+func (b *bot) serveSetToken(w http.ResponseWriter, r *http.Request) {
+    body, err := io.ReadAll(io.LimitReader(r.Body, maxTokenBody))
+    if err != nil {
+        http.Error(w, "failed to read body", http.StatusBadRequest)
+        return
+    }
+    token := strings.TrimSpace(string(body))
+    if _, err := decodeTokenClaims(token); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    b.tokens.Set(token)
+    slog.Info("token replaced via control API")
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// servePoll polls missions and targets right away.
+func (b *bot) servePoll(w http.ResponseWriter, r *http.Request) {
+    b.pollNow()
+    w.WriteHeader(http.StatusAccepted)
+}
+
+// serveRelease releases the claimed mission given as ?id=<task id>.
+func (b *bot) serveRelease(w http.ResponseWriter, r *http.Request) {
+    id := r.URL.Query().Get("id")
+    if id == "" {
+        http.Error(w, "missing id", http.StatusBadRequest)
+        return
+    }
+    task, err := b.releaseClaimed(id)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadGateway)
+        return
+    }
+    writeJSON(w, controlClaim{ID: task.ID, Title: task.Title, Payout: task.Payout.String()})
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "testing"
+)
+
+func TestControlServerRefusesForgedRequests(t *testing.T) {
+    c := &controlServer{token: "control-token", hosts: []string{"vps.example.com"}, mux: http.NewServeMux()}
+    c.mux.HandleFunc("/poll", postOnly(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }))
+
+    tests := []struct {
+        name      string
+        host      string
+        origin    string
+        fetchSite string
+        header    bool
+        want      int
+    }{
+        {name: "CLI", host: "127.0.0.1:8778", header: true, want: http.StatusNoContent},
+        {name: "configured host", host: "vps.example.com:8443", header: true, want: http.StatusNoContent},
+        {name: "dashboard", host: "localhost:8778", origin: "http://localhost:8778", fetchSite: "same-origin", header: true, want: http.StatusNoContent},
+        {name: "no header", host: "127.0.0.1:8778", want: http.StatusForbidden},
+        {name: "rebound host", host: "attacker.example", header: true, want: http.StatusForbidden},
+        {name: "other origin", host: "127.0.0.1:8778", origin: "https://attacker.example", want: http.StatusForbidden},
+        {name: "cross-site form", host: "127.0.0.1:8778", fetchSite: "cross-site", want: http.StatusForbidden},
+    }
+    for _, tt := range tests {
+        r := httptest.NewRequest("POST", "/poll", nil)
+        r.Host = tt.host
+        r.SetBasicAuth("", "control-token")
+        if tt.origin != "" {
+            r.Header.Set("Origin", tt.origin)
+        }
+        if tt.fetchSite != "" {
+            r.Header.Set("Sec-Fetch-Site", tt.fetchSite)
+        }
+        if tt.header {
+            r.Header.Set(controlHeader, "1")
+        }
+        w := httptest.NewRecorder()
+        c.ServeHTTP(w, r)
+        if w.Code != tt.want {
+            t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.want)
+        }
+    }
+}
+
+func TestDebugHostCheck(t *testing.T) {
+    h := debugHostCheck(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }))
+    tests := []struct {
+        name, host, fetchSite string
+        want                  int
+    }{
+        {name: "localhost", host: "localhost:6060", want: http.StatusNoContent},
+        {name: "IP address", host: "10.0.0.5:6060", want: http.StatusNoContent},
+        {name: "rebound host", host: "attacker.example:6060", want: http.StatusForbidden},
+        {name: "cross-site", host: "127.0.0.1:6060", fetchSite: "cross-site", want: http.StatusForbidden},
+    }
+    for _, tt := range tests {
+        r := httptest.NewRequest("GET", "/debug/vars", nil)
+        r.Host = tt.host
+        if tt.fetchSite != "" {
+            r.Header.Set("Sec-Fetch-Site", tt.fetchSite)
+        }
+        w := httptest.NewRecorder()
+        h.ServeHTTP(w, r)
+        if w.Code != tt.want {
+            t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.want)
+        }
+    }
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+    "fmt"
+    "log/slog"
+    "strings"
+)
+
+// cycleError is one failure within a poll cycle, with the context needed to
+// correlate it with the others.
+type cycleError struct {
+    Endpoint string
+    // TaskID is the mission the failure concerns, if any.
+    TaskID string
+    // Attempt counts the consecutive cycles in which this endpoint (and
+    // task) failed, 1 for a new failure.
+    Attempt int
+    Err     error
+}
+
+func (e *cycleError) Error() string {
+    var b strings.Builder
+    b.WriteString(e.Endpoint)
+    if e.TaskID != "" {
+        fmt.Fprintf(&b, " task %s", e.TaskID)
+    }
+    if e.Attempt > 1 {
+        fmt.Fprintf(&b, " (attempt %d)", e.Attempt)
+    }
+    fmt.Fprintf(&b, ": %v", e.Err)
+    return b.String()
+}
+
+func (e *cycleError) Unwrap() error { return e.Err }
+
+func (e *cycleError) key() string { return e.Endpoint + "/" + e.TaskID }
+
+// cycleErrors collects the failures of one poll cycle, so correlated
+// failures (e.g. every claim failing with the same network error) are
+// reported together once per cycle instead of as interleaved log lines.
+type cycleErrors []*cycleError
+
+// Add records a failure of endpoint, optionally for a task.
+func (c *cycleErrors) Add(endpoint, taskID string, err error) {
+    *c = append(*c, &cycleError{Endpoint: endpoint, TaskID: taskID, Attempt: 1, Err: err})
+}
+
+func (c cycleErrors) Error() string {
+    msgs := make([]string, len(c))
+    for i, e := range c {
+        msgs[i] = e.Error()
+    }
+    return fmt.Sprintf("%d errors: %s", len(c), strings.Join(msgs, "; "))
+}
+
+func (c cycleErrors) Unwrap() []error {
+    errs := make([]error, len(c))
+    for i, e := range c {
+        errs[i] = e
+    }
+    return errs
+}
+
+// LogValue implements slog.LogValuer, logging one group per error.
+func (c cycleErrors) LogValue() slog.Value {
+    attrs := make([]slog.Attr, len(c))
+    for i, e := range c {
+        group := []slog.Attr{slog.String("endpoint", e.Endpoint)}
+        if e.TaskID != "" {
+            group = append(group, slog.String("task_id", e.TaskID))
+        }
+        group = append(group, slog.Int("attempt", e.Attempt), slog.String("err", e.Err.Error()))
+        attrs[i] = slog.Attr{Key: fmt.Sprint(i), Value: slog.GroupValue(group...)}
+    }
+    return slog.GroupValue(attrs...)
+}
+
+// errorStreaks remembers which endpoints and tasks failed in the previous
+// cycle, to number the attempts of failures that persist.
+type errorStreaks map[string]int
+
+// report numbers the attempts of errs, logs them as one entry and returns
+// the streaks for the next cycle.
+func (s errorStreaks) report(cycle int, errs cycleErrors) errorStreaks {
+    next := errorStreaks{}
+    for _, e := range errs {
+        e.Attempt = s[e.key()] + 1
+        next[e.key()] = e.Attempt
+    }
+    if len(errs) > 0 {
+        slog.Error("poll cycle failed", "cycle", cycle, "count", len(errs), "errors", errs)
+    }
+    return next
+}
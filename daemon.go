@@ -0,0 +1,104 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "log/slog"
+    "os"
+    "os/exec"
+    "os/signal"
+    "slices"
+    "strconv"
+    "strings"
+)
+
+// daemonEnv is set for the background process started by -daemon, so it
+// knows it is the daemon and doesn't start another one.
+const daemonEnv = "MISSION_BOT_DAEMON"
+
+// daemonized reports whether this process is the one started by -daemon.
+func daemonized() bool {
+    return os.Getenv(daemonEnv) == "1"
+}
+
+// daemonize starts the bot again with the same arguments in the
+// background, detached from the terminal, with stdin and stdout on the
+// null device and stderr (where crashes end up) appended to the log file.
+func daemonize(args []string, logPath string) error {
+    exe, err := os.Executable()
+    if err != nil {
+        return err
+    }
+    null, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+    if err != nil {
+        return err
+    }
+    defer null.Close()
+    logOut, err := os.OpenFile(logPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+    if err != nil {
+        return err
+    }
+    defer logOut.Close()
+
+    cmd := exec.Command(exe, append([]string{"run"}, args...)...)
+    cmd.Env = append(os.Environ(), daemonEnv+"=1")
+    cmd.Stdin, cmd.Stdout, cmd.Stderr = null, null, logOut
+    cmd.SysProcAttr = daemonSysProcAttr()
+    if err := cmd.Start(); err != nil {
+        return err
+    }
+    fmt.Printf("started in the background (pid %d), logging to %s\n", cmd.Process.Pid, logPath)
+    return cmd.Process.Release()
+}
+
+// writePidFile writes this process's PID to path, unless the file names
+// another process that is still running.
+func writePidFile(path string) error {
+    if data, err := os.ReadFile(path); err == nil {
+        pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+        if err == nil && pid != os.Getpid() && processAlive(pid) {
+            return fmt.Errorf("already running with pid %d (per %s)", pid, path)
+        }
+    } else if !errors.Is(err, os.ErrNotExist) {
+        return err
+    }
+    return os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644)
+}
+
+// removePidFileOnStop removes the PID file and exits when a stop signal
+// arrives, so a stopped bot doesn't leave a stale PID file behind.
+func removePidFileOnStop(path string) {
+    sigs := make(chan os.Signal, 1)
+    signal.Notify(sigs, stopSignals...)
+    sig := <-sigs
+    slog.Info("stopping", "signal", sig)
+    os.Remove(path)
+    flushNotifications()
+    os.Exit(0)
+}
+
+// daemonTokenFlags are the token sources that work without a terminal.
+var daemonTokenFlags = []string{"token-file", "token-listener", "token-socket", "auto-login"}
+
+// checkDaemonToken returns an error unless a new token can be had without
+// a terminal once the current one is rejected: a daemon's stdin is the
+// null device, so a prompt would return nothing at once. Telegram comes
+// from cfg (nil without -config); with an accounts list, every account
+// needs a source of its own or the supervisor's.
+func checkDaemonToken(args []string, cfg *Config) error {
+    has := func(args []string) bool {
+        return slices.ContainsFunc(daemonTokenFlags, func(name string) bool { return hasFlag(args, name) })
+    }
+    if cfg != nil && cfg.Telegram.BotToken != "" || has(args) {
+        return nil
+    }
+    if cfg == nil || len(cfg.Accounts) == 0 {
+        return errors.New("-daemon needs a token source that works without a terminal: -token-file, -token-listener, -token-socket, -auto-login or Telegram")
+    }
+    for _, a := range cfg.Accounts {
+        if !has(a.Args) {
+            return fmt.Errorf("-daemon: account %q needs a token source that works without a terminal: -token-file, -token-listener, -token-socket, -auto-login or Telegram", a.Name)
+        }
+    }
+    return nil
+}
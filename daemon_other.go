@@ -0,0 +1,21 @@
+//go:build !unix
+
+package main
+
+import (
+    "os"
+    "syscall"
+)
+
+// daemonSysProcAttr has nothing to detach here; the daemon just keeps
+// running after the starting process exits.
+func daemonSysProcAttr() *syscall.SysProcAttr {
+    return nil
+}
+
+// processAlive reports whether a process with this PID is running, as far
+// as the platform can tell without signals.
+func processAlive(pid int) bool {
+    _, err := os.FindProcess(pid)
+    return err == nil
+}
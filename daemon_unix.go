@@ -0,0 +1,20 @@
+//go:build unix
+
+package main
+
+import (
+    "os"
+    "syscall"
+)
+
+// daemonSysProcAttr starts the daemon in a new session, without a
+// controlling terminal, so closing the terminal doesn't stop it.
+func daemonSysProcAttr() *syscall.SysProcAttr {
+    return &syscall.SysProcAttr{Setsid: true}
+}
+
+// processAlive reports whether a process with this PID is running.
+func processAlive(pid int) bool {
+    p, err := os.FindProcess(pid)
+    return err == nil && p.Signal(syscall.Signal(0)) == nil
+}
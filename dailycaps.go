@@ -0,0 +1,57 @@
+package main
+
+import (
+    "fmt"
+    "time"
+)
+
+// dailyCaps limits the claims and payouts per calendar day in loc.
+type dailyCaps struct {
+    // maxClaims and maxEarnings are 0 when not limited.
+    maxClaims   int
+    maxEarnings float64
+    loc         *time.Location
+}
+
+// startOfDay returns midnight at the start of t's day in loc.
+func startOfDay(t time.Time, loc *time.Location) time.Time {
+    y, m, d := t.In(loc).Date()
+    return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
+
+// today returns the start of the current day in loc (local time if unset).
+func (c dailyCaps) today() time.Time {
+    loc := c.loc
+    if loc == nil {
+        loc = time.Local
+    }
+    return startOfDay(time.Now(), loc)
+}
+
+// enforceDailyCaps pauses claiming until the next midnight once today's
+// claims or payouts reach a cap, and reports whether it did.
+func (b *bot) enforceDailyCaps() bool {
+    caps := b.dailyCaps
+    if caps.maxClaims <= 0 && caps.maxEarnings <= 0 {
+        return false
+    }
+    now := time.Now()
+    today := startOfDay(now, caps.loc)
+    claims, earnings := b.store.ClaimsSince(today)
+
+    var detail string
+    switch {
+    case caps.maxClaims > 0 && claims >= caps.maxClaims:
+        detail = fmt.Sprintf("%d missions claimed today, the daily maximum", claims)
+    case caps.maxEarnings > 0 && earnings >= caps.maxEarnings:
+        detail = fmt.Sprintf("%g earned today, the daily maximum is %g", earnings, caps.maxEarnings)
+    default:
+        return false
+    }
+    if b.state.Paused() != nil {
+        // Don't replace another pause, which may last longer.
+        return true
+    }
+    b.state.Pause(PauseDailyCap, ResumeTimer, startOfDay(today.AddDate(0, 0, 1), caps.loc), detail)
+    return true
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+    _ "embed"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "sync"
+    "time"
+)
+
+// dashboardHTML is the web dashboard served by the control API at /.
+//
+//go:embed dashboard.html
+var dashboardHTML []byte
+
+// dashboardStateInterval is how often the live stream sends the bot state.
+const dashboardStateInterval = 2 * time.Second
+
+// dashboardEvents fans notifications out to the dashboard's live streams.
+var dashboardEvents = &eventHub{subs: map[chan Event]struct{}{}}
+
+// eventHub is a Notifier that broadcasts events to subscribers. Subscribers
+// that fall behind miss events rather than hold up notifications.
+type eventHub struct {
+    mu   sync.Mutex
+    subs map[chan Event]struct{}
+}
+
+func (h *eventHub) Notify(ev Event) error {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    for c := range h.subs {
+        select {
+        case c <- ev:
+        default:
+        }
+    }
+    return nil
+}
+
+// subscribe returns a channel receiving every event and a function ending
+// the subscription.
+func (h *eventHub) subscribe() (<-chan Event, func()) {
+    c := make(chan Event, 16)
+    h.mu.Lock()
+    h.subs[c] = struct{}{}
+    h.mu.Unlock()
+    return c, func() {
+        h.mu.Lock()
+        delete(h.subs, c)
+        h.mu.Unlock()
+    }
+}
+
+// serveDashboard serves the dashboard page.
+func serveDashboard(w http.ResponseWriter, r *http.Request) {
+    if r.URL.Path != "/" {
+        http.NotFound(w, r)
+        return
+    }
+    w.Header().Set("Content-Type", "text/html; charset=utf-8")
+    w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'unsafe-inline'; style-src 'unsafe-inline'")
+    w.Write(dashboardHTML)
+}
+
+// serveEvents streams the bot state every dashboardStateInterval and every
+// notification as server-sent events ("state" and "notification").
+func (b *bot) serveEvents(w http.ResponseWriter, r *http.Request) {
+    flusher, ok := w.(http.Flusher)
+    if !ok {
+        http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("Content-Type", "text/event-stream")
+    w.Header().Set("Cache-Control", "no-cache")
+
+    events, unsubscribe := dashboardEvents.subscribe()
+    defer unsubscribe()
+    ticker := time.NewTicker(dashboardStateInterval)
+    defer ticker.Stop()
+
+    send := func(name string, v any) error {
+        data, err := json.Marshal(v)
+        if err != nil {
+            return err
+        }
+        if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data); err != nil {
+            return err
+        }
+        flusher.Flush()
+        return nil
+    }
+    if err := send("state", b.controlState()); err != nil {
+        return
+    }
+    for {
+        var err error
+        select {
+        case <-r.Context().Done():
+            return
+        case <-ticker.C:
+            err = send("state", b.controlState())
+        case ev := <-events:
+            err = send("notification", map[string]any{"type": ev.Type, "message": ev.Message, "time": ev.Time})
+        }
+        if err != nil {
+            return
+        }
+    }
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+    "fmt"
+    "log/slog"
+    "slices"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// deadlineCheckInterval is how often claimed missions' deadlines are checked.
+const deadlineCheckInterval = 5 * time.Minute
+
+// parseReminderThresholds parses a comma separated list of percentages of
+// the completion time, e.g. "50,75,90".
+func parseReminderThresholds(s string) ([]int, error) {
+    var thresholds []int
+    for _, f := range strings.Split(s, ",") {
+        f = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(f), "%"))
+        if f == "" {
+            continue
+        }
+        p, err := strconv.Atoi(f)
+        if err != nil || p <= 0 || p >= 100 {
+            return nil, fmt.Errorf("invalid reminder threshold %q (want a percentage between 1 and 99)", f)
+        }
+        thresholds = append(thresholds, p)
+    }
+    slices.Sort(thresholds)
+    return slices.Compact(thresholds), nil
+}
+
+// missionDeadline returns when a claimed task must be completed.
+func missionDeadline(task Task) (time.Time, bool) {
+    if task.ClaimedOn.IsZero() || task.MaxCompletionTimeInSecs <= 0 {
+        return time.Time{}, false
+    }
+    return task.ClaimedOn.Add(time.Duration(task.MaxCompletionTimeInSecs) * time.Second), true
+}
+
+// trackDeadlines checks the claimed missions every few minutes and sends a
+// reminder whenever a mission crosses one of the thresholds (percent of its
+// completion time elapsed). Only the highest newly crossed threshold is sent,
+// and sent reminders are remembered in the database across restarts. Claims
+// no longer in the list are recorded as completed for the claims calendar.
+func (b *bot) trackDeadlines(thresholds []int) {
+    for {
+        claimed, err := getClaimedTasks(b.tokens.Get())
+        if err != nil {
+            slog.Warn("failed to check mission deadlines", "endpoint", "claimed_tasks", "err", err)
+        } else {
+            active := make([]string, 0, len(claimed))
+            for _, task := range claimed {
+                active = append(active, task.ID)
+                b.remindDeadline(task, thresholds)
+            }
+            if err := b.store.PruneDeadlineReminders(active); err != nil {
+                slog.Error("failed to update deadline reminders", "err", err)
+            }
+            if err := b.store.MarkCompleted(active); err != nil {
+                slog.Error("failed to record completed missions", "err", err)
+            }
+        }
+        time.Sleep(deadlineCheckInterval)
+    }
+}
+
+// remindDeadline sends the reminder for task's latest crossed threshold, if
+// it wasn't sent yet.
+func (b *bot) remindDeadline(task Task, thresholds []int) {
+    deadline, ok := missionDeadline(task)
+    if !ok {
+        return
+    }
+    total := deadline.Sub(task.ClaimedOn.Time)
+    elapsed := int(100 * time.Since(task.ClaimedOn.Time) / total)
+    crossed := 0
+    for _, t := range thresholds {
+        if elapsed >= t {
+            crossed = t
+        }
+    }
+    if crossed == 0 || crossed <= b.store.DeadlineReminder(task.ID) {
+        return
+    }
+    left := time.Until(deadline).Round(time.Minute)
+    notifyEvent(Event{
+        Type: EventDeadlineReminder,
+        Message: fmt.Sprintf("Mission %s (%s) is %d%% through its completion time: %s left, due %s.",
+            task.Title, task.ListingCodename, crossed, left, deadline.Local().Format("Mon 15:04")),
+        Task:   &task,
+        Target: task.ListingCodename,
+    })
+    if err := b.store.SetDeadlineReminder(task.ID, crossed); err != nil {
+        slog.Error("failed to record deadline reminder", "task_id", task.ID, "err", err)
+    }
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+    "expvar"
+    "fmt"
+    "log/slog"
+    "net"
+    "net/http"
+    "net/http/pprof"
+    "os"
+    "runtime"
+    "strings"
+    "time"
+)
+
+func init() {
+    // The goroutine count, to spot leaks in long runs without a profile.
+    expvar.Publish("goroutines", expvar.Func(func() any { return runtime.NumGoroutine() }))
+}
+
+// registerDebugHandlers adds the debugging endpoints to mux: net/http/pprof
+// under /debug/pprof/, expvar under /debug/vars and poll phase timings
+// under /debug/timings. The command line is served with secrets redacted.
+func registerDebugHandlers(mux *http.ServeMux) {
+    mux.HandleFunc("/debug/pprof/", pprof.Index)
+    mux.HandleFunc("/debug/pprof/cmdline", serveCmdline)
+    mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+    mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+    mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+    mux.HandleFunc("/debug/vars", serveVars)
+    mux.Handle("/debug/timings", timings)
+}
+
+// serveCmdline is pprof.Cmdline with secrets (a token passed as -t)
+// redacted.
+func serveCmdline(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("X-Content-Type-Options", "nosniff")
+    w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+    fmt.Fprint(w, redactSecrets(strings.Join(os.Args, "\x00")))
+}
+
+// serveVars is expvar.Handler with secrets redacted from the values, as
+// the "cmdline" var holds the command line.
+func serveVars(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "application/json; charset=utf-8")
+    fmt.Fprint(w, "{\n")
+    first := true
+    expvar.Do(func(kv expvar.KeyValue) {
+        if !first {
+            fmt.Fprint(w, ",\n")
+        }
+        first = false
+        fmt.Fprintf(w, "%q: %s", kv.Key, redactSecrets(kv.Value.String()))
+    })
+    fmt.Fprint(w, "\n}\n")
+}
+
+// debugHostCheck refuses requests that name a host other than localhost or
+// an IP address, so a web page can't reach the debug endpoints through DNS
+// rebinding, and requests a browser sent from another site.
+func debugHostCheck(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        host := r.Host
+        if h, _, err := net.SplitHostPort(host); err == nil {
+            host = h
+        }
+        host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+        if !isLoopbackHost(r.Host) && net.ParseIP(host) == nil {
+            http.Error(w, "host not allowed", http.StatusForbidden)
+            return
+        }
+        if isCrossSite(r) {
+            http.Error(w, "cross-site request refused", http.StatusForbidden)
+            return
+        }
+        next.ServeHTTP(w, r)
+    })
+}
+
+// serveDebug serves the debugging endpoints on ln (-debug-addr) without
+// authentication, for when the control API isn't enabled or a profile
+// should be taken without its token. See debugHostCheck for the requests
+// that are refused.
+func serveDebug(ln net.Listener) {
+    mux := http.NewServeMux()
+    registerDebugHandlers(mux)
+    srv := &http.Server{
+        Handler:           debugHostCheck(mux),
+        ReadHeaderTimeout: 10 * time.Second,
+        ErrorLog:          redactedErrorLog(),
+    }
+    if host, _, _ := net.SplitHostPort(ln.Addr().String()); !net.ParseIP(host).IsLoopback() {
+        slog.Warn("debug endpoints are reachable from other hosts without authentication", "addr", ln.Addr().String())
+    }
+    slog.Info("debug endpoints started", "addr", ln.Addr().String())
+    if err := srv.Serve(ln); err != nil {
+        slog.Error("debug endpoints stopped", "addr", ln.Addr().String(), "err", err)
+    }
+}
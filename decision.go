@@ -0,0 +1,164 @@
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "log/slog"
+    "net/http"
+    "net/url"
+    "sync"
+    "time"
+)
+
+// defaultDecisionTimeout bounds a decision webhook call. Claiming is a
+// race, so the service has to answer fast.
+const defaultDecisionTimeout = 2 * time.Second
+
+// maxDecisionRequests bounds the decision webhook calls in flight, so a
+// poll full of missions doesn't open a connection per mission.
+const maxDecisionRequests = 4
+
+// DecisionWebhookConfig delegates the claim decision for every mission to
+// an external HTTP service.
+type DecisionWebhookConfig struct {
+    URL string `json:"url"`
+    // Secret, if set, is sent as "Authorization: Bearer <secret>".
+    Secret string `json:"secret"`
+    // Timeout for each call (default 2s).
+    Timeout Duration `json:"timeout"`
+    // OnError is the decision when the service fails or times out:
+    // "skip" (default) or "claim".
+    OnError string `json:"on_error"`
+}
+
+// validate checks the webhook config and fills in the defaults.
+func (c *DecisionWebhookConfig) validate() error {
+    if c.URL == "" {
+        return nil
+    }
+    if u, err := url.Parse(c.URL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+        return fmt.Errorf("decision_webhook.url must be an http(s) URL")
+    }
+    switch c.OnError {
+    case "":
+        c.OnError = "skip"
+    case "claim", "skip":
+    default:
+        return fmt.Errorf("decision_webhook.on_error must be claim or skip")
+    }
+    if c.Timeout.Duration < 0 {
+        return fmt.Errorf("decision_webhook.timeout must not be negative")
+    } else if c.Timeout.Duration == 0 {
+        c.Timeout.Duration = defaultDecisionTimeout
+    }
+    return nil
+}
+
+// decisionRequest is the body POSTed to the decision webhook.
+type decisionRequest struct {
+    Task    Task            `json:"task"`
+    Context decisionContext `json:"context"`
+}
+
+// decisionContext is what the bot knows besides the task.
+type decisionContext struct {
+    Time time.Time `json:"time"`
+    // Pending is the number of claimable missions in this poll.
+    Pending int `json:"pending"`
+    // ClaimSlots is how many more missions may be claimed (-1 = unknown).
+    ClaimSlots    int     `json:"claimSlots"`
+    ClaimedToday  int     `json:"claimedToday"`
+    EarnedToday   float64 `json:"earnedToday"`
+    SessionClaims int     `json:"sessionClaims"`
+}
+
+// decisionResponse is the webhook's answer.
+type decisionResponse struct {
+    // Decision is "claim" or "skip".
+    Decision string `json:"decision"`
+    Reason   string `json:"reason"`
+}
+
+// askDecisionWebhook asks the webhook about every task, a few at a time,
+// and drops the ones it decides to skip.
+func (b *bot) askDecisionWebhook(tasks []Task, claimSlots int) []Task {
+    cfg := b.config().DecisionWebhook
+    if cfg.URL == "" || len(tasks) == 0 {
+        return tasks
+    }
+    claimedToday, earnedToday := b.store.ClaimsSince(b.dailyCaps.today())
+    ctx := decisionContext{
+        Time:          time.Now(),
+        Pending:       len(tasks),
+        ClaimSlots:    claimSlots,
+        ClaimedToday:  claimedToday,
+        EarnedToday:   earnedToday,
+        SessionClaims: b.state.Session().Claims,
+    }
+
+    claim := make([]bool, len(tasks))
+    sem := make(chan struct{}, maxDecisionRequests)
+    var wg sync.WaitGroup
+    for i, task := range tasks {
+        sem <- struct{}{}
+        wg.Add(1)
+        goSafe(func() {
+            defer wg.Done()
+            defer func() { <-sem }()
+            d, err := postDecision(cfg, decisionRequest{Task: task, Context: ctx})
+            if err != nil {
+                slog.Warn("decision webhook failed", "task_id", task.ID, "decision", cfg.OnError, "err", err)
+                claim[i] = cfg.OnError == "claim"
+                return
+            }
+            slog.Debug("decision webhook", "task_id", task.ID, "decision", d.Decision, "reason", d.Reason)
+            claim[i] = d.Decision == "claim"
+        })
+    }
+    wg.Wait()
+
+    kept := tasks[:0:0]
+    for i, task := range tasks {
+        if !claim[i] {
+            b.state.RecordSkips(SkipWebhook, []Task{task})
+            continue
+        }
+        kept = append(kept, task)
+    }
+    return kept
+}
+
+// postDecision sends one decision request.
+func postDecision(cfg DecisionWebhookConfig, body decisionRequest) (decisionResponse, error) {
+    var d decisionResponse
+    payload, err := json.Marshal(body)
+    if err != nil {
+        return d, err
+    }
+    req, err := http.NewRequest(http.MethodPost, cfg.URL, bytes.NewReader(payload))
+    if err != nil {
+        return d, err
+    }
+    req.Header.Set("Content-Type", "application/json")
+    if cfg.Secret != "" {
+        req.Header.Set("Authorization", "Bearer "+cfg.Secret)
+    }
+    // The service is the user's, not the platform: no platform TLS pins.
+    client := &http.Client{Timeout: cfg.Timeout.Duration, Transport: notifyTransport}
+    resp, err := client.Do(req)
+    if err != nil {
+        return d, err
+    }
+    defer closeBody(resp)
+    if resp.StatusCode != http.StatusOK {
+        return d, fmt.Errorf("status code %d", resp.StatusCode)
+    }
+    if err := json.NewDecoder(resp.Body).Decode(&d); err != nil {
+        return d, fmt.Errorf("decoding decision: %v", err)
+    }
+    if d.Decision != "claim" && d.Decision != "skip" {
+        return d, fmt.Errorf("invalid decision %q (want claim or skip)", d.Decision)
+    }
+    return d, nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+    "fmt"
+    "net/url"
+    "regexp"
+    "strings"
+    "sync"
+)
+
+// Default deep link templates. They mirror the web UI's pages and are
+// unverified against every platform release, hence configurable.
+const (
+    defaultMissionLink = "https://platform.synack.com/tasks/user/available_missions?taskId={task_id}"
+    defaultTargetLink  = "https://platform.synack.com/targets/{listing_uid}"
+)
+
+// DeepLinksConfig sets the platform links added to notifications about a
+// mission or target, so an alert opens the mission brief with one tap.
+type DeepLinksConfig struct {
+    // Mission is the URL template of a mission's page. {task_id},
+    // {campaign_uid}, {listing_uid}, {codename} and {organization_uid} are
+    // replaced with the mission's values.
+    Mission string `json:"mission"`
+    // Target is the URL template of a target's page; {listing_uid} is the
+    // target's slug and {codename} its codename.
+    Target string `json:"target"`
+    // Disabled leaves the links out.
+    Disabled bool `json:"disabled"`
+}
+
+// Link is a platform page an event is about.
+type Link struct {
+    // Name is "mission" or "target".
+    Name string
+    URL  string
+}
+
+// deepLinks are the link settings from the config file. They are replaced
+// on reload, so access is guarded by deepLinksMu.
+var (
+    deepLinksMu sync.RWMutex
+    deepLinks   DeepLinksConfig
+)
+
+// setDeepLinks replaces the link settings.
+func setDeepLinks(c DeepLinksConfig) {
+    deepLinksMu.Lock()
+    deepLinks = c
+    deepLinksMu.Unlock()
+}
+
+// linkPlaceholder matches the placeholders of a link template.
+var linkPlaceholder = regexp.MustCompile(`\{[a-z_]+\}`)
+
+// linkPlaceholders are the placeholders each template may use.
+var linkPlaceholders = map[string][]string{
+    "mission": {"{task_id}", "{campaign_uid}", "{listing_uid}", "{codename}", "{organization_uid}"},
+    "target":  {"{listing_uid}", "{codename}"},
+}
+
+// templates returns the mission and target templates, defaults filled in.
+func (c DeepLinksConfig) templates() (mission, target string) {
+    mission, target = c.Mission, c.Target
+    if mission == "" {
+        mission = defaultMissionLink
+    }
+    if target == "" {
+        target = defaultTargetLink
+    }
+    return mission, target
+}
+
+// validate checks the link templates.
+func (c DeepLinksConfig) validate() error {
+    mission, target := c.templates()
+    for _, t := range []struct{ name, tmpl string }{{"mission", mission}, {"target", target}} {
+        for _, p := range linkPlaceholder.FindAllString(t.tmpl, -1) {
+            if !containsFold(linkPlaceholders[t.name], p) {
+                return fmt.Errorf("deep_links.%s: unknown placeholder %s (want %s)", t.name, p, strings.Join(linkPlaceholders[t.name], ", "))
+            }
+        }
+        if u, err := url.Parse(linkPlaceholder.ReplaceAllString(t.tmpl, "x")); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+            return fmt.Errorf("deep_links.%s must be an http(s) URL", t.name)
+        }
+    }
+    return nil
+}
+
+// expandLink fills in a template; it returns "" when a value it uses is
+// unknown.
+func expandLink(tmpl string, values map[string]string) string {
+    ok := true
+    link := linkPlaceholder.ReplaceAllStringFunc(tmpl, func(p string) string {
+        v := values[p]
+        if v == "" {
+            ok = false
+        }
+        return url.PathEscape(v)
+    })
+    if !ok {
+        return ""
+    }
+    return link
+}
+
+// eventLinks returns the links to the mission and target an event is
+// about. Events without a mission name the target by its slug.
+func eventLinks(ev Event) []Link {
+    deepLinksMu.RLock()
+    c := deepLinks
+    deepLinksMu.RUnlock()
+    if c.Disabled {
+        return nil
+    }
+    mission, target := c.templates()
+    var links []Link
+    var targetValues map[string]string
+    if t := ev.Task; t != nil {
+        values := map[string]string{
+            "{task_id}":          t.ID,
+            "{campaign_uid}":     t.CampaignUid,
+            "{listing_uid}":      t.ListingUid,
+            "{codename}":         t.ListingCodename,
+            "{organization_uid}": t.OrganizationUid,
+        }
+        if link := expandLink(mission, values); link != "" {
+            links = append(links, Link{Name: "mission", URL: link})
+        }
+        targetValues = values
+    } else if ev.Target != "" {
+        targetValues = map[string]string{"{listing_uid}": ev.Target}
+    }
+    if link := expandLink(target, targetValues); targetValues != nil && link != "" {
+        links = append(links, Link{Name: "target", URL: link})
+    }
+    return links
+}
+
+// Text returns the event's message followed by its links, one per line,
+// for channels that show plain text.
+func (ev Event) Text() string {
+    var b strings.Builder
+    b.WriteString(ev.Message)
+    for _, l := range ev.Links {
+        fmt.Fprintf(&b, "\n%s%s: %s", strings.ToUpper(l.Name[:1]), l.Name[1:], l.URL)
+    }
+    return b.String()
+}
+
+// linkMap returns the event's links by name, for JSON payloads.
+func (ev Event) linkMap() map[string]string {
+    if len(ev.Links) == 0 {
+        return nil
+    }
+    m := map[string]string{}
+    for _, l := range ev.Links {
+        m[l.Name] = l.URL
+    }
+    return m
+}
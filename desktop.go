@@ -0,0 +1,38 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "strings"
+    "time"
+)
+
+// desktopEvents are the events shown as desktop notifications.
+var desktopEvents = []string{EventMissionClaimed, EventClaimFailed, EventTokenExpiring, EventTokenExpired}
+
+// desktopTimeout bounds showing one desktop notification.
+const desktopTimeout = 10 * time.Second
+
+// desktopNotifier shows events as native desktop notifications.
+type desktopNotifier struct{}
+
+// program implements programNotifier: showing a notification may take up
+// to desktopTimeout.
+func (desktopNotifier) program() string { return "desktop" }
+
+func (desktopNotifier) Notify(ev Event) error {
+    ctx, cancel := context.WithTimeout(context.Background(), desktopTimeout)
+    defer cancel()
+    cmd := desktopCommand(ctx, notificationTitle, ev.Message)
+    if cmd == nil {
+        return fmt.Errorf("desktop: no desktop notification support on this platform")
+    }
+    out, err := cmd.CombinedOutput()
+    if err != nil {
+        if msg := strings.TrimSpace(string(out)); msg != "" {
+            return fmt.Errorf("desktop: %s: %v: %s", cmd.Path, err, msg)
+        }
+        return fmt.Errorf("desktop: %s: %v", cmd.Path, err)
+    }
+    return nil
+}
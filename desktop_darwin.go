@@ -0,0 +1,18 @@
+//go:build darwin
+
+package main
+
+import (
+    "context"
+    "os/exec"
+)
+
+// desktopCommand shows a notification in the macOS Notification Center.
+// The texts are passed as script arguments, so they need no quoting.
+func desktopCommand(ctx context.Context, title, message string) *exec.Cmd {
+    return exec.CommandContext(ctx, "osascript",
+        "-e", "on run argv",
+        "-e", "display notification (item 2 of argv) with title (item 1 of argv)",
+        "-e", "end run",
+        title, message)
+}
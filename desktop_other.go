@@ -0,0 +1,13 @@
+//go:build !unix && !windows
+
+package main
+
+import (
+    "context"
+    "os/exec"
+)
+
+// desktopCommand is unsupported on this platform.
+func desktopCommand(ctx context.Context, title, message string) *exec.Cmd {
+    return nil
+}
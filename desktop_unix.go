@@ -0,0 +1,15 @@
+//go:build unix && !darwin
+
+package main
+
+import (
+    "context"
+    "os/exec"
+)
+
+// desktopCommand shows a notification through the freedesktop
+// notification service with notify-send (libnotify). "--" ends the options,
+// so a message starting with "-" isn't taken for one.
+func desktopCommand(ctx context.Context, title, message string) *exec.Cmd {
+    return exec.CommandContext(ctx, "notify-send", "--app-name="+title, "--", title, message)
+}
@@ -0,0 +1,26 @@
+//go:build windows
+
+package main
+
+import (
+    "context"
+    "os"
+    "os/exec"
+)
+
+// showToast shows a toast through the WinRT ToastNotificationManager. The
+// texts come from the environment, so they need no quoting.
+const showToast = `[void][Windows.UI.Notifications.ToastNotificationManager,Windows.UI.Notifications,ContentType=WindowsRuntime]; ` +
+    `$xml = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02); ` +
+    `$text = $xml.GetElementsByTagName('text'); ` +
+    `[void]$text.Item(0).AppendChild($xml.CreateTextNode($env:MISSION_BOT_TITLE)); ` +
+    `[void]$text.Item(1).AppendChild($xml.CreateTextNode($env:MISSION_BOT_MESSAGE)); ` +
+    `$toast = [Windows.UI.Notifications.ToastNotification]::new($xml); ` +
+    `[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('{1AC14E77-02E7-4E5D-B744-2EB1AE5198B7}\WindowsPowerShell\v1.0\powershell.exe').Show($toast)`
+
+// desktopCommand shows a Windows toast notification from PowerShell.
+func desktopCommand(ctx context.Context, title, message string) *exec.Cmd {
+    cmd := exec.CommandContext(ctx, "powershell", "-NoProfile", "-NonInteractive", "-Command", showToast)
+    cmd.Env = append(os.Environ(), "MISSION_BOT_TITLE="+title, "MISSION_BOT_MESSAGE="+message)
+    return cmd
+}
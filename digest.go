@@ -0,0 +1,140 @@
+package main
+
+import (
+    "fmt"
+    "log/slog"
+    "maps"
+    "slices"
+    "strings"
+    "sync"
+    "time"
+)
+
+// activity counts what the daily digest reports besides claims.
+var activity = &activityCounter{counts: activityCounts{statuses: map[int]int{}}}
+
+// activityCounts are totals of signups and API error responses.
+type activityCounts struct {
+    signups       int
+    failedSignups int
+    // statuses counts API responses by status code (4xx and 5xx only).
+    statuses map[int]int
+}
+
+// activityCounter keeps running totals. Digests report the difference
+// between two snapshots.
+type activityCounter struct {
+    mu     sync.Mutex
+    counts activityCounts
+}
+
+// AddSignup counts a signup attempt.
+func (a *activityCounter) AddSignup(err error) {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    if err != nil {
+        a.counts.failedSignups++
+    } else {
+        a.counts.signups++
+    }
+}
+
+// AddStatus counts an API response status.
+func (a *activityCounter) AddStatus(code int) {
+    if code < 400 {
+        return
+    }
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    a.counts.statuses[code]++
+}
+
+// snapshot returns a copy of the counts.
+func (a *activityCounter) snapshot() activityCounts {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    c := a.counts
+    c.statuses = maps.Clone(c.statuses)
+    return c
+}
+
+// since returns the counts added after the earlier snapshot.
+func (a activityCounts) since(earlier activityCounts) activityCounts {
+    d := activityCounts{signups: a.signups - earlier.signups, failedSignups: a.failedSignups - earlier.failedSignups, statuses: map[int]int{}}
+    for code, n := range a.statuses {
+        if n -= earlier.statuses[code]; n > 0 {
+            d.statuses[code] = n
+        }
+    }
+    return d
+}
+
+// sendDigests mails a digest of the past day to an email channel every
+// day at its digest_at time (local time).
+func (b *bot) sendDigests(n NotificationConfig) {
+    at, _ := parseClock(n.DigestAt) // validated by loadConfig
+    since := time.Now()
+    counts := activity.snapshot()
+    for {
+        next := startOfDay(time.Now(), time.Local).Add(time.Duration(at) * time.Minute)
+        if !next.After(time.Now()) {
+            next = next.AddDate(0, 0, 1)
+        }
+        time.Sleep(time.Until(next))
+
+        now := time.Now()
+        current := activity.snapshot()
+        body := renderDigest(b.store.Data().Claims, since, now, current.since(counts))
+        if err := sendMail(n.SMTP, emailSubjectBase+": daily digest", body); err != nil {
+            slog.Warn("failed to send digest", "channel", n.channelName(), "err", err)
+            continue // the next digest covers this day too
+        }
+        slog.Info("sent digest", "channel", n.channelName())
+        since, counts = now, current
+    }
+}
+
+// renderDigest summarizes the claims between from and to and the activity counts.
+func renderDigest(claims []claimRecord, from, to time.Time, counts activityCounts) string {
+    var b strings.Builder
+    fmt.Fprintf(&b, "Digest from %s to %s\n\n", from.Format("Mon 2 Jan 15:04"), to.Format("Mon 2 Jan 15:04"))
+
+    payouts := map[string]float64{}
+    var lines []string
+    for _, c := range claims {
+        if c.ClaimedAt.Before(from) || !c.ClaimedAt.Before(to) {
+            continue
+        }
+        payouts[c.Task.Payout.Currency] += c.Task.Payout.Amount
+        title := c.Task.Title
+        if title == "" {
+            title = c.Task.ID
+        }
+        lines = append(lines, fmt.Sprintf("- %s  %s  %s", c.ClaimedAt.Local().Format("Mon 15:04"), c.Task.Payout, title))
+    }
+    fmt.Fprintf(&b, "Missions claimed: %d\n", len(lines))
+    if len(payouts) > 0 {
+        var totals []string
+        for _, currency := range slices.Sorted(maps.Keys(payouts)) {
+            totals = append(totals, TaskPayout{Amount: payouts[currency], Currency: currency}.String())
+        }
+        fmt.Fprintf(&b, "Payouts: %s\n", strings.Join(totals, ", "))
+    }
+    for _, l := range lines {
+        b.WriteString(l + "\n")
+    }
+
+    fmt.Fprintf(&b, "\nTarget signups: %d, failed: %d\n", counts.signups, counts.failedSignups)
+    fmt.Fprintf(&b, "403 responses: %d\n", counts.statuses[403])
+    fmt.Fprintf(&b, "429 responses: %d\n", counts.statuses[429])
+    var other []string
+    for _, code := range slices.Sorted(maps.Keys(counts.statuses)) {
+        if code != 403 && code != 429 {
+            other = append(other, fmt.Sprintf("%d× %d", counts.statuses[code], code))
+        }
+    }
+    if len(other) > 0 {
+        fmt.Fprintf(&b, "Other error responses: %s\n", strings.Join(other, ", "))
+    }
+    return b.String()
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+    "crypto/tls"
+    "fmt"
+    "net"
+    "net/smtp"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Default SMTP ports: submission with STARTTLS, and implicit TLS.
+const (
+    defaultSMTPPort  = 587
+    implicitTLSPort  = 465
+    emailSubjectBase = "synack-mission-bot"
+)
+
+// SMTPConfig is the mail server and addresses of an "email" notification channel.
+type SMTPConfig struct {
+    Host string `json:"host"`
+    // Port defaults to 587 (STARTTLS); 465 uses implicit TLS.
+    Port     int      `json:"port"`
+    Username string   `json:"username"`
+    Password string   `json:"password"`
+    From     string   `json:"from"`
+    To       []string `json:"to"`
+}
+
+// validateEmail checks the email settings of notification channel i.
+func validateEmail(i int, n NotificationConfig) error {
+    switch {
+    case n.SMTP.Host == "" || n.SMTP.From == "" || len(n.SMTP.To) == 0:
+        return fmt.Errorf("notifications[%d]: email needs smtp.host, smtp.from and smtp.to", i)
+    case n.SMTP.Port < 0 || n.SMTP.Port > 65535:
+        return fmt.Errorf("notifications[%d]: invalid smtp.port %d", i, n.SMTP.Port)
+    case n.DigestOnly && n.DigestAt == "":
+        return fmt.Errorf("notifications[%d]: digest_only needs digest_at", i)
+    }
+    if n.DigestAt != "" {
+        if _, err := parseClock(n.DigestAt); err != nil {
+            return fmt.Errorf("notifications[%d]: digest_at: %v", i, err)
+        }
+    }
+    return nil
+}
+
+// emailNotifier mails every event, unless the channel only sends digests.
+type emailNotifier struct {
+    cfg NotificationConfig
+}
+
+func (n emailNotifier) Notify(ev Event) error {
+    if n.cfg.DigestOnly {
+        return nil
+    }
+    subject := emailSubjectBase + ": " + strings.ReplaceAll(ev.Type, "_", " ")
+    return sendMail(n.cfg.SMTP, subject, ev.Text())
+}
+
+// smtpAddr returns the host:port of the mail server.
+func (c SMTPConfig) smtpAddr() string {
+    port := c.Port
+    if port == 0 {
+        port = defaultSMTPPort
+    }
+    return net.JoinHostPort(c.Host, strconv.Itoa(port))
+}
+
+// sendMail sends a plain text mail. The connection is encrypted with
+// implicit TLS on port 465 and with STARTTLS when the server offers it.
+func sendMail(cfg SMTPConfig, subject, body string) error {
+    conn, err := net.DialTimeout("tcp", cfg.smtpAddr(), notifyTimeout)
+    if err != nil {
+        return fmt.Errorf("email: %v", err)
+    }
+    conn.SetDeadline(time.Now().Add(notifyTimeout))
+    tlsCfg := &tls.Config{ServerName: cfg.Host}
+    if cfg.Port == implicitTLSPort {
+        conn = tls.Client(conn, tlsCfg)
+    }
+    c, err := smtp.NewClient(conn, cfg.Host)
+    if err != nil {
+        conn.Close()
+        return fmt.Errorf("email: %v", err)
+    }
+    defer c.Close()
+    if ok, _ := c.Extension("STARTTLS"); ok && cfg.Port != implicitTLSPort {
+        if err := c.StartTLS(tlsCfg); err != nil {
+            return fmt.Errorf("email: STARTTLS: %v", err)
+        }
+    }
+    if cfg.Username != "" {
+        if err := c.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)); err != nil {
+            return fmt.Errorf("email: %v", err)
+        }
+    }
+    if err := c.Mail(cfg.From); err != nil {
+        return fmt.Errorf("email: %v", err)
+    }
+    for _, to := range cfg.To {
+        if err := c.Rcpt(to); err != nil {
+            return fmt.Errorf("email: %s: %v", to, err)
+        }
+    }
+    w, err := c.Data()
+    if err != nil {
+        return fmt.Errorf("email: %v", err)
+    }
+    if _, err := w.Write(formatMail(cfg, subject, body, time.Now())); err != nil {
+        return fmt.Errorf("email: %v", err)
+    }
+    if err := w.Close(); err != nil {
+        return fmt.Errorf("email: %v", err)
+    }
+    return c.Quit()
+}
+
+// formatMail builds the message with headers and CRLF line endings.
+func formatMail(cfg SMTPConfig, subject, body string, date time.Time) []byte {
+    var b strings.Builder
+    fmt.Fprintf(&b, "From: %s\r\n", cfg.From)
+    fmt.Fprintf(&b, "To: %s\r\n", strings.Join(cfg.To, ", "))
+    fmt.Fprintf(&b, "Subject: %s\r\n", subject)
+    fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+    b.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+    b.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+    b.WriteString("\r\n")
+    return []byte(b.String())
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "slices"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// defaultEventHookTimeout is the default timeout of event hooks, which are
+// meant to be quick integrations rather than long jobs.
+const defaultEventHookTimeout = time.Minute
+
+// defaultSound is the event hook sound that plays the system's own
+// notification sound.
+const defaultSound = "default"
+
+// EventHooksConfig lists commands run on notification events.
+type EventHooksConfig struct {
+    Hooks []EventHookConfig `json:"hooks"`
+    // MaxConcurrent limits how many event hooks run at once (default 2).
+    MaxConcurrent int `json:"max_concurrent"`
+    // OutputDir receives one log file per hook run (default: a temp directory).
+    OutputDir string `json:"output_dir"`
+}
+
+// EventHookConfig is a command run on events. The event is passed in the
+// environment and as JSON on stdin.
+type EventHookConfig struct {
+    HookConfig
+    // Events, if set, limits the hook to these event types.
+    Events []string `json:"events"`
+    // Sound, instead of a command, plays a sound file (or "default", the
+    // system's notification sound) with the platform's player.
+    Sound string `json:"sound"`
+}
+
+// validate checks the hooks, turns sounds into commands and applies the
+// default timeout.
+func (c *EventHooksConfig) validate() error {
+    for i, h := range c.Hooks {
+        if h.Sound != "" {
+            if len(h.Command) > 0 {
+                return fmt.Errorf("event_hooks.hooks[%d] has both a command and a sound", i)
+            }
+            if h.Sound != defaultSound {
+                if _, err := os.Stat(h.Sound); err != nil {
+                    return fmt.Errorf("event_hooks.hooks[%d].sound: %v", i, err)
+                }
+            }
+            cmd, env := soundCommand(h.Sound)
+            if cmd == nil {
+                return fmt.Errorf("event_hooks.hooks[%d].sound: playing sounds is not supported on this platform", i)
+            }
+            c.Hooks[i].Command, c.Hooks[i].Env = cmd, env
+            if h.Name == "" {
+                c.Hooks[i].Name = "sound " + filepath.Base(h.Sound)
+            }
+        } else if len(h.Command) == 0 {
+            return fmt.Errorf("event_hooks.hooks[%d] has no command or sound", i)
+        }
+        if err := validateEventTypes(fmt.Sprintf("event_hooks.hooks[%d].events", i), h.Events); err != nil {
+            return err
+        }
+        if h.Timeout.Duration == 0 {
+            c.Hooks[i].Timeout.Duration = defaultEventHookTimeout
+        }
+    }
+    return nil
+}
+
+// eventHookNotifier runs the event hooks for every event they accept.
+// Like channels without an event filter, hooks without one get every
+// event but the opt-in ones.
+type eventHookNotifier struct {
+    hooks      []EventHookConfig
+    supervisor *hookSupervisor
+}
+
+func (n eventHookNotifier) Notify(ev Event) error {
+    var stdin []byte
+    for _, h := range n.hooks {
+        if len(h.Events) > 0 && !slices.Contains(h.Events, ev.Type) || len(h.Events) == 0 && slices.Contains(optInEvents, ev.Type) {
+            continue
+        }
+        if stdin == nil {
+            var err error
+            if stdin, err = json.Marshal(eventPayload(ev)); err != nil {
+                return err
+            }
+        }
+        n.supervisor.Start(h.HookConfig, ev.Type, eventHookEnv(ev), stdin)
+    }
+    return nil
+}
+
+// eventPayload is the JSON document describing an event to hook commands
+// (on stdin) and message brokers.
+func eventPayload(ev Event) map[string]any {
+    payload := map[string]any{"type": ev.Type, "message": ev.Message, "time": ev.Time}
+    if ev.Target != "" {
+        payload["target"] = ev.Target
+    }
+    if ev.Status != 0 {
+        payload["status"] = ev.Status
+    }
+    if ev.Account != "" {
+        payload["account"] = ev.Account
+    }
+    if ev.Task != nil {
+        payload["task"] = ev.Task
+    }
+    if links := ev.linkMap(); links != nil {
+        payload["links"] = links
+    }
+    return payload
+}
+
+// eventHookEnv describes an event to hook commands.
+func eventHookEnv(ev Event) []string {
+    env := []string{
+        "MISSION_BOT_EVENT=" + ev.Type,
+        "MISSION_BOT_EVENT_MESSAGE=" + ev.Message,
+        "MISSION_BOT_EVENT_TIME=" + ev.Time.Format(time.RFC3339),
+    }
+    if ev.Target != "" {
+        env = append(env, "MISSION_BOT_EVENT_TARGET="+ev.Target)
+    }
+    if ev.Status != 0 {
+        env = append(env, "MISSION_BOT_EVENT_STATUS="+strconv.Itoa(ev.Status))
+    }
+    if ev.Account != "" {
+        env = append(env, "MISSION_BOT_EVENT_ACCOUNT="+ev.Account)
+    }
+    if t := ev.Task; t != nil {
+        env = append(env,
+            "MISSION_BOT_TASK_ID="+t.ID,
+            "MISSION_BOT_TASK_TITLE="+t.Title,
+            "MISSION_BOT_TASK_CATEGORY="+t.Category,
+            "MISSION_BOT_TASK_LISTING="+t.ListingCodename,
+            "MISSION_BOT_TASK_PAYOUT="+strconv.FormatFloat(t.Payout.Amount, 'f', -1, 64),
+            "MISSION_BOT_TASK_CURRENCY="+t.Payout.Currency,
+        )
+    }
+    for _, l := range ev.Links {
+        env = append(env, "MISSION_BOT_"+strings.ToUpper(l.Name)+"_URL="+l.URL)
+    }
+    return env
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+    "archive/zip"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "io"
+    "io/fs"
+    "os"
+    "path"
+    "path/filepath"
+    "slices"
+    "sort"
+    "strings"
+    "time"
+)
+
+// evidenceManifest describes an evidence package; it is stored in the ZIP
+// as manifest.json.
+type evidenceManifest struct {
+    TaskID   string         `json:"task_id"`
+    Title    string         `json:"title,omitempty"`
+    Target   string         `json:"target,omitempty"`
+    Category string         `json:"category,omitempty"`
+    Created  time.Time      `json:"created"`
+    Files    []evidenceFile `json:"files"`
+}
+
+// evidenceFile is one file of an evidence package.
+type evidenceFile struct {
+    // Path is the file's path in the ZIP.
+    Path string `json:"path"`
+    // Source is the file's path relative to the mission workspace.
+    Source string `json:"source"`
+    Size   int64  `json:"size"`
+    SHA256 string `json:"sha256"`
+}
+
+// Folders of an evidence package, by file extension. The write-up
+// (evidence.md) stays at the top; anything unrecognized goes to notes/.
+var evidenceFolders = map[string]string{
+    ".png": "screenshots", ".jpg": "screenshots", ".jpeg": "screenshots", ".gif": "screenshots",
+    ".webp": "screenshots", ".bmp": "screenshots", ".mp4": "screenshots", ".webm": "screenshots",
+    ".har": "requests", ".http": "requests", ".req": "requests", ".raw": "requests",
+    ".burp": "requests", ".xml": "requests", ".pcap": "requests", ".curl": "requests",
+}
+
+// evidenceSkipped are the workspace files written by -mission-dir that hold
+// the platform's own brief rather than evidence.
+var evidenceSkipped = []string{"mission.json", "mission.md"}
+
+// runClaims implements "claims package <task id>".
+func runClaims(args []string) error {
+    usage := fmt.Errorf("usage: %s claims package <task id> -dir <mission dir> [-out <file.zip>]", os.Args[0])
+    if len(args) == 0 || args[0] != "package" {
+        return usage
+    }
+    args = args[1:]
+    var id string
+    if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+        id, args = args[0], args[1:]
+    }
+    fs := flag.NewFlagSet("claims package", flag.ExitOnError)
+    dirFlag := fs.String("dir", "", "Mission directory written with -mission-dir")
+    outFlag := fs.String("out", "", "ZIP file to write (default: <TARGET>_<task id>_evidence.zip)")
+    fs.Parse(args)
+    if id == "" && fs.NArg() == 1 {
+        id = fs.Arg(0)
+    }
+    if id == "" || *dirFlag == "" {
+        return usage
+    }
+
+    workspace := filepath.Join(*dirFlag, filepath.Base(id))
+    manifest, err := newEvidenceManifest(workspace, id)
+    if err != nil {
+        return err
+    }
+    out := *outFlag
+    if out == "" {
+        out = evidencePackageName(manifest)
+    }
+    if err := writeEvidencePackage(out, workspace, manifest); err != nil {
+        os.Remove(out)
+        return err
+    }
+    fmt.Printf("%s: %d files\n", out, len(manifest.Files))
+    return nil
+}
+
+// evidencePackageName returns the default file name of a package:
+// <TARGET>_<task id>_evidence.zip, or <task id>_evidence.zip without a
+// known target.
+func evidencePackageName(m evidenceManifest) string {
+    name := unsafeFileChars.ReplaceAllString(m.TaskID, "_") + "_evidence.zip"
+    if m.Target != "" {
+        name = unsafeFileChars.ReplaceAllString(strings.ToUpper(m.Target), "_") + "_" + name
+    }
+    return name
+}
+
+// newEvidenceManifest lists the evidence in a mission workspace, with the
+// mission's title, target and category from its mission.json when present.
+func newEvidenceManifest(workspace, id string) (evidenceManifest, error) {
+    m := evidenceManifest{TaskID: id, Created: time.Now().UTC()}
+    if raw, err := os.ReadFile(filepath.Join(workspace, "mission.json")); err == nil {
+        var detail missionDetail
+        if err := json.Unmarshal(raw, &detail); err == nil {
+            m.Title, m.Target, m.Category = detail.Title, detail.ListingCodename, detail.Category
+        }
+    }
+
+    err := filepath.WalkDir(workspace, func(p string, d fs.DirEntry, err error) error {
+        if err != nil {
+            return err
+        }
+        rel, _ := filepath.Rel(workspace, p)
+        if strings.HasPrefix(d.Name(), ".") && rel != "." {
+            if d.IsDir() {
+                return filepath.SkipDir
+            }
+            return nil
+        }
+        if !d.Type().IsRegular() {
+            return nil
+        }
+        rel = filepath.ToSlash(rel)
+        if slices.Contains(evidenceSkipped, rel) || strings.HasSuffix(rel, "_evidence.zip") {
+            return nil
+        }
+        dest := rel
+        if rel != "evidence.md" {
+            folder, ok := evidenceFolders[strings.ToLower(path.Ext(rel))]
+            if !ok {
+                folder = "notes"
+            }
+            dest = folder + "/" + rel
+        }
+        sum, size, err := hashFile(p)
+        if err != nil {
+            return err
+        }
+        m.Files = append(m.Files, evidenceFile{Path: dest, Source: rel, Size: size, SHA256: sum})
+        return nil
+    })
+    if err != nil {
+        return m, err
+    }
+    if len(m.Files) == 0 {
+        return m, fmt.Errorf("no evidence in %s", workspace)
+    }
+    sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].Path < m.Files[j].Path })
+    return m, nil
+}
+
+// hashFile returns the SHA-256 and size of a file.
+func hashFile(name string) (string, int64, error) {
+    f, err := os.Open(name)
+    if err != nil {
+        return "", 0, err
+    }
+    defer f.Close()
+    h := sha256.New()
+    n, err := io.Copy(h, f)
+    return hex.EncodeToString(h.Sum(nil)), n, err
+}
+
+// writeEvidencePackage writes the files of m from workspace, and m itself as
+// manifest.json, to a new ZIP file (mode 0600; evidence is confidential).
+func writeEvidencePackage(name, workspace string, m evidenceManifest) error {
+    f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+    zw := zip.NewWriter(f)
+    for _, ef := range m.Files {
+        w, err := zw.CreateHeader(&zip.FileHeader{Name: ef.Path, Method: zip.Deflate, Modified: m.Created})
+        if err != nil {
+            return err
+        }
+        src, err := os.Open(filepath.Join(workspace, filepath.FromSlash(ef.Source)))
+        if err != nil {
+            return err
+        }
+        _, err = io.Copy(w, src)
+        src.Close()
+        if err != nil {
+            return err
+        }
+    }
+    w, err := zw.CreateHeader(&zip.FileHeader{Name: "manifest.json", Method: zip.Deflate, Modified: m.Created})
+    if err != nil {
+        return err
+    }
+    enc := json.NewEncoder(w)
+    enc.SetIndent("", "  ")
+    if err := enc.Encode(m); err != nil {
+        return err
+    }
+    if err := zw.Close(); err != nil {
+        return err
+    }
+    return f.Close()
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+    "encoding/json"
+    "io"
+    "log/slog"
+    "net/http"
+    "net/http/httptest"
+    "net/url"
+    "os"
+    "strconv"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+)
+
+func TestMain(m *testing.M) {
+    // The bot logs a lot; keep test output readable.
+    slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+    // Keep files the bot creates in the user's config directory (the
+    // anonymization secret) out of the real one.
+    dir, err := os.MkdirTemp("", "mission-bot-test")
+    if err != nil {
+        panic(err)
+    }
+    os.Setenv("XDG_CONFIG_HOME", dir)
+    os.Setenv("HOME", dir)
+    code := m.Run()
+    os.RemoveAll(dir)
+    os.Exit(code)
+}
+
+// fakePlatform is an in-process stand-in for the platform API. It serves
+// the published and claimed task lists, claim transitions, the unregistered
+// targets with their details, target signups and the account's activity
+// feed, accepts only its current token and can be told to fail requests.
+type fakePlatform struct {
+    srv *httptest.Server
+
+    mu sync.Mutex
+    // token is the only session token accepted; anything else gets 401.
+    token     string
+    published []Task
+    claimed   []Task
+    // claimStatus answers claims of a task ID with a fixed status code.
+    claimStatus map[string]int
+    // targets are the unregistered targets; signing up moves one to
+    // signedUp.
+    targets  []Target
+    signedUp []Target
+    // activity is the account's activity feed, newest first.
+    activity []platformActivity
+    // failures are status codes answered, in order, instead of handling
+    // the next requests to an endpoint ("tasks", "claimed_tasks", "claim",
+    // "targets", "target_detail", "signup", "activity").
+    failures map[string][]int
+    // requests counts the requests per endpoint.
+    requests map[string]int
+}
+
+// newFakePlatform starts a fake platform publishing tasks and routes all
+// platform requests of the shared HTTP client to it for the test.
+func newFakePlatform(t *testing.T, token string, tasks ...Task) *fakePlatform {
+    t.Helper()
+    fp := &fakePlatform{
+        token:       token,
+        published:   tasks,
+        claimStatus: map[string]int{},
+        failures:    map[string][]int{},
+        requests:    map[string]int{},
+    }
+    fp.srv = httptest.NewServer(fp)
+    t.Cleanup(fp.srv.Close)
+
+    target, _ := url.Parse(fp.srv.URL)
+    globalHTTPClient()
+    saved := sharedClient
+    sharedClient = &http.Client{Transport: redirectTransport{target: target}, Timeout: 5 * time.Second}
+    t.Cleanup(func() { sharedClient = saved })
+    return fp
+}
+
+// redirectTransport sends every request to target, whatever its host.
+type redirectTransport struct {
+    target *url.URL
+}
+
+func (r redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+    req = req.Clone(req.Context())
+    req.URL.Scheme = r.target.Scheme
+    req.URL.Host = r.target.Host
+    return http.DefaultTransport.RoundTrip(req)
+}
+
+// Fail makes the next requests to endpoint fail with the given status codes.
+func (fp *fakePlatform) Fail(endpoint string, codes ...int) {
+    fp.mu.Lock()
+    defer fp.mu.Unlock()
+    fp.failures[endpoint] = append(fp.failures[endpoint], codes...)
+}
+
+// SetToken replaces the accepted session token, as if the old one expired.
+func (fp *fakePlatform) SetToken(token string) {
+    fp.mu.Lock()
+    defer fp.mu.Unlock()
+    fp.token = token
+}
+
+// Claimed returns the IDs of the claimed tasks.
+func (fp *fakePlatform) Claimed() []string {
+    fp.mu.Lock()
+    defer fp.mu.Unlock()
+    var ids []string
+    for _, task := range fp.claimed {
+        ids = append(ids, task.ID)
+    }
+    return ids
+}
+
+// AddTargets publishes unregistered targets.
+func (fp *fakePlatform) AddTargets(targets ...Target) {
+    fp.mu.Lock()
+    defer fp.mu.Unlock()
+    fp.targets = append(fp.targets, targets...)
+}
+
+// AddActivity adds entries to the top of the account's activity feed.
+func (fp *fakePlatform) AddActivity(entries ...platformActivity) {
+    fp.mu.Lock()
+    defer fp.mu.Unlock()
+    fp.activity = append(entries, fp.activity...)
+}
+
+// SignedUp returns the slugs of the targets signed up for.
+func (fp *fakePlatform) SignedUp() []string {
+    fp.mu.Lock()
+    defer fp.mu.Unlock()
+    var slugs []string
+    for _, t := range fp.signedUp {
+        slugs = append(slugs, t.Slug)
+    }
+    return slugs
+}
+
+// Requests returns how many requests an endpoint received.
+func (fp *fakePlatform) Requests(endpoint string) int {
+    fp.mu.Lock()
+    defer fp.mu.Unlock()
+    return fp.requests[endpoint]
+}
+
+func (fp *fakePlatform) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    fp.mu.Lock()
+    defer fp.mu.Unlock()
+
+    var endpoint string
+    switch {
+    case r.Method == http.MethodGet && r.URL.Path == "/api/tasks/v2/tasks" && r.URL.Query().Get("status") == "CLAIMED":
+        endpoint = "claimed_tasks"
+    case r.Method == http.MethodGet && r.URL.Path == "/api/tasks/v2/tasks":
+        endpoint = "tasks"
+    case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/api/tasks/v1/organizations/") && strings.HasSuffix(r.URL.Path, "/transitions"):
+        endpoint = "claim"
+    case r.Method == http.MethodGet && r.URL.Path == "/api/targets":
+        endpoint = "targets"
+    case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/api/targets/") && strings.HasSuffix(r.URL.Path, "/signup"):
+        endpoint = "signup"
+    case r.Method == http.MethodGet && strings.Count(r.URL.Path, "/") == 3 && strings.HasPrefix(r.URL.Path, "/api/targets/"):
+        endpoint = "target_detail"
+    case r.Method == http.MethodGet && r.URL.Path == "/api/researcher/activity":
+        endpoint = "activity"
+    default:
+        http.NotFound(w, r)
+        return
+    }
+    fp.requests[endpoint]++
+
+    if r.Header.Get("Authorization") != "Bearer "+fp.token {
+        w.WriteHeader(http.StatusUnauthorized)
+        return
+    }
+    if codes := fp.failures[endpoint]; len(codes) > 0 {
+        fp.failures[endpoint] = codes[1:]
+        // Retry right away, so backoff doesn't slow the tests down.
+        w.Header().Set("Retry-After", "0")
+        w.WriteHeader(codes[0])
+        return
+    }
+
+    switch endpoint {
+    case "tasks":
+        if r.URL.Query().Get("page") != "1" {
+            writeTestJSON(w, []Task{})
+            return
+        }
+        listing := r.URL.Query().Get("listingUid")
+        tasks := []Task{}
+        for _, task := range fp.published {
+            if listing == "" || task.ListingUid == listing {
+                tasks = append(tasks, task)
+            }
+        }
+        writeTestJSON(w, tasks)
+    case "claimed_tasks":
+        page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+        perPage, _ := strconv.Atoi(r.URL.Query().Get("perPage"))
+        from := min((max(page, 1)-1)*perPage, len(fp.claimed))
+        writeTestJSON(w, append([]Task{}, fp.claimed[from:min(from+perPage, len(fp.claimed))]...))
+    case "claim":
+        parts := strings.Split(r.URL.Path, "/")
+        id := parts[len(parts)-2]
+        if code := fp.claimStatus[id]; code != 0 {
+            w.WriteHeader(code)
+            return
+        }
+        for i, task := range fp.published {
+            if task.ID == id {
+                fp.published = append(fp.published[:i:i], fp.published[i+1:]...)
+                fp.claimed = append(fp.claimed, task)
+                w.WriteHeader(http.StatusCreated)
+                return
+            }
+        }
+        w.WriteHeader(http.StatusPreconditionFailed)
+    case "targets":
+        if r.URL.Query().Get("pagination[page]") != "1" {
+            writeTestJSON(w, []Target{})
+            return
+        }
+        writeTestJSON(w, append([]Target{}, fp.targets...))
+    case "signup":
+        slug := strings.Split(r.URL.Path, "/")[3]
+        for i, t := range fp.targets {
+            if t.Slug == slug {
+                fp.targets = append(fp.targets[:i:i], fp.targets[i+1:]...)
+                fp.signedUp = append(fp.signedUp, t)
+                return
+            }
+        }
+        w.WriteHeader(http.StatusNotFound)
+    case "target_detail":
+        slug := strings.TrimPrefix(r.URL.Path, "/api/targets/")
+        for _, t := range append(append([]Target{}, fp.targets...), fp.signedUp...) {
+            if t.Slug == slug {
+                writeTestJSON(w, targetDetail{Slug: t.Slug, Codename: t.Codename, OrganizationID: t.OrganizationID, Category: t.Category})
+                return
+            }
+        }
+        w.WriteHeader(http.StatusNotFound)
+    case "activity":
+        writeTestJSON(w, append([]platformActivity{}, fp.activity...))
+    }
+}
+
+func writeTestJSON(w http.ResponseWriter, v any) {
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(v)
+}
+
+// testTask returns a published task with the given ID.
+func testTask(id string) Task {
+    return Task{
+        ID:              id,
+        Title:           "Mission " + id,
+        CampaignUid:     "campaign-" + id,
+        ListingUid:      "listing",
+        OrganizationUid: "org",
+        Payout:          TaskPayout{Amount: 50, Currency: "USD"},
+    }
+}
+
+// recordingNotifier collects the events sent during a test.
+type recordingNotifier struct {
+    mu     sync.Mutex
+    events []Event
+    // onEvent, if set, is called with every event.
+    onEvent func(Event)
+}
+
+func (n *recordingNotifier) Notify(ev Event) error {
+    n.mu.Lock()
+    n.events = append(n.events, ev)
+    onEvent := n.onEvent
+    n.mu.Unlock()
+    if onEvent != nil {
+        onEvent(ev)
+    }
+    return nil
+}
+
+// Types returns the types of the events received so far.
+func (n *recordingNotifier) Types() []string {
+    n.mu.Lock()
+    defer n.mu.Unlock()
+    var types []string
+    for _, ev := range n.events {
+        types = append(types, ev.Type)
+    }
+    return types
+}
+
+// recordNotifications routes notifications to a recordingNotifier for the test.
+func recordNotifications(t *testing.T) *recordingNotifier {
+    rec := &recordingNotifier{}
+    saved := notifier
+    notifier = rec
+    t.Cleanup(func() { notifier = saved })
+    return rec
+}
+
+// newTestBot returns a bot polling every few milliseconds with token. It
+// waits for a new token on 401, as with -token-file.
+func newTestBot(t *testing.T, token string) *bot {
+    t.Helper()
+    db, err := openStore("", nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return &bot{
+        tokens:       newTokenStore(token),
+        tokenFile:    "token.txt",
+        state:        &botState{},
+        store:        db,
+        taskInterval: 5 * time.Millisecond,
+        knownSlugs:   &sync.Map{},
+        targetCache:  newTargetCache(TargetCacheConfig{}),
+        claimRules:   &ruleSet{},
+        approvals:    newSignupApprovals(),
+        hotTasks:     make(chan []Task),
+        pollTasks:    make(chan struct{}, 1),
+        pollTargets:  make(chan struct{}, 1),
+    }
+}
+
+// runFor runs the bot's claim loop for d.
+func runFor(b *bot, d time.Duration) {
+    b.deadline = time.Now().Add(d)
+    b.mainLoop()
+}
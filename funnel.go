@@ -0,0 +1,113 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "os"
+    "text/tabwriter"
+    "time"
+)
+
+// claimFunnel counts the missions that reached each stage of the pipeline
+// from discovery to submission.
+type claimFunnel struct {
+    Discovered int
+    Attempted  int
+    Won        int
+    Submitted  int
+    // LostRaces are claims answered with 412 (claimed by someone else first).
+    LostRaces int
+    // Open are won missions still within their deadline; Overdue passed
+    // theirs without being submitted.
+    Open    int
+    Overdue int
+}
+
+// computeFunnel builds the funnel of the missions first seen, attempted or
+// claimed on or after since. Attempts are claim races the bot won or lost
+// (412); claims failing for other reasons never competed for the mission.
+func computeFunnel(data storeData, since, now time.Time) claimFunnel {
+    var f claimFunnel
+    for _, seen := range data.Discovered {
+        if !seen.Before(since) {
+            f.Discovered++
+        }
+    }
+    for _, t := range data.ClaimTimings {
+        if !t.Won && !t.AttemptedAt.Before(since) {
+            f.LostRaces++
+        }
+    }
+    for _, c := range data.Claims {
+        if c.ClaimedAt.Before(since) {
+            continue
+        }
+        f.Won++
+        deadline, ok := c.Deadline()
+        switch {
+        case !c.CompletedAt.IsZero():
+            f.Submitted++
+        case ok && now.After(deadline):
+            f.Overdue++
+        default:
+            f.Open++
+        }
+    }
+    f.Attempted = f.Won + f.LostRaces
+    return f
+}
+
+// runStats implements "stats funnel".
+func runStats(args []string) error {
+    if len(args) == 0 || args[0] != "funnel" {
+        return fmt.Errorf("usage: %s stats funnel -db <file> [-since 2006-01-02]", os.Args[0])
+    }
+    fs := flag.NewFlagSet("stats funnel", flag.ExitOnError)
+    dbFlag := fs.String("db", "", "Database file")
+    passphraseFlag := fs.String("db-passphrase-file", "", "File containing the database passphrase")
+    sinceFlag := fs.String("since", "", "Only include missions seen or claimed on or after this date (2006-01-02)")
+    fs.Parse(args[1:])
+    if *dbFlag == "" {
+        fs.Usage()
+        return fmt.Errorf("-db is required")
+    }
+    var since time.Time
+    if *sinceFlag != "" {
+        var err error
+        if since, err = time.ParseInLocation("2006-01-02", *sinceFlag, time.Local); err != nil {
+            return fmt.Errorf("invalid -since date: %v", err)
+        }
+    }
+    passphrase, err := readPassphrase(*passphraseFlag)
+    if err != nil {
+        return err
+    }
+    db, err := openStore(*dbFlag, passphrase)
+    if err != nil {
+        return err
+    }
+
+    w := newTable()
+    writeFunnel(w, computeFunnel(db.Data(), since, time.Now()))
+    return w.Flush()
+}
+
+// writeFunnel prints each stage with its conversion from the previous stage
+// and where the missions in between were lost.
+func writeFunnel(w *tabwriter.Writer, f claimFunnel) {
+    fmt.Fprintln(w, "STAGE\tMISSIONS\tOF PREVIOUS\tLOST")
+    fmt.Fprintf(w, "discovered\t%d\t\t\n", f.Discovered)
+    fmt.Fprintf(w, "attempted\t%d\t%s\t%d not attempted (filtered, paused, capped, claim failed or gone)\n",
+        f.Attempted, conversion(f.Attempted, f.Discovered), max(f.Discovered-f.Attempted, 0))
+    fmt.Fprintf(w, "won\t%d\t%s\t%d lost races (412)\n", f.Won, conversion(f.Won, f.Attempted), f.LostRaces)
+    fmt.Fprintf(w, "submitted\t%d\t%s\t%d past deadline, %d still open\n", f.Submitted, conversion(f.Submitted, f.Won), f.Overdue, f.Open)
+    fmt.Fprintf(w, "approved\t-\t\tnot reported by the platform API\n")
+}
+
+// conversion formats n as a percentage of of.
+func conversion(n, of int) string {
+    if of == 0 {
+        return "-"
+    }
+    return fmt.Sprintf("%.0f%%", 100*float64(n)/float64(of))
+}
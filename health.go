@@ -0,0 +1,140 @@
+package main
+
+import (
+    "encoding/json"
+    "flag"
+    "fmt"
+    "io"
+    "log/slog"
+    "net"
+    "net/http"
+    "os"
+    "time"
+)
+
+// healthMinAge is the least time without a successful mission poll before
+// the bot counts as stalled, however short the poll interval.
+const healthMinAge = 5 * time.Minute
+
+// healthReport is the answer of GET /healthz.
+type healthReport struct {
+    // Status is "ok", "starting" (no poll yet, within the allowed age),
+    // "degraded" (the platform is failing, which a restart won't fix) or
+    // "unhealthy".
+    Status         string     `json:"status"`
+    Problems       []string   `json:"problems,omitempty"`
+    LastPoll       *time.Time `json:"lastPoll,omitempty"`
+    LastTargetPoll *time.Time `json:"lastTargetPoll,omitempty"`
+    TokenValid     bool       `json:"tokenValid"`
+    TokenExpiry    *time.Time `json:"tokenExpiry,omitempty"`
+    Pause          string     `json:"pause,omitempty"`
+}
+
+// healthy reports whether the report should pass a health check.
+func (h healthReport) healthy() bool {
+    return h.Status != "unhealthy"
+}
+
+// healthMaxAge returns how long the bot may go without a successful mission
+// poll: maxAge if set, else three poll intervals but at least healthMinAge.
+func (b *bot) healthMaxAge(maxAge time.Duration) time.Duration {
+    if maxAge > 0 {
+        return maxAge
+    }
+    return max(3*b.taskInterval, healthMinAge)
+}
+
+// health checks that the mission poll succeeded recently and the token
+// hasn't expired.
+func (b *bot) health(now time.Time, maxAge time.Duration) healthReport {
+    snap := b.state.Snapshot()
+    h := healthReport{Status: "ok", TokenValid: snap.TokenExpiry.IsZero() || now.Before(snap.TokenExpiry)}
+    if !snap.LastPoll.IsZero() {
+        h.LastPoll = &snap.LastPoll
+    }
+    if !snap.LastTargetPoll.IsZero() {
+        h.LastTargetPoll = &snap.LastTargetPoll
+    }
+    if !snap.TokenExpiry.IsZero() {
+        h.TokenExpiry = &snap.TokenExpiry
+    }
+    if snap.Pause != nil {
+        h.Pause = snap.Pause.String()
+    }
+
+    maxAge = b.healthMaxAge(maxAge)
+    switch since := b.state.Session().Start; {
+    case snap.LastPoll.IsZero() && now.Sub(since) < maxAge:
+        h.Status = "starting"
+    case snap.LastPoll.IsZero():
+        h.Problems = append(h.Problems, fmt.Sprintf("no successful mission poll since the start %s ago", now.Sub(since).Round(time.Second)))
+    case now.Sub(snap.LastPoll) > maxAge:
+        h.Problems = append(h.Problems, fmt.Sprintf("last successful mission poll %s ago", now.Sub(snap.LastPoll).Round(time.Second)))
+    }
+    if len(h.Problems) > 0 {
+        h.Status = "unhealthy"
+        if snap.Pause != nil && snap.Pause.Reason == PauseMaintenance {
+            h.Status = "degraded"
+        }
+    }
+    if !h.TokenValid {
+        h.Status = "unhealthy"
+        h.Problems = append(h.Problems, "session token expired at "+snap.TokenExpiry.Local().Format(time.DateTime))
+    }
+    return h
+}
+
+// serveHealth serves GET /healthz on ln without authentication, for
+// container health checks and liveness probes: 200 while healthy, 503
+// otherwise, with the report as JSON either way.
+func (b *bot) serveHealth(ln net.Listener, maxAge time.Duration) {
+    mux := http.NewServeMux()
+    mux.HandleFunc("/healthz", getOnly(func(w http.ResponseWriter, r *http.Request) {
+        h := b.health(time.Now(), maxAge)
+        w.Header().Set("Content-Type", "application/json")
+        w.Header().Set("Cache-Control", "no-store")
+        if !h.healthy() {
+            w.WriteHeader(http.StatusServiceUnavailable)
+        }
+        json.NewEncoder(w).Encode(h)
+    }))
+    srv := &http.Server{
+        Handler:           mux,
+        ReadHeaderTimeout: 10 * time.Second,
+        ErrorLog:          redactedErrorLog(),
+    }
+    slog.Info("health endpoint started", "addr", ln.Addr().String())
+    if err := srv.Serve(ln); err != nil {
+        slog.Error("health endpoint stopped", "addr", ln.Addr().String(), "err", err)
+    }
+}
+
+// runHealthcheck implements "healthcheck": it asks a running bot's
+// /healthz (-health-addr) and exits non-zero unless the bot is healthy,
+// for Docker's HEALTHCHECK and exec liveness probes.
+func runHealthcheck(args []string) error {
+    fs := flag.NewFlagSet("healthcheck", flag.ExitOnError)
+    addrFlag := fs.String("addr", "127.0.0.1:8779", "The bot's -health-addr")
+    timeoutFlag := fs.Duration("timeout", 5*time.Second, "Give up after this long")
+    fs.Parse(args)
+
+    host, port, err := net.SplitHostPort(*addrFlag)
+    if err != nil {
+        return fmt.Errorf("invalid -addr: %v", err)
+    }
+    if host == "" || host == "0.0.0.0" || host == "::" {
+        host = "127.0.0.1"
+    }
+    client := &http.Client{Timeout: *timeoutFlag}
+    resp, err := client.Get("http://" + net.JoinHostPort(host, port) + "/healthz")
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+    os.Stdout.Write(body)
+    if resp.StatusCode != http.StatusOK {
+        return fmt.Errorf("unhealthy (%s)", resp.Status)
+    }
+    return nil
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+    "bytes"
+    "context"
+    "fmt"
+    "log/slog"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "regexp"
+    "slices"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+// Defaults for supervised hooks.
+const (
+    defaultHookTimeout       = 30 * time.Minute
+    defaultHookMaxConcurrent = 2
+    hookRetryDelay           = 30 * time.Second
+    hookKillGrace            = 10 * time.Second
+)
+
+// Hook run statuses.
+const (
+    hookQueued    = "queued"
+    hookRunning   = "running"
+    hookSucceeded = "succeeded"
+    hookFailed    = "failed"
+    hookTimedOut  = "timed out"
+)
+
+// hookRun is the state of one supervised hook execution.
+type hookRun struct {
+    ID       int
+    Hook     string
+    Subject  string
+    Status   string
+    Attempt  int
+    Started  time.Time
+    Finished time.Time
+    ExitCode int
+    LogFile  string
+}
+
+// lastHookRunID numbers hook runs across all supervisors.
+var lastHookRunID atomic.Int64
+
+// hookSupervisor runs external commands with a concurrency limit, per-run
+// timeouts, retries and captured output, and reports each run's progress.
+type hookSupervisor struct {
+    sem       chan struct{}
+    outputDir string
+    report    func(hookRun)
+    running   sync.WaitGroup
+}
+
+// newHookSupervisor creates a supervisor. report is called on every status change.
+func newHookSupervisor(maxConcurrent int, outputDir string, report func(hookRun)) *hookSupervisor {
+    if maxConcurrent <= 0 {
+        maxConcurrent = defaultHookMaxConcurrent
+    }
+    return &hookSupervisor{sem: make(chan struct{}, maxConcurrent), outputDir: outputDir, report: report}
+}
+
+// Start runs hook in the background for subject (e.g. a target slug) with the
+// given extra environment variables and, if not nil, stdin. Hooks inherit
+// the environment except for the variables holding secrets.
+func (s *hookSupervisor) Start(hook HookConfig, subject string, env []string, stdin []byte) {
+    run := hookRun{ID: int(lastHookRunID.Add(1)), Hook: hook.displayName(), Subject: subject, Status: hookQueued}
+    s.report(run)
+
+    s.running.Add(1)
+    goSafe(func() {
+        defer s.running.Done()
+        for run.Attempt = 1; run.Attempt <= hook.Retries+1; run.Attempt++ {
+            if run.Attempt > 1 {
+                time.Sleep(hookRetryDelay)
+            }
+            // The slot is only held while the hook runs, so other hooks
+            // can run while this one waits to be retried.
+            s.sem <- struct{}{}
+            s.runOnce(hook, &run, env, stdin)
+            <-s.sem
+            if run.Status == hookSucceeded {
+                return
+            }
+        }
+        slog.Warn("hook failed", "hook", run.Hook, "subject", subject, "status", run.Status, "exit_code", run.ExitCode, "log", run.LogFile)
+    })
+}
+
+// Wait waits until the started hooks have finished, retries included.
+func (s *hookSupervisor) Wait() {
+    s.running.Wait()
+}
+
+// runOnce executes one attempt of the hook and updates run.
+func (s *hookSupervisor) runOnce(hook HookConfig, run *hookRun, env []string, stdin []byte) {
+    timeout := hook.Timeout.Duration
+    if timeout <= 0 {
+        timeout = defaultHookTimeout
+    }
+    ctx, cancel := context.WithTimeout(context.Background(), timeout)
+    defer cancel()
+
+    run.Status, run.Started, run.Finished, run.ExitCode = hookRunning, time.Now(), time.Time{}, 0
+    run.LogFile = ""
+
+    cmd := exec.CommandContext(ctx, hook.Command[0], hook.Command[1:]...)
+    // Hooks don't get the bot's secrets, such as the control token or the
+    // database passphrase.
+    cmd.Env = slices.Concat(environWithoutSecrets(), hook.Env, env)
+    if stdin != nil {
+        cmd.Stdin = bytes.NewReader(stdin)
+    }
+    cmd.WaitDelay = hookKillGrace
+    killProcessTree(cmd)
+
+    if logFile, err := s.openLog(run); err != nil {
+        slog.Warn("failed to create hook log", "hook", run.Hook, "err", err)
+    } else {
+        defer logFile.Close()
+        cmd.Stdout, cmd.Stderr = logFile, logFile
+        run.LogFile = logFile.Name()
+    }
+    s.report(*run)
+
+    err := cmd.Run()
+    run.Finished = time.Now()
+    switch {
+    case ctx.Err() == context.DeadlineExceeded:
+        run.Status = hookTimedOut
+        run.ExitCode = -1
+    case err != nil:
+        run.Status = hookFailed
+        run.ExitCode = -1
+        if exitErr, ok := err.(*exec.ExitError); ok {
+            run.ExitCode = exitErr.ExitCode()
+        }
+    default:
+        run.Status = hookSucceeded
+    }
+    s.report(*run)
+}
+
+// unsafeFileChars are replaced in log file names.
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// openLog creates the output capture file for a run. Without an output
+// directory, logs go to a directory only this user can access under the
+// shared temporary directory. Files are created anew, never opened through
+// a link or over an existing file.
+func (s *hookSupervisor) openLog(run *hookRun) (*os.File, error) {
+    dir := s.outputDir
+    if dir == "" {
+        dir = filepath.Join(os.TempDir(), fmt.Sprintf("synack-mission-bot-hooks-%d", os.Getuid()))
+        if err := preparePrivateDir(dir); err != nil {
+            return nil, err
+        }
+    } else if err := os.MkdirAll(dir, 0700); err != nil {
+        return nil, err
+    }
+    pattern := fmt.Sprintf("%s-%s-%s-%d-*.log",
+        time.Now().Format("20060102-150405"),
+        unsafeFileChars.ReplaceAllString(run.Subject, "_"),
+        unsafeFileChars.ReplaceAllString(run.Hook, "_"),
+        run.Attempt)
+    return os.CreateTemp(dir, pattern)
+}
+
+// displayName is how a hook is shown in logs and the dashboard.
+func (h HookConfig) displayName() string {
+    if h.Name != "" {
+        return h.Name
+    }
+    return filepath.Base(h.Command[0])
+}
+
+// targetHookEnv describes a target to hook commands.
+func targetHookEnv(t Target) []string {
+    return []string{
+        "MISSION_BOT_TARGET_SLUG=" + t.Slug,
+        "MISSION_BOT_TARGET_CODENAME=" + t.Codename,
+        "MISSION_BOT_TARGET_ORGANIZATION=" + t.OrganizationID,
+        "MISSION_BOT_TARGET_CATEGORY=" + t.Category.Name,
+    }
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package main
+
+import "os/exec"
+
+// killProcessTree is a no-op where process groups are unavailable; only the
+// hook process itself is killed on cancellation.
+func killProcessTree(cmd *exec.Cmd) {}
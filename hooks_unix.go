@@ -0,0 +1,18 @@
+//go:build unix
+
+package main
+
+import (
+    "os/exec"
+    "syscall"
+)
+
+// killProcessTree makes cmd run in its own process group and, on
+// cancellation, kills the whole group so scanners started by a hook script
+// don't outlive it.
+func killProcessTree(cmd *exec.Cmd) {
+    cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+    cmd.Cancel = func() error {
+        return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+    }
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+    "encoding/base64"
+    "encoding/json"
+    "fmt"
+    "strings"
+    "time"
+)
+
+// roleClaimKeys are the JWT claims that may carry the researcher's roles or scopes.
+var roleClaimKeys = []string{"roles", "role", "scope", "scopes", "permissions", "authorities"}
+
+// decodeTokenClaims returns the (unverified) claims section of a JWT.
+func decodeTokenClaims(token string) (map[string]interface{}, error) {
+    parts := strings.Split(token, ".")
+    if len(parts) != 3 {
+        return nil, fmt.Errorf("token is not a JWT")
+    }
+    payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+    if err != nil {
+        return nil, fmt.Errorf("decoding token claims: %v", err)
+    }
+    claims := map[string]interface{}{}
+    if err := json.Unmarshal(payload, &claims); err != nil {
+        return nil, fmt.Errorf("decoding token claims: %v", err)
+    }
+    return claims, nil
+}
+
+// tokenRoles collects every role/scope string found in the claims. Space separated
+// scope strings are split into individual entries.
+func tokenRoles(claims map[string]interface{}) []string {
+    var roles []string
+    for _, key := range roleClaimKeys {
+        switch v := claims[key].(type) {
+        case string:
+            roles = append(roles, strings.Fields(v)...)
+        case []interface{}:
+            for _, r := range v {
+                if s, ok := r.(string); ok {
+                    roles = append(roles, s)
+                }
+            }
+        }
+    }
+    return roles
+}
+
+// featureAllowed reports whether the token carries at least one of the required roles.
+// A feature without required roles is always allowed, and so is a token whose roles
+// cannot be determined, since gating on missing information would only hide features.
+func featureAllowed(token string, required []string) (bool, string) {
+    if len(required) == 0 {
+        return true, ""
+    }
+    claims, err := decodeTokenClaims(token)
+    if err != nil {
+        return true, ""
+    }
+    roles := tokenRoles(claims)
+    if len(roles) == 0 {
+        return true, ""
+    }
+    for _, r := range roles {
+        if containsFold(required, r) {
+            return true, ""
+        }
+    }
+    return false, fmt.Sprintf("token has none of the required roles %v", required)
+}
+
+// tokenExpiry returns the time encoded in the token's exp claim.
+func tokenExpiry(token string) (time.Time, bool) {
+    claims, err := decodeTokenClaims(token)
+    if err != nil {
+        return time.Time{}, false
+    }
+    exp, ok := claims["exp"].(float64)
+    if !ok {
+        return time.Time{}, false
+    }
+    return time.Unix(int64(exp), 0), true
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+    "bufio"
+    "bytes"
+    "flag"
+    "fmt"
+    "log/slog"
+    "os"
+    "os/exec"
+    "strings"
+)
+
+// Keychain entries: the session token and the login credentials of
+// -auto-login, both under one service. With an accounts list, every account
+// has its own, named by keychainEntry.
+const (
+    keychainService      = "synack-mission-bot"
+    keychainAccount      = "session-token"
+    keychainLoginAccount = "login"
+)
+
+// keychainLabels describe the keychain entries where the backend shows one.
+var keychainLabels = map[string]string{
+    keychainAccount:      "session token",
+    keychainLoginAccount: "login credentials",
+}
+
+// keychainEntry returns the name of the keychain entry of kind
+// (keychainAccount or keychainLoginAccount) for account, e.g.
+// "session-token/eu"; without an account, it is kind itself.
+func keychainEntry(kind, account string) string {
+    if account == "" {
+        return kind
+    }
+    return kind + "/" + account
+}
+
+// keychainLabel describes a keychain entry where the backend shows one.
+func keychainLabel(entry string) string {
+    kind, account, ok := strings.Cut(entry, "/")
+    if !ok {
+        return keychainLabels[kind]
+    }
+    return keychainLabels[kind] + " (" + account + ")"
+}
+
+// runKeychainTool runs one of the platform's credential tools, feeding it
+// stdin (so secrets stay off the command line where possible) and returning
+// its trimmed output.
+func runKeychainTool(stdin string, name string, args ...string) (string, error) {
+    cmd := exec.Command(name, args...)
+    cmd.Stdin = strings.NewReader(stdin)
+    var stdout, stderr bytes.Buffer
+    cmd.Stdout = &stdout
+    cmd.Stderr = &stderr
+    if err := cmd.Run(); err != nil {
+        if msg := strings.TrimSpace(stderr.String()); msg != "" {
+            return "", fmt.Errorf("%s: %w: %s", name, err, msg)
+        }
+        return "", fmt.Errorf("%s: %w", name, err)
+    }
+    return strings.TrimSpace(stdout.String()), nil
+}
+
+// loadKeychainToken returns the token saved in the OS keychain for the
+// account this process runs.
+func loadKeychainToken() (string, error) {
+    token, err := keychainGet(keychainEntry(keychainAccount, accountName))
+    if err != nil {
+        return "", fmt.Errorf("reading token from keychain: %w", err)
+    }
+    if token == "" {
+        return "", fmt.Errorf("no token saved in keychain")
+    }
+    return token, nil
+}
+
+// syncTokensToKeychain saves every new token (from prompts, the token file,
+// Telegram or the token daemon) to the OS keychain, so the next start picks
+// up the latest one.
+func syncTokensToKeychain(tokens TokenProvider) {
+    token := tokens.Get()
+    for {
+        token = tokens.WaitChange(token)
+        if err := keychainSet(keychainEntry(keychainAccount, accountName), token); err != nil {
+            slog.Warn("failed to save token to keychain", "err", err)
+            continue
+        }
+        slog.Debug("saved token to keychain")
+    }
+}
+
+// runKeychain implements the "keychain" subcommand. "keychain set" reads a
+// token from stdin and saves it, so it never appears in shell history or ps;
+// "keychain set-login" does the same for the login credentials. Both take
+// -account to save the entry of one account of an accounts list.
+func runKeychain(args []string) error {
+    if len(args) == 0 || (args[0] != "set" && args[0] != "set-login") {
+        return fmt.Errorf("usage: %s keychain set|set-login [-account <name>]", os.Args[0])
+    }
+    fs := flag.NewFlagSet("keychain "+args[0], flag.ExitOnError)
+    accountFlag := fs.String("account", "", "Save the entry of this account of the config's accounts list")
+    fs.Parse(args[1:])
+    if args[0] == "set-login" {
+        return runKeychainSetLogin(*accountFlag)
+    }
+    fmt.Fprint(os.Stderr, "Paste your session token:\n> ")
+    line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+    token := strings.TrimSpace(line)
+    if token == "" {
+        if err != nil {
+            return err
+        }
+        return fmt.Errorf("no token given")
+    }
+    entry := keychainEntry(keychainAccount, *accountFlag)
+    if err := keychainSet(entry, token); err != nil {
+        return err
+    }
+    slog.Info("token saved to keychain", "service", keychainService, "entry", entry)
+    return nil
+}
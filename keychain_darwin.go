@@ -0,0 +1,15 @@
+//go:build darwin
+
+package main
+
+// keychainGet reads a secret from the macOS login keychain.
+func keychainGet(account string) (string, error) {
+    return runKeychainTool("", "security", "find-generic-password", "-s", keychainService, "-a", account, "-w")
+}
+
+// keychainSet stores a secret in the macOS login keychain. security(1) only
+// accepts the password as an argument, so it is briefly visible to ps.
+func keychainSet(account, secret string) error {
+    _, err := runKeychainTool("", "security", "add-generic-password", "-U", "-s", keychainService, "-a", account, "-w", secret)
+    return err
+}
@@ -0,0 +1,17 @@
+//go:build !unix && !windows
+
+package main
+
+import "errors"
+
+var errNoKeychain = errors.New("no OS keychain support on this platform")
+
+// keychainGet is unsupported on this platform.
+func keychainGet(account string) (string, error) {
+    return "", errNoKeychain
+}
+
+// keychainSet is unsupported on this platform.
+func keychainSet(account, secret string) error {
+    return errNoKeychain
+}
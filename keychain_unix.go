@@ -0,0 +1,15 @@
+//go:build unix && !darwin
+
+package main
+
+// keychainGet reads a secret from the Secret Service (GNOME Keyring,
+// KWallet) via libsecret's secret-tool.
+func keychainGet(account string) (string, error) {
+    return runKeychainTool("", "secret-tool", "lookup", "service", keychainService, "account", account)
+}
+
+// keychainSet stores a secret in the Secret Service; secret-tool reads it from stdin.
+func keychainSet(account, secret string) error {
+    _, err := runKeychainTool(secret, "secret-tool", "store", "--label=Synack mission bot "+keychainLabel(account), "service", keychainService, "account", account)
+    return err
+}
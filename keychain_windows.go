@@ -0,0 +1,21 @@
+//go:build windows
+
+package main
+
+// Windows Credential Manager is reached through the WinRT PasswordVault
+// from PowerShell; secrets are passed on stdin.
+const loadPasswordVault = `[void][Windows.Security.Credentials.PasswordVault,Windows.Security.Credentials,ContentType=WindowsRuntime]; $v = New-Object Windows.Security.Credentials.PasswordVault; `
+
+// keychainGet reads a secret from Windows Credential Manager.
+func keychainGet(account string) (string, error) {
+    script := loadPasswordVault + `$c = $v.Retrieve('` + keychainService + `', '` + account + `'); $c.RetrievePassword(); $c.Password`
+    return runKeychainTool("", "powershell", "-NoProfile", "-NonInteractive", "-Command", script)
+}
+
+// keychainSet stores a secret in Windows Credential Manager, replacing any previous one.
+func keychainSet(account, secret string) error {
+    script := loadPasswordVault + `$t = [Console]::In.ReadToEnd().Trim(); ` +
+        `$v.Add((New-Object Windows.Security.Credentials.PasswordCredential('` + keychainService + `', '` + account + `', $t)))`
+    _, err := runKeychainTool(secret, "powershell", "-NoProfile", "-NonInteractive", "-Command", script)
+    return err
+}
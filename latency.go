@@ -0,0 +1,106 @@
+package main
+
+import (
+    "crypto/tls"
+    "fmt"
+    "log/slog"
+    "net"
+    "slices"
+    "time"
+)
+
+// platformAddr is the host the latency probe connects to.
+const platformAddr = "platform.synack.com:443"
+
+// Latency probing: how many samples the percentiles cover, the probe timeout,
+// and the RTT above which a closer host is recommended.
+const (
+    latencySamples   = 24
+    latencyTimeout   = 10 * time.Second
+    latencyAdviseRTT = 80 * time.Millisecond
+)
+
+// latencyReport summarizes recent connection timings to the platform.
+type latencyReport struct {
+    Samples int
+    RTT     time.Duration // p50 TCP connect time, roughly one round trip
+    TLS     time.Duration // p50 TLS handshake time
+    Advice  string
+}
+
+// String formats the report for status output.
+func (r latencyReport) String() string {
+    return fmt.Sprintf("p50 RTT %s, TLS handshake %s (%d samples) — %s",
+        r.RTT.Round(time.Millisecond), r.TLS.Round(time.Millisecond), r.Samples, r.Advice)
+}
+
+// probeLatency opens one fresh connection to the platform and returns the TCP
+// connect and TLS handshake durations. The probe always connects directly,
+// even when a proxy is configured, since it measures this host's placement.
+func probeLatency() (rtt, handshake time.Duration, err error) {
+    dialer := net.Dialer{Timeout: latencyTimeout}
+    start := time.Now()
+    conn, err := dialer.Dial("tcp", platformAddr)
+    if err != nil {
+        return 0, 0, err
+    }
+    defer conn.Close()
+    rtt = time.Since(start)
+
+    host, _, _ := net.SplitHostPort(platformAddr)
+    cfg := tlsConfig.Clone()
+    if cfg == nil {
+        cfg = &tls.Config{}
+    }
+    cfg.ServerName = host
+    tlsConn := tls.Client(conn, cfg)
+    tlsConn.SetDeadline(time.Now().Add(latencyTimeout))
+    start = time.Now()
+    if err := tlsConn.Handshake(); err != nil {
+        return 0, 0, err
+    }
+    return rtt, time.Since(start), nil
+}
+
+// median returns the middle value of durations.
+func median(durations []time.Duration) time.Duration {
+    sorted := slices.Clone(durations)
+    slices.Sort(sorted)
+    return sorted[len(sorted)/2]
+}
+
+// latencyAdvice turns a p50 RTT into a recommendation. Claim races are
+// decided by latency, so hosts far from the platform lose most of them.
+func latencyAdvice(rtt time.Duration) string {
+    switch {
+    case rtt <= latencyAdviseRTT/2:
+        return "this host is well placed"
+    case rtt <= latencyAdviseRTT:
+        return "acceptable; a us-east host may win more claim races"
+    default:
+        return "consider a us-east host"
+    }
+}
+
+// monitorLatency probes the platform every interval and publishes the
+// rolling report to the bot state.
+func monitorLatency(interval time.Duration, state *botState) {
+    var rtts, handshakes []time.Duration
+    for {
+        rtt, handshake, err := probeLatency()
+        if err != nil {
+            slog.Warn("latency probe failed", "addr", platformAddr, "err", err)
+        } else {
+            rtts = append(rtts, rtt)
+            handshakes = append(handshakes, handshake)
+            if len(rtts) > latencySamples {
+                rtts, handshakes = rtts[1:], handshakes[1:]
+            }
+            report := latencyReport{Samples: len(rtts), RTT: median(rtts), TLS: median(handshakes)}
+            report.Advice = latencyAdvice(report.RTT)
+            state.SetLatency(report)
+            slog.Debug("latency probe", "rtt", rtt, "tls_handshake", handshake, "p50_rtt", report.RTT)
+        }
+        time.Sleep(interval)
+    }
+}
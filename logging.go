@@ -0,0 +1,185 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "io"
+    "log/slog"
+    "net/http"
+    "os"
+    "strings"
+    "sync"
+    "time"
+)
+
+// setupLogging installs the default structured logger. level is one of
+// debug/info/warn/error and format is text or json. Output of the standard
+// log package is routed through the same handler.
+func setupLogging(w io.Writer, level, format string) error {
+    var lvl slog.Level
+    if err := lvl.UnmarshalText([]byte(level)); err != nil {
+        return fmt.Errorf("invalid log level %q (want debug, info, warn or error)", level)
+    }
+    opts := &slog.HandlerOptions{Level: lvl}
+
+    var h slog.Handler
+    switch format {
+    case "text":
+        h = slog.NewTextHandler(w, opts)
+    case "json":
+        h = slog.NewJSONHandler(w, opts)
+    default:
+        return fmt.Errorf("invalid log format %q (want text or json)", format)
+    }
+    slog.SetDefault(slog.New(h))
+    return nil
+}
+
+// errorRecorder passes records on to the wrapped handler and also hands
+// warnings and errors, formatted as "message key=value ...", to record.
+type errorRecorder struct {
+    slog.Handler
+    record func(at time.Time, line string)
+}
+
+// recordErrors wraps the default logger so warnings and errors also go to record.
+func recordErrors(record func(at time.Time, line string)) {
+    slog.SetDefault(slog.New(errorRecorder{Handler: slog.Default().Handler(), record: record}))
+}
+
+func (h errorRecorder) Handle(ctx context.Context, r slog.Record) error {
+    if r.Level >= slog.LevelWarn {
+        var b strings.Builder
+        b.WriteString(r.Message)
+        r.Attrs(func(a slog.Attr) bool {
+            fmt.Fprintf(&b, " %s=%v", a.Key, a.Value.Resolve())
+            return true
+        })
+        h.record(r.Time, b.String())
+    }
+    return h.Handler.Handle(ctx, r)
+}
+
+func (h errorRecorder) WithAttrs(attrs []slog.Attr) slog.Handler {
+    return errorRecorder{Handler: h.Handler.WithAttrs(attrs), record: h.record}
+}
+
+func (h errorRecorder) WithGroup(name string) slog.Handler {
+    return errorRecorder{Handler: h.Handler.WithGroup(name), record: h.record}
+}
+
+// logResponse records the outcome of an API call: successful responses at
+// debug level, everything else as a warning. Every outcome also goes to the
+// audit log.
+func logResponse(endpoint string, resp *http.Response, attrs ...any) {
+    level := slog.LevelDebug
+    if resp.StatusCode >= 400 {
+        level = slog.LevelWarn
+    }
+    args := append([]any{
+        "endpoint", endpoint,
+        "method", resp.Request.Method,
+        "status", resp.StatusCode,
+    }, attrs...)
+    slog.Log(resp.Request.Context(), level, "api response", args...)
+    audit.Record(AuditAPICall, args...)
+    activity.AddStatus(resp.StatusCode)
+}
+
+// rotatingLog is the log file of -log-file. It is rotated when it grows
+// past maxSize or gets older than maxAge: path becomes path.1, path.1
+// becomes path.2 and so on, keeping at most backups old files.
+type rotatingLog struct {
+    path    string
+    maxSize int64
+    maxAge  time.Duration
+    backups int
+
+    mu     sync.Mutex
+    f      *os.File
+    size   int64
+    opened time.Time
+}
+
+// logFile is the open -log-file, reopened on reload; nil when logging to
+// stderr.
+var logFile *rotatingLog
+
+// openRotatingLog opens (or creates) the log file at path for appending.
+// A maxSize or maxAge of zero doesn't limit.
+func openRotatingLog(path string, maxSize int64, maxAge time.Duration, backups int) (*rotatingLog, error) {
+    l := &rotatingLog{path: path, maxSize: maxSize, maxAge: maxAge, backups: backups}
+    if err := l.open(); err != nil {
+        return nil, err
+    }
+    return l, nil
+}
+
+// open opens the file. The caller holds l.mu, or l isn't shared yet.
+func (l *rotatingLog) open() error {
+    f, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+    if err != nil {
+        return err
+    }
+    info, err := f.Stat()
+    if err != nil {
+        f.Close()
+        return err
+    }
+    l.f, l.size, l.opened = f, info.Size(), time.Now()
+    return nil
+}
+
+func (l *rotatingLog) Write(p []byte) (int, error) {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if l.size > 0 && ((l.maxSize > 0 && l.size+int64(len(p)) > l.maxSize) || (l.maxAge > 0 && time.Since(l.opened) > l.maxAge)) {
+        if err := l.rotate(); err != nil {
+            fmt.Fprintf(os.Stderr, "rotating log file %s: %v\n", l.path, err)
+        }
+    }
+    if l.f == nil {
+        if err := l.open(); err != nil {
+            return 0, err
+        }
+    }
+    n, err := l.f.Write(p)
+    l.size += int64(n)
+    return n, err
+}
+
+// rotate moves the current file aside and starts a new one. The caller
+// holds l.mu.
+func (l *rotatingLog) rotate() error {
+    l.f.Close()
+    l.f = nil
+    os.Remove(fmt.Sprintf("%s.%d", l.path, l.backups))
+    for i := l.backups - 1; i >= 1; i-- {
+        os.Rename(fmt.Sprintf("%s.%d", l.path, i), fmt.Sprintf("%s.%d", l.path, i+1))
+    }
+    var err error
+    if l.backups > 0 {
+        err = os.Rename(l.path, l.path+".1")
+    } else {
+        err = os.Remove(l.path)
+    }
+    if err != nil {
+        return err
+    }
+    return l.open()
+}
+
+// Reopen closes and reopens the file, for a file moved aside by an
+// external logrotate. A nil rotatingLog does nothing.
+func (l *rotatingLog) Reopen() error {
+    if l == nil {
+        return nil
+    }
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if l.f != nil {
+        l.f.Close()
+        l.f = nil
+    }
+    return l.open()
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+    "bufio"
+    "bytes"
+    "crypto/hmac"
+    "crypto/sha1"
+    "encoding/base32"
+    "encoding/binary"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "log/slog"
+    "net/http"
+    "net/http/cookiejar"
+    "net/url"
+    "os"
+    "regexp"
+    "strings"
+    "time"
+)
+
+// Endpoints of the web login. The flow mirrors what the browser does: the
+// login page hands out a CSRF token, email and password earn a progress
+// token, the TOTP code then earns a grant token, and the platform trades
+// that for a session JWT.
+const (
+    loginPageEndpoint    = "https://login.synack.com/"
+    authenticateEndpoint = "https://login.synack.com/api/authenticate"
+    tokenGrantEndpoint   = "https://platform.synack.com/token"
+)
+
+// loginRetryInterval is the least time between failed logins, so a wrong
+// password or a changed login flow doesn't hammer the login service.
+const loginRetryInterval = 10 * time.Minute
+
+// TOTP parameters of the authenticator app codes (RFC 6238 defaults).
+const (
+    totpPeriod = 30
+    totpDigits = 6
+)
+
+// loginCredentials are the account's login details, kept in the OS keychain
+// as JSON.
+type loginCredentials struct {
+    Email    string `json:"email"`
+    Password string `json:"password"`
+    // TOTPSecret is the base32 secret behind the authenticator app's codes
+    // (the text form of the QR code shown when enabling two-factor login).
+    TOTPSecret string `json:"totp_secret"`
+}
+
+// csrfMeta finds the CSRF token in the login page.
+var csrfMeta = regexp.MustCompile(`<meta[^>]+name="csrf-token"[^>]+content="([^"]+)"`)
+
+// totpCode returns the TOTP code of secret for time t.
+func totpCode(secret string, t time.Time) (string, error) {
+    secret = strings.ToUpper(strings.NewReplacer(" ", "", "-", "").Replace(secret))
+    key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(secret, "="))
+    if err != nil {
+        return "", fmt.Errorf("invalid TOTP secret: %v", err)
+    }
+    mac := hmac.New(sha1.New, key)
+    binary.Write(mac, binary.BigEndian, uint64(t.Unix()/totpPeriod))
+    sum := mac.Sum(nil)
+    offset := sum[len(sum)-1] & 0x0f
+    code := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+    mod := uint32(1)
+    for range totpDigits {
+        mod *= 10
+    }
+    return fmt.Sprintf("%0*d", totpDigits, code%mod), nil
+}
+
+// loadLoginCredentials reads the login credentials of the account this
+// process runs from the OS keychain.
+func loadLoginCredentials() (loginCredentials, error) {
+    var creds loginCredentials
+    raw, err := keychainGet(keychainEntry(keychainLoginAccount, accountName))
+    if err != nil {
+        return creds, fmt.Errorf("reading login credentials from keychain: %w", err)
+    }
+    if raw == "" {
+        return creds, errors.New("no login credentials saved in keychain (run keychain set-login)")
+    }
+    if err := json.Unmarshal([]byte(raw), &creds); err != nil {
+        return creds, fmt.Errorf("reading login credentials from keychain: %v", err)
+    }
+    registerSecret(creds.Password)
+    registerSecret(creds.TOTPSecret)
+    return creds, nil
+}
+
+// login signs in with creds and returns a fresh session token.
+func login(creds loginCredentials) (string, error) {
+    jar, _ := cookiejar.New(nil)
+    client := &http.Client{Transport: globalHTTPClient().Transport, Timeout: requestTimeout, Jar: jar}
+
+    page, err := loginGet(client, loginPageEndpoint, nil)
+    if err != nil {
+        return "", fmt.Errorf("login page: %w", err)
+    }
+    m := csrfMeta.FindSubmatch(page)
+    if m == nil {
+        return "", errors.New("login page: no CSRF token found")
+    }
+    csrf := string(m[1])
+
+    var progress struct {
+        ProgressToken string `json:"progress_token"`
+    }
+    if err := loginPost(client, csrf, map[string]string{"email": creds.Email, "password": creds.Password}, &progress); err != nil {
+        return "", fmt.Errorf("password: %w", err)
+    }
+    if progress.ProgressToken == "" {
+        return "", errors.New("password: no progress token in the response")
+    }
+
+    code, err := totpCode(creds.TOTPSecret, time.Now())
+    if err != nil {
+        return "", err
+    }
+    var grant struct {
+        GrantToken string `json:"grant_token"`
+    }
+    if err := loginPost(client, csrf, map[string]string{"authy_token": code, "progress_token": progress.ProgressToken}, &grant); err != nil {
+        return "", fmt.Errorf("two-factor code: %w", err)
+    }
+    if grant.GrantToken == "" {
+        return "", errors.New("two-factor code: no grant token in the response")
+    }
+
+    body, err := loginGet(client, tokenGrantEndpoint+"?grant_token="+url.QueryEscape(grant.GrantToken), map[string]string{"X-Requested-With": "XMLHttpRequest"})
+    if err != nil {
+        return "", fmt.Errorf("token grant: %w", err)
+    }
+    var token struct {
+        AccessToken string `json:"access_token"`
+    }
+    if err := json.Unmarshal(body, &token); err != nil || token.AccessToken == "" {
+        return "", errors.New("token grant: no access token in the response")
+    }
+    return token.AccessToken, nil
+}
+
+// loginGet fetches a page of the login flow.
+func loginGet(client *http.Client, u string, header map[string]string) ([]byte, error) {
+    req, err := http.NewRequest(http.MethodGet, u, nil)
+    if err != nil {
+        return nil, err
+    }
+    for k, v := range header {
+        req.Header.Set(k, v)
+    }
+    resp, err := client.Do(req)
+    if err != nil {
+        return nil, err
+    }
+    defer closeBody(resp)
+    if resp.StatusCode != http.StatusOK {
+        return nil, fmt.Errorf("status code %d", resp.StatusCode)
+    }
+    return io.ReadAll(resp.Body)
+}
+
+// loginPost sends one step of the login flow and decodes its answer into v.
+func loginPost(client *http.Client, csrf string, payload any, v any) error {
+    body, err := json.Marshal(payload)
+    if err != nil {
+        return err
+    }
+    req, err := http.NewRequest(http.MethodPost, authenticateEndpoint, bytes.NewReader(body))
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Content-Type", "application/json")
+    req.Header.Set("X-CSRF-Token", csrf)
+    resp, err := client.Do(req)
+    if err != nil {
+        return err
+    }
+    defer closeBody(resp)
+    if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+        return fmt.Errorf("status code %d", resp.StatusCode)
+    }
+    return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// autoLogin mints a new session token with the keychain's login
+// credentials (-auto-login). After a failed login, no further attempt is
+// made for loginRetryInterval. The caller holds b.rotateMu.
+func (b *bot) autoLogin() (string, bool) {
+    if !b.autoLoginEnabled || time.Since(b.loginFailedAt) < loginRetryInterval {
+        return "", false
+    }
+    creds, err := loadLoginCredentials()
+    if err == nil {
+        var token string
+        if token, err = login(creds); err == nil {
+            return token, true
+        }
+    }
+    b.loginFailedAt = time.Now()
+    slog.Error("automatic login failed", "err", err, "retry_in", loginRetryInterval)
+    return "", false
+}
+
+// runKeychainSetLogin implements "keychain set-login": it reads the email,
+// password and TOTP secret from stdin, checks the secret, and saves them
+// for -auto-login, as the credentials of account if one is given.
+func runKeychainSetLogin(account string) error {
+    r := bufio.NewReader(os.Stdin)
+    ask := func(prompt string) string {
+        fmt.Fprintf(os.Stderr, "%s:\n> ", prompt)
+        line, _ := r.ReadString('\n')
+        return strings.TrimSpace(line)
+    }
+    creds := loginCredentials{
+        Email:      ask("Email"),
+        Password:   ask("Password"),
+        TOTPSecret: ask("TOTP secret (the base32 text behind the authenticator QR code)"),
+    }
+    if creds.Email == "" || creds.Password == "" || creds.TOTPSecret == "" {
+        return errors.New("email, password and TOTP secret are all required")
+    }
+    if _, err := totpCode(creds.TOTPSecret, time.Now()); err != nil {
+        return err
+    }
+    raw, err := json.Marshal(creds)
+    if err != nil {
+        return err
+    }
+    entry := keychainEntry(keychainLoginAccount, account)
+    if err := keychainSet(entry, string(raw)); err != nil {
+        return err
+    }
+    slog.Info("login credentials saved to keychain", "service", keychainService, "entry", entry)
+    return nil
+}
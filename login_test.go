@@ -0,0 +1,40 @@
+package main
+
+import (
+    "testing"
+    "time"
+)
+
+func TestTOTPCode(t *testing.T) {
+    // The SHA-1 test vectors of RFC 6238, appendix B, for the ASCII secret
+    // "12345678901234567890", truncated to the 6 digits the platform uses.
+    const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+    tests := []struct {
+        unix int64
+        want string
+    }{
+        {59, "287082"},
+        {1111111109, "081804"},
+        {1111111111, "050471"},
+        {1234567890, "005924"},
+        {2000000000, "279037"},
+        {20000000000, "353130"},
+    }
+    for _, tt := range tests {
+        got, err := totpCode(secret, time.Unix(tt.unix, 0))
+        if err != nil {
+            t.Fatal(err)
+        }
+        if got != tt.want {
+            t.Errorf("totpCode(T=%d) = %s, want %s", tt.unix, got, tt.want)
+        }
+    }
+
+    // Authenticator apps show secrets lower case and in groups.
+    if got, err := totpCode("gezd gnbv-gy3t qojq gezd gnbv gy3t qojq", time.Unix(59, 0)); err != nil || got != "287082" {
+        t.Errorf("totpCode() of a grouped lower case secret = %s, %v, want 287082", got, err)
+    }
+    if _, err := totpCode("not base32!", time.Now()); err == nil {
+        t.Error("totpCode() accepted an invalid secret")
+    }
+}
@@ -0,0 +1,634 @@
+package main
+
+import (
+    "encoding/base64"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "slices"
+    "strconv"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestMainLoopClaimsPublishedMissions(t *testing.T) {
+    fp := newFakePlatform(t, "token", testTask("1"), testTask("2"))
+    rec := recordNotifications(t)
+    b := newTestBot(t, "token")
+
+    runFor(b, 200*time.Millisecond)
+
+    if got := fp.Claimed(); !slices.Equal(got, []string{"1", "2"}) {
+        t.Errorf("claimed %v, want [1 2]", got)
+    }
+    for _, id := range []string{"1", "2"} {
+        if !b.store.HasClaimed(id) {
+            t.Errorf("task %s not recorded as claimed", id)
+        }
+    }
+    if n := fp.Requests("claim"); n != 2 {
+        t.Errorf("%d claim requests, want 2", n)
+    }
+    if got := rec.Types(); !slices.Equal(got, []string{EventMissionClaimed, EventMissionClaimed}) {
+        t.Errorf("events %v, want two %s", got, EventMissionClaimed)
+    }
+}
+
+func TestMainLoopSkipsMissionsClaimedByOthers(t *testing.T) {
+    fp := newFakePlatform(t, "token", testTask("gone"), testTask("2"))
+    fp.claimStatus["gone"] = http.StatusPreconditionFailed
+    recordNotifications(t)
+    b := newTestBot(t, "token")
+
+    runFor(b, 200*time.Millisecond)
+
+    if got := fp.Claimed(); !slices.Equal(got, []string{"2"}) {
+        t.Errorf("claimed %v, want [2]", got)
+    }
+    if b.store.HasClaimed("gone") {
+        t.Error("mission answered with 412 recorded as claimed")
+    }
+    if p := b.state.Paused(); p != nil {
+        t.Errorf("claiming paused after 412: %s", p)
+    }
+}
+
+func TestMainLoopRetriesClaimsAfterServerErrors(t *testing.T) {
+    fp := newFakePlatform(t, "token", testTask("1"))
+    fp.Fail("claim", http.StatusInternalServerError)
+    rec := recordNotifications(t)
+    b := newTestBot(t, "token")
+
+    runFor(b, 200*time.Millisecond)
+
+    if got := fp.Claimed(); !slices.Equal(got, []string{"1"}) {
+        t.Errorf("claimed %v, want [1] after the failed attempt", got)
+    }
+    if !slices.Contains(rec.Types(), EventClaimFailed) {
+        t.Errorf("events %v, want a %s", rec.Types(), EventClaimFailed)
+    }
+    if _, ok := b.store.Forbidden(testTask("1")); ok {
+        t.Error("mission answered with 500 recorded as forbidden")
+    }
+    if p := b.state.Paused(); p != nil {
+        t.Errorf("claiming paused after one 500: %s", p)
+    }
+}
+
+func TestMainLoopCoolsDownAfter403Streak(t *testing.T) {
+    var tasks []Task
+    for _, id := range []string{"1", "2", "3", "4", "5", "6"} {
+        tasks = append(tasks, testTask(id))
+    }
+    fp := newFakePlatform(t, "token", tasks...)
+    for _, task := range tasks {
+        fp.claimStatus[task.ID] = http.StatusForbidden
+    }
+    rec := recordNotifications(t)
+    b := newTestBot(t, "token")
+    b.forbiddenCooldown = time.Hour
+
+    runFor(b, 200*time.Millisecond)
+
+    p := b.state.Paused()
+    if p == nil || p.Reason != PauseCircuitOpen {
+        t.Fatalf("pause = %v, want %s", p, PauseCircuitOpen)
+    }
+    if n := fp.Requests("claim"); n != 5 {
+        t.Errorf("%d claim requests, want 5 before cooling down", n)
+    }
+    if !slices.Contains(rec.Types(), EventPaused) {
+        t.Errorf("no %s event in %v", EventPaused, rec.Types())
+    }
+}
+
+func TestMainLoopStopsAfter403StreakWithoutCooldown(t *testing.T) {
+    var tasks []Task
+    for _, id := range []string{"1", "2", "3", "4", "5"} {
+        tasks = append(tasks, testTask(id))
+    }
+    fp := newFakePlatform(t, "token", tasks...)
+    for _, task := range tasks {
+        fp.claimStatus[task.ID] = http.StatusForbidden
+    }
+    rec := recordNotifications(t)
+    b := newTestBot(t, "token")
+
+    done := make(chan struct{})
+    go func() {
+        runFor(b, time.Minute)
+        close(done)
+    }()
+    select {
+    case <-done:
+    case <-time.After(5 * time.Second):
+        t.Fatal("bot did not stop after 5 consecutive 403 responses")
+    }
+    if !slices.Contains(rec.Types(), EventBotStopped) {
+        t.Errorf("no %s event in %v", EventBotStopped, rec.Types())
+    }
+}
+
+func TestMainLoopRemembersForbiddenCampaigns(t *testing.T) {
+    gated, sameCampaign := testTask("1"), testTask("2")
+    sameCampaign.CampaignUid = gated.CampaignUid
+    fp := newFakePlatform(t, "token", gated, sameCampaign, testTask("3"))
+    fp.claimStatus[gated.ID] = http.StatusForbidden
+    recordNotifications(t)
+    b := newTestBot(t, "token")
+
+    runFor(b, 100*time.Millisecond)
+
+    if n := fp.Requests("claim"); n != 2 {
+        t.Errorf("%d claim requests, want 2 (the gated campaign once, then task 3)", n)
+    }
+    if got := fp.Claimed(); !slices.Equal(got, []string{"3"}) {
+        t.Errorf("claimed %v, want [3]", got)
+    }
+}
+
+func TestMainLoopRefreshesTokenOn401(t *testing.T) {
+    fp := newFakePlatform(t, "fresh", testTask("1"))
+    rec := recordNotifications(t)
+    b := newTestBot(t, "expired")
+    rec.onEvent = func(ev Event) {
+        if ev.Type == EventTokenExpired {
+            // As if the user pasted a new token into the token file.
+            go b.tokens.Set("fresh")
+        }
+    }
+
+    runFor(b, 300*time.Millisecond)
+
+    if !slices.Contains(rec.Types(), EventTokenExpired) {
+        t.Errorf("no %s event in %v", EventTokenExpired, rec.Types())
+    }
+    if got := fp.Claimed(); !slices.Equal(got, []string{"1"}) {
+        t.Errorf("claimed %v with the refreshed token, want [1]", got)
+    }
+}
+
+func TestMainLoopBacksOffWithoutNewToken(t *testing.T) {
+    fp := newFakePlatform(t, "fresh", testTask("1"))
+    recordNotifications(t)
+    b := newTestBot(t, "expired")
+    // Prompted without a terminal, as a daemon is.
+    b.tokenFile = ""
+    null, err := os.Open(os.DevNull)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer null.Close()
+    savedStdin, savedDelay := os.Stdin, tokenRetryDelay
+    os.Stdin, tokenRetryDelay = null, 100*time.Millisecond
+    t.Cleanup(func() { os.Stdin, tokenRetryDelay = savedStdin, savedDelay })
+
+    runFor(b, 300*time.Millisecond)
+
+    if n := fp.Requests("tasks"); n > 4 {
+        t.Errorf("%d task requests with a rejected token, want one per retry delay", n)
+    }
+    if got := b.tokens.Get(); got != "expired" {
+        t.Errorf("token %q after empty prompts, want the old one kept", got)
+    }
+}
+
+func TestMainLoopSwitchesToSpareTokenOn401(t *testing.T) {
+    fp := newFakePlatform(t, "spare-2", testTask("1"))
+    rec := recordNotifications(t)
+    b := newTestBot(t, "expired")
+    path := filepath.Join(t.TempDir(), "spares.txt")
+    if err := os.WriteFile(path, []byte("# rejected too\nspare-1\n\nspare-2\n"), 0600); err != nil {
+        t.Fatal(err)
+    }
+    spares, err := loadSpareTokens(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    b.spareTokens = spares
+
+    runFor(b, 300*time.Millisecond)
+
+    if got := fp.Claimed(); !slices.Equal(got, []string{"1"}) {
+        t.Errorf("claimed %v with the spare token, want [1]", got)
+    }
+    if slices.Contains(rec.Types(), EventTokenExpired) {
+        t.Errorf("asked for a new token with a usable spare left: %v", rec.Types())
+    }
+    if !slices.Contains(rec.Types(), EventTokenRotated) {
+        t.Errorf("no %s event in %v", EventTokenRotated, rec.Types())
+    }
+}
+
+func TestMainLoopRefreshesTokenOnClaim401(t *testing.T) {
+    fp := newFakePlatform(t, "token", testTask("1"))
+    fp.Fail("claim", http.StatusUnauthorized)
+    rec := recordNotifications(t)
+    b := newTestBot(t, "token")
+    rec.onEvent = func(ev Event) {
+        if ev.Type == EventTokenExpired {
+            go b.tokens.Set("token-2")
+        }
+    }
+
+    // The platform accepts the new token once the bot asks for it.
+    go func() {
+        for fp.Requests("claim") == 0 {
+            time.Sleep(time.Millisecond)
+        }
+        fp.SetToken("token-2")
+    }()
+    runFor(b, 300*time.Millisecond)
+
+    if got := fp.Claimed(); !slices.Equal(got, []string{"1"}) {
+        t.Errorf("claimed %v after refreshing the token, want [1]", got)
+    }
+}
+
+func TestMainLoopRetriesRateLimitedPolls(t *testing.T) {
+    fp := newFakePlatform(t, "token", testTask("1"))
+    fp.Fail("tasks", http.StatusTooManyRequests, http.StatusServiceUnavailable)
+    recordNotifications(t)
+    b := newTestBot(t, "token")
+
+    runFor(b, 200*time.Millisecond)
+
+    if got := fp.Claimed(); !slices.Equal(got, []string{"1"}) {
+        t.Errorf("claimed %v, want [1]", got)
+    }
+    if p := b.state.Paused(); p != nil {
+        t.Errorf("claiming paused after retried polls: %s", p)
+    }
+}
+
+func TestMainLoopPausesDuringMaintenance(t *testing.T) {
+    fp := newFakePlatform(t, "token", testTask("1"))
+    fp.Fail("tasks", http.StatusInternalServerError, http.StatusBadGateway, http.StatusInternalServerError)
+    rec := recordNotifications(t)
+    b := newTestBot(t, "token")
+
+    runFor(b, 200*time.Millisecond)
+
+    types := rec.Types()
+    paused, resumed := slices.Index(types, EventPaused), slices.Index(types, EventResumed)
+    if paused < 0 || resumed < paused {
+        t.Errorf("events %v, want %s followed by %s", types, EventPaused, EventResumed)
+    }
+    if got := fp.Claimed(); !slices.Equal(got, []string{"1"}) {
+        t.Errorf("claimed %v after maintenance, want [1]", got)
+    }
+}
+
+func TestMainLoopLeavesMissionsAloneWhilePaused(t *testing.T) {
+    fp := newFakePlatform(t, "token", testTask("1"))
+    recordNotifications(t)
+    b := newTestBot(t, "token")
+    b.state.Pause(PauseManual, ResumeManual, time.Time{}, "")
+
+    runFor(b, 100*time.Millisecond)
+
+    if n := fp.Requests("claim"); n != 0 {
+        t.Errorf("%d claim requests while paused, want 0", n)
+    }
+    if fp.Requests("tasks") == 0 {
+        t.Error("missions not polled while paused")
+    }
+}
+
+// testJWT returns an unsigned JWT expiring at exp.
+func testJWT(exp time.Time) string {
+    enc := base64.RawURLEncoding
+    payload := fmt.Sprintf(`{"exp":%d}`, exp.Unix())
+    return enc.EncodeToString([]byte(`{"alg":"none"}`)) + "." + enc.EncodeToString([]byte(payload)) + ".sig"
+}
+
+func TestMainLoopStopsClaimingWithExpiringToken(t *testing.T) {
+    dying := testJWT(time.Now().Add(time.Minute))
+    fresh := testJWT(time.Now().Add(time.Hour))
+    fp := newFakePlatform(t, dying, testTask("1"))
+    rec := recordNotifications(t)
+    b := newTestBot(t, dying)
+    b.tokenGrace = 2 * time.Minute
+
+    runFor(b, 100*time.Millisecond)
+
+    if n := fp.Requests("claim"); n != 0 {
+        t.Errorf("%d claim requests with an expiring token, want 0", n)
+    }
+    if fp.Requests("tasks") == 0 {
+        t.Error("missions not polled with an expiring token")
+    }
+    if p := b.state.Paused(); p == nil || p.Reason != PauseTokenGrace {
+        t.Fatalf("pause = %v, want %s", p, PauseTokenGrace)
+    }
+
+    fp.SetToken(fresh)
+    b.tokens.Set(fresh)
+    runFor(b, 100*time.Millisecond)
+
+    if got := fp.Claimed(); !slices.Equal(got, []string{"1"}) {
+        t.Errorf("claimed %v with a fresh token, want [1]", got)
+    }
+    if !slices.Contains(rec.Types(), EventResumed) {
+        t.Errorf("no %s event in %v", EventResumed, rec.Types())
+    }
+}
+
+func TestMainLoopObeysDecisionWebhook(t *testing.T) {
+    fp := newFakePlatform(t, "token", testTask("1"), testTask("2"))
+    recordNotifications(t)
+    decider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        var req decisionRequest
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+            t.Errorf("decoding decision request: %v", err)
+        }
+        decision := "claim"
+        if req.Task.ID == "1" {
+            decision = "skip"
+        }
+        json.NewEncoder(w).Encode(decisionResponse{Decision: decision})
+    }))
+    defer decider.Close()
+    b := newTestBot(t, "token")
+    b.cfg.DecisionWebhook = DecisionWebhookConfig{URL: decider.URL, Timeout: Duration{time.Second}, OnError: "claim"}
+
+    runFor(b, 100*time.Millisecond)
+
+    if got := fp.Claimed(); !slices.Equal(got, []string{"2"}) {
+        t.Errorf("claimed %v, want [2]", got)
+    }
+}
+
+func TestMainLoopAppliesTargetOverrides(t *testing.T) {
+    capped := testTask("1")
+    capped.ListingUid, capped.ListingCodename = "capped", "CAPPED-WEB"
+    watched := testTask("2")
+    watched.ListingUid, watched.ListingCodename = "watched", "WATCHED-API"
+    fp := newFakePlatform(t, "token", capped, testTask("3"), watched)
+    recordNotifications(t)
+    setTargetOverrides([]TargetOverride{
+        {Match: "capped-*", MaxClaimsPerDay: 1},
+        {Match: "watched", Preset: "watch-only"},
+    })
+    t.Cleanup(func() { setTargetOverrides(nil) })
+    b := newTestBot(t, "token")
+    if err := b.store.AddClaim(Task{ID: "0", ListingUid: "capped"}); err != nil {
+        t.Fatal(err)
+    }
+
+    runFor(b, 100*time.Millisecond)
+
+    if got := fp.Claimed(); !slices.Equal(got, []string{"3"}) {
+        t.Errorf("claimed %v, want [3]", got)
+    }
+}
+
+func TestMainLoopReportsHealth(t *testing.T) {
+    newFakePlatform(t, "token", testTask("1"))
+    recordNotifications(t)
+    b := newTestBot(t, "token")
+    b.state.StartSession(time.Now())
+
+    if h := b.health(time.Now(), time.Minute); h.Status != "starting" || !h.healthy() {
+        t.Errorf("health before the first poll = %+v, want starting", h)
+    }
+    runFor(b, 100*time.Millisecond)
+
+    if h := b.health(time.Now(), time.Minute); h.Status != "ok" || h.LastPoll == nil {
+        t.Errorf("health after a poll = %+v, want ok", h)
+    }
+    if h := b.health(time.Now().Add(time.Hour), time.Minute); h.healthy() || len(h.Problems) != 1 {
+        t.Errorf("health an hour later = %+v, want unhealthy with one problem", h)
+    }
+}
+
+func TestMainLoopRunsOnce(t *testing.T) {
+    fp := newFakePlatform(t, "token", testTask("1"))
+    recordNotifications(t)
+    b := newTestBot(t, "token")
+    b.once = true
+
+    b.mainLoop()
+
+    if got := fp.Claimed(); !slices.Equal(got, []string{"1"}) {
+        t.Errorf("claimed %v, want [1]", got)
+    }
+    polls := fp.Requests("tasks")
+
+    // A rejected token ends the run instead of waiting for a new one.
+    fp.SetToken("other")
+    done := make(chan struct{})
+    go func() {
+        b.mainLoop()
+        close(done)
+    }()
+    select {
+    case <-done:
+    case <-time.After(5 * time.Second):
+        t.Fatal("once waited for a new token")
+    }
+    if n := fp.Requests("tasks") - polls; n != 1 {
+        t.Errorf("%d task polls with a rejected token, want 1", n)
+    }
+}
+
+func TestMainLoopClaimsWithWorkerPool(t *testing.T) {
+    // Task 1 is listed twice; the first claim request is rate limited.
+    fp := newFakePlatform(t, "token", testTask("1"), testTask("2"), testTask("1"), testTask("3"), testTask("4"), testTask("5"))
+    fp.Fail("claim", http.StatusTooManyRequests)
+    recordNotifications(t)
+    b := newTestBot(t, "token")
+    b.claimPipeline = 3
+
+    runFor(b, 200*time.Millisecond)
+
+    got := fp.Claimed()
+    slices.Sort(got)
+    if !slices.Equal(got, []string{"1", "2", "3", "4", "5"}) {
+        t.Errorf("claimed %v, want [1 2 3 4 5] once each", got)
+    }
+    // One request per mission, plus the rate limited one again.
+    if n := fp.Requests("claim"); n != 6 {
+        t.Errorf("%d claim requests, want 6", n)
+    }
+}
+
+func TestPrefetchClaimsStopsOnRefusal(t *testing.T) {
+    for _, status := range []int{http.StatusTooManyRequests, http.StatusUnauthorized, http.StatusForbidden} {
+        var tasks []Task
+        for i := range 6 {
+            tasks = append(tasks, testTask(strconv.Itoa(i)))
+        }
+        fp := newFakePlatform(t, "token", tasks...)
+        for _, task := range tasks {
+            fp.claimStatus[task.ID] = status
+        }
+        b := newTestBot(t, "token")
+        b.claimPipeline = 2
+
+        results := b.prefetchClaims("token", tasks, -1)
+
+        // Each worker stops after its first refused claim.
+        if n := fp.Requests("claim"); n != 2 || len(results) != 2 {
+            t.Errorf("%d: %d claim requests and %d results, want 2", status, n, len(results))
+        }
+    }
+}
+
+func TestMainLoopKeepsWorkloadWithinCapacity(t *testing.T) {
+    fp := newFakePlatform(t, "token", testTask("1"), testTask("2"), testTask("3"))
+    recordNotifications(t)
+    b := newTestBot(t, "token")
+    b.cfg.Workload = WorkloadConfig{DefaultEstimate: Duration{2 * time.Hour}, Capacity: Duration{5 * time.Hour}}
+    b.claimLimit = 10
+
+    runFor(b, 100*time.Millisecond)
+
+    if got := fp.Claimed(); !slices.Equal(got, []string{"1", "2"}) {
+        t.Errorf("claimed %v, want [1 2]", got)
+    }
+    if n := b.state.TakeSkips()[SkipWorkload]; n != 1 {
+        t.Errorf("%d missions skipped for the workload, want 1", n)
+    }
+    // The claim limit check's list of claimed missions is reused, so each
+    // round with missions fetches it once.
+    if n, polls := fp.Requests("claimed_tasks"), fp.Requests("tasks"); n > polls {
+        t.Errorf("%d claimed mission requests in %d polls, want at most one per poll", n, polls)
+    }
+}
+
+func TestMainLoopClaimsHotTargetMissionsOnce(t *testing.T) {
+    // Someone else claimed the hot target's mission, but it is still listed.
+    hot := testTask("hot")
+    hot.ListingUid = "hot-listing"
+    fp := newFakePlatform(t, "token", hot)
+    fp.claimStatus[hot.ID] = http.StatusPreconditionFailed
+    recordNotifications(t)
+    b := newTestBot(t, "token")
+    b.taskInterval = time.Hour
+    done, stopped := make(chan struct{}), make(chan struct{})
+    go func() {
+        defer close(stopped)
+        b.pollHotTarget(HotTarget{ListingUid: hot.ListingUid, Interval: Duration{2 * time.Millisecond}}, done)
+    }()
+    // Stop it before the next test replaces the shared client.
+    t.Cleanup(func() {
+        close(done)
+        <-stopped
+    })
+
+    runFor(b, 200*time.Millisecond)
+
+    // One attempt from the first full poll, at most one more from the hot
+    // target poller, however often it lists the mission.
+    if n := fp.Requests("claim"); n == 0 || n > 2 {
+        t.Errorf("%d claim requests, want 1 or 2", n)
+    }
+}
+
+func TestGetClaimedTasksReadsAllPages(t *testing.T) {
+    fp := newFakePlatform(t, "token")
+    for i := range claimedTasksPerPage + 10 {
+        fp.claimed = append(fp.claimed, testTask(strconv.Itoa(i)))
+    }
+
+    claimed, err := getClaimedTasks("token")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(claimed) != claimedTasksPerPage+10 {
+        t.Errorf("got %d claimed missions, want %d", len(claimed), claimedTasksPerPage+10)
+    }
+    if n := fp.Requests("claimed_tasks"); n != 2 {
+        t.Errorf("%d requests, want 2", n)
+    }
+}
+
+func TestDecodeTasks(t *testing.T) {
+    tests := []struct {
+        body    string
+        want    []string
+        wantErr string
+    }{
+        {body: `[{"id":"1"},{"id":"2"}]`, want: []string{"1", "2"}},
+        {body: `[]`, want: []string{}},
+        {body: `null`},
+        {body: `{"id":"1"}`, wantErr: "tasks: expected a list, got {"},
+        {body: `"maintenance"`, wantErr: "tasks: expected a list, got maintenance"},
+        {body: `[{"id":"1"},{"id":"2"`, wantErr: "unexpected EOF"},
+        {body: `[{"id":"1"}`, wantErr: "unexpected end of JSON input"},
+        {body: ``, wantErr: "EOF"},
+    }
+    for _, tt := range tests {
+        tasks, err := decodeTasks(strings.NewReader(tt.body))
+        if tt.wantErr != "" {
+            if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+                t.Errorf("decodeTasks(%q) error = %v, want %q", tt.body, err, tt.wantErr)
+            }
+            continue
+        }
+        if err != nil {
+            t.Errorf("decodeTasks(%q) error = %v", tt.body, err)
+            continue
+        }
+        var ids []string
+        if tasks != nil {
+            ids = []string{}
+        }
+        for _, task := range tasks {
+            ids = append(ids, task.ID)
+        }
+        if !slices.Equal(ids, tt.want) || (ids == nil) != (tt.want == nil) {
+            t.Errorf("decodeTasks(%q) = %q, want %q", tt.body, ids, tt.want)
+        }
+    }
+}
+
+func TestEnoughTasksAppliesFilters(t *testing.T) {
+    b := newTestBot(t, "token")
+    rules, err := compileClaimRules([]ClaimRule{{Name: "not this one", If: `id == "skip"`, Then: "skip"}})
+    if err != nil {
+        t.Fatal(err)
+    }
+    b.claimRules.Set(rules)
+    // Two claimable tasks, one of them listed twice.
+    tasks := []Task{testTask("1"), testTask("1"), testTask("skip"), testTask("2")}
+
+    if enough := b.enoughTasks(0); enough != nil {
+        t.Error("enoughTasks(0) stops early, want every page read")
+    }
+    if !b.enoughTasks(2)(tasks) {
+        t.Error("two claimable tasks aren't enough for two slots")
+    }
+    if b.enoughTasks(3)(tasks) {
+        t.Error("two claimable tasks are enough for three slots")
+    }
+
+    // A second mission of a capped campaign, a capped target's and a
+    // watched target's don't count either.
+    campaign := testTask("3")
+    campaign.CampaignUid = "campaign-1"
+    capped, watched := testTask("4"), testTask("5")
+    capped.ListingUid, watched.ListingUid = "capped", "watched"
+    setTargetOverrides([]TargetOverride{
+        {Match: "capped", MaxClaimsPerDay: 1},
+        {Match: "watched", Preset: "watch-only"},
+    })
+    t.Cleanup(func() { setTargetOverrides(nil) })
+    if err := b.store.AddClaim(Task{ID: "0", ListingUid: "capped"}); err != nil {
+        t.Fatal(err)
+    }
+    b.maxPerCampaign = 1
+    if b.enoughTasks(3)(append(tasks, campaign, capped, watched)) {
+        t.Error("missions the claim loop skips count as claimable")
+    }
+
+    b.cfg.Strategy.Order = []string{"payout"}
+    if enough := b.enoughTasks(1); enough != nil {
+        t.Error("enoughTasks() stops early with a strategy order, want every page read")
+    }
+}
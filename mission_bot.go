@@ -3,13 +3,18 @@ package main
 import (
     "bufio"
     "bytes"
-    "crypto/tls"
     "encoding/json"
+    "errors"
     "flag"
     "fmt"
-    "log"
+    "io"
+    "log/slog"
+    "maps"
+    "net"
     "net/http"
+    "net/url"
     "os"
+    "slices"
     "strconv"
     "strings"
     "sync"
@@ -18,132 +23,465 @@ import (
 
 // Task represents the JSON structure for tasks returned by Synack.
 type Task struct {
-    ID              string `json:"id"`
-    CampaignUid     string `json:"campaignUid"`
-    ListingUid      string `json:"listingUid"`
-    OrganizationUid string `json:"organizationUid"`
-    // Optionally, if the API returns a payout or similar, you could add:
-    // Payout          float64 `json:"payout"`
+    ID              string       `json:"id"`
+    Title           string       `json:"title"`
+    CampaignUid     string       `json:"campaignUid"`
+    ListingUid      string       `json:"listingUid"`
+    ListingCodename string       `json:"listingCodename"`
+    Category        string       `json:"category"`
+    OrganizationUid string       `json:"organizationUid"`
+    Payout          TaskPayout   `json:"payout"`
+    PublishedOn     platformTime `json:"publishedOn"`
+    // Set for claimed tasks: when the task was claimed and how long the
+    // researcher has to complete it.
+    ClaimedOn               platformTime `json:"claimedOn"`
+    MaxCompletionTimeInSecs int64        `json:"maxCompletionTimeInSecs"`
+}
+
+// platformTime accepts the timestamp formats the platform uses: RFC 3339
+// strings and Unix epoch numbers in seconds or milliseconds.
+type platformTime struct {
+    time.Time
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Unparseable values are left zero.
+func (t *platformTime) UnmarshalJSON(data []byte) error {
+    var s string
+    if err := json.Unmarshal(data, &s); err == nil {
+        t.Time, _ = time.Parse(time.RFC3339, s)
+        return nil
+    }
+    var n float64
+    if err := json.Unmarshal(data, &n); err == nil && n > 0 {
+        if n > 1e12 {
+            t.Time = time.UnixMilli(int64(n))
+        } else {
+            t.Time = time.Unix(int64(n), 0)
+        }
+    }
+    return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t platformTime) MarshalJSON() ([]byte, error) {
+    if t.IsZero() {
+        return []byte("null"), nil
+    }
+    return json.Marshal(t.Time)
+}
+
+// TaskPayout is the reward for completing a task.
+type TaskPayout struct {
+    Amount   float64 `json:"amount"`
+    Currency string  `json:"currency"`
+}
+
+// String formats the payout for display, e.g. "$50" or "50 EUR".
+func (p TaskPayout) String() string {
+    if p.Currency == "" || p.Currency == "USD" {
+        return fmt.Sprintf("$%g", p.Amount)
+    }
+    return fmt.Sprintf("%g %s", p.Amount, p.Currency)
 }
 
 // Target represents the JSON structure for unregistered targets.
 type Target struct {
-    Slug string `json:"slug"`
+    Slug           string         `json:"slug"`
+    Codename       string         `json:"codename"`
+    OrganizationID string         `json:"organization_id"`
+    Category       TargetCategory `json:"category"`
+}
+
+// TargetCategory is the category a target belongs to (e.g. "Web Application").
+type TargetCategory struct {
+    ID   int    `json:"id"`
+    Name string `json:"name"`
 }
 
 // tasksEndpoint is the default endpoint for retrieving tasks.
 var tasksEndpoint = "https://platform.synack.com/api/tasks/v2/tasks"
 
-// globalHTTPClient returns an HTTP client with InsecureSkipVerify (for demo).
-// In production, handle certificates properly.
-func globalHTTPClient() *http.Client {
-    tr := &http.Transport{
-        TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-    }
-    return &http.Client{Transport: tr}
-}
-
 // init overrides the default flag usage to display a custom help message.
 func init() {
     flag.Usage = func() {
         fmt.Fprintf(os.Stderr, `
-Usage of %s:
+Usage: %[1]s [run] [flags]
+       %[1]s <command> [flags] [args]
+
+Commands:
+  run                       Poll and claim missions, sign up for targets (default).
+  once                      Like run, but poll and claim once, check targets once, print the
+                            result as JSON and exit (for cron and serverless schedulers).
+  missions list             List the available missions.
+  missions claim <id>       Claim one available mission.
+  targets list              List the unregistered targets.
+  targets signup <slug>     Sign up for one target.
+  status                    Show the token's lifetime and your claimed missions with deadlines
+                            (-html: as a self-contained HTML page).
+  history -db <file>        List the missions recorded in the database.
+  portfolio -db <file>      Summarize the completed missions by skill as sanitized Markdown.
+  stats funnel -db <file>   Show how many missions were discovered, attempted, won and submitted.
+  release <id>              Release (unclaim) one of your claimed missions
+                            (-db <file>: and record the release).
+  token push -host <addr>   Send a token from stdin or -clipboard to a remote bot's control API.
+  token grab -profile <dir> Print the session token from a local Chrome or Firefox profile
+                            (-out <file> or -keychain to save it instead).
+  claims package <id>       Bundle a mission's workspace into an evidence ZIP.
+  healthcheck               Exit non-zero unless the bot on -addr (its -health-addr) is healthy.
+  native-host               Relay a browser extension's native messages to the -token-listener.
+  The one-off commands take the token as -t, -token-file or -keychain.
+
+Run flags:
   -t <token>    Provide your session token (JWT) for authentication with the Synack platform.
-  -v            Enable verbose logging.
+  -token-socket <path>
+                Get the token from a running token daemon instead of -t and prompts.
+  -token-file <path>
+                Read the token from this file and reload it whenever the file changes.
+  -spare-tokens <path>
+                Further tokens of the same account, one per line, switched to when the current
+                one expires or is rejected; a new token is only asked for once all are used up.
+  -keychain     Load the token from the OS keychain (if no other source is given) and save
+                every new token to it.
+  -auto-login   Log in with the email, password and TOTP secret saved by "keychain set-login"
+                whenever a new token is needed.
+  -control-addr <addr>
+                Serve the web dashboard and local control API (pause/resume, token, state,
+                poll now, pprof, expvar, poll timings) on this address.
+  -control-token-file <file>
+                Bearer token required by the control API (or MISSION_BOT_CONTROL_TOKEN).
+  -control-host <host>[,<host>...]
+                Host names the control API accepts besides localhost and loopback addresses,
+                e.g. the name "token push -host" reaches it by.
+  -control-tls-cert <file>, -control-tls-key <file>
+                Serve the control API over HTTPS with this certificate and key.
+  -debug-addr <addr>
+                Serve pprof, expvar and poll timings under /debug/ on this address, without
+                authentication (keep it on localhost).
+  -health-addr <addr>
+                Serve an unauthenticated GET /healthz for container health checks on this address.
+  -health-max-age <duration>
+                /healthz fails after this long without a successful mission poll (default three
+                -task-interval, at least 5m).
+  -token-listener <addr>
+                Accept fresh tokens POSTed to http://<addr>/token by a browser extension and
+                stream notifications to it at /events.
+  -token-listener-secret-file <file>
+                Secret required by the token listener (or MISSION_BOT_LISTENER_SECRET).
+  -v            Enable verbose logging (same as -log-level debug).
+  -log-level <l>
+                Minimum log level: debug, info, warn or error (default info).
+  -log-format <f>
+                Log output format: text or json (default text).
+  -log-file <file>
+                Write logs to this file instead of stderr, rotated by -log-max-size
+                (megabytes, default 10) and -log-max-age, keeping -log-backups old files
+                (default 5). SIGHUP reopens it.
+  -daemon       Run in the background, detached from the terminal (requires -log-file and
+                -token-file, -token-listener, -token-socket, -auto-login or Telegram).
+  -pid-file <file>
+                Write the process ID to this file while running.
+  -config <f>   Path to a JSON config file (target allow/deny lists, ...). Send SIGHUP to
+                reload it and the token file.
+  -account <name>
+                Run only this account of the config's accounts list (set by the supervisor).
+  -preset <name>
+                Start from built-in settings: conservative, balanced, aggressive or watch-only.
+                Flags given explicitly override the preset.
+  -dry-run      Poll and evaluate as usual, but only log what would be claimed or signed up for.
+  -notify-batch <d>
+                Collect notifications for this long (e.g. 10s) and send them as one summary.
+  -desktop-notify
+                Show desktop notifications for claimed missions, failed claims and token expiry.
+  -tui          Show a live terminal dashboard (p: pause/resume claiming, q: quit).
+  -db <file>    Keep mission history in this local database file.
+  -db-passphrase-file <file>
+                Encrypt the database with the passphrase in this file (AES-256-GCM).
+                MISSION_BOT_DB_PASSPHRASE is used when no file is given.
+  -insecure     Skip TLS certificate verification (only for debugging, e.g. through Burp).
+  -ca-bundle <file>
+                Additionally trust the CA certificates in this PEM file.
+  -pin-sha256 <pins>
+                Require one of these comma separated SHA-256 public key pins in the server chain.
+  -proxy <url>  Send all requests through this proxy (http://, https://, socks5://).
+                HTTP_PROXY/HTTPS_PROXY/NO_PROXY are honored when not set.
+  -verify-claims
+                Check that claimed missions show up in your claimed list (default true).
+  -claim-limit <n>
+                Your concurrent claim limit; stop claiming while n missions are claimed.
+  -max-per-campaign <n>
+                Claim at most n tasks of the same campaign (0 = unlimited).
+  -skip-republished <outcomes>
+                Don't claim re-published missions you released and/or completed before
+                (released, completed or both, comma separated).
+  -max-claims-per-day <n>, -max-earnings-per-day <amount>
+                Pause claiming until midnight once a day's claims or payouts reach the cap.
+  -day-timezone <zone>
+                Time zone whose midnight starts a new day for the daily caps (default local).
+  -vacation-until <date>
+                Start with claiming paused until this date (2006-01-02 or RFC 3339).
+  -archive-dir <dir>
+                Keep the raw task poll responses in daily JSON lines files in this directory.
+  -task-interval <duration>
+                Time between mission polls (default 15s).
+  -claim-delay <duration>
+                Time to wait after each successful claim (default 5s).
+  -target-interval <duration>
+                Time between unregistered target polls (default 5m).
+  -jitter <percent>
+                Randomize the intervals above by up to this percentage (default 10).
+  -announcement-interval <duration>
+                Check platform announcements this often and notify on new ones (default 30m, 0 disables).
+  -activity-sync <duration>
+                Check the account's activity feed against -audit-log this often and notify on
+                claims, releases and signups the bot didn't make (0 disables, the default).
+  -skip-summary <duration>
+                Report the missions skipped, by reason, this often (default 1h, 0 disables).
+  -deadline-reminders <percentages>
+                Remind when 50,75,90 (default) percent of a claimed mission's time has elapsed.
+  -claim-pipeline <n>
+                Claim with n workers at once over HTTP/2 (default 1 = one after another).
+  -forbidden-cooldown <duration>
+                Pause claiming this long after 5 consecutive 403s (default 1h, 0 = stop the bot).
+  -run-for <duration>
+                Stop gracefully after this long (e.g. 8h) with a session summary.
+  -until <time>
+                Stop gracefully at this time (e.g. 22:00, or RFC 3339) with a session summary.
+  -max-task-pages <n>
+                Read at most n pages of 20 missions per poll (default 10, 0 = no limit).
+  -expiry-warning <duration>
+                Notify this long before the token's exp claim is reached (default 10m, 0 disables).
+  -token-grace <duration>
+                Stop claims and signups this long before the token expires, polling read-only
+                until a fresh token arrives (default 2m, 0 disables).
+  -latency-probe <duration>
+                Measure RTT and TLS handshake time to the platform this often (default 5m, 0 disables).
+  -mission-dir <dir>
+                Save the full details of every claimed mission to <dir>/<task id>/ (JSON and
+                Markdown) with an evidence.md answer template.
+  -audit-log <file>
+                Append every API call outcome, claim, signup, token change and state change
+                to this JSON lines file.
+  -api-journal <file>
+                Record API changes (new fields, unhandled status codes) to this JSON lines file.
+  -api-journal-report <url>
+                Also send each API change, anonymized, to this aggregation endpoint.
+  -strict       Minimize stored confidential data (see README).
+  -export-claim-timings <file.csv>
+                Write anonymized claim timing data from the database to a CSV file and exit.
 
 Description:
   This program periodically polls the Synack platform for two things:
 
     1. Available missions (tasks):
        - If a mission can be claimed, the script claims it.
-       - If 403 is received 5 times in a row while claiming tasks, it gracefully stops.
+       - If 403 is received 5 times in a row while claiming tasks, claiming is paused for
+         -forbidden-cooldown (or the bot stops if it is 0).
        - If 401 (unauthorized) is encountered, it prompts for a new token.
-       - Waits 5 seconds between each claimed task, and 30 seconds between polling cycles.
+       - Waits 5 seconds between each claimed task, and 15 seconds between polling cycles
+         (see -claim-delay, -task-interval and -jitter).
 
     2. Unregistered targets:
-       - Checks every 5 minutes. Any newly discovered unregistered targets are automatically
-         signed up for.
+       - Checks every 5 minutes (-target-interval). Any newly discovered unregistered targets are automatically
+         signed up for, unless excluded by the allow/deny lists in the config file.
 
 Example:
   synack-mission-bot -t "YOUR_SESSION_TOKEN_HERE" -v
 
+Archive:
+  %[1]s archive compact -dir <archive dir> -out <dataset.csv>
+      Compacts responses recorded with -archive-dir into an anonymized dataset
+      (payouts, categories, timing; no IDs, titles or brief text) for sharing.
+
+Evidence:
+  %[1]s claims package <task id> -dir <mission dir> [-out <file.zip>]
+      Bundles a claimed mission's workspace under -mission-dir (evidence.md, notes,
+      screenshots, requests) into a ZIP with a manifest, ready to upload as evidence.
+
+Mark viewed:
+  %[1]s mark-viewed -t <token> -org <uid> -listing <uid> -campaign <uid> -task <id>
+      Marks a single claimed mission as viewed, for scripted workflows.
+
+State:
+  %[1]s state export -db <file> [-db-passphrase-file <file>] [-config <file>] -out <snapshot>
+  %[1]s state import -in <snapshot> -db <file> [-db-passphrase-file <file>] [-config-out <file>] [-force]
+      Moves the database and config to another machine, or restores them from a backup.
+
+Notifications:
+  %[1]s notify test [-config <file>] [channel]
+      Sends a test notification through stdout and every configured channel (telegram,
+      slack, discord, webhook, ntfy, gotify, email, mqtt, nats), or only the one with
+      the given name (its "name", or else its type), and reports which deliveries failed.
+
+Keychain:
+  %[1]s keychain set [-account <name>]
+      Reads a token from stdin and saves it in the OS keychain for use with -keychain.
+  %[1]s keychain set-login [-account <name>]
+      Reads your email, password and TOTP secret from stdin and saves them in the OS
+      keychain for use with -auto-login. With -account, the entry is that account's
+      of the config's accounts list.
+
+Token daemon:
+  %[1]s token-daemon [-t <token>] [-socket <path>] [-token-file <file>] [-spare-tokens <file>]
+          [-keychain] [-auto-login] [-account <name>]
+      Only maintains a fresh token (from these sources, or a prompt without any, when
+      it expires or a bot reports it invalid) and serves it to bots started with
+      -token-socket <path>.
+
 Flags:
 `, os.Args[0])
         flag.PrintDefaults()
     }
 }
 
-// getTasks retrieves tasks from Synack.
-func getTasks(token string) ([]Task, error) {
-    client := globalHTTPClient()
+// tasksPerPage is the page size requested from the tasks endpoint.
+const tasksPerPage = 20
 
-    req, err := http.NewRequest("GET", tasksEndpoint, nil)
-    if err != nil {
-        return nil, err
+// getTasks retrieves tasks from Synack, following pages until an empty or
+// short page is returned or maxPages pages were read (0 = no limit), so a
+// large mission drop is seen beyond the first page.
+func getTasks(token string, maxPages int) ([]Task, error) {
+    return getTasksUntil(token, maxPages, nil)
+}
+
+// partialTasksError reports that a page after the first failed; the tasks
+// of the pages before it were read and are returned with it.
+type partialTasksError struct {
+    page int
+    err  error
+}
+
+func (e partialTasksError) Error() string { return fmt.Sprintf("task page %d: %v", e.page, e.err) }
+func (e partialTasksError) Unwrap() error { return e.err }
+
+// getTasksUntil is getTasks that also stops following pages once enough
+// (if not nil) reports that the tasks read so far are enough to claim, so
+// a large drop doesn't delay the first claim by its later pages. If a later
+// page fails, the tasks read so far are returned with a partialTasksError.
+func getTasksUntil(token string, maxPages int, enough func([]Task) bool) ([]Task, error) {
+    tasks := make([]Task, 0, tasksPerPage)
+    for page := 1; maxPages <= 0 || page <= maxPages; page++ {
+        pageTasks, err := getTasksPage(token, page, "")
+        if err != nil {
+            if page > 1 {
+                return tasks, partialTasksError{page: page, err: err}
+            }
+            return nil, err
+        }
+        tasks = append(tasks, pageTasks...)
+        if len(pageTasks) < tasksPerPage {
+            break
+        }
+        if enough != nil && enough(tasks) {
+            slog.Debug("enough claimable tasks, not reading further pages", "endpoint", "tasks", "pages", page, "tasks", len(tasks))
+            break
+        }
     }
-    req.Header.Set("Authorization", "Bearer "+token)
-    req.Header.Set("Content-Type", "application/json")
+    return tasks, nil
+}
 
+// getTasksPage retrieves one page of tasks, optionally only those of one
+// listing. Overlapping polls of the same page share one request.
+func getTasksPage(token string, page int, listingUid string) ([]Task, error) {
     // Query params
-    q := req.URL.Query()
-    q.Add("perPage", "20")
+    q := url.Values{}
+    q.Add("perPage", strconv.Itoa(tasksPerPage))
     q.Add("viewed", "true")
-    q.Add("page", "1")
+    q.Add("page", strconv.Itoa(page))
     q.Add("status", "PUBLISHED")
     q.Add("sort", "CLAIMABLE")
     q.Add("sortDir", "DESC")
     q.Add("includeAssignedBySynackUser", "false")
-    req.URL.RawQuery = q.Encode()
+    if listingUid != "" {
+        q.Add("listingUid", listingUid)
+    }
+    u := tasksEndpoint + "?" + q.Encode()
+    return sharedRead("tasks", u, token, func() ([]Task, error) { return fetchTasksPage(u, token, page) })
+}
 
-    resp, err := client.Do(req)
+// fetchTasksPage requests one page of tasks from u.
+func fetchTasksPage(u, token string, page int) ([]Task, error) {
+    start := time.Now()
+    resp, err := doWithRetry("tasks", defaultRetryPolicy, func() (*http.Request, error) {
+        return newAPIRequest("GET", u, token, nil)
+    })
+    timings.Since(PhaseFetch, start)
     if err != nil {
         return nil, err
     }
-    defer resp.Body.Close()
+    defer closeBody(resp)
+    logResponse("tasks", resp, "page", page)
 
     switch resp.StatusCode {
     case http.StatusOK:
-        var tasks []Task
-        if err := json.NewDecoder(resp.Body).Decode(&tasks); err != nil {
+        start = time.Now()
+        defer timings.Since(PhaseDecode, start)
+        // The body is only kept when the archive or the API journal wants
+        // it; otherwise the tasks are decoded as they arrive.
+        var body *bytes.Buffer
+        r := io.Reader(resp.Body)
+        if archive != nil || journal != nil {
+            body = bytes.NewBuffer(make([]byte, 0, taskBodySize))
+            r = io.TeeReader(resp.Body, body)
+        }
+        tasks, err := decodeTasks(r)
+        if err != nil {
             return nil, err
         }
+        if body != nil {
+            if _, err := io.Copy(io.Discard, r); err != nil {
+                return nil, err
+            }
+            if err := archive.Record("tasks", resp.StatusCode, body.Bytes()); err != nil {
+                slog.Warn("failed to archive response", "endpoint", "tasks", "err", err)
+            }
+            journal.CheckFields("tasks", body.Bytes())
+        }
         return tasks, nil
 
     case http.StatusUnauthorized:
         return nil, fmt.Errorf("unauthorized (401)")
 
-    case 429:
-        // If we hit a 429, implement a simple backoff or check Retry-After
-        retryAfter := resp.Header.Get("Retry-After")
-        if retryAfter == "" {
-            fmt.Println("Got 429 Too Many Requests. Sleeping 30 seconds.")
-            time.Sleep(30 * time.Second)
-        } else {
-            if secs, err := strconv.Atoi(retryAfter); err == nil {
-                fmt.Printf("Got 429 Too Many Requests, waiting %d seconds...\n", secs)
-                time.Sleep(time.Duration(secs) * time.Second)
-            } else {
-                time.Sleep(30 * time.Second)
-            }
-        }
-        // Retry once after waiting
-        return getTasks(token)
-
     default:
-        return nil, fmt.Errorf("failed to retrieve tasks, status code: %d", resp.StatusCode)
+        return nil, unexpectedStatus("tasks", resp.StatusCode, fmt.Errorf("failed to retrieve tasks, status code: %d", resp.StatusCode))
     }
 }
 
+// taskBodySize is the buffer preallocated for a page of tasks that is kept,
+// about the size of a full page.
+const taskBodySize = 32 << 10
+
+// decodeTasks decodes a JSON list of tasks element by element, without
+// reading the whole body first. A null body is an empty list.
+func decodeTasks(r io.Reader) ([]Task, error) {
+    dec := json.NewDecoder(r)
+    tok, err := dec.Token()
+    if err != nil {
+        return nil, err
+    }
+    if tok == nil {
+        return nil, nil
+    }
+    if d, ok := tok.(json.Delim); !ok || d != '[' {
+        return nil, fmt.Errorf("tasks: expected a list, got %v", tok)
+    }
+    tasks := make([]Task, 0, tasksPerPage)
+    for dec.More() {
+        tasks = append(tasks, Task{})
+        if err := dec.Decode(&tasks[len(tasks)-1]); err != nil {
+            return nil, err
+        }
+    }
+    if _, err := dec.Token(); err != nil {
+        return nil, err
+    }
+    return tasks, nil
+}
+
 // postClaimTask attempts to claim a specific task.
 func postClaimTask(token string, task Task) error {
     client := globalHTTPClient()
-    url := fmt.Sprintf(
-        "https://platform.synack.com/api/tasks/v1/organizations/%s/listings/%s/campaigns/%s/tasks/%s/transitions",
-        task.OrganizationUid, task.ListingUid, task.CampaignUid, task.ID,
-    )
-
     payload := []byte(`{"type": "CLAIM"}`)
-    req, err := http.NewRequest("POST", url, bytes.NewBuffer(payload))
+    req, err := http.NewRequest("POST", taskTransitionsURL(task), bytes.NewBuffer(payload))
     if err != nil {
         return err
     }
@@ -154,11 +492,11 @@ func postClaimTask(token string, task Task) error {
     if err != nil {
         return err
     }
-    defer resp.Body.Close()
+    defer closeBody(resp)
+    logResponse("claim", resp, "task_id", task.ID)
 
     switch resp.StatusCode {
     case http.StatusCreated:
-        fmt.Println("Mission claimed successfully.")
         return nil
     case http.StatusPreconditionFailed:
         return fmt.Errorf("Mission cannot be claimed anymore (412)")
@@ -167,216 +505,1345 @@ func postClaimTask(token string, task Task) error {
     case http.StatusForbidden:
         return fmt.Errorf("Failed to claim task, status code: 403")
     default:
-        return fmt.Errorf("Failed to claim task, status code: %d", resp.StatusCode)
+        return unexpectedStatus("claim", resp.StatusCode, fmt.Errorf("Failed to claim task, status code: %d", resp.StatusCode))
     }
 }
 
-// pollUnregisteredTargets checks unregistered targets every 5 minutes and signs up for new ones.
-func pollUnregisteredTargets(token string, knownSlugs *sync.Map, tokenChan chan string, verbose bool) {
+// pollUnregisteredTargets checks unregistered targets every target interval and signs up for new ones
+// that pass the target filter.
+func (b *bot) pollUnregisteredTargets() {
+    var gatedToken string
+    var gatedRoles []string
+    var signupAllowed bool
+
     for {
-        select {
-        case newToken := <-tokenChan:
-            token = newToken
-        default:
-            // continue using current token
-        }
+        token := b.tokens.Get()
 
-        // Verbose logging
-        if verbose {
-            log.Println("Checking for unregistered targets...")
+        // The gate is checked again for a new token or reloaded role_gates.
+        if roles := b.config().RoleGates.Targets; token != gatedToken || !slices.Equal(roles, gatedRoles) {
+            var reason string
+            signupAllowed, reason = featureAllowed(token, roles)
+            if !signupAllowed {
+                slog.Warn("target signup disabled", "reason", reason)
+            }
+            gatedToken, gatedRoles = token, roles
         }
 
-        targets, err := getUnregisteredTargets(token)
-        if err != nil {
+        b.expireSignups()
+        if err := b.checkTargets(token, signupAllowed); err != nil {
             if strings.Contains(err.Error(), "401") {
-                newToken := refreshToken()
-                tokenChan <- newToken
-                token = newToken
+                b.refreshToken(token)
                 continue
             }
-            log.Println(err)
-        } else {
-            for _, t := range targets {
-                if _, loaded := knownSlugs.LoadOrStore(t.Slug, true); !loaded {
-                    // Found a new slug, sign up
-                    err := signupTarget(token, t.Slug)
-                    if err != nil {
-                        log.Println(err)
-                    }
+            slog.Error("failed to retrieve unregistered targets", "endpoint", "targets", "err", err)
+        }
+
+        select {
+        case <-time.After(jittered(b.targetInterval, b.jitter)):
+        case <-b.pollTargets:
+        }
+    }
+}
+
+// checkTargets lists the unregistered targets and, if signupAllowed, signs
+// up for the new ones that pass the target filter. It returns the error of
+// the listing.
+func (b *bot) checkTargets(token string, signupAllowed bool) error {
+    slog.Debug("checking for unregistered targets")
+    targets, err := getUnregisteredTargets(token)
+    if err != nil {
+        return err
+    }
+    b.state.SetTargets(targets)
+    switch {
+    case !signupAllowed:
+        slog.Debug("signup disabled for this token, not signing up", "targets", len(targets))
+    case b.state.SignupsPaused():
+        slog.Debug("signups paused, not signing up", "targets", len(targets))
+    case b.tokenExpiring(token):
+        slog.Debug("session token about to expire, not signing up", "targets", len(targets))
+    default:
+        filter := b.config().Targets
+        for _, t := range targets {
+            if _, known := b.knownSlugs.Load(t.Slug); known {
+                continue
+            }
+            // A target the filter skips stays unknown, so a reloaded
+            // filter looks at it again.
+            if ok, reason := filter.Permits(t); !ok {
+                slog.Debug("skipping target", "slug", t.Slug, "reason", reason)
+                continue
+            }
+            if _, loaded := b.knownSlugs.LoadOrStore(t.Slug, true); !loaded {
+                if b.dryRun {
+                    slog.Info("dry-run: would sign up for target", "slug", t.Slug)
+                    continue
+                }
+                if filter.AsksFirst(t) {
+                    b.askSignup(token, t)
+                    continue
                 }
+                // Found a new slug, sign up
+                b.signup(token, t)
             }
         }
+    }
+    return nil
+}
 
-        // Sleep 5 minutes (300 seconds) before checking again
-        time.Sleep(5 * time.Minute)
+// signup signs up for a target, records it and runs the signup hooks. A
+// signup that failed for a reason worth retrying isn't recorded, so it is
+// tried again after a restart.
+func (b *bot) signup(token string, t Target) error {
+    err := signupTarget(token, t.Slug)
+    activity.AddSignup(err)
+    if err == nil || signupRefused(err) {
+        if err := b.store.AddKnownSlug(t.Slug); err != nil {
+            slog.Error("failed to record target slug", "slug", t.Slug, "err", err)
+        }
+    }
+    if err != nil {
+        slog.Error("target signup failed", "endpoint", "signup", "slug", t.Slug, "err", err)
+        return err
     }
+    b.state.AddSignup()
+    notifyEvent(Event{Type: EventSignup, Message: fmt.Sprintf("Signed up for target %s successfully.", t.Slug), Target: t.Slug})
+    for _, hook := range b.config().SignupHooks.Hooks {
+        b.hooks.Start(hook, t.Slug, targetHookEnv(t), nil)
+    }
+    return nil
 }
 
-// getUnregisteredTargets retrieves the unregistered targets from Synack.
-func getUnregisteredTargets(token string) ([]Target, error) {
-    client := globalHTTPClient()
-    url := "https://platform.synack.com/api/targets?filter%5Bprimary%5D=unregistered&filter%5Bsecondary%5D=all&filter%5Bcategory%5D=all&filter%5Bindustry%5D=all&filter%5Bpayout_status%5D=all&sorting%5Bfield%5D=onboardedAt&sorting%5Bdirection%5D=desc&pagination%5Bpage%5D=1&pagination%5Bper_page%5D=15"
+// signupRefused reports whether a failed signup was the platform's final
+// answer, rather than a server error, rate limiting, a rejected token or no
+// answer at all.
+func signupRefused(err error) bool {
+    switch code := errorStatus(err); {
+    case code == 0, code == http.StatusUnauthorized, code == http.StatusTooManyRequests, code >= 500:
+        return false
+    }
+    return true
+}
 
-    req, err := http.NewRequest("GET", url, nil)
-    if err != nil {
-        return nil, err
+// Target listing pagination: page size and a safety limit on pages per poll.
+const (
+    targetsPerPage = 15
+    maxTargetPages = 50
+)
+
+// getUnregisteredTargets retrieves all unregistered targets from Synack, page
+// by page, so a backlog (after a fresh account or long downtime) is fully
+// enumerated rather than only the newest page.
+func getUnregisteredTargets(token string) ([]Target, error) {
+    var targets []Target
+    for page := 1; page <= maxTargetPages; page++ {
+        pageTargets, err := getUnregisteredTargetsPage(token, page)
+        if err != nil {
+            return nil, err
+        }
+        targets = append(targets, pageTargets...)
+        if len(pageTargets) < targetsPerPage {
+            return targets, nil
+        }
     }
-    req.Header.Set("Authorization", "Bearer "+token)
-    req.Header.Set("Content-Type", "application/json")
+    slog.Warn("stopped listing unregistered targets at the page limit", "endpoint", "targets", "pages", maxTargetPages)
+    return targets, nil
+}
 
-    resp, err := client.Do(req)
+// getUnregisteredTargetsPage retrieves one page of unregistered targets.
+// Overlapping polls of the same page share one request.
+func getUnregisteredTargetsPage(token string, page int) ([]Target, error) {
+    url := fmt.Sprintf("https://platform.synack.com/api/targets?filter%%5Bprimary%%5D=unregistered&filter%%5Bsecondary%%5D=all&filter%%5Bcategory%%5D=all&filter%%5Bindustry%%5D=all&filter%%5Bpayout_status%%5D=all&sorting%%5Bfield%%5D=onboardedAt&sorting%%5Bdirection%%5D=desc&pagination%%5Bpage%%5D=%d&pagination%%5Bper_page%%5D=%d", page, targetsPerPage)
+    return sharedRead("targets", url, token, func() ([]Target, error) { return fetchTargetsPage(url, token, page) })
+}
+
+// fetchTargetsPage requests one page of unregistered targets from url.
+func fetchTargetsPage(url, token string, page int) ([]Target, error) {
+    resp, err := doWithRetry("targets", defaultRetryPolicy, func() (*http.Request, error) {
+        return newAPIRequest("GET", url, token, nil)
+    })
     if err != nil {
         return nil, err
     }
-    defer resp.Body.Close()
+    defer closeBody(resp)
+    logResponse("targets", resp, "page", page)
 
     switch resp.StatusCode {
     case http.StatusOK:
+        body, err := io.ReadAll(resp.Body)
+        if err != nil {
+            return nil, err
+        }
+        journal.CheckFields("targets", body)
         var targets []Target
-        if err := json.NewDecoder(resp.Body).Decode(&targets); err != nil {
+        if err := json.Unmarshal(body, &targets); err != nil {
             return nil, err
         }
         return targets, nil
     case http.StatusUnauthorized:
         return nil, fmt.Errorf("unauthorized (401)")
-    case 429:
-        fmt.Println("Got 429 Too Many Requests on targets. Sleeping 30 seconds.")
-        time.Sleep(30 * time.Second)
-        // Retry once after waiting
-        return getUnregisteredTargets(token)
     default:
-        return nil, fmt.Errorf("failed to retrieve unregistered targets, status code: %d", resp.StatusCode)
+        return nil, unexpectedStatus("targets", resp.StatusCode, fmt.Errorf("failed to retrieve unregistered targets, status code: %d", resp.StatusCode))
     }
 }
 
 // signupTarget attempts to sign up for a target using its slug.
 func signupTarget(token, slug string) error {
-    client := globalHTTPClient()
     url := fmt.Sprintf("https://platform.synack.com/api/targets/%s/signup", slug)
     payload := []byte(`{"ResearcherListing": {"terms": 1}}`)
 
-    req, err := http.NewRequest("POST", url, bytes.NewBuffer(payload))
+    resp, err := doWithRetry("signup", defaultRetryPolicy, func() (*http.Request, error) {
+        return newAPIRequest("POST", url, token, payload)
+    })
     if err != nil {
         return err
     }
-    req.Header.Set("Authorization", "Bearer "+token)
-    req.Header.Set("Content-Type", "application/json")
-
-    resp, err := client.Do(req)
-    if err != nil {
-        return err
-    }
-    defer resp.Body.Close()
+    defer closeBody(resp)
+    logResponse("signup", resp, "slug", slug)
 
     if resp.StatusCode == http.StatusOK {
-        fmt.Printf("Signed up for target %s successfully.\n", slug)
         return nil
     } else if resp.StatusCode == http.StatusUnauthorized {
         return fmt.Errorf("unauthorized (401)")
-    } else if resp.StatusCode == 429 {
-        fmt.Println("Got 429 Too Many Requests on signup. Sleeping 30 seconds.")
-        time.Sleep(30 * time.Second)
-        // Retry once
-        return signupTarget(token, slug)
     }
 
-    return fmt.Errorf("failed to sign up for target %s, status code: %d", slug, resp.StatusCode)
+    return unexpectedStatus("signup", resp.StatusCode, fmt.Errorf("failed to sign up for target %s, status code: %d", slug, resp.StatusCode))
 }
 
-// refreshToken prompts the user to enter a new token.
-func refreshToken() string {
+// refreshToken obtains a replacement for a token the platform rejected from
+// the bot's token source and shares it with all loops. If another loop
+// already replaced the token, that one is used, and a usable spare token
+// (-spare-tokens) is tried before the source. Only one loop asks at a time;
+// the others wait for its token, so a second prompt can't wait forever for
+// input the first one consumed. An empty or unchanged token from the source
+// (a prompt without a terminal) is ignored, and the old token returned after
+// tokenRetryDelay, so the loops don't hammer the platform with it.
+func (b *bot) refreshToken(old string) string {
+    if current := b.tokens.Get(); current != old {
+        return current
+    }
+    if token, ok := b.rotateToken(old, "was rejected"); ok {
+        return token
+    }
+    b.promptMu.Lock()
+    defer b.promptMu.Unlock()
+    if current := b.tokens.Get(); current != old {
+        return current
+    }
+    source := b.tokenSource()
+    slog.Debug("asking for a new token", "source", source.Name())
+    token := source.Refresh(old)
+    if token == "" || token == old {
+        slog.Warn("no new token, retrying later", "source", source.Name(), "delay", tokenRetryDelay)
+        time.Sleep(tokenRetryDelay)
+        return old
+    }
+    if token != b.tokens.Get() {
+        b.tokens.Set(token)
+    }
+    return token
+}
+
+// tokenRetryDelay is how long refreshToken waits after its token source
+// came up without a new token.
+var tokenRetryDelay = 30 * time.Second
+
+// promptToken prompts the user to enter a new token.
+func promptToken() string {
+    if activeTUI != nil {
+        return activeTUI.promptToken()
+    }
     fmt.Print("Token expired or invalid. Please enter a new token:\n> ")
     reader := bufio.NewReader(os.Stdin)
     newToken, _ := reader.ReadString('\n')
     return strings.TrimSpace(newToken)
 }
 
-// mainLoop continuously polls tasks, attempts to claim them, and gracefully stops
-// if 403 is encountered 5 times in a row. If verbose is set, it logs each check.
-func mainLoop(token string, tokenChan chan string, verbose bool) {
-    var consecutive403Count int
+// mainLoop continuously polls tasks, attempts to claim them, and pauses claiming
+// for the forbidden cooldown if 403 is encountered 5 times in a row (or stops
+// without a cooldown).
+// With dryRun set, tasks are only logged and never claimed; while claiming is
+// paused, tasks are still polled but left alone. Pauses caused by platform
+// maintenance (repeated 5xx) end automatically with the next successful poll,
+// and timed pauses once their time has passed. In a bounded run it returns
+// once the session deadline has passed; with once set, it returns after one
+// cycle and doesn't ask for a new token on 401. Under systemd it reports
+// readiness after the first successful poll and pets the watchdog every cycle.
+func (b *bot) mainLoop() {
+    var consecutive403 []string // IDs of the tasks of the current 403 streak
+    var consecutive5xxCount int
+    claimSlots := -1 // unlimited until updateClaimCapacity says otherwise
+    var gatedToken string
+    var gatedRoles []string
+    var claimAllowed bool
+    var hot []Task         // missions reported by a hot target poller, claimed without a full poll
+    var nextPoll time.Time // when the next full poll is due; hot claims don't move it
+    var quiet bool
+    var ready bool
+    watchdog := sdWatchdog()
+    streaks := errorStreaks{}
 
-    for {
-        select {
-        case newToken := <-tokenChan:
-            token = newToken
-        default:
-            // no token update
+    for cycle := 1; !b.sessionOver(); cycle++ {
+        cycleStart := time.Now()
+        if watchdog {
+            sdNotify("WATCHDOG=1")
         }
+        token := b.tokens.Get()
+        var errs cycleErrors
 
-        if verbose {
-            log.Println("Checking for available missions...")
+        // The gate is checked again for a new token or reloaded role_gates.
+        if roles := b.config().RoleGates.Missions; token != gatedToken || !slices.Equal(roles, gatedRoles) {
+            var reason string
+            claimAllowed, reason = featureAllowed(token, roles)
+            if !claimAllowed {
+                slog.Warn("mission claiming disabled", "reason", reason)
+            }
+            exp, _ := tokenExpiry(token)
+            b.state.SetTokenExpiry(exp)
+            gatedToken, gatedRoles = token, roles
         }
 
-        tasks, err := getTasks(token)
+        b.state.ResumeIfDue()
+        b.enforceDailyCaps()
+        b.enforceTokenGrace(token)
+        quietHours := b.quiet()
+        if q := quietHours.Active(time.Now()); q != quiet {
+            quiet = q
+            audit.Record(AuditQuietHours, "active", quiet)
+            if quiet {
+                slog.Info("quiet hours started, not claiming")
+            } else {
+                slog.Info("quiet hours ended, claiming again")
+            }
+        }
+
+        var tasks []Task
+        var claimed []Task // the claimed missions, if fetched this cycle
+        var err error
+        hotCycle := hot != nil
+        if hotCycle {
+            slog.Debug("claiming missions found by hot target poller", "tasks", len(hot))
+            tasks, hot = hot, nil
+        } else {
+            slog.Debug("checking for available missions")
+            tasks, err = getTasksUntil(token, b.maxTaskPages, b.enoughTasks(claimSlots))
+            // The pages read before a failing one are still claimed; the
+            // failure is reported with the cycle's errors.
+            if errors.As(err, new(partialTasksError)) {
+                errs.Add("tasks", "", err)
+                err = nil
+            }
+        }
+        seenAt := time.Now()
+        if err == nil && !ready {
+            ready = true
+            sdNotify("READY=1")
+        }
+        if err == nil {
+            // A hot target's missions are added to those of the last full
+            // poll, which alone tells whether the platform is healthy again.
+            if hotCycle {
+                b.state.AddPending(tasks)
+            } else {
+                b.state.SetPending(tasks)
+            }
+            if err := b.store.AddDiscovered(tasks); err != nil {
+                slog.Error("failed to record discovered missions", "err", err)
+            }
+            if !hotCycle {
+                b.state.ResumeIfHealthy()
+                consecutive5xxCount = 0
+            }
+            if len(tasks) > 0 {
+                start := time.Now()
+                claimSlots, claimed = b.updateClaimCapacity(token)
+                timings.Since(PhaseDecide, start)
+            }
+        } else if isServerError(err) {
+            consecutive5xxCount++
+            if consecutive5xxCount == maintenanceThreshold && b.state.Paused() == nil {
+                b.state.Pause(PauseMaintenance, ResumeEndpointHealthy, time.Time{}, err.Error())
+            }
+        }
         if err != nil {
-            if strings.Contains(err.Error(), "401") {
-                newToken := refreshToken()
-                tokenChan <- newToken
-                token = newToken
-                consecutive403Count = 0
+            if strings.Contains(err.Error(), "401") && !b.once {
+                b.refreshToken(token)
+                consecutive403 = nil
                 continue
             }
-            log.Println(err)
+            errs.Add("tasks", "", err)
+        } else if !claimAllowed {
+            slog.Debug("claiming disabled for this token, not claiming", "tasks", len(tasks))
+            b.state.RecordSkips(SkipRoleGate, tasks)
+        } else if p := b.state.Paused(); p != nil {
+            slog.Debug("claiming is paused, not claiming", "tasks", len(tasks), "reason", p.Reason, "resume", p.Resume)
+            b.state.RecordSkips(SkipPaused, tasks)
         } else {
+            start := time.Now()
+            b.refreshCategoryRates(token)
+            ordered := b.applyClaimRules(token, orderTasks(dedupeTasks(tasks), b.config().Strategy, b.categoryRates))
+            ordered = b.dropOverCapacity(token, claimed, ordered)
+            ordered = b.dropRepublished(ordered)
+            ordered = b.dropUnavailable(ordered)
+            ordered = b.askDecisionWebhook(ordered, claimSlots)
+            timings.Since(PhaseDecide, start)
+            if quiet {
+                if quietHours.record {
+                    for _, task := range ordered {
+                        slog.Info("quiet hours: would claim task", "task_id", task.ID, "title", task.Title, "payout", task.Payout.String())
+                    }
+                }
+                b.state.RecordSkips(SkipQuietHours, ordered)
+                ordered = nil
+            }
+            var prefetched map[string]claimResult
+            if b.claimPipeline > 1 && !b.dryRun {
+                prefetched = b.prefetchClaims(token, ordered, claimSlots)
+            }
             // Process tasks
-            for _, task := range tasks {
-                err := postClaimTask(token, task)
+            for _, task := range ordered {
+                if b.sessionOver() {
+                    break
+                }
+                if b.store.HasClaimed(task.ID) {
+                    slog.Debug("skipping task, already claimed", "task_id", task.ID)
+                    b.state.RecordSkips(SkipAlreadyClaimed, []Task{task})
+                    continue
+                }
+                if f, ok := b.store.Forbidden(task); ok {
+                    slog.Debug("skipping task, its campaign answered with 403 before", "task_id", task.ID, "campaign", task.CampaignUid, "forbidden_task", f.TaskID, "at", f.At)
+                    b.state.RecordSkips(SkipForbidden, []Task{task})
+                    continue
+                }
+                settings := b.settingsFor(task)
+                if settings.maxPerCampaign > 0 && b.store.CampaignClaims(task.CampaignUid) >= settings.maxPerCampaign {
+                    slog.Debug("skipping task, campaign claim cap reached", "task_id", task.ID, "campaign", task.CampaignUid, "cap", settings.maxPerCampaign)
+                    b.state.RecordSkips(SkipCampaignCap, []Task{task})
+                    continue
+                }
+                if b.targetCapReached(task, settings) {
+                    slog.Debug("skipping task, target's daily claim cap reached", "task_id", task.ID, "target", task.ListingCodename, "cap", settings.maxClaimsPerDay)
+                    b.state.RecordSkips(SkipTargetCap, []Task{task})
+                    continue
+                }
+                if claimSlots == 0 {
+                    slog.Debug("skipping task, claim limit reached", "task_id", task.ID)
+                    b.state.RecordSkips(SkipClaimLimit, []Task{task})
+                    continue
+                }
+                if settings.dryRun {
+                    slog.Info("dry-run: would claim task", "task_id", task.ID, "campaign", task.CampaignUid)
+                    b.state.RecordSkips(SkipDryRun, []Task{task})
+                    continue
+                }
+                attemptedAt := time.Now()
+                var err error
+                r, pipelined := prefetched[task.ID]
+                if pipelined {
+                    delete(prefetched, task.ID)
+                    attemptedAt, err = r.attemptedAt, r.err
+                } else {
+                    err = postClaimTask(token, task)
+                    timings.Since(PhaseClaim, attemptedAt)
+                }
+                b.recordClaimTiming(task, err, seenAt, attemptedAt)
+                if err != nil && !strings.Contains(err.Error(), "401") {
+                    notifyEvent(Event{
+                        Type:    EventClaimFailed,
+                        Message: fmt.Sprintf("Failed to claim task %s (%s, %s): %v", task.ID, task.Title, task.Payout, err),
+                        Task:    &task,
+                        Target:  task.ListingCodename,
+                        Status:  errorStatus(err),
+                    })
+                }
                 if err != nil {
-                    // If it's a 403, increment counter
+                    // A 403 is remembered, so the mission's campaign isn't
+                    // attempted again; a streak of them is a wider problem.
                     if strings.Contains(err.Error(), "403") {
-                        consecutive403Count++
-                        log.Printf("Got 403. Current consecutive403Count = %d\n", consecutive403Count)
-                        if consecutive403Count >= 5 {
-                            log.Println("Received 403 five times in a row. Stopping the bot.")
-                            return // Graceful exit
+                        consecutive403 = append(consecutive403, task.ID)
+                        errs.Add("claim", task.ID, err)
+                        if err := b.store.AddForbidden(task); err != nil {
+                            slog.Error("failed to record forbidden mission", "task_id", task.ID, "err", err)
                         }
+                        if len(consecutive403) >= 5 {
+                            // Not clearance-gated missions after all: try
+                            // them again once the streak is over.
+                            if err := b.store.ForgetForbidden(consecutive403); err != nil {
+                                slog.Error("failed to forget forbidden missions", "err", err)
+                            }
+                            if b.forbiddenCooldown <= 0 {
+                                streaks.report(cycle, errs)
+                                slog.Error("received 403 five times in a row, stopping the bot")
+                                notify(EventBotStopped, "Bot stopped after 5 consecutive 403 responses.")
+                                return // Graceful exit
+                            }
+                            slog.Warn("received 403 five times in a row, cooling down", "cooldown", b.forbiddenCooldown)
+                            b.state.Pause(PauseCircuitOpen, ResumeTimer, time.Now().Add(b.forbiddenCooldown), "5 consecutive 403 responses")
+                            consecutive403 = nil
+                            break
+                        }
+                    } else if strings.Contains(err.Error(), "401") && b.once {
+                        errs.Add("claim", task.ID, err)
+                        break
                     } else if strings.Contains(err.Error(), "401") {
-                        newToken := refreshToken()
-                        tokenChan <- newToken
-                        token = newToken
-                        consecutive403Count = 0
+                        token = b.refreshToken(token)
+                        consecutive403 = nil
                         break
                     } else {
-                        log.Println(err)
+                        errs.Add("claim", task.ID, err)
                     }
                 } else {
                     // Success, reset the 403 counter
-                    consecutive403Count = 0
-                    fmt.Printf("Claimed task %s successfully.\n", task.ID)
-                    // Sleep 5s per your existing logic
-                    time.Sleep(5 * time.Second)
+                    consecutive403 = nil
+                    if claimSlots > 0 {
+                        claimSlots--
+                    }
+                    b.recordClaim(token, task, &errs)
+                    if b.enforceDailyCaps() {
+                        break
+                    }
+                    if !pipelined {
+                        time.Sleep(jittered(settings.claimDelay, b.jitter))
+                    }
                 }
             }
+            // Pipelined claims the loop stopped before still have to be recorded.
+            for _, r := range prefetched {
+                if r.err == nil {
+                    b.recordClaim(token, r.task, &errs)
+                }
+            }
+        }
+
+        streaks = streaks.report(cycle, errs)
+        timings.Since(PhaseCycle, cycleStart)
+        if b.once {
+            return
         }
+        if !hotCycle {
+            nextPoll = time.Now().Add(jittered(b.taskInterval, b.jitter))
+        }
+        hot = b.sleep(time.Until(nextPoll))
+    }
+}
+
+// tokenExpiring reports whether token expires within the grace period, so
+// only read-only calls should be made with it.
+func (b *bot) tokenExpiring(token string) bool {
+    exp, ok := tokenExpiry(token)
+    return ok && b.tokenGrace > 0 && time.Until(exp) < b.tokenGrace
+}
+
+// enforceTokenGrace pauses claiming while the token is about to expire,
+// so no claim is started with a dying session, and resumes once a fresh
+// token arrives. With a spare token left, it switches to it instead. Other
+// pauses are left alone.
+func (b *bot) enforceTokenGrace(token string) {
+    if !b.tokenExpiring(token) {
+        b.state.ResumeIfTokenRefreshed()
+        return
+    }
+    if _, ok := b.rotateToken(token, "is about to expire"); ok {
+        b.state.ResumeIfTokenRefreshed()
+        return
+    }
+    if b.state.Paused() == nil {
+        exp, _ := tokenExpiry(token)
+        b.state.Pause(PauseTokenGrace, ResumeTokenRefreshed, time.Time{}, fmt.Sprintf("session token expires at %s", exp.Local().Format("15:04:05")))
+    }
+}
 
-        // Sleep 30s between task polls
-        time.Sleep(15 * time.Second)
+// applyClaimRules drops the tasks the claim rules decide to skip.
+func (b *bot) applyClaimRules(token string, tasks []Task) []Task {
+    kept := tasks[:0:0]
+    for _, task := range tasks {
+        claim, rule := b.claimRules.Decide(task, func() (string, error) {
+            detail, ok := b.targetCache.CachedDetail(task.ListingUid)
+            if !ok {
+                b.targetCache.PrefetchDetail(token, task.ListingUid)
+                return "", fmt.Errorf("details of target %s not cached yet", task.ListingUid)
+            }
+            return detail.Category.Name, nil
+        })
+        if !claim {
+            slog.Debug("skipping task, claim rule", "task_id", task.ID, "rule", rule)
+            b.state.RecordSkips(SkipRule, []Task{task})
+            continue
+        }
+        kept = append(kept, task)
     }
+    return kept
+}
+
+// recordClaim records a successfully claimed task and starts the follow-up
+// work: verification, marking it viewed and saving its details. Failures are
+// added to errs.
+func (b *bot) recordClaim(token string, task Task, errs *cycleErrors) {
+    b.state.AddClaimed(task)
+    r, isRepublished := b.store.Republished(task)
+    if err := b.store.AddClaim(task); err != nil {
+        errs.Add("store", task.ID, err)
+    }
+    msg := fmt.Sprintf("Claimed task %s successfully.", task.ID)
+    if isRepublished {
+        msg = fmt.Sprintf("Claimed task %s successfully (%s).", task.ID, r)
+    }
+    notifyEvent(Event{Type: EventMissionClaimed, Message: msg, Task: &task, Target: task.ListingCodename, Status: http.StatusCreated})
+    if b.verifyClaims {
+        goSafe(func() { verifyClaim(token, task) })
+    }
+    if b.markViewed {
+        if err := markTaskViewed(token, task); err != nil {
+            errs.Add("mark_viewed", task.ID, err)
+        }
+    }
+    if b.missionDir != "" {
+        goSafe(func() {
+            dir, err := saveMissionDetail(b.missionDir, token, task, b.targetCache)
+            if err != nil {
+                slog.Warn("failed to save mission details", "endpoint", "mission_detail", "task_id", task.ID, "err", err)
+                return
+            }
+            slog.Info("saved mission details", "task_id", task.ID, "dir", dir)
+        })
+    }
+}
+
+// sessionOver reports whether the deadline of a bounded run has passed.
+func (b *bot) sessionOver() bool {
+    return !b.deadline.IsZero() && !time.Now().Before(b.deadline)
+}
+
+// sleep waits for d, but no longer than until the session deadline. It
+// returns early with the missions a hot target poller found in the meantime,
+// or with none when an immediate poll is requested.
+func (b *bot) sleep(d time.Duration) []Task {
+    if !b.deadline.IsZero() {
+        d = min(d, time.Until(b.deadline))
+    }
+    if d <= 0 {
+        return nil
+    }
+    timer := time.NewTimer(d)
+    defer timer.Stop()
+    select {
+    case <-timer.C:
+        return nil
+    case <-b.pollTasks:
+        return nil
+    case tasks := <-b.hotTasks:
+        return tasks
+    }
+}
+
+// pollNow wakes both polling loops for an immediate poll.
+func (b *bot) pollNow() {
+    for _, c := range []chan struct{}{b.pollTasks, b.pollTargets} {
+        select {
+        case c <- struct{}{}:
+        default:
+            // A poll is already requested.
+        }
+    }
+}
+
+// pollHotTarget polls the missions of one listing at its own interval and
+// hands new ones to mainLoop, which claims them right away, between its full
+// polls. Each mission is handed over once while it stays listed. Errors are
+// only logged; mainLoop's own poll takes care of token refreshes and pauses.
+// It returns once done is closed (never for a nil done).
+func (b *bot) pollHotTarget(t HotTarget, done <-chan struct{}) {
+    delivered := map[string]bool{} // IDs of the listed missions mainLoop got
+    for {
+        tasks, err := getTasksPage(b.tokens.Get(), 1, t.ListingUid)
+        if err != nil {
+            slog.Debug("hot target poll failed", "endpoint", "tasks", "listing", t.ListingUid, "err", err)
+        } else {
+            listed := make(map[string]bool, len(tasks))
+            var fresh []Task
+            for _, task := range tasks {
+                listed[task.ID] = true
+                if !delivered[task.ID] && !b.store.HasClaimed(task.ID) {
+                    fresh = append(fresh, task)
+                }
+            }
+            maps.DeleteFunc(delivered, func(id string, _ bool) bool { return !listed[id] })
+            if len(fresh) > 0 {
+                select {
+                case b.hotTasks <- fresh:
+                    for _, task := range fresh {
+                        delivered[task.ID] = true
+                    }
+                default:
+                    // mainLoop is busy claiming; the next poll finds these again.
+                }
+            }
+        }
+        select {
+        case <-time.After(jittered(t.Interval.Duration, b.jitter)):
+        case <-done:
+            return
+        }
+    }
+}
+
+// recordClaimTiming stores how a claim attempt went, for claim-loss analysis.
+// Only wins and 412 losses are interesting; other failures are not races.
+func (b *bot) recordClaimTiming(task Task, claimErr error, seenAt, attemptedAt time.Time) {
+    won := claimErr == nil
+    if !won && !strings.Contains(claimErr.Error(), "412") {
+        return
+    }
+    timing := newClaimTiming(task, won, seenAt, attemptedAt, time.Now())
+    if err := b.store.AddClaimTiming(timing); err != nil {
+        slog.Error("failed to record claim timing", "task_id", task.ID, "err", err)
+    }
+}
+
+// exportClaimTimings writes the recorded claim timings to a CSV file.
+func exportClaimTimings(db *store, path string) error {
+    f, err := os.Create(path)
+    if err != nil {
+        return err
+    }
+    if err := writeClaimTimingsCSV(f, db.ClaimTimings()); err != nil {
+        f.Close()
+        return err
+    }
+    return f.Close()
 }
 
 func main() {
+    defer crashOnPanic()
+    registerEnvSecrets()
+    if len(os.Args) > 1 {
+        if cmd, ok := subcommands[os.Args[1]]; ok {
+            if err := cmd(os.Args[2:]); err != nil {
+                slog.Error("command failed", "command", os.Args[1], "err", err)
+                os.Exit(1)
+            }
+            return
+        }
+    }
+    args := os.Args[1:]
+    if len(args) > 0 && args[0] == "run" {
+        args = args[1:]
+    } else if len(args) > 0 && args[0] == "once" {
+        args = append([]string{"-once"}, args[1:]...)
+    }
+    run(args)
+}
+
+// run implements the "run" subcommand (the default): it polls and claims
+// missions and signs up for targets until stopped.
+func run(args []string) {
     tokenFlag := flag.String("t", "", "Session token for authentication")
-    verboseFlag := flag.Bool("v", false, "Enable verbose logging")
-    flag.Parse()
+    tokenSocketFlag := flag.String("token-socket", "", "Unix socket of a token daemon to get the token from")
+    tokenFileFlag := flag.String("token-file", "", "File to read the token from; reloaded when it changes")
+    autoLoginFlag := flag.Bool("auto-login", false, "Log in with the email, password and TOTP secret saved by \"keychain set-login\" whenever a new token is needed")
+    spareTokensFlag := flag.String("spare-tokens", "", "File of further tokens of the account, one per line, switched to when the current one expires or is rejected")
+    keychainFlag := flag.Bool("keychain", false, "Load the token from the OS keychain and save new tokens to it")
+    debugAddrFlag := flag.String("debug-addr", "", "Local address (e.g. 127.0.0.1:6060) for unauthenticated pprof, expvar and poll timing endpoints")
+    healthAddrFlag := flag.String("health-addr", "", "Address (e.g. 0.0.0.0:8779) for the unauthenticated /healthz endpoint")
+    healthMaxAgeFlag := flag.Duration("health-max-age", 0, "Fail /healthz after this long without a successful mission poll (default 3 poll intervals, at least 5m)")
+    controlAddrFlag := flag.String("control-addr", "", "Local address (e.g. 127.0.0.1:8778) for the control API")
+    controlTokenFlag := flag.String("control-token-file", "", "File containing the bearer token required by the control API")
+    controlHostFlag := flag.String("control-host", "", "Comma separated host names the control API accepts besides localhost, e.g. the VPS name \"token push\" uses")
+    controlCertFlag := flag.String("control-tls-cert", "", "TLS certificate (PEM) for serving the control API over HTTPS")
+    controlKeyFlag := flag.String("control-tls-key", "", "TLS private key (PEM) for -control-tls-cert")
+    tokenListenerFlag := flag.String("token-listener", "", "Local address (e.g. 127.0.0.1:8777) to accept tokens pushed by a browser extension")
+    listenerSecretFlag := flag.String("token-listener-secret-file", "", "File containing the secret required by the token listener")
+    verboseFlag := flag.Bool("v", false, "Enable verbose logging (same as -log-level debug)")
+    logLevelFlag := flag.String("log-level", "info", "Minimum log level: debug, info, warn or error")
+    logFormatFlag := flag.String("log-format", "text", "Log output format: text or json")
+    logFileFlag := flag.String("log-file", "", "Write logs to this file instead of stderr")
+    logMaxSizeFlag := flag.Int("log-max-size", 10, "Rotate the log file when it grows past this many megabytes (0 = no limit)")
+    logMaxAgeFlag := flag.Duration("log-max-age", 0, "Rotate the log file when it gets older than this (0 = no limit)")
+    logBackupsFlag := flag.Int("log-backups", 5, "Rotated log files to keep")
+    daemonFlag := flag.Bool("daemon", false, "Run in the background, detached from the terminal (requires -log-file)")
+    pidFileFlag := flag.String("pid-file", "", "Write the process ID to this file while running")
+    configFlag := flag.String("config", "", "Path to a JSON config file")
+    accountFlag := flag.String("account", "", "Run only this account of the config's accounts list (set by the account supervisor)")
+    presetFlag := flag.String("preset", "", "Built-in strategy preset: conservative, balanced, aggressive or watch-only")
+    dryRunFlag := flag.Bool("dry-run", false, "Log what would be claimed or signed up for without sending POST requests")
+    notifyBatchFlag := flag.Duration("notify-batch", 0, "Batch notifications over this window into one summary (0 disables)")
+    desktopNotifyFlag := flag.Bool("desktop-notify", false, "Show desktop notifications for claims, failed claims and token expiry")
+    tuiFlag := flag.Bool("tui", false, "Show a live terminal dashboard")
+    dbFlag := flag.String("db", "", "Path to the local mission history database")
+    dbPassphraseFlag := flag.String("db-passphrase-file", "", "File containing the passphrase used to encrypt the database")
+    insecureFlag := flag.Bool("insecure", false, "Skip TLS certificate verification (debugging only)")
+    caBundleFlag := flag.String("ca-bundle", "", "PEM file with additional CA certificates to trust")
+    pinFlag := flag.String("pin-sha256", "", "Comma separated SHA-256 SPKI pins (base64 or hex), one of which must match")
+    proxyFlag := flag.String("proxy", "", "Proxy URL (http, https, socks5 or socks5h); defaults to HTTP_PROXY/HTTPS_PROXY")
+    verifyClaimsFlag := flag.Bool("verify-claims", true, "Verify claimed missions appear in the claimed list and alert if not")
+    markViewedFlag := flag.Bool("mark-viewed", false, "Mark each claimed mission as viewed, as opening it in the web client does")
+    claimLimitFlag := flag.Int("claim-limit", 0, "Pause claiming while this many missions are claimed (0 = no limit)")
+    maxPerCampaignFlag := flag.Int("max-per-campaign", 0, "Claim at most this many tasks per campaign (0 = unlimited)")
+    maxClaimsPerDayFlag := flag.Int("max-claims-per-day", 0, "Pause claiming until midnight after this many claims in a day (0 = unlimited)")
+    maxEarningsPerDayFlag := flag.Float64("max-earnings-per-day", 0, "Pause claiming until midnight once the day's claimed payouts reach this amount (0 = unlimited)")
+    dayTimezoneFlag := flag.String("day-timezone", "", "Time zone (e.g. Europe/Berlin) whose midnight starts a new day for the daily caps (default local)")
+    vacationFlag := flag.String("vacation-until", "", "Keep claiming paused until this date (2006-01-02 or RFC 3339)")
+    archiveDirFlag := flag.String("archive-dir", "", "Directory to archive raw task poll responses in")
+    missionDirFlag := flag.String("mission-dir", "", "Save the full details of every claimed mission in this directory")
+    auditLogFlag := flag.String("audit-log", "", "Append every API call, claim, signup, token change and state change to this JSON lines file")
+    apiJournalFlag := flag.String("api-journal", "", "Record API changes (new fields, unhandled status codes) to this JSON lines file")
+    apiJournalReportFlag := flag.String("api-journal-report", "", "Also send each API change, anonymized, to this aggregation URL")
+    strictFlag := flag.Bool("strict", false, "Minimize stored confidential data: no archiving, redacted and encrypted database, 7 day retention")
+    taskIntervalFlag := flag.Duration("task-interval", 15*time.Second, "Time between mission polls")
+    claimDelayFlag := flag.Duration("claim-delay", 5*time.Second, "Time to wait after each successful claim")
+    targetIntervalFlag := flag.Duration("target-interval", 5*time.Minute, "Time between unregistered target polls")
+    jitterFlag := flag.Int("jitter", 10, "Randomize the intervals by up to this percentage in either direction")
+    announcementsFlag := flag.Duration("announcement-interval", 30*time.Minute, "Check the platform announcements this often and notify on new ones (0 disables)")
+    activitySyncFlag := flag.Duration("activity-sync", 0, "Reconcile the account's activity feed with -audit-log this often and notify on activity the bot didn't do (0 disables)")
+    skipSummaryFlag := flag.Duration("skip-summary", time.Hour, "Report the missions skipped, by reason, this often (0 disables)")
+    remindersFlag := flag.String("deadline-reminders", "50,75,90", "Remind when this percentage of a claimed mission's completion time has elapsed (empty disables)")
+    claimPipelineFlag := flag.Int("claim-pipeline", 1, "Claim with this many workers at once over HTTP/2 (1 = one after another)")
+    forbiddenCooldownFlag := flag.Duration("forbidden-cooldown", time.Hour, "Pause claiming this long after 5 consecutive 403s (0 = stop the bot)")
+    runForFlag := flag.Duration("run-for", 0, "Stop gracefully with a session summary after this long (e.g. 8h)")
+    untilFlag := flag.String("until", "", "Stop gracefully with a session summary at this time (15:04 or RFC 3339)")
+    maxTaskPagesFlag := flag.Int("max-task-pages", 10, "Read at most this many pages of 20 missions per poll (0 = no limit)")
+    expiryWarningFlag := flag.Duration("expiry-warning", 10*time.Minute, "Notify this long before the token expires (0 disables)")
+    skipRepublishedFlag := flag.String("skip-republished", "", "Don't claim re-published missions whose earlier claim was released and/or completed (e.g. released,completed)")
+    tokenGraceFlag := flag.Duration("token-grace", 2*time.Minute, "Stop claims and signups this long before the token expires until a fresh token arrives (0 disables)")
+    latencyProbeFlag := flag.Duration("latency-probe", 5*time.Minute, "Measure RTT and TLS handshake time to the platform this often (0 disables)")
+    onceFlag := flag.Bool("once", false, "Poll and claim missions once, check targets once, print the result as JSON and exit")
+    exportTimingsFlag := flag.String("export-claim-timings", "", "Export anonymized claim timing data to this CSV file and exit")
+    flag.CommandLine.Parse(args)
+    if *presetFlag != "" {
+        if err := applyPreset(flag.CommandLine, *presetFlag); err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            os.Exit(1)
+        }
+    }
 
-    if *tokenFlag == "" {
+    if *onceFlag && (*daemonFlag || *tuiFlag) {
+        fmt.Fprintln(os.Stderr, "once cannot be used with -daemon or -tui")
+        os.Exit(1)
+    }
+    if *daemonFlag && !daemonized() {
+        if *logFileFlag == "" || *tuiFlag {
+            fmt.Fprintln(os.Stderr, "-daemon requires -log-file and cannot be used with -tui")
+            os.Exit(1)
+        }
+        var cfg *Config
+        if *configFlag != "" {
+            c, err := loadConfig(*configFlag)
+            if err != nil {
+                fmt.Fprintln(os.Stderr, "-config:", err)
+                os.Exit(1)
+            }
+            cfg = &c
+        }
+        if err := checkDaemonToken(args, cfg); err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            os.Exit(1)
+        }
+        if err := daemonize(args, *logFileFlag); err != nil {
+            fmt.Fprintln(os.Stderr, "starting daemon:", err)
+            os.Exit(1)
+        }
+        return
+    }
+    logOutput := io.Writer(os.Stderr)
+    if *logFileFlag != "" {
+        lf, err := openRotatingLog(*logFileFlag, int64(*logMaxSizeFlag)<<20, *logMaxAgeFlag, *logBackupsFlag)
+        if err != nil {
+            fmt.Fprintln(os.Stderr, "opening log file:", err)
+            os.Exit(1)
+        }
+        logFile, logOutput = lf, lf
+    }
+    if daemonized() || *onceFlag {
+        // Nobody reads stdout, or it carries the result of "once"; events
+        // go to the log.
+        notifier = logNotifier{}
+    }
+    if *pidFileFlag != "" {
+        if err := writePidFile(*pidFileFlag); err != nil {
+            fmt.Fprintln(os.Stderr, "-pid-file:", err)
+            os.Exit(1)
+        }
+        defer os.Remove(*pidFileFlag)
+    }
+
+    if *configFlag != "" {
+        // With an accounts list, this process only supervises one bot
+        // process per account. A config that can't be loaded can't tell
+        // whether it has one, so it stops the bot rather than running a
+        // single account with the supervisor's flags.
+        cfg, err := loadConfig(*configFlag)
+        if err != nil {
+            fmt.Fprintln(os.Stderr, "-config:", err)
+            os.Exit(1)
+        }
+        if *accountFlag != "" && !slices.ContainsFunc(cfg.Accounts, func(a AccountConfig) bool { return a.Name == *accountFlag }) {
+            fmt.Fprintf(os.Stderr, "-account: no account %q in the config's accounts list\n", *accountFlag)
+            os.Exit(1)
+        }
+        if *accountFlag == "" && len(cfg.Accounts) > 0 {
+            if *onceFlag {
+                fmt.Fprintln(os.Stderr, "once doesn't run accounts; run it per account, with -account and the account's flags")
+                os.Exit(1)
+            }
+            if err := setupLogging(logOutput, *logLevelFlag, *logFormatFlag); err != nil {
+                fmt.Fprintln(os.Stderr, err)
+                os.Exit(1)
+            }
+            if err := runAccounts(cfg.Accounts, args, logOutput); err != nil {
+                slog.Error("account supervisor failed", "err", err)
+                os.Exit(1)
+            }
+            return
+        }
+    } else if *accountFlag != "" {
+        fmt.Fprintln(os.Stderr, "-account requires a -config with an accounts list")
+        os.Exit(1)
+    }
+
+    if *pidFileFlag != "" {
+        goSafe(func() { removePidFileOnStop(*pidFileFlag) })
+    }
+
+    if *tokenFlag == "" && *tokenSocketFlag == "" && *tokenFileFlag == "" && *spareTokensFlag == "" && !*autoLoginFlag && !*keychainFlag && *tokenListenerFlag == "" && *exportTimingsFlag == "" {
         flag.Usage()
         os.Exit(1)
     }
 
+    if *taskIntervalFlag <= 0 || *claimDelayFlag < 0 || *targetIntervalFlag <= 0 || *jitterFlag < 0 || *jitterFlag > 100 {
+        fmt.Fprintln(os.Stderr, "intervals must be positive and -jitter between 0 and 100")
+        os.Exit(1)
+    }
+    if *activitySyncFlag > 0 && *auditLogFlag == "" {
+        fmt.Fprintln(os.Stderr, "-activity-sync requires -audit-log")
+        os.Exit(1)
+    }
+    if *claimPipelineFlag < 1 {
+        fmt.Fprintln(os.Stderr, "-claim-pipeline must be at least 1")
+        os.Exit(1)
+    }
+    dayLoc := time.Local
+    if *dayTimezoneFlag != "" {
+        loc, err := time.LoadLocation(*dayTimezoneFlag)
+        if err != nil {
+            fmt.Fprintln(os.Stderr, "invalid -day-timezone:", err)
+            os.Exit(1)
+        }
+        dayLoc = loc
+    }
+    reminderThresholds, err := parseReminderThresholds(*remindersFlag)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(1)
+    }
+    skipRepublished, err := parseRepublishedPolicy(*skipRepublishedFlag)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, "-skip-republished:", err)
+        os.Exit(1)
+    }
+    if (*controlCertFlag == "") != (*controlKeyFlag == "") {
+        fmt.Fprintln(os.Stderr, "-control-tls-cert and -control-tls-key must be given together")
+        os.Exit(1)
+    }
+    var listenerSecret string
+    if *tokenListenerFlag != "" {
+        if listenerSecret, err = readListenerSecret(*listenerSecretFlag); err != nil || listenerSecret == "" {
+            fmt.Fprintln(os.Stderr, "-token-listener requires a secret in -token-listener-secret-file or MISSION_BOT_LISTENER_SECRET")
+            os.Exit(1)
+        }
+    }
+    var controlToken string
+    if *controlAddrFlag != "" {
+        if controlToken, err = readControlToken(*controlTokenFlag); err != nil || controlToken == "" {
+            fmt.Fprintln(os.Stderr, "-control-addr requires a token in -control-token-file or MISSION_BOT_CONTROL_TOKEN")
+            os.Exit(1)
+        }
+    }
+
     token := *tokenFlag
-    verbose := *verboseFlag
+    dryRun := *dryRunFlag
+
+    logLevel := *logLevelFlag
+    if *verboseFlag {
+        logLevel = "debug"
+    }
+    state := &botState{}
+    if *tuiFlag {
+        // The dashboard owns the terminal; logs and notifications go to its
+        // log pane (and the log file).
+        if logFile != nil {
+            logOutput = io.MultiWriter(state, logFile)
+        } else {
+            logOutput = state
+        }
+        notifier = logNotifier{}
+    }
+    if err := setupLogging(logOutput, logLevel, *logFormatFlag); err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(1)
+    }
+    if *accountFlag != "" {
+        setAccount(*accountFlag)
+    }
+    recordErrors(state.AddError)
 
-    // Known slugs map to track which slugs have been processed
-    knownSlugs := &sync.Map{}
+    if *insecureFlag {
+        slog.Warn("TLS certificate verification is disabled; your session token can be intercepted")
+    }
 
-    // Channel to communicate token updates between goroutines
-    tokenChan := make(chan string)
+    // Each stage is retried on transient failures; a stage that still
+    // fails stops the bot before it begins polling.
+    stage := func(name string, fn func() error) {
+        if err := runStage(name, fn); err != nil {
+            slog.Error("startup failed", "stage", name, "err", err)
+            os.Exit(1)
+        }
+    }
 
-    // Start polling unregistered targets every 5 mins
-    go pollUnregisteredTargets(token, knownSlugs, tokenChan, verbose)
+    var cfg Config
+    var deadline time.Time
+    stage("config", func() error {
+        if err := configureTLS(*insecureFlag, *caBundleFlag, *pinFlag); err != nil {
+            return unlessIO(fmt.Errorf("invalid TLS settings: %w", err))
+        }
+        if err := configureProxy(*proxyFlag); err != nil {
+            return permanent(fmt.Errorf("invalid proxy settings: %w", err))
+        }
+        var err error
+        if cfg, err = loadConfig(*configFlag); err != nil {
+            return unlessIO(err)
+        }
+        setStatusPolicies(cfg.StatusPolicies)
+        setTargetOverrides(cfg.TargetOverrides)
+        setDeepLinks(cfg.DeepLinks)
+        if *vacationFlag != "" {
+            until, err := parseVacationUntil(*vacationFlag)
+            if err != nil {
+                return permanent(fmt.Errorf("invalid vacation date: %w", err))
+            }
+            state.Pause(PauseVacation, ResumeTimer, until, "")
+        }
+        if deadline, err = sessionDeadline(time.Now(), *runForFlag, *untilFlag); err != nil {
+            return permanent(fmt.Errorf("invalid run limit: %w", err))
+        }
+        return nil
+    })
+
+    var db *store
+    stage("storage", func() error {
+        passphrase, err := readPassphrase(*dbPassphraseFlag)
+        if err != nil {
+            return fmt.Errorf("reading database passphrase: %w", err)
+        }
+        if *strictFlag {
+            if *archiveDirFlag != "" {
+                return permanent(fmt.Errorf("-archive-dir stores raw responses and cannot be used with -strict"))
+            }
+            if *missionDirFlag != "" {
+                return permanent(fmt.Errorf("-mission-dir stores mission briefs and cannot be used with -strict"))
+            }
+            if *auditLogFlag != "" {
+                return permanent(fmt.Errorf("-audit-log keeps mission titles indefinitely and cannot be used with -strict"))
+            }
+            if *dbFlag != "" && len(passphrase) == 0 {
+                return permanent(fmt.Errorf("-strict requires an encrypted database; set -db-passphrase-file or MISSION_BOT_DB_PASSPHRASE"))
+            }
+        }
+        if db, err = openStore(*dbFlag, passphrase); err != nil {
+            return unlessIO(err)
+        }
+        if *strictFlag {
+            if err := db.EnableStrict(); err != nil {
+                return fmt.Errorf("applying strict mode to the database: %w", err)
+            }
+        }
+        if *auditLogFlag != "" && audit == nil {
+            if audit, err = openAuditLog(*auditLogFlag); err != nil {
+                return unlessIO(err)
+            }
+        }
+        if *apiJournalReportFlag != "" && *apiJournalFlag == "" {
+            return permanent(fmt.Errorf("-api-journal-report requires -api-journal"))
+        }
+        if *apiJournalFlag != "" && journal == nil {
+            if journal, err = openAPIJournal(*apiJournalFlag, *apiJournalReportFlag); err != nil {
+                return unlessIO(err)
+            }
+        }
+        return nil
+    })
+
+    if *exportTimingsFlag != "" {
+        if err := exportClaimTimings(db, *exportTimingsFlag); err != nil {
+            slog.Error("failed to export claim timings", "err", err)
+            os.Exit(1)
+        }
+        return
+    }
+
+    if *archiveDirFlag != "" {
+        archive = &responseArchive{dir: *archiveDirFlag}
+    }
+
+    var spares *spareTokens
+    stage("auth", func() error {
+        var err error
+        if *tokenSocketFlag != "" {
+            if token, err = fetchSocketToken(*tokenSocketFlag); err != nil {
+                return fmt.Errorf("getting token from token daemon: %w", err)
+            }
+        }
+        if *tokenFileFlag != "" {
+            if token, err = readTokenFile(*tokenFileFlag); err != nil {
+                return fmt.Errorf("reading token file: %w", err)
+            }
+        }
+        if *keychainFlag && token == "" {
+            if token, err = loadKeychainToken(); err != nil {
+                return err
+            }
+        } else if *keychainFlag {
+            if err := keychainSet(keychainEntry(keychainAccount, accountName), token); err != nil {
+                slog.Warn("failed to save token to keychain", "err", err)
+            }
+        }
+        if *spareTokensFlag != "" {
+            if spares, err = loadSpareTokens(*spareTokensFlag); err != nil {
+                return unlessIO(fmt.Errorf("reading spare tokens: %w", err))
+            }
+            if token == "" {
+                token, _ = spares.Next("", *tokenGraceFlag)
+            }
+        }
+        if *autoLoginFlag && token == "" {
+            creds, err := loadLoginCredentials()
+            if err != nil {
+                return err
+            }
+            if token, err = login(creds); err != nil {
+                return fmt.Errorf("logging in: %w", err)
+            }
+        }
+        if exp, ok := tokenExpiry(token); ok && time.Now().After(exp) {
+            slog.Warn("session token has expired; a new one will be requested on the first 401", "expired", exp.Format(time.RFC3339))
+        }
+        return nil
+    })
+
+    // Notifications are not essential: a channel that stays unreachable is
+    // reported, but the bot starts anyway.
+    channels := notificationChannels(cfg)
+    if err := runStage("notifiers", func() error { return checkNotificationChannels(channels) }); err != nil {
+        slog.Warn("notification channel check failed; continuing without guarantee of delivery", "err", err)
+    }
+    // Batch per channel, so a slow or rate limited channel doesn't hold back the others.
+    sinks := multiNotifier{notificationChannel{Notifier: notifier}.sink(*notifyBatchFlag)}
+    for _, c := range channels {
+        sinks = append(sinks, c.sink(*notifyBatchFlag))
+    }
+    if *controlAddrFlag != "" {
+        // The dashboard shows events live, unbatched.
+        sinks = append(sinks, dashboardEvents)
+    }
+    if *tokenListenerFlag != "" {
+        // The browser extension shows events as they happen, too.
+        sinks = append(sinks, extensionEvents)
+    }
+    var eventHooks *hookSupervisor
+    if len(cfg.EventHooks.Hooks) > 0 {
+        eventHooks = newHookSupervisor(cfg.EventHooks.MaxConcurrent, cfg.EventHooks.OutputDir, state.UpdateHookRun)
+        sinks = append(sinks, eventHookNotifier{hooks: cfg.EventHooks.Hooks, supervisor: eventHooks})
+    }
+    if *desktopNotifyFlag {
+        sinks = append(sinks, notificationChannel{Notifier: desktopNotifier{}, Events: desktopEvents}.sink(*notifyBatchFlag))
+    }
+    if audit != nil {
+        sinks = append(sinks, auditNotifier{})
+    }
+    notifier = sinks
+
+    if !deadline.IsZero() {
+        slog.Info("bounded run", "until", deadline.Format(time.RFC3339))
+        goSafe(func() {
+            time.Sleep(time.Until(deadline) + sessionGrace)
+            slog.Warn("bot did not stop within the grace period after the deadline; exiting")
+            notify(EventSessionEnded, "%s", state.Session().Summary(time.Now()))
+            flushNotifications()
+            if err := db.Flush(); err != nil {
+                slog.Error("failed to save the database", "err", err)
+            }
+            os.Exit(0)
+        })
+    }
+    state.StartSession(time.Now())
+
+    b := &bot{
+        tokenSocket:       *tokenSocketFlag,
+        tokenFile:         *tokenFileFlag,
+        tokenListener:     *tokenListenerFlag,
+        tokens:            newTokenStore(token),
+        cfg:               cfg,
+        state:             state,
+        store:             db,
+        dryRun:            dryRun,
+        maxPerCampaign:    *maxPerCampaignFlag,
+        dailyCaps:         dailyCaps{maxClaims: *maxClaimsPerDayFlag, maxEarnings: *maxEarningsPerDayFlag, loc: dayLoc},
+        claimLimit:        *claimLimitFlag,
+        verifyClaims:      *verifyClaimsFlag,
+        markViewed:        *markViewedFlag,
+        maxTaskPages:      *maxTaskPagesFlag,
+        deadline:          deadline,
+        once:              *onceFlag,
+        taskInterval:      *taskIntervalFlag,
+        claimDelay:        *claimDelayFlag,
+        targetInterval:    *targetIntervalFlag,
+        jitter:            float64(*jitterFlag) / 100,
+        forbiddenCooldown: *forbiddenCooldownFlag,
+        tokenGrace:        *tokenGraceFlag,
+        spareTokens:       spares,
+        autoLoginEnabled:  *autoLoginFlag,
+        skipRepublished:   skipRepublished,
+        missionDir:        *missionDirFlag,
+        claimPipeline:     *claimPipelineFlag,
+        // Known slugs map to track which slugs have been processed
+        knownSlugs:  &sync.Map{},
+        targetCache: newTargetCache(cfg.TargetCache),
+        claimRules:  &ruleSet{},
+        approvals:   newSignupApprovals(),
+        hotTasks:    make(chan []Task),
+        pollTasks:   make(chan struct{}, 1),
+        pollTargets: make(chan struct{}, 1),
+    }
+    // loadConfig has validated the rules and quiet hours.
+    rules, _ := compileClaimRules(cfg.ClaimRules)
+    b.claimRules.Set(rules)
+    b.quietHours, _ = newQuietHours(cfg.QuietHours)
+    // Slugs signed up for in earlier runs are not attempted again.
+    for _, slug := range db.KnownSlugs() {
+        b.knownSlugs.Store(slug, true)
+    }
+
+    b.hooks = newHookSupervisor(cfg.SignupHooks.MaxConcurrent, cfg.SignupHooks.OutputDir, state.UpdateHookRun)
+
+    var tokenListenerLn, controlLn, healthLn, debugLn net.Listener
+    stage("pollers", func() error {
+        if b.once {
+            // A single cycle runs in the foreground below.
+            return nil
+        }
+        // Bind the listeners first, so a busy port is retried like any
+        // other startup failure.
+        if b.tokenListener != "" && tokenListenerLn == nil {
+            ln, err := net.Listen("tcp", b.tokenListener)
+            if err != nil {
+                return err
+            }
+            tokenListenerLn = ln
+        }
+        if *controlAddrFlag != "" && controlLn == nil {
+            ln, err := net.Listen("tcp", *controlAddrFlag)
+            if err != nil {
+                return err
+            }
+            controlLn = ln
+        }
+        if *healthAddrFlag != "" && healthLn == nil {
+            ln, err := net.Listen("tcp", *healthAddrFlag)
+            if err != nil {
+                return err
+            }
+            healthLn = ln
+        }
+        if *debugAddrFlag != "" && debugLn == nil {
+            ln, err := net.Listen("tcp", *debugAddrFlag)
+            if err != nil {
+                return err
+            }
+            debugLn = ln
+        }
+
+        if b.tokenFile != "" {
+            goSafe(func() { watchTokenFile(b.tokenFile, b.tokens) })
+        }
+        if *configFlag != "" {
+            goSafe(func() { b.watchConfig(*configFlag) })
+        }
+        goSafe(func() { b.reloadOnSignal(*configFlag) })
+        if *keychainFlag {
+            goSafe(func() { syncTokensToKeychain(b.tokens) })
+        }
+        if tokenListenerLn != nil {
+            goSafe(func() { runTokenListener(tokenListenerLn, b.tokens, extensionEvents, listenerSecret) })
+        }
+        goSafe(func() { watchTokenExpiry(b.tokens, *expiryWarningFlag) })
+        if audit != nil {
+            goSafe(func() { auditTokens(b.tokens) })
+        }
+        if *latencyProbeFlag > 0 {
+            goSafe(func() { monitorLatency(*latencyProbeFlag, state) })
+        }
+        if cfg.Telegram.BotToken != "" {
+            goSafe(func() { b.runTelegramControl() })
+        }
+
+        for _, t := range cfg.HotTargets {
+            goSafe(func() { b.pollHotTarget(t, nil) })
+        }
+        for _, n := range cfg.Notifications {
+            if n.Type == "email" && n.DigestAt != "" {
+                goSafe(func() { b.sendDigests(n) })
+            }
+        }
+        if len(reminderThresholds) > 0 || controlLn != nil {
+            goSafe(func() { b.trackDeadlines(reminderThresholds) })
+        }
+        if *skipSummaryFlag > 0 {
+            goSafe(func() { b.summarizeSkips(*skipSummaryFlag) })
+        }
+        if controlLn != nil {
+            goSafe(func() { newControlServer(controlToken, parseControlHosts(*controlHostFlag), b).Serve(controlLn, *controlCertFlag, *controlKeyFlag) })
+        }
+        if healthLn != nil {
+            goSafe(func() { b.serveHealth(healthLn, *healthMaxAgeFlag) })
+        }
+        if debugLn != nil {
+            goSafe(func() { serveDebug(debugLn) })
+        }
+        if *activitySyncFlag > 0 {
+            goSafe(func() { b.syncActivity(*activitySyncFlag) })
+        }
+        if *announcementsFlag > 0 {
+            goSafe(func() { b.pollAnnouncements(*announcementsFlag) })
+        }
+
+        // Start polling unregistered targets every target interval
+        goSafe(func() { b.pollUnregisteredTargets() })
+        return nil
+    })
+
+    if b.once {
+        code := b.runOnce(eventHooks)
+        if *pidFileFlag != "" {
+            os.Remove(*pidFileFlag)
+        }
+        os.Exit(code)
+    }
 
     // Start the main loop to poll tasks and claim them
-    mainLoop(token, tokenChan, verbose)
-}
\ No newline at end of file
+    if *tuiFlag {
+        done := make(chan struct{})
+        goSafe(func() {
+            b.mainLoop()
+            close(done)
+        })
+        runTUI(state, done)
+    } else {
+        b.mainLoop()
+    }
+    if b.sessionOver() {
+        notify(EventSessionEnded, "%s", state.Session().Summary(time.Now()))
+    }
+    flushNotifications()
+    if err := b.store.Flush(); err != nil {
+        slog.Error("failed to save the database", "err", err)
+    }
+}
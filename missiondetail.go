@@ -0,0 +1,209 @@
+package main
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "log/slog"
+    "net/http"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+// missionDetail is the part of a task's full details rendered to Markdown.
+// The complete response is kept alongside as JSON.
+type missionDetail struct {
+    ID              string              `json:"id"`
+    Title           string              `json:"title"`
+    Description     string              `json:"description"`
+    Scope           string              `json:"scope"`
+    ListingCodename string              `json:"listingCodename"`
+    Category        string              `json:"category"`
+    Payout          TaskPayout          `json:"payout"`
+    Deadline        platformTime        `json:"deadline"`
+    Attachments     []missionAttachment `json:"attachments"`
+    Questions       []missionQuestion   `json:"questions"`
+}
+
+// missionQuestion is one of the questions a mission's answer must address.
+type missionQuestion struct {
+    ID   string `json:"id"`
+    Text string `json:"text"`
+}
+
+// missionAttachment is a file attached to a mission.
+type missionAttachment struct {
+    Filename string `json:"filename"`
+    URL      string `json:"url"`
+}
+
+// getMissionDetail retrieves the full details of a claimed task as raw JSON.
+func getMissionDetail(token string, task Task) ([]byte, error) {
+    url := fmt.Sprintf(
+        "https://platform.synack.com/api/tasks/v1/organizations/%s/listings/%s/campaigns/%s/tasks/%s",
+        task.OrganizationUid, task.ListingUid, task.CampaignUid, task.ID,
+    )
+    resp, err := doWithRetry("mission_detail", defaultRetryPolicy, func() (*http.Request, error) {
+        return newAPIRequest("GET", url, token, nil)
+    })
+    if err != nil {
+        return nil, err
+    }
+    defer closeBody(resp)
+    logResponse("mission_detail", resp, "task_id", task.ID)
+
+    switch resp.StatusCode {
+    case http.StatusOK:
+        return io.ReadAll(resp.Body)
+    case http.StatusUnauthorized:
+        return nil, fmt.Errorf("unauthorized (401)")
+    default:
+        return nil, unexpectedStatus("mission_detail", resp.StatusCode, fmt.Errorf("failed to retrieve mission details, status code: %d", resp.StatusCode))
+    }
+}
+
+// saveMissionDetail fetches a claimed mission's details and writes them to
+// dir/<task id>/ as mission.json, a rendered mission.md and an evidence.md
+// answer template (mode 0600; the details are confidential). An existing
+// evidence.md is never overwritten, since it may already hold work. The
+// target's details and scope are added to mission.md from targets when
+// available.
+func saveMissionDetail(dir, token string, task Task, targets *targetCache) (string, error) {
+    raw, err := getMissionDetail(token, task)
+    if err != nil {
+        return "", err
+    }
+    var detail missionDetail
+    if err := json.Unmarshal(raw, &detail); err != nil {
+        return "", fmt.Errorf("parsing mission details: %w", err)
+    }
+
+    missionDir := filepath.Join(dir, filepath.Base(task.ID))
+    if err := os.MkdirAll(missionDir, 0700); err != nil {
+        return "", err
+    }
+    if err := os.WriteFile(filepath.Join(missionDir, "mission.json"), raw, 0600); err != nil {
+        return "", err
+    }
+    md := renderMissionMarkdown(detail)
+    if target, err := targets.Detail(token, task.ListingUid); err != nil {
+        slog.Warn("failed to retrieve target details", "endpoint", "target_detail", "task_id", task.ID, "err", err)
+    } else if scope, err := targets.Scope(token, task.ListingUid); err != nil {
+        slog.Warn("failed to retrieve target scope", "endpoint", "target_scope", "task_id", task.ID, "err", err)
+    } else {
+        md += renderTargetMarkdown(target, scope)
+    }
+    if err := os.WriteFile(filepath.Join(missionDir, "mission.md"), []byte(md), 0600); err != nil {
+        return "", err
+    }
+    evidence, err := os.OpenFile(filepath.Join(missionDir, "evidence.md"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+    if errors.Is(err, os.ErrExist) {
+        return missionDir, nil
+    } else if err != nil {
+        return "", err
+    }
+    if _, err := evidence.WriteString(renderEvidenceTemplate(detail)); err != nil {
+        evidence.Close()
+        return "", err
+    }
+    return missionDir, evidence.Close()
+}
+
+// renderMissionMarkdown renders mission details for reading offline.
+func renderMissionMarkdown(d missionDetail) string {
+    var b strings.Builder
+    fmt.Fprintf(&b, "# %s\n\n", d.Title)
+    fmt.Fprintf(&b, "- Task: %s\n", d.ID)
+    if d.ListingCodename != "" {
+        fmt.Fprintf(&b, "- Target: %s\n", d.ListingCodename)
+    }
+    if d.Category != "" {
+        fmt.Fprintf(&b, "- Category: %s\n", d.Category)
+    }
+    fmt.Fprintf(&b, "- Payout: %s\n", d.Payout)
+    if !d.Deadline.IsZero() {
+        fmt.Fprintf(&b, "- Deadline: %s\n", d.Deadline.Local().Format("2006-01-02 15:04 MST"))
+    }
+    if d.Description != "" {
+        fmt.Fprintf(&b, "\n## Description\n\n%s\n", strings.TrimSpace(d.Description))
+    }
+    if d.Scope != "" {
+        fmt.Fprintf(&b, "\n## Scope\n\n%s\n", strings.TrimSpace(d.Scope))
+    }
+    if len(d.Attachments) > 0 {
+        b.WriteString("\n## Attachments\n\n")
+        for _, a := range d.Attachments {
+            fmt.Fprintf(&b, "- [%s](%s)\n", a.Filename, a.URL)
+        }
+    }
+    return b.String()
+}
+
+// renderTargetMarkdown renders the target section of mission.md.
+func renderTargetMarkdown(t targetDetail, scope []scopeAsset) string {
+    var b strings.Builder
+    fmt.Fprintf(&b, "\n## Target %s\n\n", t.Codename)
+    if t.Category.Name != "" {
+        fmt.Fprintf(&b, "- Category: %s\n", t.Category.Name)
+    }
+    if t.Description != "" {
+        fmt.Fprintf(&b, "\n%s\n", strings.TrimSpace(t.Description))
+    }
+    if len(scope) > 0 {
+        b.WriteString("\n### In-scope assets\n\n")
+        for _, a := range scope {
+            if a.Type != "" {
+                fmt.Fprintf(&b, "- %s (%s)\n", a.Location, a.Type)
+            } else {
+                fmt.Fprintf(&b, "- %s\n", a.Location)
+            }
+        }
+    }
+    return b.String()
+}
+
+// evidenceHints are the category-specific prompts for the methodology
+// section of the evidence template, keyed by lower-cased mission category.
+var evidenceHints = map[string]string{
+    "sqli":           "Parameters tested, payloads (boolean/time/error/union based), DBMS fingerprint, tool output (e.g. sqlmap) with the exact request.",
+    "xss":            "Injection points, contexts (HTML, attribute, JS, URL), payloads and encodings tried, CSP and filter behaviour, browser used.",
+    "authentication": "Login, logout, session and reset flows tested; accounts and roles used; token handling observations.",
+    "authorization":  "Roles and accounts compared, objects/functions accessed across them, requests replayed with swapped identifiers.",
+}
+
+// renderEvidenceTemplate renders a pre-filled answer template following the
+// mission answer sections, with one placeholder per mission question.
+func renderEvidenceTemplate(d missionDetail) string {
+    var b strings.Builder
+    fmt.Fprintf(&b, "# %s\n\n", d.Title)
+    fmt.Fprintf(&b, "Task: %s", d.ID)
+    if d.ListingCodename != "" {
+        fmt.Fprintf(&b, " · Target: %s", d.ListingCodename)
+    }
+    if d.Category != "" {
+        fmt.Fprintf(&b, " · Category: %s", d.Category)
+    }
+    b.WriteString("\n\n## Introduction\n\n<What was tested and against which assets in scope.>\n")
+
+    b.WriteString("\n## Methodology\n\n")
+    if hint, ok := evidenceHints[strings.ToLower(d.Category)]; ok {
+        fmt.Fprintf(&b, "<%s>\n", hint)
+    } else {
+        b.WriteString("<Steps performed, tools and accounts used, requests sent.>\n")
+    }
+
+    if len(d.Questions) > 0 {
+        b.WriteString("\n## Answers\n")
+        for i, q := range d.Questions {
+            fmt.Fprintf(&b, "\n### %d. %s\n\n<Answer.>\n\nEvidence:\n\n- <screenshot / request and response>\n", i+1, strings.TrimSpace(q.Text))
+        }
+    } else {
+        b.WriteString("\n## Evidence\n\n- <screenshot / request and response>\n")
+    }
+
+    b.WriteString("\n## Result\n\n<Vulnerable / not vulnerable, with a short justification.>\n")
+    b.WriteString("\n## Conclusion\n\n<Summary and, if vulnerable, the vulnerability report reference.>\n")
+    return b.String()
+}
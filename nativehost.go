@@ -0,0 +1,215 @@
+package main
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/binary"
+    "encoding/json"
+    "errors"
+    "flag"
+    "fmt"
+    "io"
+    "net"
+    "net/http"
+    "os"
+    "strings"
+    "sync"
+    "time"
+)
+
+// Native messaging limits: the browser rejects messages to it over 1 MB;
+// messages from it are only ever a token.
+const (
+    maxNativeMessageOut = 1 << 20
+    maxNativeMessageIn  = maxTokenBody
+)
+
+// nativeReconnectDelay is how long the native host waits before
+// reconnecting to a bot that isn't running or restarted.
+const nativeReconnectDelay = 5 * time.Second
+
+// nativeMessage is a message between the browser extension and the native
+// host. The extension sends {"type": "token", "token": "<jwt>"}; the host
+// answers with {"type": "token", "ok": ...} and sends every notification as
+// {"type": "notification", "notification": {...}}.
+type nativeMessage struct {
+    Type         string                 `json:"type"`
+    Token        string                 `json:"token,omitempty"`
+    OK           bool                   `json:"ok,omitempty"`
+    Error        string                 `json:"error,omitempty"`
+    Notification *extensionNotification `json:"notification,omitempty"`
+}
+
+// nativeHost relays between a browser extension's native messaging port
+// (stdin/stdout) and the bot's token listener.
+type nativeHost struct {
+    base   string
+    secret string
+    client *http.Client
+    mu     sync.Mutex // serializes writes to out
+    out    io.Writer
+}
+
+// runNativeHost implements "native-host": the native messaging host a
+// companion browser extension talks to when it can't reach localhost
+// itself. The browser starts it and passes the extension's origin as an
+// argument, which is ignored.
+func runNativeHost(args []string) error {
+    fs := flag.NewFlagSet("native-host", flag.ExitOnError)
+    addrFlag := fs.String("addr", "127.0.0.1:8777", "The bot's -token-listener address (or MISSION_BOT_TOKEN_LISTENER)")
+    secretFlag := fs.String("secret-file", "", "File containing the bot's -token-listener secret (or MISSION_BOT_LISTENER_SECRET)")
+    fs.Parse(args)
+    // The browser starts the host without flags of our choosing, so the
+    // address can also come from the environment.
+    explicit := false
+    fs.Visit(func(f *flag.Flag) { explicit = explicit || f.Name == "addr" })
+    if addr := os.Getenv("MISSION_BOT_TOKEN_LISTENER"); addr != "" && !explicit {
+        *addrFlag = addr
+    }
+
+    if _, _, err := net.SplitHostPort(*addrFlag); err != nil {
+        return fmt.Errorf("invalid -addr: %v", err)
+    }
+    secret, err := readListenerSecret(*secretFlag)
+    if err != nil || secret == "" {
+        return fmt.Errorf("the token listener secret is required in -secret-file or MISSION_BOT_LISTENER_SECRET")
+    }
+    h := &nativeHost{
+        base:   "http://" + *addrFlag,
+        secret: secret,
+        client: &http.Client{Timeout: 10 * time.Second},
+        out:    os.Stdout,
+    }
+    goSafe(h.relayEvents)
+    return h.readMessages(bufio.NewReader(os.Stdin))
+}
+
+// readMessages handles the extension's messages until the browser closes
+// the port.
+func (h *nativeHost) readMessages(r io.Reader) error {
+    for {
+        var msg nativeMessage
+        if err := readNativeMessage(r, &msg); err != nil {
+            if errors.Is(err, io.EOF) {
+                return nil
+            }
+            return err
+        }
+        switch msg.Type {
+        case "token":
+            reply := nativeMessage{Type: "token", OK: true}
+            if err := h.pushToken(msg.Token); err != nil {
+                reply = nativeMessage{Type: "token", Error: err.Error()}
+            }
+            h.write(reply)
+        default:
+            h.write(nativeMessage{Type: "error", Error: fmt.Sprintf("unknown message type %q", msg.Type)})
+        }
+    }
+}
+
+// pushToken hands a token to the bot's token listener.
+func (h *nativeHost) pushToken(token string) error {
+    req, err := http.NewRequest(http.MethodPost, h.base+"/token", strings.NewReader(token))
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Content-Type", "text/plain")
+    req.Header.Set("Authorization", "Bearer "+h.secret)
+    resp, err := h.client.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusNoContent {
+        body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+        return fmt.Errorf("bot answered %s: %s", resp.Status, strings.TrimSpace(string(body)))
+    }
+    return nil
+}
+
+// relayEvents forwards the bot's notifications to the extension,
+// reconnecting while the bot isn't reachable.
+func (h *nativeHost) relayEvents() {
+    for {
+        err := h.streamEvents()
+        h.write(nativeMessage{Type: "error", Error: fmt.Sprintf("lost connection to the bot: %v", err)})
+        time.Sleep(nativeReconnectDelay)
+    }
+}
+
+// streamEvents reads the token listener's event stream until it ends.
+func (h *nativeHost) streamEvents() error {
+    req, err := http.NewRequest(http.MethodGet, h.base+"/events", nil)
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Authorization", "Bearer "+h.secret)
+    // No timeout: the stream stays open for as long as the bot runs.
+    resp, err := http.DefaultClient.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        return fmt.Errorf("unexpected status %s", resp.Status)
+    }
+    sc := bufio.NewScanner(resp.Body)
+    sc.Buffer(make([]byte, 0, 64<<10), maxNativeMessageOut)
+    for sc.Scan() {
+        data, ok := strings.CutPrefix(sc.Text(), "data: ")
+        if !ok {
+            continue
+        }
+        var n extensionNotification
+        if json.Unmarshal([]byte(data), &n) != nil {
+            continue
+        }
+        h.write(nativeMessage{Type: "notification", Notification: &n})
+    }
+    if err := sc.Err(); err != nil {
+        return err
+    }
+    return io.EOF
+}
+
+// write sends msg to the extension. Write errors are ignored: the browser
+// closing the port also ends readMessages, which exits.
+func (h *nativeHost) write(msg nativeMessage) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    writeNativeMessage(h.out, msg)
+}
+
+// readNativeMessage reads one native messaging message: a 32-bit length
+// in native byte order followed by that much JSON.
+func readNativeMessage(r io.Reader, v any) error {
+    var n uint32
+    if err := binary.Read(r, binary.NativeEndian, &n); err != nil {
+        return err
+    }
+    if n > maxNativeMessageIn {
+        return fmt.Errorf("native message of %d bytes is too large", n)
+    }
+    data := make([]byte, n)
+    if _, err := io.ReadFull(r, data); err != nil {
+        return err
+    }
+    return json.Unmarshal(data, v)
+}
+
+// writeNativeMessage writes v as one native messaging message.
+func writeNativeMessage(w io.Writer, v any) error {
+    data, err := json.Marshal(v)
+    if err != nil {
+        return err
+    }
+    if len(data) > maxNativeMessageOut {
+        return fmt.Errorf("native message of %d bytes is too large", len(data))
+    }
+    var buf bytes.Buffer
+    binary.Write(&buf, binary.NativeEndian, uint32(len(data)))
+    buf.Write(data)
+    _, err = w.Write(buf.Bytes())
+    return err
+}
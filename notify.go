@@ -0,0 +1,424 @@
+package main
+
+import (
+    "errors"
+    "flag"
+    "fmt"
+    "log/slog"
+    "net/http"
+    "os"
+    "slices"
+    "strings"
+    "sync"
+    "time"
+)
+
+// Event types emitted by the bot.
+const (
+    EventMissionClaimed      = "mission_claimed"
+    EventClaimFailed         = "claim_failed"
+    EventSignup              = "signup_succeeded"
+    EventSignupApproval      = "signup_approval"
+    EventBotStopped          = "bot_stopped"
+    EventClaimUnverified     = "claim_unverified"
+    EventUnexpectedStatus    = "unexpected_status"
+    EventPaused              = "claiming_paused"
+    EventResumed             = "claiming_resumed"
+    EventTokenExpired        = "token_expired"
+    EventTokenExpiring       = "token_expiring"
+    EventTokenRotated        = "token_rotated"
+    EventSessionEnded        = "session_ended"
+    EventAnnouncement        = "announcement"
+    EventDeadlineReminder    = "deadline_reminder"
+    EventSkipSummary         = "skip_summary"
+    EventScopeChanged        = "scope_changed"
+    EventOutsideAvailability = "outside_availability"
+    EventForeignActivity     = "foreign_activity"
+    EventSummary             = "summary"
+    EventTest                = "test"
+)
+
+// eventTypes are the event types channels can be limited to.
+var eventTypes = []string{
+    EventMissionClaimed, EventClaimFailed, EventSignup, EventSignupApproval, EventBotStopped, EventClaimUnverified, EventUnexpectedStatus,
+    EventPaused, EventResumed, EventTokenExpired, EventTokenExpiring, EventTokenRotated, EventSessionEnded,
+    EventAnnouncement, EventDeadlineReminder, EventSkipSummary, EventScopeChanged, EventSummary,
+    EventOutsideAvailability, EventForeignActivity, EventTest,
+}
+
+// optInEvents are only sent to channels that list them in their events,
+// as they would flood channels that take every event.
+var optInEvents = []string{EventClaimFailed}
+
+// Event is something worth telling the user about.
+type Event struct {
+    Type    string
+    Message string
+    Time    time.Time
+    // Task is the mission the event is about, if any.
+    Task *Task
+    // Target is the codename or slug of the target the event is about, if any.
+    Target string
+    // Status is the HTTP status code behind the event, if known.
+    Status int
+    // Account is the account (-account) the event happened on, if any.
+    Account string
+    // Links are the platform pages of the event's mission and target.
+    Links []Link
+}
+
+// Notifier delivers events to a single channel (stdout, chat, email, ...).
+type Notifier interface {
+    Notify(ev Event) error
+}
+
+// notifier is the channel all events are sent to. It defaults to plain stdout
+// output, which is what `| notify -silent` style pipelines consume.
+var notifier Notifier = stdoutNotifier{}
+
+// notifyTransport is used for third-party notification services. It honors
+// the proxy environment but not the platform TLS pins.
+var notifyTransport http.RoundTripper = &http.Transport{Proxy: http.ProxyFromEnvironment}
+
+// notify sends an event to the configured notifier, logging delivery failures.
+func notify(eventType, format string, args ...interface{}) {
+    notifyEvent(Event{Type: eventType, Message: fmt.Sprintf(format, args...)})
+}
+
+// notifyEvent sends an event with details (task, target, status) to the
+// configured notifier, logging delivery failures.
+func notifyEvent(ev Event) {
+    if ev.Time.IsZero() {
+        ev.Time = time.Now()
+    }
+    if accountName != "" && ev.Account == "" {
+        ev.Account = accountName
+        ev.Message = "[" + accountName + "] " + ev.Message
+    }
+    if ev.Links == nil {
+        ev.Links = eventLinks(ev)
+    }
+    if err := notifier.Notify(ev); err != nil {
+        slog.Error("notification failed", "event", ev.Type, "err", err)
+    }
+}
+
+// flusher is implemented by notifiers that hold events back.
+type flusher interface {
+    Flush()
+}
+
+// flushNotifications delivers any events still held back by batching.
+func flushNotifications() {
+    if f, ok := notifier.(flusher); ok {
+        f.Flush()
+    }
+}
+
+// notificationChannel is a notification channel configured in the config file.
+type notificationChannel struct {
+    Name     string
+    Notifier Notifier
+    // Endpoint is the URL the channel delivers to, if any. Its host is
+    // resolved at startup as a dependency check.
+    Endpoint string
+    // Events, if set, are the only event types sent to the channel.
+    Events []string
+}
+
+// notificationChannels returns the channels configured in cfg. Stdout (or the
+// dashboard log) is always used in addition to these.
+func notificationChannels(cfg Config) []notificationChannel {
+    var channels []notificationChannel
+    if cfg.Telegram.BotToken != "" {
+        channels = append(channels, notificationChannel{Name: "telegram", Notifier: telegramNotifier{cfg: cfg.Telegram}, Endpoint: telegramAPI, Events: cfg.Telegram.Events})
+    }
+    for _, n := range cfg.Notifications {
+        channels = append(channels, notificationChannel{Name: n.channelName(), Notifier: notificationTypes[n.Type](n), Endpoint: n.endpoint(), Events: n.Events})
+    }
+    return channels
+}
+
+// sink returns the channel's notifier as used by the running bot: batched
+// over batch (if positive) and limited to the channel's events, or to all
+// but the opt-in events, and to the targets routed to it. A batch only
+// holds the events the channel accepts, so filtering comes first. Channels
+// delivering over the network or through a helper program are queued, so
+// they don't hold up claiming.
+func (c notificationChannel) sink(batch time.Duration) Notifier {
+    n := c.Notifier
+    if batch > 0 {
+        n = newBatchingNotifier(n, batch)
+    }
+    if c.Endpoint != "" {
+        n = newQueuedNotifier(n, c.Name)
+    } else if p, ok := c.Notifier.(programNotifier); ok {
+        n = newQueuedNotifier(n, p.program())
+    }
+    events := c.Events
+    if len(events) == 0 {
+        events = slices.DeleteFunc(slices.Clone(eventTypes), func(e string) bool {
+            return slices.Contains(optInEvents, e)
+        })
+    }
+    return filteredNotifier{next: n, events: events, channel: c.Name}
+}
+
+// programNotifier is implemented by notifiers that deliver by running a
+// helper program, which can block like a network call. program names the
+// channel in logs.
+type programNotifier interface {
+    program() string
+}
+
+// multiNotifier fans every event out to several channels.
+type multiNotifier []Notifier
+
+func (m multiNotifier) Notify(ev Event) error {
+    var errs []error
+    for _, n := range m {
+        if err := n.Notify(ev); err != nil {
+            errs = append(errs, err)
+        }
+    }
+    return errors.Join(errs...)
+}
+
+// Flush flushes every channel that batches.
+func (m multiNotifier) Flush() {
+    for _, n := range m {
+        if f, ok := n.(flusher); ok {
+            f.Flush()
+        }
+    }
+}
+
+// stdoutNotifier prints each event on its own line.
+type stdoutNotifier struct{}
+
+func (stdoutNotifier) Notify(ev Event) error {
+    fmt.Println(ev.Message)
+    return nil
+}
+
+// logNotifier sends events to the logger, e.g. while the dashboard owns stdout.
+type logNotifier struct{}
+
+func (logNotifier) Notify(ev Event) error {
+    attrs := []any{"event", ev.Type}
+    for _, l := range ev.Links {
+        attrs = append(attrs, l.Name, l.URL)
+    }
+    slog.Info(ev.Message, attrs...)
+    return nil
+}
+
+// notifyQueueSize is how many events a channel holds while it is still
+// delivering an earlier one.
+const notifyQueueSize = 64
+
+// queuedEvent is an event waiting for delivery, or, with done set, a
+// marker closed once everything queued before it was delivered.
+type queuedEvent struct {
+    ev   Event
+    done chan struct{}
+}
+
+// queuedNotifier delivers events to a channel in the background, one at a
+// time and in order, so a slow or unreachable service (each delivery may
+// take up to notifyTimeout) doesn't delay the next claim. Events arriving
+// while the queue is full are dropped and logged; delivery failures are
+// only logged.
+type queuedNotifier struct {
+    next    Notifier
+    channel string
+    queue   chan queuedEvent
+}
+
+// newQueuedNotifier wraps next and starts its delivery goroutine.
+func newQueuedNotifier(next Notifier, channel string) *queuedNotifier {
+    q := &queuedNotifier{next: next, channel: channel, queue: make(chan queuedEvent, notifyQueueSize)}
+    goSafe(q.run)
+    return q
+}
+
+func (q *queuedNotifier) run() {
+    for e := range q.queue {
+        if e.done != nil {
+            close(e.done)
+            continue
+        }
+        if err := q.next.Notify(e.ev); err != nil {
+            slog.Error("notification failed", "channel", q.channel, "event", e.ev.Type, "err", err)
+        }
+    }
+}
+
+// Notify queues the event, or drops it if the queue is full.
+func (q *queuedNotifier) Notify(ev Event) error {
+    select {
+    case q.queue <- queuedEvent{ev: ev}:
+    default:
+        slog.Warn("notification queue full, dropping event", "channel", q.channel, "event", ev.Type)
+    }
+    return nil
+}
+
+// Flush waits until the events queued so far are delivered, then flushes
+// next if it batches.
+func (q *queuedNotifier) Flush() {
+    done := make(chan struct{})
+    q.queue <- queuedEvent{done: done}
+    <-done
+    if f, ok := q.next.(flusher); ok {
+        f.Flush()
+    }
+}
+
+// maxBatchBackoff caps how long a failing channel is left alone before retrying.
+const maxBatchBackoff = 5 * time.Minute
+
+// batchingNotifier collects events arriving within a window and delivers them to
+// the wrapped notifier as one consolidated summary, so a mass mission drop results
+// in a single message instead of dozens. When delivery fails (e.g. the chat
+// provider rate limits us), events are kept and the window doubles until a
+// delivery succeeds again.
+type batchingNotifier struct {
+    next   Notifier
+    window time.Duration
+
+    mu      sync.Mutex
+    pending []Event
+    backoff time.Duration
+    timer   *time.Timer
+}
+
+// newBatchingNotifier wraps next so events are batched over window.
+func newBatchingNotifier(next Notifier, window time.Duration) *batchingNotifier {
+    return &batchingNotifier{next: next, window: window, backoff: window}
+}
+
+// Notify queues the event; the batch is delivered when the window elapses.
+func (b *batchingNotifier) Notify(ev Event) error {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    b.pending = append(b.pending, ev)
+    if b.timer == nil {
+        b.timer = time.AfterFunc(b.backoff, b.flushSafe)
+    }
+    return nil
+}
+
+// Flush delivers all pending events now.
+func (b *batchingNotifier) Flush() {
+    b.mu.Lock()
+    events := b.pending
+    b.pending = nil
+    if b.timer != nil {
+        b.timer.Stop()
+        b.timer = nil
+    }
+    b.mu.Unlock()
+
+    if len(events) == 0 {
+        return
+    }
+
+    err := b.next.Notify(summarizeEvents(events))
+
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    if err != nil {
+        slog.Warn("notification failed, retrying later", "events", len(events), "err", err)
+        b.pending = append(events, b.pending...)
+        b.backoff *= 2
+        if b.backoff > maxBatchBackoff {
+            b.backoff = maxBatchBackoff
+        }
+    } else {
+        b.backoff = b.window
+    }
+    if len(b.pending) > 0 && b.timer == nil {
+        b.timer = time.AfterFunc(b.backoff, b.flushSafe)
+    }
+}
+
+// flushSafe is Flush for the batch timer, whose goroutine would otherwise
+// crash without redacting secrets.
+func (b *batchingNotifier) flushSafe() {
+    defer crashOnPanic()
+    b.Flush()
+}
+
+// summarizeEvents turns a batch into one event. A batch of one is passed through as is.
+func summarizeEvents(events []Event) Event {
+    if len(events) == 1 {
+        return events[0]
+    }
+
+    counts := map[string]int{}
+    var lines []string
+    for _, ev := range events {
+        counts[ev.Type]++
+        lines = append(lines, "- "+ev.Message)
+        for _, l := range ev.Links {
+            lines = append(lines, "  "+l.URL)
+        }
+    }
+
+    var parts []string
+    for _, t := range []string{EventMissionClaimed, EventSignup} {
+        if counts[t] > 0 {
+            parts = append(parts, fmt.Sprintf("%d %s", counts[t], t))
+        }
+    }
+    header := fmt.Sprintf("%d events", len(events))
+    if len(parts) > 0 {
+        header += " (" + strings.Join(parts, ", ") + ")"
+    }
+
+    return Event{
+        Type:    EventSummary,
+        Message: header + ":\n" + strings.Join(lines, "\n"),
+        Time:    events[len(events)-1].Time,
+    }
+}
+
+// runNotify implements the "notify test [channel]" subcommand: it sends a
+// sample event through every configured channel, or only the named one, and
+// reports whether each delivery succeeded.
+func runNotify(args []string) error {
+    if len(args) == 0 || args[0] != "test" {
+        return fmt.Errorf("usage: %s notify test [-config <file>] [channel]", os.Args[0])
+    }
+    fs := flag.NewFlagSet("notify test", flag.ExitOnError)
+    configFlag := fs.String("config", "", "Path to the JSON config file with the notification channels")
+    fs.Parse(args[1:])
+
+    cfg, err := loadConfig(*configFlag)
+    if err != nil {
+        return err
+    }
+    channels := append([]notificationChannel{{Name: "stdout", Notifier: stdoutNotifier{}}}, notificationChannels(cfg)...)
+    if name := fs.Arg(0); name != "" {
+        channels = slices.DeleteFunc(channels, func(c notificationChannel) bool { return c.Name != name })
+        if len(channels) == 0 {
+            return fmt.Errorf("notification channel %q is not configured", name)
+        }
+    }
+
+    ev := Event{Type: EventTest, Message: "Test notification from synack-mission-bot. If you can read this, alerts will reach you here.", Time: time.Now()}
+    var failed int
+    for _, c := range channels {
+        if err := c.Notifier.Notify(ev); err != nil {
+            fmt.Fprintf(os.Stderr, "%-10s FAILED: %v\n", c.Name, err)
+            failed++
+            continue
+        }
+        fmt.Fprintf(os.Stderr, "%-10s ok\n", c.Name)
+    }
+    if failed > 0 {
+        return fmt.Errorf("%d of %d channels failed", failed, len(channels))
+    }
+    return nil
+}
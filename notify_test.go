@@ -0,0 +1,80 @@
+package main
+
+import (
+    "strconv"
+    "sync"
+    "testing"
+    "time"
+)
+
+// blockedNotifier records events, each delivery waiting for release.
+type blockedNotifier struct {
+    release chan struct{}
+
+    mu       sync.Mutex
+    messages []string
+}
+
+func (n *blockedNotifier) Notify(ev Event) error {
+    <-n.release
+    n.mu.Lock()
+    defer n.mu.Unlock()
+    n.messages = append(n.messages, ev.Message)
+    return nil
+}
+
+func TestQueuedNotifierDoesNotBlock(t *testing.T) {
+    slow := &blockedNotifier{release: make(chan struct{})}
+    q := newQueuedNotifier(slow, "slow")
+
+    start := time.Now()
+    // One event is being delivered, notifyQueueSize wait and the rest
+    // are dropped.
+    for i := range notifyQueueSize + 10 {
+        q.Notify(Event{Type: EventMissionClaimed, Message: strconv.Itoa(i)})
+        if i == 0 {
+            // Let the worker take the first one off the queue.
+            for len(q.queue) > 0 {
+                time.Sleep(time.Millisecond)
+            }
+        }
+    }
+    if d := time.Since(start); d > time.Second {
+        t.Errorf("queueing took %s behind a blocked channel", d)
+    }
+
+    close(slow.release)
+    q.Flush()
+    slow.mu.Lock()
+    defer slow.mu.Unlock()
+    if len(slow.messages) != notifyQueueSize+1 {
+        t.Fatalf("%d events delivered, want %d", len(slow.messages), notifyQueueSize+1)
+    }
+    for i, m := range slow.messages {
+        if m != strconv.Itoa(i) {
+            t.Fatalf("event %d delivered as %q, want the events in order", i, m)
+        }
+    }
+}
+
+// blockedProgram is a blockedNotifier delivering through a helper program.
+type blockedProgram struct{ *blockedNotifier }
+
+func (blockedProgram) program() string { return "blocked" }
+
+func TestSinkQueuesProgramNotifiers(t *testing.T) {
+    slow := &blockedNotifier{release: make(chan struct{})}
+    n := notificationChannel{Notifier: blockedProgram{slow}, Events: []string{EventMissionClaimed}}.sink(0)
+
+    done := make(chan struct{})
+    go func() {
+        n.Notify(Event{Type: EventMissionClaimed, Message: "claimed"})
+        close(done)
+    }()
+    select {
+    case <-done:
+    case <-time.After(time.Second):
+        t.Fatal("a channel running a helper program held up the caller")
+    }
+    close(slow.release)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+    "encoding/json"
+    "log/slog"
+    "os"
+    "time"
+)
+
+// onceResult is what "once" prints on stdout when it is done.
+type onceResult struct {
+    // OK is set when both the mission poll and the target check succeeded.
+    OK bool `json:"ok"`
+    // Missions is the number of available missions the poll returned.
+    Missions int        `json:"missions"`
+    Claimed  []string   `json:"claimed"`
+    Skipped  skipCounts `json:"skipped,omitempty"`
+    Targets  int        `json:"unregisteredTargets"`
+    Signups  int        `json:"signups"`
+    Pause    string     `json:"pause,omitempty"`
+    // Errors are the warnings and errors logged during the run.
+    Errors  []string `json:"errors,omitempty"`
+    Seconds float64  `json:"seconds"`
+}
+
+// runOnce polls and claims missions once and checks the unregistered
+// targets once, waits for the signup hooks and eventHooks (if not nil) the
+// run started, so a scheduler doesn't cut them off, prints the result as
+// JSON and returns the exit code: 0 if both polls succeeded, 1 otherwise.
+func (b *bot) runOnce(eventHooks *hookSupervisor) int {
+    b.mainLoop()
+
+    token := b.tokens.Get()
+    signupAllowed, reason := featureAllowed(token, b.config().RoleGates.Targets)
+    if !signupAllowed {
+        slog.Warn("target signup disabled", "reason", reason)
+    }
+    if err := b.checkTargets(token, signupAllowed); err != nil {
+        slog.Error("failed to retrieve unregistered targets", "endpoint", "targets", "err", err)
+    }
+    flushNotifications()
+    b.hooks.Wait()
+    if eventHooks != nil {
+        eventHooks.Wait()
+    }
+
+    snap := b.state.Snapshot()
+    session := b.state.Session()
+    r := onceResult{
+        OK:       !snap.LastPoll.IsZero() && !snap.LastTargetPoll.IsZero(),
+        Missions: len(snap.Pending),
+        Claimed:  []string{},
+        Skipped:  b.state.TakeSkips(),
+        Targets:  len(snap.Targets),
+        Signups:  session.Signups,
+        Errors:   snap.RecentErrors,
+        Seconds:  time.Since(session.Start).Round(time.Millisecond).Seconds(),
+    }
+    for _, c := range snap.Claimed {
+        r.Claimed = append(r.Claimed, c.Task.ID)
+    }
+    if snap.Pause != nil {
+        r.Pause = snap.Pause.String()
+    }
+    enc := json.NewEncoder(os.Stdout)
+    enc.SetIndent("", "  ")
+    enc.Encode(r)
+    if !r.OK {
+        return 1
+    }
+    return 0
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+    "fmt"
+    "maps"
+    "path"
+    "slices"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// TargetOverride changes settings for the missions and events of the
+// targets it matches, so very different targets don't have to share one
+// compromise. The first override in the list that matches a target applies.
+type TargetOverride struct {
+    // Match is matched against a target's slug, which is the ListingUid of
+    // its missions and the key of the per-target caps, and its codename
+    // (ListingCodename), as is or as a pattern with * and ? (e.g.
+    // "ACME-*"); case is ignored.
+    Match string `json:"match"`
+    // Preset applies the per-mission settings of a built-in -preset: its
+    // claim delay, campaign cap and, for watch-only, dry run. The fields
+    // below take precedence.
+    Preset string `json:"preset"`
+    // ClaimDelay replaces -claim-delay after claims of the target's missions.
+    ClaimDelay Duration `json:"claim_delay"`
+    // MaxPerCampaign replaces -max-per-campaign.
+    MaxPerCampaign int `json:"max_per_campaign"`
+    // MaxClaimsPerDay caps the missions of the target claimed per day
+    // (0 = no cap); the day is the one of -day-timezone.
+    MaxClaimsPerDay int `json:"max_claims_per_day"`
+    // Notify, if set, sends the target's events only to these notification
+    // channels (by name; "telegram" for the Telegram bot) instead of all.
+    Notify []string `json:"notify"`
+}
+
+// targetSettings are the per-mission settings an override can change.
+type targetSettings struct {
+    claimDelay      time.Duration
+    maxPerCampaign  int
+    maxClaimsPerDay int
+    dryRun          bool
+}
+
+// targetOverrides are the overrides from the config file. They are
+// replaced on reload, so access is guarded by targetOverridesMu.
+var (
+    targetOverridesMu sync.RWMutex
+    targetOverrides   []TargetOverride
+)
+
+// setTargetOverrides replaces the configured overrides.
+func setTargetOverrides(overrides []TargetOverride) {
+    targetOverridesMu.Lock()
+    targetOverrides = overrides
+    targetOverridesMu.Unlock()
+}
+
+// targetOverrideFor returns the first override matching any of a target's
+// IDs (slug, codename).
+func targetOverrideFor(ids ...string) (TargetOverride, bool) {
+    targetOverridesMu.RLock()
+    defer targetOverridesMu.RUnlock()
+    for _, o := range targetOverrides {
+        if o.matches(ids...) {
+            return o, true
+        }
+    }
+    return TargetOverride{}, false
+}
+
+// matches reports whether the override matches any of ids.
+func (o TargetOverride) matches(ids ...string) bool {
+    for _, id := range ids {
+        if id == "" {
+            continue
+        }
+        if ok, _ := path.Match(strings.ToLower(o.Match), strings.ToLower(id)); ok {
+            return true
+        }
+    }
+    return false
+}
+
+// apply returns s changed by the override's preset and settings.
+func (o TargetOverride) apply(s targetSettings) targetSettings {
+    preset := strategyPresets[o.Preset]
+    if v, ok := preset["claim-delay"]; ok {
+        s.claimDelay, _ = time.ParseDuration(v)
+    }
+    if v, ok := preset["max-per-campaign"]; ok {
+        s.maxPerCampaign, _ = strconv.Atoi(v)
+    }
+    if v, ok := preset["dry-run"]; ok {
+        s.dryRun, _ = strconv.ParseBool(v)
+    }
+    if o.ClaimDelay.Duration > 0 {
+        s.claimDelay = o.ClaimDelay.Duration
+    }
+    if o.MaxPerCampaign > 0 {
+        s.maxPerCampaign = o.MaxPerCampaign
+    }
+    s.maxClaimsPerDay = o.MaxClaimsPerDay
+    return s
+}
+
+// settingsFor returns the settings for a task's target: the bot's own,
+// changed by the target's override if there is one.
+func (b *bot) settingsFor(task Task) targetSettings {
+    s := targetSettings{claimDelay: b.claimDelay, maxPerCampaign: b.maxPerCampaign, dryRun: b.dryRun}
+    if o, ok := targetOverrideFor(task.ListingUid, task.ListingCodename); ok {
+        s = o.apply(s)
+    }
+    return s
+}
+
+// targetCapReached reports whether today's claims on the task's target
+// reached the target's daily cap.
+func (b *bot) targetCapReached(task Task, s targetSettings) bool {
+    if s.maxClaimsPerDay <= 0 {
+        return false
+    }
+    return b.store.TargetClaimsSince(task.ListingUid, b.dailyCaps.today()) >= s.maxClaimsPerDay
+}
+
+// capCounter checks tasks against the per-campaign and per-target caps
+// while going through a list of them, counting the tasks taken so far on
+// top of the claims recorded in the store.
+type capCounter struct {
+    b        *bot
+    today    time.Time
+    campaign map[string]int
+    target   map[string]int
+}
+
+func (b *bot) newCapCounter() *capCounter {
+    return &capCounter{b: b, today: b.dailyCaps.today(), campaign: map[string]int{}, target: map[string]int{}}
+}
+
+// claimable reports whether the claim loop would try to claim the task:
+// not claimed or forbidden before, not a dry run on its target, and its
+// campaign's and target's caps not reached.
+func (c *capCounter) claimable(task Task, s targetSettings) bool {
+    if c.b.store.HasClaimed(task.ID) || s.dryRun {
+        return false
+    }
+    if _, ok := c.b.store.Forbidden(task); ok {
+        return false
+    }
+    if s.maxPerCampaign > 0 && c.b.store.CampaignClaims(task.CampaignUid)+c.campaign[task.CampaignUid] >= s.maxPerCampaign {
+        return false
+    }
+    return s.maxClaimsPerDay <= 0 || c.b.store.TargetClaimsSince(task.ListingUid, c.today)+c.target[task.ListingUid] < s.maxClaimsPerDay
+}
+
+// add counts a task towards its campaign's and target's caps.
+func (c *capCounter) add(task Task) {
+    c.campaign[task.CampaignUid]++
+    c.target[task.ListingUid]++
+}
+
+// routesTo reports whether an event goes to the named notification
+// channel: events of a target whose override lists channels only go to
+// those.
+func routesTo(ev Event, channel string) bool {
+    ids := []string{ev.Target}
+    if ev.Task != nil {
+        ids = append(ids, ev.Task.ListingUid, ev.Task.ListingCodename)
+    }
+    o, ok := targetOverrideFor(ids...)
+    return !ok || len(o.Notify) == 0 || containsFold(o.Notify, channel)
+}
+
+// validateTargetOverrides checks the overrides against the config's
+// notification channels.
+func validateTargetOverrides(cfg Config) error {
+    channels := map[string]bool{}
+    if cfg.Telegram.BotToken != "" {
+        channels["telegram"] = true
+    }
+    for _, n := range cfg.Notifications {
+        channels[strings.ToLower(n.channelName())] = true
+    }
+    for i, o := range cfg.TargetOverrides {
+        if o.Match == "" {
+            return fmt.Errorf("target_overrides[%d]: match is required", i)
+        }
+        if _, err := path.Match(o.Match, ""); err != nil {
+            return fmt.Errorf("target_overrides[%d]: invalid match %q", i, o.Match)
+        }
+        if _, ok := strategyPresets[o.Preset]; o.Preset != "" && !ok {
+            names := slices.Sorted(maps.Keys(strategyPresets))
+            return fmt.Errorf("target_overrides[%d]: unknown preset %q (want %s)", i, o.Preset, strings.Join(names, ", "))
+        }
+        if o.ClaimDelay.Duration < 0 || o.MaxPerCampaign < 0 || o.MaxClaimsPerDay < 0 {
+            return fmt.Errorf("target_overrides[%d]: claim_delay, max_per_campaign and max_claims_per_day must not be negative", i)
+        }
+        for _, name := range o.Notify {
+            if !channels[strings.ToLower(name)] {
+                return fmt.Errorf("target_overrides[%d]: notify: unknown notification channel %q", i, name)
+            }
+        }
+    }
+    return nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+    "strings"
+    "testing"
+    "time"
+)
+
+// useTargetOverrides sets the overrides for the test.
+func useTargetOverrides(t *testing.T, overrides ...TargetOverride) {
+    setTargetOverrides(overrides)
+    t.Cleanup(func() { setTargetOverrides(nil) })
+}
+
+func TestTargetOverrideMatches(t *testing.T) {
+    tests := []struct {
+        match string
+        ids   []string
+        want  bool
+    }{
+        {match: "ufxq3h7n2k", ids: []string{"ufxq3h7n2k", "ACME-WEB"}, want: true},
+        {match: "acme-*", ids: []string{"ufxq3h7n2k", "ACME-WEB"}, want: true},
+        {match: "ACME-?EB", ids: []string{"", "acme-web"}, want: true},
+        {match: "ACME-*", ids: []string{"ufxq3h7n2k", "LEGACY-1"}},
+        {match: "*", ids: []string{"", ""}},
+    }
+    for _, tt := range tests {
+        if got := (TargetOverride{Match: tt.match}).matches(tt.ids...); got != tt.want {
+            t.Errorf("%q matches %q = %v, want %v", tt.match, tt.ids, got, tt.want)
+        }
+    }
+}
+
+func TestTargetOverrideApply(t *testing.T) {
+    base := targetSettings{claimDelay: 5 * time.Second, maxPerCampaign: 4}
+    tests := []struct {
+        name     string
+        override TargetOverride
+        want     targetSettings
+    }{
+        {name: "nothing set", want: base},
+        {name: "preset", override: TargetOverride{Preset: "conservative"}, want: targetSettings{claimDelay: 15 * time.Second, maxPerCampaign: 2}},
+        {name: "watch-only", override: TargetOverride{Preset: "watch-only"}, want: targetSettings{claimDelay: 5 * time.Second, maxPerCampaign: 4, dryRun: true}},
+        {
+            name:     "fields over preset",
+            override: TargetOverride{Preset: "conservative", ClaimDelay: Duration{500 * time.Millisecond}, MaxPerCampaign: 1, MaxClaimsPerDay: 2},
+            want:     targetSettings{claimDelay: 500 * time.Millisecond, maxPerCampaign: 1, maxClaimsPerDay: 2},
+        },
+    }
+    for _, tt := range tests {
+        if got := tt.override.apply(base); got != tt.want {
+            t.Errorf("%s: apply() = %+v, want %+v", tt.name, got, tt.want)
+        }
+    }
+}
+
+func TestSettingsForAndTargetCap(t *testing.T) {
+    useTargetOverrides(t,
+        TargetOverride{Match: "ACME-*", MaxClaimsPerDay: 1},
+        TargetOverride{Match: "*", Preset: "watch-only"},
+    )
+    b := newTestBot(t, "token")
+    b.claimDelay = time.Second
+
+    acme := Task{ID: "t1", ListingUid: "ufxq3h7n2k", ListingCodename: "ACME-WEB"}
+    s := b.settingsFor(acme)
+    if s.maxClaimsPerDay != 1 || s.dryRun || s.claimDelay != time.Second {
+        t.Errorf("settingsFor(ACME-WEB) = %+v, want the first override only", s)
+    }
+    if other := b.settingsFor(Task{ListingUid: "x", ListingCodename: "LEGACY-1"}); !other.dryRun {
+        t.Errorf("settingsFor(LEGACY-1) = %+v, want a dry run", other)
+    }
+
+    if b.targetCapReached(acme, s) {
+        t.Error("cap reached before any claim")
+    }
+    if err := b.store.AddClaim(acme); err != nil {
+        t.Fatal(err)
+    }
+    // Claims count towards the cap by listing UID, whatever their codename.
+    if !b.targetCapReached(Task{ID: "t2", ListingUid: "ufxq3h7n2k", ListingCodename: "ACME-WEB"}, s) {
+        t.Error("cap not reached after a claim on the target")
+    }
+}
+
+func TestRoutesTo(t *testing.T) {
+    useTargetOverrides(t, TargetOverride{Match: "ACME-*", Notify: []string{"acme-slack"}})
+    tests := []struct {
+        name    string
+        ev      Event
+        channel string
+        want    bool
+    }{
+        {name: "routed target, its channel", ev: Event{Target: "ACME-WEB"}, channel: "ACME-Slack", want: true},
+        {name: "routed target, other channel", ev: Event{Target: "ACME-WEB"}, channel: "telegram"},
+        {name: "routed mission", ev: Event{Task: &Task{ListingUid: "ufxq3h7n2k", ListingCodename: "ACME-WEB"}}, channel: "telegram"},
+        {name: "other target", ev: Event{Target: "LEGACY-1"}, channel: "telegram", want: true},
+        {name: "no target", ev: Event{}, channel: "telegram", want: true},
+    }
+    for _, tt := range tests {
+        if got := routesTo(tt.ev, tt.channel); got != tt.want {
+            t.Errorf("%s: routesTo() = %v, want %v", tt.name, got, tt.want)
+        }
+    }
+}
+
+func TestValidateTargetOverrides(t *testing.T) {
+    channels := Config{
+        Telegram:      TelegramConfig{BotToken: "123:abc"},
+        Notifications: []NotificationConfig{{Type: "slack", Name: "acme-slack"}},
+    }
+    tests := []struct {
+        name     string
+        override TargetOverride
+        wantErr  string
+    }{
+        {name: "valid", override: TargetOverride{Match: "ACME-*", Preset: "conservative", Notify: []string{"ACME-SLACK", "telegram"}}},
+        {name: "no match", override: TargetOverride{Preset: "conservative"}, wantErr: "match is required"},
+        {name: "bad pattern", override: TargetOverride{Match: "ACME-["}, wantErr: "invalid match"},
+        {name: "unknown preset", override: TargetOverride{Match: "ACME-*", Preset: "reckless"}, wantErr: "unknown preset"},
+        {name: "negative cap", override: TargetOverride{Match: "ACME-*", MaxClaimsPerDay: -1}, wantErr: "must not be negative"},
+        {name: "unknown channel", override: TargetOverride{Match: "ACME-*", Notify: []string{"discord"}}, wantErr: "unknown notification channel"},
+    }
+    for _, tt := range tests {
+        cfg := channels
+        cfg.TargetOverrides = []TargetOverride{tt.override}
+        err := validateTargetOverrides(cfg)
+        if tt.wantErr == "" && err != nil {
+            t.Errorf("%s: validateTargetOverrides() error = %v", tt.name, err)
+        } else if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+            t.Errorf("%s: validateTargetOverrides() error = %v, want one containing %q", tt.name, err, tt.wantErr)
+        }
+    }
+}
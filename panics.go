@@ -0,0 +1,163 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "log"
+    "log/slog"
+    "os"
+    "regexp"
+    "runtime"
+    "slices"
+    "strings"
+    "sync"
+)
+
+// tokenPatterns match token-like strings: JWTs (session tokens), bearer
+// credentials and Telegram bot tokens.
+var tokenPatterns = []*regexp.Regexp{
+    regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+    regexp.MustCompile(`(?i)(bearer|basic)\s+[A-Za-z0-9._~+/=-]{8,}`),
+    regexp.MustCompile(`\b\d{6,}:[A-Za-z0-9_-]{30,}\b`),
+}
+
+// secretEnvWords mark environment variables whose values are secrets.
+var secretEnvWords = []string{"TOKEN", "SECRET", "PASSWORD", "PASSPHRASE", "KEY"}
+
+// minSecretLen keeps short values (e.g. "1") from being redacted everywhere.
+const minSecretLen = 8
+
+var (
+    secretsMu sync.Mutex
+    // secrets are literal values redacted wherever they appear.
+    secrets []string
+)
+
+// registerSecret adds a value, e.g. the control token, to the redacted
+// secrets. Values registered again, as on every config reload or automatic
+// login, are only kept once.
+func registerSecret(s string) {
+    if len(s) < minSecretLen {
+        return
+    }
+    secretsMu.Lock()
+    defer secretsMu.Unlock()
+    if !slices.Contains(secrets, s) {
+        secrets = append(secrets, s)
+    }
+}
+
+// registerEnvSecrets registers the values of environment variables whose
+// names look like they hold secrets (MISSION_BOT_CONTROL_TOKEN,
+// MISSION_BOT_DB_PASSPHRASE, AWS_SECRET_ACCESS_KEY, ...).
+func registerEnvSecrets() {
+    for _, kv := range os.Environ() {
+        name, value, _ := strings.Cut(kv, "=")
+        if isSecretEnv(name) {
+            registerSecret(value)
+        }
+    }
+}
+
+// isSecretEnv reports whether the environment variable name looks like it
+// holds a secret.
+func isSecretEnv(name string) bool {
+    return slices.ContainsFunc(secretEnvWords, func(w string) bool {
+        return strings.Contains(strings.ToUpper(name), w)
+    })
+}
+
+// environWithoutSecrets returns the environment without the variables
+// registerEnvSecrets treats as secrets, for commands that needn't see them.
+func environWithoutSecrets() []string {
+    return slices.DeleteFunc(os.Environ(), func(kv string) bool {
+        name, _, _ := strings.Cut(kv, "=")
+        return isSecretEnv(name)
+    })
+}
+
+// redactSecrets replaces registered secrets and token-like strings in s.
+func redactSecrets(s string) string {
+    secretsMu.Lock()
+    for _, v := range secrets {
+        s = strings.ReplaceAll(s, v, "[REDACTED]")
+    }
+    secretsMu.Unlock()
+    for _, p := range tokenPatterns {
+        s = p.ReplaceAllString(s, "[REDACTED]")
+    }
+    return s
+}
+
+// redactingHandler redacts secrets from the message and string attributes
+// of records before passing them on to the wrapped handler.
+type redactingHandler struct {
+    slog.Handler
+}
+
+func (h redactingHandler) Handle(ctx context.Context, r slog.Record) error {
+    redacted := slog.NewRecord(r.Time, r.Level, redactSecrets(r.Message), r.PC)
+    r.Attrs(func(a slog.Attr) bool {
+        redacted.AddAttrs(redactAttr(a))
+        return true
+    })
+    return h.Handler.Handle(ctx, redacted)
+}
+
+func (h redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+    redacted := make([]slog.Attr, len(attrs))
+    for i, a := range attrs {
+        redacted[i] = redactAttr(a)
+    }
+    return redactingHandler{h.Handler.WithAttrs(redacted)}
+}
+
+func (h redactingHandler) WithGroup(name string) slog.Handler {
+    return redactingHandler{h.Handler.WithGroup(name)}
+}
+
+// redactAttr redacts secrets from an attribute's value, within groups too.
+func redactAttr(a slog.Attr) slog.Attr {
+    v := a.Value.Resolve()
+    switch v.Kind() {
+    case slog.KindGroup:
+        group := v.Group()
+        redacted := make([]any, len(group))
+        for i, g := range group {
+            redacted[i] = redactAttr(g)
+        }
+        return slog.Group(a.Key, redacted...)
+    case slog.KindString, slog.KindAny:
+        return slog.String(a.Key, redactSecrets(v.String()))
+    }
+    return a
+}
+
+// redactedErrorLog returns the error log of the HTTP servers, e.g. for the
+// panics net/http recovers from: the default logger at error level, with
+// secrets redacted.
+func redactedErrorLog() *log.Logger {
+    return slog.NewLogLogger(redactingHandler{slog.Default().Handler()}, slog.LevelError)
+}
+
+// crashOnPanic recovers a panic and exits like the runtime would, but with
+// secrets redacted from the panic value and the goroutine dump. It must be
+// deferred at the top of every goroutine.
+func crashOnPanic() {
+    r := recover()
+    if r == nil {
+        return
+    }
+    buf := make([]byte, 1<<20)
+    buf = buf[:runtime.Stack(buf, true)]
+    fmt.Fprintf(os.Stderr, "panic: %s\n\n%s", redactSecrets(fmt.Sprint(r)), redactSecrets(string(buf)))
+    os.Exit(2)
+}
+
+// goSafe runs fn in a new goroutine that crashes through crashOnPanic.
+func goSafe(fn func()) {
+    go func() {
+        defer crashOnPanic()
+        fn()
+    }()
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+    "fmt"
+    "regexp"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// PauseReason says why claiming is paused.
+type PauseReason string
+
+// Pause reasons.
+const (
+    PauseManual      PauseReason = "manual"
+    PauseCircuitOpen PauseReason = "circuit_open"
+    PauseVacation    PauseReason = "vacation"
+    PauseMaintenance PauseReason = "maintenance"
+    PauseClaimLimit  PauseReason = "claim_limit"
+    PauseDailyCap    PauseReason = "daily_cap"
+    PauseTokenGrace  PauseReason = "token_grace"
+)
+
+// ResumeCondition says what ends a pause.
+type ResumeCondition string
+
+// Resume conditions.
+const (
+    // ResumeManual waits for the user to resume.
+    ResumeManual ResumeCondition = "manual"
+    // ResumeTimer resumes once the pause's Until time has passed.
+    ResumeTimer ResumeCondition = "timer"
+    // ResumeEndpointHealthy resumes after the next successful task poll.
+    ResumeEndpointHealthy ResumeCondition = "endpoint_healthy"
+    // ResumeSlotFree resumes when fewer missions than the claim limit are claimed.
+    ResumeSlotFree ResumeCondition = "slot_free"
+    // ResumeTokenRefreshed resumes once a token with enough lifetime left arrives.
+    ResumeTokenRefreshed ResumeCondition = "token_refreshed"
+)
+
+// maintenanceThreshold is the number of consecutive 5xx task polls after which
+// the platform is assumed to be down for maintenance.
+const maintenanceThreshold = 3
+
+// pauseInfo describes an active pause.
+type pauseInfo struct {
+    Reason PauseReason     `json:"reason"`
+    Resume ResumeCondition `json:"resume"`
+    Since  time.Time       `json:"since"`
+    Until  time.Time       `json:"until,omitempty"`
+    Detail string          `json:"detail,omitempty"`
+}
+
+// String describes the pause for status output and notifications.
+func (p pauseInfo) String() string {
+    s := fmt.Sprintf("paused (%s", p.Reason)
+    if p.Detail != "" {
+        s += ": " + p.Detail
+    }
+    switch p.Resume {
+    case ResumeTimer:
+        s += fmt.Sprintf("), resumes at %s", p.Until.Format("2006-01-02 15:04"))
+    case ResumeEndpointHealthy:
+        s += "), resumes when the platform responds again"
+    case ResumeSlotFree:
+        s += "), resumes when a claim slot frees up"
+    case ResumeTokenRefreshed:
+        s += "), resumes when a fresh token arrives"
+    default:
+        s += "), resume manually"
+    }
+    return s
+}
+
+// isServerError reports whether err came from a 5xx response.
+func isServerError(err error) bool {
+    return err != nil && strings.Contains(err.Error(), "status code: 5")
+}
+
+// statusInError finds the status code in API errors such as "... (412)" or
+// "... status code: 503".
+var statusInError = regexp.MustCompile(`(?:\(|status code: )([1-5][0-9]{2})\b`)
+
+// errorStatus returns the HTTP status code an API error reports, or 0.
+func errorStatus(err error) int {
+    if err == nil {
+        return 0
+    }
+    m := statusInError.FindStringSubmatch(err.Error())
+    if m == nil {
+        return 0
+    }
+    code, _ := strconv.Atoi(m[1])
+    return code
+}
+
+// parseVacationUntil parses -vacation-until, accepting RFC 3339 or a plain
+// date (which means midnight local time at the start of that day).
+func parseVacationUntil(s string) (time.Time, error) {
+    if t, err := time.Parse(time.RFC3339, s); err == nil {
+        return t, nil
+    }
+    if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+        return t, nil
+    }
+    return time.Time{}, fmt.Errorf("invalid -vacation-until %q (want 2006-01-02 or RFC 3339)", s)
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+    "testing"
+    "time"
+)
+
+func TestPauseInfoString(t *testing.T) {
+    until := time.Date(2026, time.October, 16, 18, 30, 0, 0, time.Local)
+    tests := []struct {
+        p    pauseInfo
+        want string
+    }{
+        {pauseInfo{Reason: PauseManual, Resume: ResumeManual}, "paused (manual), resume manually"},
+        {pauseInfo{Reason: PauseVacation, Resume: ResumeTimer, Until: until}, "paused (vacation), resumes at 2026-10-16 18:30"},
+        {pauseInfo{Reason: PauseMaintenance, Resume: ResumeEndpointHealthy, Detail: "status code: 503"}, "paused (maintenance: status code: 503), resumes when the platform responds again"},
+        {pauseInfo{Reason: PauseClaimLimit, Resume: ResumeSlotFree}, "paused (claim_limit), resumes when a claim slot frees up"},
+        {pauseInfo{Reason: PauseTokenGrace, Resume: ResumeTokenRefreshed, Detail: "token expires in 4m"}, "paused (token_grace: token expires in 4m), resumes when a fresh token arrives"},
+    }
+    for _, tt := range tests {
+        if got := tt.p.String(); got != tt.want {
+            t.Errorf("%+v.String() = %q, want %q", tt.p, got, tt.want)
+        }
+    }
+}
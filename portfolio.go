@@ -0,0 +1,185 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "io"
+    "maps"
+    "os"
+    "slices"
+    "strings"
+    "time"
+)
+
+// categorySkills maps lower-cased mission categories to the skill they
+// demonstrate, for the portfolio. Other categories are listed as they are.
+var categorySkills = map[string]string{
+    "sqli":                   "Injection",
+    "command injection":      "Injection",
+    "xxe":                    "Injection",
+    "ssti":                   "Injection",
+    "xss":                    "Client-side security",
+    "csrf":                   "Client-side security",
+    "open redirect":          "Client-side security",
+    "authentication":         "Authentication and session management",
+    "session":                "Authentication and session management",
+    "authorization":          "Access control",
+    "idor":                   "Access control",
+    "ssrf":                   "Server-side request forgery",
+    "tls":                    "Transport security",
+    "information disclosure": "Information disclosure",
+}
+
+// skillOf returns the skill a category demonstrates.
+func skillOf(category string) string {
+    if skill, ok := categorySkills[strings.ToLower(category)]; ok {
+        return skill
+    }
+    if category == "" {
+        return "Other"
+    }
+    return category
+}
+
+// runPortfolio implements "portfolio": a sanitized Markdown summary of the
+// completed missions in the database, by skill and category. Missions
+// released through the bot or still open are left out. No mission IDs,
+// titles, target codenames or organizations are included.
+func runPortfolio(args []string) error {
+    fs := flag.NewFlagSet("portfolio", flag.ExitOnError)
+    dbFlag := fs.String("db", "", "Database file")
+    passphraseFlag := fs.String("db-passphrase-file", "", "File containing the database passphrase")
+    outFlag := fs.String("out", "", "Write the portfolio to this file instead of stdout")
+    sinceFlag := fs.String("since", "", "Only include missions completed on or after this date (2006-01-02), by the time they left the claimed list")
+    hidePayoutsFlag := fs.Bool("hide-payouts", false, "Leave out payout totals")
+    fs.Parse(args)
+    if *dbFlag == "" {
+        fs.Usage()
+        return fmt.Errorf("-db is required")
+    }
+    var since time.Time
+    if *sinceFlag != "" {
+        var err error
+        if since, err = time.ParseInLocation("2006-01-02", *sinceFlag, time.Local); err != nil {
+            return fmt.Errorf("invalid -since date: %v", err)
+        }
+    }
+    passphrase, err := readPassphrase(*passphraseFlag)
+    if err != nil {
+        return err
+    }
+    db, err := openStore(*dbFlag, passphrase)
+    if err != nil {
+        return err
+    }
+
+    var claims []claimRecord
+    for _, c := range db.Data().Claims {
+        if !c.CompletedAt.IsZero() && c.ReleasedAt.IsZero() && !c.CompletedAt.Before(since) {
+            claims = append(claims, c)
+        }
+    }
+    out := io.Writer(os.Stdout)
+    if *outFlag != "" {
+        f, err := os.OpenFile(*outFlag, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+        if err != nil {
+            return err
+        }
+        defer f.Close()
+        out = f
+    }
+    _, err = io.WriteString(out, renderPortfolio(claims, !*hidePayoutsFlag))
+    return err
+}
+
+// renderPortfolio renders the portfolio Markdown for the claims of
+// completed missions, dated by their completion.
+func renderPortfolio(claims []claimRecord, payouts bool) string {
+    var b strings.Builder
+    b.WriteString("# Mission portfolio\n\n")
+    if len(claims) == 0 {
+        b.WriteString("No completed missions recorded.\n")
+        return b.String()
+    }
+
+    first, last := claims[0].CompletedAt, claims[0].CompletedAt
+    bySkill := map[string]map[string]int{}
+    byMonth := map[string]int{}
+    totals := map[string]float64{}
+    // Categories are grouped case-insensitively under their first spelling.
+    spelling := map[string]string{}
+    for _, c := range claims {
+        first, last = minTime(first, c.CompletedAt), maxTime(last, c.CompletedAt)
+        skill := skillOf(c.Task.Category)
+        if bySkill[skill] == nil {
+            bySkill[skill] = map[string]int{}
+        }
+        key := strings.ToLower(c.Task.Category)
+        if _, ok := spelling[key]; !ok {
+            spelling[key] = c.Task.Category
+        }
+        bySkill[skill][spelling[key]]++
+        byMonth[c.CompletedAt.Local().Format("2006-01")]++
+        totals[c.Task.Payout.Currency] += c.Task.Payout.Amount
+    }
+
+    fmt.Fprintf(&b, "%d missions completed between %s and %s.\n", len(claims), first.Local().Format("2006-01-02"), last.Local().Format("2006-01-02"))
+    if payouts {
+        var parts []string
+        for _, currency := range slices.Sorted(maps.Keys(totals)) {
+            parts = append(parts, fmt.Sprintf("%g %s", totals[currency], currency))
+        }
+        fmt.Fprintf(&b, "Total payouts: %s.\n", strings.Join(parts, ", "))
+    }
+
+    b.WriteString("\n## By skill\n\n| Skill | Missions | Share |\n|---|---:|---:|\n")
+    skills := slices.Collect(maps.Keys(bySkill))
+    count := func(skill string) int {
+        n := 0
+        for _, c := range bySkill[skill] {
+            n += c
+        }
+        return n
+    }
+    slices.SortFunc(skills, func(a, b string) int {
+        if d := count(b) - count(a); d != 0 {
+            return d
+        }
+        return strings.Compare(a, b)
+    })
+    for _, skill := range skills {
+        n := count(skill)
+        fmt.Fprintf(&b, "| %s | %d | %.0f%% |\n", skill, n, 100*float64(n)/float64(len(claims)))
+    }
+
+    b.WriteString("\n## By category\n\n| Skill | Category | Missions |\n|---|---|---:|\n")
+    for _, skill := range skills {
+        for _, category := range slices.Sorted(maps.Keys(bySkill[skill])) {
+            name := category
+            if name == "" {
+                name = "(none)"
+            }
+            fmt.Fprintf(&b, "| %s | %s | %d |\n", skill, name, bySkill[skill][category])
+        }
+    }
+
+    b.WriteString("\n## By month\n\n| Month | Missions |\n|---|---:|\n")
+    for _, month := range slices.Sorted(maps.Keys(byMonth)) {
+        fmt.Fprintf(&b, "| %s | %d |\n", month, byMonth[month])
+    }
+    return b.String()
+}
+
+func minTime(a, b time.Time) time.Time {
+    if b.Before(a) {
+        return b
+    }
+    return a
+}
+
+func maxTime(a, b time.Time) time.Time {
+    if b.After(a) {
+        return b
+    }
+    return a
+}
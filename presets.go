@@ -0,0 +1,69 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "maps"
+    "slices"
+    "strings"
+)
+
+// strategyPresets are the built-in settings selectable with -preset, as
+// flag values. Flags given explicitly on the command line take precedence.
+var strategyPresets = map[string]map[string]string{
+    // conservative polls slowly, claims little and varies its timing a lot.
+    "conservative": {
+        "task-interval":    "60s",
+        "claim-delay":      "15s",
+        "target-interval":  "15m",
+        "jitter":           "30",
+        "claim-pipeline":   "1",
+        "claim-limit":      "3",
+        "max-per-campaign": "2",
+        "max-task-pages":   "2",
+    },
+    // balanced is the default behavior.
+    "balanced": {
+        "task-interval":   "15s",
+        "claim-delay":     "5s",
+        "target-interval": "5m",
+        "jitter":          "10",
+        "claim-pipeline":  "1",
+    },
+    // aggressive polls fast and claims several missions at once.
+    "aggressive": {
+        "task-interval":   "5s",
+        "claim-delay":     "1s",
+        "target-interval": "2m",
+        "jitter":          "5",
+        "claim-pipeline":  "4",
+    },
+    // watch-only never claims or signs up; it reports what it would do.
+    "watch-only": {
+        "dry-run":         "true",
+        "task-interval":   "30s",
+        "target-interval": "10m",
+        "jitter":          "20",
+    },
+}
+
+// applyPreset sets the flags of the named preset that were not set
+// explicitly in fs.
+func applyPreset(fs *flag.FlagSet, name string) error {
+    preset, ok := strategyPresets[name]
+    if !ok {
+        names := slices.Sorted(maps.Keys(strategyPresets))
+        return fmt.Errorf("unknown preset %q (want %s)", name, strings.Join(names, ", "))
+    }
+    explicit := map[string]bool{}
+    fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+    for flagName, value := range preset {
+        if explicit[flagName] {
+            continue
+        }
+        if err := fs.Set(flagName, value); err != nil {
+            return fmt.Errorf("preset %s: -%s: %v", name, flagName, err)
+        }
+    }
+    return nil
+}
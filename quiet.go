@@ -0,0 +1,148 @@
+package main
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// QuietHoursConfig sets time windows during which no missions are claimed.
+type QuietHoursConfig struct {
+    // Windows are like "22:00-07:00", "sat-sun" or "mon-fri 12:00-13:00". A
+    // window crossing midnight belongs to the day it starts on.
+    Windows []string `json:"windows"`
+    // Timezone is an IANA name like "Europe/Berlin" (default local time).
+    Timezone string `json:"timezone"`
+    // Record logs the missions that would have been claimed.
+    Record bool `json:"record"`
+}
+
+// weekdayNames are the day names accepted in quiet windows.
+var weekdayNames = map[string]time.Weekday{
+    "sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+    "thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// quietWindow is a parsed quiet window: the days it starts on and its
+// start and end in minutes after midnight.
+type quietWindow struct {
+    days       [7]bool
+    start, end int
+}
+
+// quietHours decides whether claiming is quiet at a given time.
+type quietHours struct {
+    windows []quietWindow
+    loc     *time.Location
+    record  bool
+}
+
+// newQuietHours parses the quiet hours configuration. It returns nil when
+// no windows are configured.
+func newQuietHours(cfg QuietHoursConfig) (*quietHours, error) {
+    if len(cfg.Windows) == 0 {
+        return nil, nil
+    }
+    q := &quietHours{loc: time.Local, record: cfg.Record}
+    if cfg.Timezone != "" {
+        loc, err := time.LoadLocation(cfg.Timezone)
+        if err != nil {
+            return nil, fmt.Errorf("quiet_hours.timezone: %v", err)
+        }
+        q.loc = loc
+    }
+    for _, s := range cfg.Windows {
+        w, err := parseQuietWindow(s)
+        if err != nil {
+            return nil, fmt.Errorf("quiet_hours.windows: %q: %v", s, err)
+        }
+        q.windows = append(q.windows, w)
+    }
+    return q, nil
+}
+
+// parseQuietWindow parses "[days] [HH:MM-HH:MM]", where days is a day, a
+// range like "mon-fri" or a comma separated list; both parts default to
+// the whole week and day.
+func parseQuietWindow(s string) (quietWindow, error) {
+    w := quietWindow{start: 0, end: 24 * 60}
+    fields := strings.Fields(strings.ToLower(s))
+    if len(fields) == 0 || len(fields) > 2 {
+        return w, fmt.Errorf("want \"[days] [HH:MM-HH:MM]\"")
+    }
+    if strings.Contains(fields[len(fields)-1], ":") {
+        from, to, ok := strings.Cut(fields[len(fields)-1], "-")
+        if !ok {
+            return w, fmt.Errorf("time range must be HH:MM-HH:MM")
+        }
+        var err error
+        if w.start, err = parseClock(from); err != nil {
+            return w, err
+        }
+        if w.end, err = parseClock(to); err != nil {
+            return w, err
+        }
+        if w.start == w.end {
+            return w, fmt.Errorf("empty time range")
+        }
+        fields = fields[:len(fields)-1]
+    }
+    if len(fields) == 0 {
+        for d := range w.days {
+            w.days[d] = true
+        }
+        return w, nil
+    }
+    for _, part := range strings.Split(fields[0], ",") {
+        from, to, isRange := strings.Cut(part, "-")
+        first, ok := weekdayNames[from]
+        last := first
+        if isRange {
+            last, ok = weekdayNames[to]
+        }
+        if !ok || (!isRange && from != part) {
+            return w, fmt.Errorf("unknown day %q (want sun, mon, ... sat)", part)
+        }
+        for d := first; ; d = (d + 1) % 7 {
+            w.days[d] = true
+            if d == last {
+                break
+            }
+        }
+    }
+    return w, nil
+}
+
+// parseClock parses HH:MM (up to 24:00) into minutes after midnight.
+func parseClock(s string) (int, error) {
+    hh, mm, _ := strings.Cut(s, ":")
+    h, errH := strconv.Atoi(hh)
+    m, errM := strconv.Atoi(mm)
+    if errH != nil || errM != nil || len(mm) != 2 || h < 0 || m < 0 || m > 59 || h*60+m > 24*60 {
+        return 0, fmt.Errorf("invalid time %q", s)
+    }
+    return h*60 + m, nil
+}
+
+// Active reports whether t falls into a quiet window. A nil quietHours is
+// never active.
+func (q *quietHours) Active(t time.Time) bool {
+    if q == nil {
+        return false
+    }
+    t = t.In(q.loc)
+    day := t.Weekday()
+    yesterday := (day + 6) % 7
+    minute := t.Hour()*60 + t.Minute()
+    for _, w := range q.windows {
+        if w.start < w.end {
+            if w.days[day] && minute >= w.start && minute < w.end {
+                return true
+            }
+        } else if (w.days[day] && minute >= w.start) || (w.days[yesterday] && minute < w.end) {
+            return true
+        }
+    }
+    return false
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+    "testing"
+    "time"
+)
+
+func TestParseQuietWindow(t *testing.T) {
+    days := func(ds ...time.Weekday) (w [7]bool) {
+        for _, d := range ds {
+            w[d] = true
+        }
+        return w
+    }
+    all := days(time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday)
+
+    tests := []struct {
+        in      string
+        want    quietWindow
+        wantErr bool
+    }{
+        {in: "22:00-07:00", want: quietWindow{days: all, start: 22 * 60, end: 7 * 60}},
+        {in: "12:00-13:30", want: quietWindow{days: all, start: 12 * 60, end: 13*60 + 30}},
+        {in: "00:00-24:00", want: quietWindow{days: all, start: 0, end: 24 * 60}},
+        {in: "sat", want: quietWindow{days: days(time.Saturday), end: 24 * 60}},
+        {in: "Sat-Sun", want: quietWindow{days: days(time.Saturday, time.Sunday), end: 24 * 60}},
+        {in: "mon-fri 12:00-13:00", want: quietWindow{days: days(time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday), start: 12 * 60, end: 13 * 60}},
+        {in: "fri-mon", want: quietWindow{days: days(time.Friday, time.Saturday, time.Sunday, time.Monday), end: 24 * 60}},
+        {in: "mon,wed,fri-sat 23:00-01:00", want: quietWindow{days: days(time.Monday, time.Wednesday, time.Friday, time.Saturday), start: 23 * 60, end: 60}},
+        {in: "", wantErr: true},
+        {in: "mon 12:00-13:00 extra", wantErr: true},
+        {in: "funday", wantErr: true},
+        {in: "mon-someday", wantErr: true},
+        {in: "12:00", wantErr: true},
+        {in: "12:00-12:00", wantErr: true},
+        {in: "25:00-26:00", wantErr: true},
+        {in: "24:01-01:00", wantErr: true},
+        {in: "12:60-13:00", wantErr: true},
+        {in: "-1:00-02:00", wantErr: true},
+        {in: "ab:cd-01:00", wantErr: true},
+        {in: "12:00-13:00x", wantErr: true},
+        {in: "12:5-13:00", wantErr: true},
+    }
+    for _, tt := range tests {
+        got, err := parseQuietWindow(tt.in)
+        if tt.wantErr {
+            if err == nil {
+                t.Errorf("parseQuietWindow(%q) = %+v, want an error", tt.in, got)
+            }
+            continue
+        }
+        if err != nil {
+            t.Errorf("parseQuietWindow(%q) error = %v", tt.in, err)
+        } else if got != tt.want {
+            t.Errorf("parseQuietWindow(%q) = %+v, want %+v", tt.in, got, tt.want)
+        }
+    }
+}
+
+func TestQuietHoursActive(t *testing.T) {
+    q, err := newQuietHours(QuietHoursConfig{
+        Windows:  []string{"fri 22:00-07:00", "sun", "mon-tue 12:00-13:00"},
+        Timezone: "UTC",
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    // 2026-10-16 is a Friday.
+    at := func(day, hour, min int) time.Time {
+        return time.Date(2026, time.October, day, hour, min, 0, 0, time.UTC)
+    }
+    tests := []struct {
+        at   time.Time
+        want bool
+    }{
+        {at(16, 21, 59), false},
+        {at(16, 22, 0), true},
+        {at(16, 23, 59), true},
+        {at(17, 0, 0), true}, // Saturday morning belongs to Friday's window
+        {at(17, 6, 59), true},
+        {at(17, 7, 0), false},
+        {at(17, 22, 30), false}, // the window starts on Fridays only
+        {at(18, 0, 0), true},    // Sunday, all day
+        {at(18, 23, 59), true},
+        {at(19, 0, 0), false}, // Monday
+        {at(19, 12, 0), true},
+        {at(20, 12, 59), true},
+        {at(20, 13, 0), false},
+        {at(21, 12, 30), false}, // Wednesday
+    }
+    for _, tt := range tests {
+        if got := q.Active(tt.at); got != tt.want {
+            t.Errorf("Active(%s) = %v, want %v", tt.at.Format("Mon 15:04"), got, tt.want)
+        }
+    }
+
+    var none *quietHours
+    if none.Active(time.Now()) {
+        t.Error("nil quietHours is active")
+    }
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "log/slog"
+    "net/http"
+)
+
+// releaseTask gives up a claimed task (the DISCLAIM transition), freeing the
+// claim slot.
+func releaseTask(token string, task Task) error {
+    resp, err := doWithRetry("release", defaultRetryPolicy, func() (*http.Request, error) {
+        return newAPIRequest("POST", taskTransitionsURL(task), token, []byte(`{"type": "DISCLAIM"}`))
+    })
+    if err != nil {
+        return err
+    }
+    defer closeBody(resp)
+    logResponse("release", resp, "task_id", task.ID)
+
+    switch resp.StatusCode {
+    case http.StatusOK, http.StatusCreated:
+        return nil
+    case http.StatusPreconditionFailed:
+        return fmt.Errorf("task %s cannot be released (412)", task.ID)
+    case http.StatusUnauthorized:
+        return fmt.Errorf("unauthorized (401)")
+    default:
+        return unexpectedStatus("release", resp.StatusCode, fmt.Errorf("failed to release task, status code: %d", resp.StatusCode))
+    }
+}
+
+// releaseClaimedTask looks up one of the researcher's claimed tasks by ID and
+// releases it.
+func releaseClaimedTask(token, taskID string) (Task, error) {
+    claimed, err := getClaimedTasks(token)
+    if err != nil {
+        return Task{}, err
+    }
+    for _, task := range claimed {
+        if task.ID == taskID {
+            return task, releaseTask(token, task)
+        }
+    }
+    return Task{}, fmt.Errorf("task %s is not among your claimed missions", taskID)
+}
+
+// runRelease implements the "release" subcommand. With -db the release is
+// recorded, so the mission isn't later counted as completed.
+func runRelease(args []string) error {
+    fs := flag.NewFlagSet("release", flag.ExitOnError)
+    tokenFlag := fs.String("t", "", "Session token for authentication")
+    dbFlag := fs.String("db", "", "Database file to record the release in")
+    passphraseFlag := fs.String("db-passphrase-file", "", "File containing the database passphrase")
+    fs.Parse(args)
+    if *tokenFlag == "" || fs.NArg() != 1 {
+        fs.Usage()
+        return fmt.Errorf("usage: release -t <token> [-db <file>] <task id>")
+    }
+    var db *store
+    if *dbFlag != "" {
+        passphrase, err := readPassphrase(*passphraseFlag)
+        if err != nil {
+            return err
+        }
+        if db, err = openStore(*dbFlag, passphrase); err != nil {
+            return err
+        }
+    }
+    task, err := releaseClaimedTask(*tokenFlag, fs.Arg(0))
+    if err != nil {
+        return err
+    }
+    slog.Info("task released", "task_id", task.ID, "title", task.Title)
+    if db != nil {
+        if err := db.MarkReleased(task.ID); err != nil {
+            return fmt.Errorf("recording the release: %v", err)
+        }
+    }
+    return nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+    "log/slog"
+    "os"
+    "os/signal"
+    "reflect"
+    "time"
+)
+
+// restartSections are the parts of the config that are only read at
+// startup, by goroutines or connections set up once. A reload that changes
+// them says so instead of applying them.
+var restartSections = []struct {
+    name string
+    get  func(Config) any
+}{
+    {"accounts", func(c Config) any { return c.Accounts }},
+    {"event_hooks", func(c Config) any { return c.EventHooks }},
+    {"hot_targets", func(c Config) any { return c.HotTargets }},
+    {"notifications", func(c Config) any { return c.Notifications }},
+    {"signup_hooks.max_concurrent", func(c Config) any { return c.SignupHooks.MaxConcurrent }},
+    {"signup_hooks.output_dir", func(c Config) any { return c.SignupHooks.OutputDir }},
+    {"target_cache", func(c Config) any { return c.TargetCache }},
+    {"telegram", func(c Config) any { return c.Telegram }},
+}
+
+// config returns the current config.
+func (b *bot) config() Config {
+    b.cfgMu.Lock()
+    defer b.cfgMu.Unlock()
+    return b.cfg
+}
+
+// quiet returns the current quiet hours (nil = none).
+func (b *bot) quiet() *quietHours {
+    b.cfgMu.Lock()
+    defer b.cfgMu.Unlock()
+    return b.quietHours
+}
+
+// reloadConfig loads the config file again and applies it to the running
+// bot. A config that fails to load changes nothing.
+func (b *bot) reloadConfig(path string) error {
+    cfg, err := loadConfig(path)
+    if err != nil {
+        return err
+    }
+    // loadConfig has validated the rules and quiet hours.
+    rules, _ := compileClaimRules(cfg.ClaimRules)
+    quiet, _ := newQuietHours(cfg.QuietHours)
+
+    b.cfgMu.Lock()
+    old := b.cfg
+    b.cfg, b.quietHours = cfg, quiet
+    b.cfgMu.Unlock()
+    b.claimRules.Set(rules)
+    setStatusPolicies(cfg.StatusPolicies)
+    setTargetOverrides(cfg.TargetOverrides)
+    setDeepLinks(cfg.DeepLinks)
+
+    var restart []string
+    for _, s := range restartSections {
+        if !reflect.DeepEqual(s.get(old), s.get(cfg)) {
+            restart = append(restart, s.name)
+        }
+    }
+    if len(restart) > 0 {
+        slog.Warn("config changes that only apply after a restart", "sections", restart)
+    }
+    slog.Info("config reloaded", "path", path, "claim_rules", len(rules))
+    return nil
+}
+
+// watchConfig checks the config file every few seconds and reloads it
+// (see reloadConfig) when it changed. A config that fails to load keeps the
+// previous one.
+func (b *bot) watchConfig(path string) {
+    var lastMod time.Time
+    if fi, err := os.Stat(path); err == nil {
+        lastMod = fi.ModTime()
+    }
+    for {
+        time.Sleep(tokenFilePollInterval)
+        fi, err := os.Stat(path)
+        if err != nil || fi.ModTime().Equal(lastMod) {
+            continue
+        }
+        lastMod = fi.ModTime()
+        if err := b.reloadConfig(path); err != nil {
+            slog.Error("config changed but could not be loaded, keeping the previous one", "err", err)
+        }
+    }
+}
+
+// reloadTokenFile reads the token file again, even if its modification time
+// didn't change.
+func (b *bot) reloadTokenFile() {
+    token, err := readTokenFile(b.tokenFile)
+    if err != nil {
+        slog.Error("token file could not be reloaded, keeping the current token", "path", b.tokenFile, "err", err)
+        return
+    }
+    if token != b.tokens.Get() {
+        b.tokens.Set(token)
+        slog.Info("loaded new token from file", "path", b.tokenFile)
+    }
+}
+
+// reloadOnSignal reopens the log file and reloads the config file and the
+// token file on every reload signal (SIGHUP), for service managers and
+// logrotate hooks. The polling loops keep running, and the known slugs,
+// pauses and claims are kept. Targets skipped by an earlier target filter
+// are looked at again with the reloaded one.
+func (b *bot) reloadOnSignal(configPath string) {
+    if len(reloadSignals) == 0 {
+        return
+    }
+    sigs := make(chan os.Signal, 1)
+    signal.Notify(sigs, reloadSignals...)
+    for sig := range sigs {
+        if err := logFile.Reopen(); err != nil {
+            slog.Error("log file could not be reopened", "err", err)
+        }
+        slog.Info("reloading", "signal", sig)
+        if configPath != "" {
+            if err := b.reloadConfig(configPath); err != nil {
+                slog.Error("config could not be reloaded, keeping the previous one", "err", err)
+            }
+        }
+        if b.tokenFile != "" {
+            b.reloadTokenFile()
+        }
+    }
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "log/slog"
+    "slices"
+    "strings"
+    "time"
+)
+
+// Outcomes of an earlier claim of the same mission content.
+const (
+    OutcomeReleased  = "released"
+    OutcomeCompleted = "completed"
+)
+
+// missionContentHash identifies a mission by its content rather than its
+// ID: target, category and normalized title. A re-published mission gets a
+// new ID but keeps this hash.
+func missionContentHash(task Task) string {
+    title := strings.Join(strings.Fields(strings.ToLower(task.Title)), " ")
+    sum := sha256.Sum256([]byte(task.ListingUid + "\x00" + task.Category + "\x00" + title))
+    return hex.EncodeToString(sum[:8])
+}
+
+// parseRepublishedPolicy parses the -skip-republished list of outcomes.
+func parseRepublishedPolicy(s string) ([]string, error) {
+    var outcomes []string
+    for _, f := range strings.Split(s, ",") {
+        f = strings.TrimSpace(f)
+        switch f {
+        case "":
+            continue
+        case OutcomeReleased, OutcomeCompleted:
+            outcomes = append(outcomes, f)
+        default:
+            return nil, fmt.Errorf("invalid outcome %q (want released or completed)", f)
+        }
+    }
+    return outcomes, nil
+}
+
+// republished describes an earlier claim of the same mission content.
+type republished struct {
+    Outcome string
+    At      time.Time
+}
+
+func (r republished) String() string {
+    return fmt.Sprintf("re-publication of a mission you %s on %s", r.Outcome, r.At.Local().Format("2 Jan 2006"))
+}
+
+// dropRepublished drops the tasks that re-publish a mission whose earlier
+// claim ended with one of the outcomes in b.skipRepublished.
+func (b *bot) dropRepublished(tasks []Task) []Task {
+    if len(b.skipRepublished) == 0 {
+        return tasks
+    }
+    kept := tasks[:0:0]
+    for _, task := range tasks {
+        if r, ok := b.store.Republished(task); ok && slices.Contains(b.skipRepublished, r.Outcome) {
+            slog.Info("skipping task, re-published mission", "task_id", task.ID, "title", task.Title, "outcome", r.Outcome, "at", r.At)
+            b.state.RecordSkips(SkipRepublished, []Task{task})
+            continue
+        }
+        kept = append(kept, task)
+    }
+    return kept
+}
+
+// releaseClaimed releases one of the researcher's claimed missions and
+// records the release, so a re-publication of it can be recognized.
+func (b *bot) releaseClaimed(taskID string) (Task, error) {
+    task, err := releaseClaimedTask(b.tokens.Get(), taskID)
+    if err != nil {
+        return task, err
+    }
+    if err := b.store.MarkReleased(task.ID); err != nil {
+        slog.Error("failed to record released mission", "task_id", task.ID, "err", err)
+    }
+    return task, nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+    "bytes"
+    "io"
+    "log/slog"
+    "math/rand/v2"
+    "net/http"
+    "strconv"
+    "time"
+)
+
+// retryPolicy controls how throttled or temporarily unavailable requests are retried.
+type retryPolicy struct {
+    // MaxAttempts is the total number of attempts, including the first one.
+    MaxAttempts int
+    // BaseDelay is the first backoff; it doubles on every further attempt.
+    BaseDelay time.Duration
+    // MaxDelay caps the computed backoff and any Retry-After value.
+    MaxDelay time.Duration
+    // Jitter randomizes each delay by up to this fraction in either direction.
+    Jitter float64
+}
+
+// defaultRetryPolicy is used for all read endpoints and target signups.
+var defaultRetryPolicy = retryPolicy{
+    MaxAttempts: 4,
+    BaseDelay:   15 * time.Second,
+    MaxDelay:    5 * time.Minute,
+    Jitter:      0.2,
+}
+
+// retryableStatus reports whether a known response status is worth retrying.
+func retryableStatus(code int) bool {
+    return code == http.StatusTooManyRequests || code == http.StatusServiceUnavailable
+}
+
+// newAPIRequest builds an authenticated JSON request to the platform.
+func newAPIRequest(method, url, token string, payload []byte) (*http.Request, error) {
+    var body io.Reader
+    if payload != nil {
+        body = bytes.NewReader(payload)
+    }
+    req, err := http.NewRequest(method, url, body)
+    if err != nil {
+        return nil, err
+    }
+    req.Header.Set("Authorization", "Bearer "+token)
+    req.Header.Set("Content-Type", "application/json")
+    return req, nil
+}
+
+// doWithRetry sends the request returned by newReq, retrying 429 and 503
+// responses, unknown responses whose endpoint policy is "retry", and (for GET
+// requests) network errors, with exponential backoff and jitter, honoring
+// Retry-After. newReq is called for every attempt so
+// request bodies are fresh. When all attempts are used up, the last response
+// or error is returned for the caller to handle.
+func doWithRetry(endpoint string, policy retryPolicy, newReq func() (*http.Request, error)) (*http.Response, error) {
+    client := globalHTTPClient()
+    for attempt := 1; ; attempt++ {
+        req, err := newReq()
+        if err != nil {
+            return nil, err
+        }
+
+        resp, err := client.Do(req)
+        last := attempt >= policy.MaxAttempts
+        switch {
+        case err != nil:
+            audit.Record(AuditAPICall, "endpoint", endpoint, "method", req.Method, "attempt", attempt, "err", err)
+            if last || req.Method != http.MethodGet {
+                return nil, err
+            }
+            delay := policy.backoff(attempt)
+            slog.Warn("request failed, retrying", "endpoint", endpoint, "attempt", attempt, "wait", delay, "err", err)
+            time.Sleep(delay)
+
+        case shouldRetry(endpoint, resp.StatusCode) && !last:
+            delay := policy.delay(attempt, resp.Header.Get("Retry-After"))
+            slog.Warn("rate limited or unavailable, backing off", "endpoint", endpoint, "status", resp.StatusCode,
+                "attempt", attempt, "retry_after", resp.Header.Get("Retry-After"), "wait", delay)
+            closeBody(resp)
+            time.Sleep(delay)
+
+        default:
+            return resp, nil
+        }
+    }
+}
+
+// delay returns how long to wait before the next attempt, preferring the
+// server's Retry-After (seconds or HTTP-date) over the computed backoff.
+func (p retryPolicy) delay(attempt int, retryAfter string) time.Duration {
+    if d, ok := parseRetryAfter(retryAfter, time.Now()); ok {
+        if d > p.MaxDelay {
+            d = p.MaxDelay
+        }
+        return d
+    }
+    return p.backoff(attempt)
+}
+
+// backoff returns the jittered exponential backoff for an attempt (1-based).
+func (p retryPolicy) backoff(attempt int) time.Duration {
+    d := p.BaseDelay << (attempt - 1)
+    if d <= 0 || d > p.MaxDelay {
+        d = p.MaxDelay
+    }
+    return jittered(d, p.Jitter)
+}
+
+// jittered randomizes d by up to fraction in either direction.
+func jittered(d time.Duration, fraction float64) time.Duration {
+    if fraction <= 0 {
+        return d
+    }
+    return time.Duration(float64(d) * (1 + fraction*(2*rand.Float64()-1)))
+}
+
+// parseRetryAfter parses a Retry-After header given as delay-seconds or HTTP-date.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+    if v == "" {
+        return 0, false
+    }
+    if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+        return time.Duration(secs) * time.Second, true
+    }
+    if t, err := http.ParseTime(v); err == nil {
+        if d := t.Sub(now); d > 0 {
+            return d, true
+        }
+        return 0, true
+    }
+    return 0, false
+}
@@ -0,0 +1,574 @@
+package main
+
+import (
+    "fmt"
+    "log/slog"
+    "regexp"
+    "slices"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+    "unicode"
+)
+
+// ClaimRule decides whether the missions its expression matches are claimed.
+// Rules are tried in order and the first match wins; a mission no rule
+// matches is claimed.
+type ClaimRule struct {
+    Name string `json:"name"`
+    // If is an expression such as
+    // `payout >= 50 && target.category == "web" && !title.lower().contains("mobile")`.
+    If string `json:"if"`
+    // Then is "claim" or "skip".
+    Then string `json:"then"`
+}
+
+// ruleVars are the mission attributes available to rule expressions.
+var ruleVars = map[string]func(t Task) any{
+    "id":               func(t Task) any { return t.ID },
+    "title":            func(t Task) any { return t.Title },
+    "category":         func(t Task) any { return t.Category },
+    "payout":           func(t Task) any { return t.Payout.Amount },
+    "currency":         func(t Task) any { return t.Payout.Currency },
+    "campaign":         func(t Task) any { return t.CampaignUid },
+    "organization":     func(t Task) any { return t.OrganizationUid },
+    "target.slug":      func(t Task) any { return t.ListingUid },
+    "target.codename":  func(t Task) any { return t.ListingCodename },
+    "age_minutes":      func(t Task) any { return publishedMinutesAgo(t) },
+    "completion_hours": func(t Task) any { return float64(t.MaxCompletionTimeInSecs) / 3600 },
+    // target.category is looked up in the target cache, only when a rule
+    // needs it; see ruleSet.Decide.
+    "target.category": nil,
+}
+
+// publishedMinutesAgo is how long ago the task was published, or 0 if unknown.
+func publishedMinutesAgo(t Task) float64 {
+    if t.PublishedOn.IsZero() {
+        return 0
+    }
+    return time.Since(t.PublishedOn.Time).Minutes()
+}
+
+// compiledRule is a ClaimRule with its expression parsed.
+type compiledRule struct {
+    ClaimRule
+    expr ruleExpr
+}
+
+// compileClaimRules parses the rules' expressions.
+func compileClaimRules(rules []ClaimRule) ([]compiledRule, error) {
+    compiled := make([]compiledRule, 0, len(rules))
+    for i, r := range rules {
+        name := r.Name
+        if name == "" {
+            name = fmt.Sprintf("claim_rules[%d]", i)
+        }
+        if r.Then != "claim" && r.Then != "skip" {
+            return nil, fmt.Errorf("%s: then must be \"claim\" or \"skip\", not %q", name, r.Then)
+        }
+        p := &ruleParser{src: r.If}
+        expr, err := p.parse()
+        if err != nil {
+            return nil, fmt.Errorf("%s: %v", name, err)
+        }
+        r.Name = name
+        compiled = append(compiled, compiledRule{ClaimRule: r, expr: expr})
+    }
+    return compiled, nil
+}
+
+// ruleSet holds the active claim rules, which can be replaced while the bot
+// runs. All methods are safe for concurrent use.
+type ruleSet struct {
+    mu    sync.Mutex
+    rules []compiledRule
+}
+
+// Set replaces the rules.
+func (s *ruleSet) Set(rules []compiledRule) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.rules = rules
+}
+
+// Decide reports whether the task should be claimed and which rule decided.
+// targetCategory is only called for rules that need it. A rule whose
+// expression fails to evaluate, or isn't a condition, is logged; a skip rule
+// then skips the task, since it might have matched, and a claim rule is
+// treated as not matching.
+func (s *ruleSet) Decide(task Task, targetCategory func() (string, error)) (bool, string) {
+    s.mu.Lock()
+    rules := s.rules
+    s.mu.Unlock()
+
+    var category *string
+    env := func(name string) (any, error) {
+        if name != "target.category" {
+            return ruleVars[name](task), nil
+        }
+        if category == nil {
+            c, err := targetCategory()
+            if err != nil {
+                return nil, fmt.Errorf("looking up target category: %v", err)
+            }
+            category = &c
+        }
+        return *category, nil
+    }
+    for _, r := range rules {
+        v, err := r.expr.eval(env)
+        matched, ok := v.(bool)
+        if err == nil && !ok {
+            err = fmt.Errorf("not a condition: %v", v)
+        }
+        if err != nil {
+            slog.Warn("claim rule failed", "rule", r.Name, "then", r.Then, "task_id", task.ID, "err", err)
+            if r.Then == "skip" {
+                return false, r.Name
+            }
+            continue
+        }
+        if matched {
+            return r.Then == "claim", r.Name
+        }
+    }
+    return true, ""
+}
+
+// ruleExpr is a node of a parsed rule expression. Values are float64,
+// string or bool.
+type ruleExpr interface {
+    eval(env func(name string) (any, error)) (any, error)
+}
+
+type (
+    literalExpr  struct{ value any }
+    variableExpr struct{ name string }
+    notExpr      struct{ x ruleExpr }
+    negExpr      struct{ x ruleExpr }
+    binaryExpr   struct {
+        op   string
+        x, y ruleExpr
+    }
+    methodExpr struct {
+        recv ruleExpr
+        name string
+        args []ruleExpr
+        re   *regexp.Regexp // for matches() with a literal pattern
+    }
+)
+
+func (e literalExpr) eval(func(string) (any, error)) (any, error) { return e.value, nil }
+
+func (e variableExpr) eval(env func(string) (any, error)) (any, error) { return env(e.name) }
+
+func (e notExpr) eval(env func(string) (any, error)) (any, error) {
+    v, err := evalAs[bool](e.x, env, "!")
+    return !v, err
+}
+
+func (e negExpr) eval(env func(string) (any, error)) (any, error) {
+    v, err := evalAs[float64](e.x, env, "-")
+    return -v, err
+}
+
+func (e binaryExpr) eval(env func(string) (any, error)) (any, error) {
+    switch e.op {
+    case "&&", "||":
+        x, err := evalAs[bool](e.x, env, e.op)
+        if err != nil || x == (e.op == "||") {
+            return x, err
+        }
+        return evalAs[bool](e.y, env, e.op)
+    }
+
+    x, err := e.x.eval(env)
+    if err != nil {
+        return nil, err
+    }
+    y, err := e.y.eval(env)
+    if err != nil {
+        return nil, err
+    }
+    switch e.op {
+    case "==":
+        return x == y, sameType(x, y, e.op)
+    case "!=":
+        return x != y, sameType(x, y, e.op)
+    }
+    if err := sameType(x, y, e.op); err != nil {
+        return nil, err
+    }
+    var cmp int
+    switch x := x.(type) {
+    case float64:
+        cmp = compare(x, y.(float64))
+    case string:
+        cmp = strings.Compare(x, y.(string))
+    default:
+        return nil, fmt.Errorf("%s cannot compare %T values", e.op, x)
+    }
+    switch e.op {
+    case "<":
+        return cmp < 0, nil
+    case "<=":
+        return cmp <= 0, nil
+    case ">":
+        return cmp > 0, nil
+    default:
+        return cmp >= 0, nil
+    }
+}
+
+func (e methodExpr) eval(env func(string) (any, error)) (any, error) {
+    s, err := evalAs[string](e.recv, env, "."+e.name+"()")
+    if err != nil {
+        return nil, err
+    }
+    if e.name == "lower" {
+        return strings.ToLower(s), nil
+    }
+    arg, err := evalAs[string](e.args[0], env, "."+e.name+"()")
+    if err != nil {
+        return nil, err
+    }
+    switch e.name {
+    case "contains":
+        return strings.Contains(s, arg), nil
+    case "startsWith":
+        return strings.HasPrefix(s, arg), nil
+    case "endsWith":
+        return strings.HasSuffix(s, arg), nil
+    default: // matches
+        re := e.re
+        if re == nil {
+            if re, err = regexp.Compile(arg); err != nil {
+                return nil, err
+            }
+        }
+        return re.MatchString(s), nil
+    }
+}
+
+// evalAs evaluates x and checks that the result has type T.
+func evalAs[T any](x ruleExpr, env func(string) (any, error), op string) (T, error) {
+    var zero T
+    v, err := x.eval(env)
+    if err != nil {
+        return zero, err
+    }
+    t, ok := v.(T)
+    if !ok {
+        return zero, fmt.Errorf("%s expects %s, got %s", op, typeName(zero), typeName(v))
+    }
+    return t, nil
+}
+
+// sameType reports an error unless x and y have the same type.
+func sameType(x, y any, op string) error {
+    if fmt.Sprintf("%T", x) != fmt.Sprintf("%T", y) {
+        return fmt.Errorf("%s compares %s with %s", op, typeName(x), typeName(y))
+    }
+    return nil
+}
+
+func compare(x, y float64) int {
+    switch {
+    case x < y:
+        return -1
+    case x > y:
+        return 1
+    }
+    return 0
+}
+
+// typeName names a value's type in rule terms.
+func typeName(v any) string {
+    switch v.(type) {
+    case float64:
+        return "number"
+    case string:
+        return "string"
+    case bool:
+        return "bool"
+    }
+    return fmt.Sprintf("%T", v)
+}
+
+// ruleMethods are the string methods available to rule expressions, with
+// their number of arguments.
+var ruleMethods = map[string]int{"contains": 1, "startsWith": 1, "endsWith": 1, "matches": 1, "lower": 0}
+
+// ruleParser is a recursive descent parser for rule expressions:
+//
+//	expr    = and { "||" and }
+//	and     = cmp { "&&" cmp }
+//	cmp     = unary [ ( "==" | "!=" | "<" | "<=" | ">" | ">=" ) unary ]
+//	unary   = ( "!" | "-" ) unary | postfix
+//	postfix = primary { "." method "(" [ expr { "," expr } ] ")" }
+//	primary = number | string | "true" | "false" | variable | "(" expr ")"
+type ruleParser struct {
+    src string
+    pos int
+    tok string // current token; "" at the end
+}
+
+func (p *ruleParser) parse() (ruleExpr, error) {
+    if err := p.next(); err != nil {
+        return nil, err
+    }
+    x, err := p.parseOr()
+    if err != nil {
+        return nil, err
+    }
+    if p.tok != "" {
+        return nil, p.errorf("unexpected %q", p.tok)
+    }
+    return x, nil
+}
+
+func (p *ruleParser) errorf(format string, args ...any) error {
+    return fmt.Errorf("expression %q at offset %d: %s", p.src, p.pos, fmt.Sprintf(format, args...))
+}
+
+// next advances to the next token.
+func (p *ruleParser) next() error {
+    for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+        p.pos++
+    }
+    if p.pos >= len(p.src) {
+        p.tok = ""
+        return nil
+    }
+    start := p.pos
+    c := p.src[p.pos]
+    switch {
+    case c == '"' || c == '\'':
+        p.pos++
+        for p.pos < len(p.src) && p.src[p.pos] != c {
+            if p.src[p.pos] == '\\' {
+                p.pos++
+            }
+            p.pos++
+        }
+        if p.pos >= len(p.src) {
+            return p.errorf("unterminated string")
+        }
+        p.pos++
+    case c >= '0' && c <= '9':
+        for p.pos < len(p.src) && (p.src[p.pos] >= '0' && p.src[p.pos] <= '9' || p.src[p.pos] == '.') {
+            p.pos++
+        }
+    case c == '_' || unicode.IsLetter(rune(c)):
+        for p.pos < len(p.src) && (p.src[p.pos] == '_' || unicode.IsLetter(rune(p.src[p.pos])) || unicode.IsDigit(rune(p.src[p.pos]))) {
+            p.pos++
+        }
+    default:
+        for _, op := range []string{"&&", "||", "==", "!=", "<=", ">="} {
+            if strings.HasPrefix(p.src[p.pos:], op) {
+                p.pos += len(op)
+                p.tok = op
+                return nil
+            }
+        }
+        if !strings.ContainsRune("!<>()-.,", rune(c)) {
+            return p.errorf("unexpected character %q", c)
+        }
+        p.pos++
+    }
+    p.tok = p.src[start:p.pos]
+    return nil
+}
+
+func (p *ruleParser) parseOr() (ruleExpr, error) {
+    return p.parseBinary([]string{"||"}, p.parseAnd)
+}
+
+func (p *ruleParser) parseAnd() (ruleExpr, error) {
+    return p.parseBinary([]string{"&&"}, p.parseCmp)
+}
+
+// parseBinary parses left-associative operators of one precedence level.
+func (p *ruleParser) parseBinary(ops []string, operand func() (ruleExpr, error)) (ruleExpr, error) {
+    x, err := operand()
+    if err != nil {
+        return nil, err
+    }
+    for slices.Contains(ops, p.tok) {
+        op := p.tok
+        if err := p.next(); err != nil {
+            return nil, err
+        }
+        y, err := operand()
+        if err != nil {
+            return nil, err
+        }
+        x = binaryExpr{op: op, x: x, y: y}
+    }
+    return x, nil
+}
+
+func (p *ruleParser) parseCmp() (ruleExpr, error) {
+    x, err := p.parseUnary()
+    if err != nil {
+        return nil, err
+    }
+    switch op := p.tok; op {
+    case "==", "!=", "<", "<=", ">", ">=":
+        if err := p.next(); err != nil {
+            return nil, err
+        }
+        y, err := p.parseUnary()
+        if err != nil {
+            return nil, err
+        }
+        return binaryExpr{op: op, x: x, y: y}, nil
+    }
+    return x, nil
+}
+
+func (p *ruleParser) parseUnary() (ruleExpr, error) {
+    switch op := p.tok; op {
+    case "!", "-":
+        if err := p.next(); err != nil {
+            return nil, err
+        }
+        x, err := p.parseUnary()
+        if err != nil {
+            return nil, err
+        }
+        if op == "!" {
+            return notExpr{x}, nil
+        }
+        return negExpr{x}, nil
+    }
+    return p.parsePostfix()
+}
+
+func (p *ruleParser) parsePostfix() (ruleExpr, error) {
+    x, err := p.parsePrimary()
+    if err != nil {
+        return nil, err
+    }
+    for p.tok == "." {
+        if err := p.next(); err != nil {
+            return nil, err
+        }
+        name := p.tok
+        nargs, ok := ruleMethods[name]
+        if !ok {
+            return nil, p.errorf("unknown method %q (want contains, startsWith, endsWith, matches or lower)", name)
+        }
+        if err := p.next(); err != nil {
+            return nil, err
+        }
+        if err := p.expect("("); err != nil {
+            return nil, err
+        }
+        m := methodExpr{recv: x, name: name}
+        for p.tok != ")" {
+            if len(m.args) > 0 {
+                if err := p.expect(","); err != nil {
+                    return nil, err
+                }
+            }
+            arg, err := p.parseOr()
+            if err != nil {
+                return nil, err
+            }
+            m.args = append(m.args, arg)
+        }
+        if err := p.expect(")"); err != nil {
+            return nil, err
+        }
+        if len(m.args) != nargs {
+            return nil, p.errorf("%s() takes %d argument(s)", name, nargs)
+        }
+        if nargs == 0 {
+            x = m
+            continue
+        }
+        if lit, ok := m.args[0].(literalExpr); ok && name == "matches" {
+            if pattern, ok := lit.value.(string); ok {
+                if m.re, err = regexp.Compile(pattern); err != nil {
+                    return nil, p.errorf("matches(): %v", err)
+                }
+            }
+        }
+        x = m
+    }
+    return x, nil
+}
+
+func (p *ruleParser) parsePrimary() (ruleExpr, error) {
+    tok := p.tok
+    switch {
+    case tok == "":
+        return nil, p.errorf("unexpected end of expression")
+    case tok == "(":
+        if err := p.next(); err != nil {
+            return nil, err
+        }
+        x, err := p.parseOr()
+        if err != nil {
+            return nil, err
+        }
+        return x, p.expect(")")
+    case tok[0] == '"' || tok[0] == '\'':
+        s, err := unquoteRuleString(tok)
+        if err != nil {
+            return nil, p.errorf("invalid string %s", tok)
+        }
+        return literalExpr{s}, p.next()
+    case tok[0] >= '0' && tok[0] <= '9':
+        n, err := strconv.ParseFloat(tok, 64)
+        if err != nil {
+            return nil, p.errorf("invalid number %s", tok)
+        }
+        return literalExpr{n}, p.next()
+    case tok == "true" || tok == "false":
+        return literalExpr{tok == "true"}, p.next()
+    }
+
+    // A variable, possibly dotted like target.category.
+    name := tok
+    if err := p.next(); err != nil {
+        return nil, err
+    }
+    for p.tok == "." {
+        if _, isVar := ruleVars[name]; isVar {
+            break // a method call on the variable
+        }
+        if err := p.next(); err != nil {
+            return nil, err
+        }
+        name += "." + p.tok
+        if err := p.next(); err != nil {
+            return nil, err
+        }
+    }
+    if _, ok := ruleVars[name]; !ok {
+        return nil, p.errorf("unknown variable %q", name)
+    }
+    return variableExpr{name}, nil
+}
+
+// expect consumes tok or reports an error.
+func (p *ruleParser) expect(tok string) error {
+    if p.tok != tok {
+        if p.tok == "" {
+            return p.errorf("expected %q", tok)
+        }
+        return p.errorf("expected %q, got %q", tok, p.tok)
+    }
+    return p.next()
+}
+
+// unquoteRuleString unquotes a double or single quoted string literal.
+func unquoteRuleString(tok string) (string, error) {
+    if tok[0] == '\'' {
+        tok = `"` + strings.ReplaceAll(strings.ReplaceAll(tok[1:len(tok)-1], `"`, `\"`), `\'`, `'`) + `"`
+    }
+    return strconv.Unquote(tok)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+    "errors"
+    "strings"
+    "testing"
+)
+
+func TestClaimRulesDecide(t *testing.T) {
+    category := func() (string, error) { return "Web Application", nil }
+    uncached := func() (string, error) { return "", errors.New("details of target listing not cached yet") }
+
+    tests := []struct {
+        name     string
+        rules    []ClaimRule
+        category func() (string, error)
+        wantErr  string
+        claim    bool
+        rule     string
+    }{
+        {name: "no rules", claim: true},
+        {
+            name:  "first match decides",
+            rules: []ClaimRule{{Name: "a", If: "payout >= 50", Then: "skip"}, {Name: "b", If: "true", Then: "claim"}},
+            claim: false, rule: "a",
+        },
+        {
+            name:  "no match claims",
+            rules: []ClaimRule{{Name: "a", If: "payout > 50", Then: "skip"}},
+            claim: true,
+        },
+        {
+            name:  "and binds tighter than or",
+            rules: []ClaimRule{{Name: "a", If: "true || false && false", Then: "skip"}},
+            claim: false, rule: "a",
+        },
+        {
+            name:  "parentheses",
+            rules: []ClaimRule{{Name: "a", If: "(true || false) && false", Then: "skip"}},
+            claim: true,
+        },
+        {
+            name:  "not binds tighter than and",
+            rules: []ClaimRule{{Name: "a", If: "!false && !(payout < 10)", Then: "skip"}},
+            claim: false, rule: "a",
+        },
+        {
+            name:  "string methods",
+            rules: []ClaimRule{{Name: "a", If: `title.lower().startsWith("mission") && id.matches("^[0-9]+$")`, Then: "skip"}},
+            claim: false, rule: "a",
+        },
+        {
+            name:     "target category",
+            rules:    []ClaimRule{{Name: "a", If: `target.category == "Web Application"`, Then: "claim"}, {Name: "b", If: "true", Then: "skip"}},
+            category: category,
+            claim:    true, rule: "a",
+        },
+        {
+            name:     "skip rule that can't be evaluated skips",
+            rules:    []ClaimRule{{Name: "a", If: `target.category == "Mobile"`, Then: "skip"}},
+            category: uncached,
+            claim:    false, rule: "a",
+        },
+        {
+            name:     "claim rule that can't be evaluated doesn't match",
+            rules:    []ClaimRule{{Name: "a", If: `target.category == "Web Application"`, Then: "claim"}, {Name: "b", If: "true", Then: "skip"}},
+            category: uncached,
+            claim:    false, rule: "b",
+        },
+        {
+            name:  "type mismatch in a skip rule skips",
+            rules: []ClaimRule{{Name: "a", If: `payout == "50"`, Then: "skip"}},
+            claim: false, rule: "a",
+        },
+        {
+            name:  "type mismatch in a claim rule doesn't match",
+            rules: []ClaimRule{{Name: "a", If: `payout < title`, Then: "claim"}, {Name: "b", If: "true", Then: "skip"}},
+            claim: false, rule: "b",
+        },
+        {
+            name:  "non-condition skip rule skips",
+            rules: []ClaimRule{{Name: "a", If: "payout", Then: "skip"}},
+            claim: false, rule: "a",
+        },
+        {name: "unknown variable", rules: []ClaimRule{{If: "bounty > 1", Then: "skip"}}, wantErr: "unknown variable"},
+        {name: "unterminated string", rules: []ClaimRule{{If: `title == "x`, Then: "skip"}}, wantErr: "unterminated string"},
+        {name: "unknown method", rules: []ClaimRule{{If: `title.upper()`, Then: "skip"}}, wantErr: "unknown method"},
+        {name: "trailing input", rules: []ClaimRule{{If: "true true", Then: "skip"}}, wantErr: "claim_rules[0]"},
+        {name: "bad then", rules: []ClaimRule{{Name: "a", If: "true", Then: "maybe"}}, wantErr: `then must be "claim" or "skip"`},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            compiled, err := compileClaimRules(tt.rules)
+            if tt.wantErr != "" {
+                if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+                    t.Fatalf("compileClaimRules() error = %v, want one containing %q", err, tt.wantErr)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("compileClaimRules() error = %v", err)
+            }
+            var s ruleSet
+            s.Set(compiled)
+            task := testTask("42")
+            if tt.category == nil {
+                tt.category = category
+            }
+            claim, rule := s.Decide(task, tt.category)
+            if claim != tt.claim || rule != tt.rule {
+                t.Errorf("Decide() = %v, %q, want %v, %q", claim, rule, tt.claim, tt.rule)
+            }
+        })
+    }
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+    "time"
+)
+
+// sessionGrace is how long after the deadline of a bounded run the bot may
+// take to finish what it is doing (e.g. waiting out a retry) before it is stopped.
+const sessionGrace = time.Minute
+
+// sessionDeadline returns when a bounded run should end: after runFor, or at
+// until (a clock time such as "22:00", taken as its next occurrence, or an
+// RFC 3339 timestamp), whichever comes first. The zero time means no limit.
+func sessionDeadline(now time.Time, runFor time.Duration, until string) (time.Time, error) {
+    var deadline time.Time
+    if runFor > 0 {
+        deadline = now.Add(runFor)
+    }
+    if until != "" {
+        t, err := parseUntil(now, until)
+        if err != nil {
+            return time.Time{}, err
+        }
+        if deadline.IsZero() || t.Before(deadline) {
+            deadline = t
+        }
+    }
+    return deadline, nil
+}
+
+// parseUntil parses an -until value relative to now.
+func parseUntil(now time.Time, s string) (time.Time, error) {
+    if t, err := time.Parse(time.RFC3339, s); err == nil {
+        return t, nil
+    }
+    clock, err := time.ParseInLocation("15:04", s, now.Location())
+    if err != nil {
+        return time.Time{}, fmt.Errorf("invalid -until %q (want 15:04 or RFC 3339)", s)
+    }
+    t := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, now.Location())
+    if !t.After(now) {
+        t = t.AddDate(0, 0, 1)
+    }
+    return t, nil
+}
+
+// sessionStats counts what the bot achieved since it started.
+type sessionStats struct {
+    Start   time.Time          `json:"start"`
+    Claims  int                `json:"claims"`
+    Payouts map[string]float64 `json:"payouts"` // total claimed payout per currency
+    Signups int                `json:"signups"`
+}
+
+// Summary describes the session for the shutdown notification.
+func (s sessionStats) Summary(end time.Time) string {
+    var b strings.Builder
+    fmt.Fprintf(&b, "Session ended after %s: claimed %d missions", end.Sub(s.Start).Round(time.Minute), s.Claims)
+    if len(s.Payouts) > 0 {
+        currencies := make([]string, 0, len(s.Payouts))
+        for c := range s.Payouts {
+            currencies = append(currencies, c)
+        }
+        sort.Strings(currencies)
+        totals := make([]string, len(currencies))
+        for i, c := range currencies {
+            totals[i] = TaskPayout{Amount: s.Payouts[c], Currency: c}.String()
+        }
+        fmt.Fprintf(&b, " worth %s", strings.Join(totals, " + "))
+    }
+    fmt.Fprintf(&b, ", signed up for %d targets.", s.Signups)
+    return b.String()
+}
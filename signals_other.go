@@ -0,0 +1,12 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// stopSignals are passed on from the account supervisor to the accounts.
+var stopSignals = []os.Signal{os.Interrupt}
+
+// reloadSignals make the bot reload its config and token file; there is no
+// SIGHUP here.
+var reloadSignals []os.Signal
@@ -0,0 +1,14 @@
+//go:build unix
+
+package main
+
+import (
+    "os"
+    "syscall"
+)
+
+// stopSignals are passed on from the account supervisor to the accounts.
+var stopSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// reloadSignals make the bot reload its config and token file.
+var reloadSignals = []os.Signal{syscall.SIGHUP}
@@ -0,0 +1,186 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "log/slog"
+    "slices"
+    "strings"
+    "sync"
+    "time"
+)
+
+// defaultAskExpiry is how long a signup waits for approval by default.
+const defaultAskExpiry = 12 * time.Hour
+
+// scopePreviewAssets is how many in-scope assets a signup request shows.
+const scopePreviewAssets = 10
+
+// errNoPendingSignup is returned for decisions on signups that are not
+// (or no longer) awaiting approval.
+var errNoPendingSignup = errors.New("no signup is awaiting approval")
+
+// errSignupBlocked is returned for approvals that can't be carried out
+// right now; the signup stays pending.
+var errSignupBlocked = errors.New("signup not possible right now")
+
+// pendingSignup is a target waiting for the user to approve or deny the signup.
+type pendingSignup struct {
+    Target Target `json:"target"`
+    // Scope are the first in-scope assets; ScopeSize counts all of them
+    // (-1 if the scope could not be fetched).
+    Scope     []scopeAsset `json:"scope"`
+    ScopeSize int          `json:"scopeSize"`
+    Asked     time.Time    `json:"asked"`
+    Expires   time.Time    `json:"expires"`
+}
+
+// Preview describes the target and its scope for the approval request.
+func (p pendingSignup) Preview() string {
+    var b strings.Builder
+    fmt.Fprintf(&b, "Sign up for target %s (%s, %s)?", p.Target.Codename, p.Target.Slug, p.Target.Category.Name)
+    switch {
+    case p.ScopeSize < 0:
+        b.WriteString("\nScope: not available")
+    case p.ScopeSize == 0:
+        b.WriteString("\nScope: no assets listed")
+    default:
+        fmt.Fprintf(&b, "\nScope (%d assets):", p.ScopeSize)
+        for _, a := range p.Scope {
+            fmt.Fprintf(&b, "\n- %s (%s)", a.Location, a.Type)
+        }
+        if p.ScopeSize > len(p.Scope) {
+            fmt.Fprintf(&b, "\n- and %d more", p.ScopeSize-len(p.Scope))
+        }
+    }
+    fmt.Fprintf(&b, "\nDecide before %s (Telegram: /approve %s or /deny %s, or the dashboard).", p.Expires.Local().Format("Mon 15:04"), p.Target.Slug, p.Target.Slug)
+    return b.String()
+}
+
+// signupApprovals holds the signups waiting for a decision, by slug.
+type signupApprovals struct {
+    mu      sync.Mutex
+    pending map[string]pendingSignup
+}
+
+func newSignupApprovals() *signupApprovals {
+    return &signupApprovals{pending: map[string]pendingSignup{}}
+}
+
+// Add queues a signup for a decision, unless one is already pending.
+func (a *signupApprovals) Add(p pendingSignup) bool {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    if _, ok := a.pending[p.Target.Slug]; ok {
+        return false
+    }
+    a.pending[p.Target.Slug] = p
+    return true
+}
+
+// Take removes the pending signup of slug for a decision. Expired signups
+// can no longer be decided.
+func (a *signupApprovals) Take(slug string) (pendingSignup, bool) {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    p, ok := a.pending[slug]
+    if !ok || time.Now().After(p.Expires) {
+        return pendingSignup{}, false
+    }
+    delete(a.pending, slug)
+    return p, true
+}
+
+// Expire removes and returns the signups whose decision expired.
+func (a *signupApprovals) Expire(now time.Time) []pendingSignup {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    var expired []pendingSignup
+    for slug, p := range a.pending {
+        if now.After(p.Expires) {
+            expired = append(expired, p)
+            delete(a.pending, slug)
+        }
+    }
+    return expired
+}
+
+// List returns the pending signups, oldest first.
+func (a *signupApprovals) List() []pendingSignup {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    list := make([]pendingSignup, 0, len(a.pending))
+    for _, p := range a.pending {
+        list = append(list, p)
+    }
+    slices.SortFunc(list, func(x, y pendingSignup) int { return x.Asked.Compare(y.Asked) })
+    return list
+}
+
+// askSignup asks the user to approve the signup for t, with a preview of
+// its scope.
+func (b *bot) askSignup(token string, t Target) {
+    now := time.Now()
+    p := pendingSignup{Target: t, ScopeSize: -1, Asked: now, Expires: now.Add(b.config().Targets.askExpiry())}
+    if scope, err := b.targetCache.Scope(token, t.Slug); err != nil {
+        slog.Warn("failed to fetch scope for signup approval", "endpoint", "target_scope", "slug", t.Slug, "err", err)
+    } else {
+        p.Scope = scope[:min(len(scope), scopePreviewAssets)]
+        p.ScopeSize = len(scope)
+    }
+    if !b.approvals.Add(p) {
+        return
+    }
+    slog.Info("target signup awaiting approval", "slug", t.Slug, "expires", p.Expires)
+    notifyEvent(Event{Type: EventSignupApproval, Message: p.Preview(), Target: t.Slug})
+}
+
+// decideSignup approves (signs up for) or denies a pending signup and
+// returns a reply for the user. An approval goes through the same checks
+// as automatic signups: while signups are paused or the token is about to
+// expire it fails with errSignupBlocked and the signup stays pending, and
+// in dry run nothing is signed up for.
+func (b *bot) decideSignup(slug string, approve bool) (string, error) {
+    p, ok := b.approvals.Take(slug)
+    if !ok {
+        return "", fmt.Errorf("%s: %w", slug, errNoPendingSignup)
+    }
+    if !approve {
+        if err := b.store.AddKnownSlug(slug); err != nil {
+            slog.Error("failed to record target slug", "slug", slug, "err", err)
+        }
+        slog.Info("target signup denied", "slug", slug)
+        return fmt.Sprintf("Not signing up for target %s.", p.Target.Codename), nil
+    }
+    token := b.tokens.Get()
+    var blocked string
+    switch {
+    case b.state.SignupsPaused():
+        blocked = "signups are paused"
+    case b.tokenExpiring(token):
+        blocked = "the session token is about to expire"
+    case b.dryRun:
+        slog.Info("dry-run: would sign up for target", "slug", slug)
+        return fmt.Sprintf("Dry run: would sign up for target %s.", p.Target.Codename), nil
+    }
+    if blocked != "" {
+        b.approvals.Add(p)
+        return "", fmt.Errorf("%s: %w: %s; approve again later (before %s)", p.Target.Codename, errSignupBlocked, blocked, p.Expires.Local().Format("Mon 15:04"))
+    }
+    if err := b.signup(token, p.Target); err != nil {
+        return "", fmt.Errorf("signup for %s failed: %v", p.Target.Codename, err)
+    }
+    return "", nil // the signup notification tells the user
+}
+
+// expireSignups drops the signups that were not decided in time. They are
+// not asked again; sign up manually with "targets signup" instead.
+func (b *bot) expireSignups() {
+    for _, p := range b.approvals.Expire(time.Now()) {
+        if err := b.store.AddKnownSlug(p.Target.Slug); err != nil {
+            slog.Error("failed to record target slug", "slug", p.Target.Slug, "err", err)
+        }
+        slog.Info("target signup approval expired", "slug", p.Target.Slug)
+        notify(EventSignupApproval, "No decision on signing up for target %s (%s); not signing up.", p.Target.Codename, p.Target.Slug)
+    }
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+    "expvar"
+    "log/slog"
+    "slices"
+    "sync"
+)
+
+// sharedReads counts, per endpoint, the reads that joined a request already
+// in flight instead of sending their own.
+var sharedReads = expvar.NewMap("shared_reads")
+
+// flightCall is a read in flight; waiters block on done.
+type flightCall struct {
+    done  chan struct{}
+    value any
+    err   error
+}
+
+// flightGroup collapses concurrent calls with the same key into one: the
+// first caller runs the request, and everyone arriving while it is in
+// flight waits for and shares its result. Nothing is cached afterwards.
+type flightGroup struct {
+    mu    sync.Mutex
+    calls map[string]*flightCall
+}
+
+// reads is the flight group of all read endpoints. Keys include the token,
+// so results are never shared between sessions.
+var reads = &flightGroup{calls: map[string]*flightCall{}}
+
+// Do runs fn unless a call with the same key is in flight, and reports
+// whether the result came from another caller's request.
+func (g *flightGroup) Do(key string, fn func() (any, error)) (any, error, bool) {
+    g.mu.Lock()
+    if c, ok := g.calls[key]; ok {
+        g.mu.Unlock()
+        <-c.done
+        return c.value, c.err, true
+    }
+    c := &flightCall{done: make(chan struct{})}
+    g.calls[key] = c
+    g.mu.Unlock()
+
+    defer func() {
+        g.mu.Lock()
+        delete(g.calls, key)
+        g.mu.Unlock()
+        close(c.done)
+    }()
+    c.value, c.err = fn()
+    return c.value, c.err, false
+}
+
+// sharedRead fetches a list from a read endpoint through the flight group,
+// so overlapping polls (the interval, a hot target, a manual "poll now")
+// send one request between them. Every caller, the one that sent the
+// request included, gets its own copy of the list, as the loops filter
+// their lists in place while others may still be copying it.
+func sharedRead[S ~[]E, E any](endpoint, url, token string, fetch func() (S, error)) (S, error) {
+    v, err, shared := reads.Do(endpoint+" "+url+" "+token, func() (any, error) { return fetch() })
+    if shared {
+        sharedReads.Add(endpoint, 1)
+        slog.Debug("joined in-flight request", "endpoint", endpoint)
+    }
+    list, _ := v.(S)
+    return slices.Clone(list), err
+}
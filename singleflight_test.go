@@ -0,0 +1,34 @@
+package main
+
+import (
+    "sync"
+    "testing"
+    "time"
+)
+
+func TestSharedReadGivesEveryCallerItsOwnList(t *testing.T) {
+    calls := 0
+    fetch := func() ([]int, error) {
+        calls++
+        time.Sleep(50 * time.Millisecond) // long enough for the others to join
+        return []int{1, 2, 3}, nil
+    }
+    lists := make([][]int, 3)
+    var wg sync.WaitGroup
+    for i := range lists {
+        wg.Go(func() {
+            lists[i], _ = sharedRead("test", "url", "token", fetch)
+        })
+    }
+    wg.Wait()
+
+    if calls != 1 {
+        t.Errorf("%d requests, want 1 shared by all callers", calls)
+    }
+    lists[0][0] = 99
+    for i, list := range lists[1:] {
+        if list[0] != 1 {
+            t.Errorf("caller %d sees another caller's change: %v", i+1, list)
+        }
+    }
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "net/url"
+    "slices"
+    "strconv"
+    "strings"
+    "text/template"
+    "time"
+)
+
+// notifyTimeout bounds a single delivery to a notification service.
+const notifyTimeout = 15 * time.Second
+
+// NotificationConfig is a notification channel from the "notifications"
+// list of the config file.
+type NotificationConfig struct {
+    // Name identifies the channel in logs and "notify test" (default: Type).
+    Name string `json:"name"`
+    // Type is "slack", "discord", "webhook", "ntfy", "gotify", "email",
+    // "mqtt" or "nats".
+    Type string `json:"type"`
+    // URL is the incoming webhook URL (Slack, Discord), the endpoint events
+    // are POSTed to as JSON (webhook), the topic URL (ntfy) or the server
+    // URL (Gotify) or the broker URL (MQTT, NATS).
+    URL string `json:"url"`
+    // Token is the access token (ntfy, NATS, optional) or application
+    // token (Gotify, required).
+    Token string `json:"token"`
+    // Priority of ntfy (1-5) and Gotify (0-10) messages (0 = the
+    // service's default).
+    Priority int `json:"priority"`
+    // SMTP is the mail server and addresses of an email channel.
+    SMTP SMTPConfig `json:"smtp"`
+    // DigestAt (HH:MM, local time) mails a daily digest from an email channel.
+    DigestAt string `json:"digest_at"`
+    // DigestOnly sends no mail per event, only the digest.
+    DigestOnly bool `json:"digest_only"`
+    // Template, if set, is the Go template (text/template) rendering the
+    // JSON body of webhook requests from the event.
+    Template string `json:"template"`
+    // Topic prefixes the MQTT topic or NATS subject events are published
+    // to (default "synack-mission-bot"); the event type is appended.
+    Topic string `json:"topic"`
+    // Events, if set, limits the channel to these event types.
+    Events []string `json:"events"`
+}
+
+// notificationTypes build the notifier of each NotificationConfig type.
+var notificationTypes = map[string]func(NotificationConfig) Notifier{
+    "slack":   func(c NotificationConfig) Notifier { return slackNotifier{url: c.URL} },
+    "discord": func(c NotificationConfig) Notifier { return discordNotifier{url: c.URL} },
+    "webhook": newWebhookNotifier,
+    "ntfy":    func(c NotificationConfig) Notifier { return ntfyNotifier{cfg: c} },
+    "gotify":  func(c NotificationConfig) Notifier { return gotifyNotifier{cfg: c} },
+    "email":   func(c NotificationConfig) Notifier { return emailNotifier{cfg: c} },
+    "mqtt":    func(c NotificationConfig) Notifier { return mqttNotifier{cfg: c} },
+    "nats":    func(c NotificationConfig) Notifier { return natsNotifier{cfg: c} },
+}
+
+// validateNotifications checks the configured channels.
+func validateNotifications(cfg Config) error {
+    names := map[string]bool{}
+    if cfg.Telegram.BotToken != "" {
+        names["telegram"] = true
+    }
+    if err := validateEventTypes("telegram.events", cfg.Telegram.Events); err != nil {
+        return err
+    }
+    for i, n := range cfg.Notifications {
+        if _, ok := notificationTypes[n.Type]; !ok {
+            return fmt.Errorf("notifications[%d]: unknown type %q (want slack, discord, webhook, ntfy, gotify, email, mqtt or nats)", i, n.Type)
+        }
+        switch {
+        case n.Type == "gotify" && n.Token == "":
+            return fmt.Errorf("notifications[%d]: gotify needs an application token", i)
+        case n.Type == "ntfy" && (n.Priority < 0 || n.Priority > 5):
+            return fmt.Errorf("notifications[%d]: ntfy priority must be 1-5", i)
+        case n.Type == "gotify" && (n.Priority < 0 || n.Priority > 10):
+            return fmt.Errorf("notifications[%d]: gotify priority must be 0-10", i)
+        case n.Template != "" && n.Type != "webhook":
+            return fmt.Errorf("notifications[%d]: template is only supported by webhook channels", i)
+        case n.Topic != "" && brokerSchemes[n.Type] == nil:
+            return fmt.Errorf("notifications[%d]: topic is only supported by mqtt and nats channels", i)
+        }
+        if _, err := parsePayloadTemplate(n.Template); err != nil {
+            return fmt.Errorf("notifications[%d]: template: %v", i, err)
+        }
+        if n.Type == "email" {
+            if err := validateEmail(i, n); err != nil {
+                return err
+            }
+        } else if brokerSchemes[n.Type] != nil {
+            if err := validateBroker(i, n); err != nil {
+                return err
+            }
+        } else if u, err := url.Parse(n.URL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+            return fmt.Errorf("notifications[%d]: url must be an http(s) URL", i)
+        }
+        name := n.channelName()
+        if names[name] {
+            return fmt.Errorf("notifications[%d]: duplicate channel name %q", i, name)
+        }
+        names[name] = true
+        if err := validateEventTypes(fmt.Sprintf("notifications[%d].events", i), n.Events); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// validateEventTypes checks an event filter.
+func validateEventTypes(field string, events []string) error {
+    for _, e := range events {
+        if !slices.Contains(eventTypes, e) {
+            return fmt.Errorf("%s: unknown event %q", field, e)
+        }
+    }
+    return nil
+}
+
+// endpoint returns the URL the channel delivers to.
+func (n NotificationConfig) endpoint() string {
+    if n.Type == "email" {
+        return "smtp://" + n.SMTP.smtpAddr()
+    }
+    return n.URL
+}
+
+// channelName returns the configured name, or the type.
+func (n NotificationConfig) channelName() string {
+    if n.Name != "" {
+        return n.Name
+    }
+    return n.Type
+}
+
+// filteredNotifier passes only some event types on to next. Events of
+// targets routed to other channels by their override are left out too.
+type filteredNotifier struct {
+    next   Notifier
+    events []string
+    // channel is the name of a configured channel, empty for the others.
+    channel string
+}
+
+func (f filteredNotifier) Notify(ev Event) error {
+    if !slices.Contains(f.events, ev.Type) || (f.channel != "" && !routesTo(ev, f.channel)) {
+        return nil
+    }
+    return f.next.Notify(ev)
+}
+
+// Flush flushes next if it batches.
+func (f filteredNotifier) Flush() {
+    if fl, ok := f.next.(flusher); ok {
+        fl.Flush()
+    }
+}
+
+// slackNotifier posts events to a Slack incoming webhook.
+type slackNotifier struct {
+    url string
+}
+
+func (n slackNotifier) Notify(ev Event) error {
+    return postNotification("slack", n.url, map[string]string{"text": ev.Text()})
+}
+
+// discordNotifier posts events to a Discord webhook.
+type discordNotifier struct {
+    url string
+}
+
+// discordMaxContent is the longest message Discord accepts.
+const discordMaxContent = 2000
+
+func (n discordNotifier) Notify(ev Event) error {
+    msg := ev.Text()
+    if len(msg) > discordMaxContent {
+        msg = msg[:discordMaxContent-1] + "…"
+    }
+    return postNotification("discord", n.url, map[string]string{"content": msg})
+}
+
+// webhookNotifier POSTs every event as JSON: {"type", "message", "time"}
+// and "links" if it has any, or the body rendered by the channel's template.
+type webhookNotifier struct {
+    url  string
+    tmpl *template.Template
+}
+
+// newWebhookNotifier builds the notifier of a webhook channel.
+func newWebhookNotifier(c NotificationConfig) Notifier {
+    tmpl, _ := parsePayloadTemplate(c.Template) // validated by loadConfig
+    return webhookNotifier{url: c.URL, tmpl: tmpl}
+}
+
+func (n webhookNotifier) Notify(ev Event) error {
+    if n.tmpl == nil {
+        payload := map[string]any{"type": ev.Type, "message": ev.Message, "time": ev.Time}
+        if links := ev.linkMap(); links != nil {
+            payload["links"] = links
+        }
+        return postNotification("webhook", n.url, payload)
+    }
+    var body bytes.Buffer
+    if err := n.tmpl.Execute(&body, ev); err != nil {
+        return fmt.Errorf("webhook: template: %v", err)
+    }
+    if !json.Valid(body.Bytes()) {
+        return fmt.Errorf("webhook: template did not render valid JSON: %.200s", body.String())
+    }
+    req, err := http.NewRequest(http.MethodPost, n.url, &body)
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Content-Type", "application/json")
+    return sendNotification("webhook", req)
+}
+
+// payloadFuncs are the functions available to payload templates besides
+// the text/template builtins.
+var payloadFuncs = template.FuncMap{
+    // json encodes a value as JSON, e.g. "message": {{json .Message}}.
+    "json": func(v any) (string, error) {
+        b, err := json.Marshal(v)
+        return string(b), err
+    },
+}
+
+// parsePayloadTemplate parses a webhook payload template; nil without one.
+func parsePayloadTemplate(s string) (*template.Template, error) {
+    if s == "" {
+        return nil, nil
+    }
+    return template.New("payload").Funcs(payloadFuncs).Option("missingkey=error").Parse(s)
+}
+
+// notificationTitle is the title of push notifications.
+const notificationTitle = "synack-mission-bot"
+
+// ntfyNotifier publishes events to an ntfy topic.
+type ntfyNotifier struct {
+    cfg NotificationConfig
+}
+
+func (n ntfyNotifier) Notify(ev Event) error {
+    req, err := http.NewRequest(http.MethodPost, n.cfg.URL, strings.NewReader(ev.Text()))
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Title", notificationTitle)
+    req.Header.Set("Tags", ev.Type)
+    if len(ev.Links) > 0 {
+        // Tapping the notification opens the mission, or the target.
+        req.Header.Set("Click", ev.Links[0].URL)
+    }
+    if n.cfg.Priority > 0 {
+        req.Header.Set("Priority", strconv.Itoa(n.cfg.Priority))
+    }
+    if n.cfg.Token != "" {
+        req.Header.Set("Authorization", "Bearer "+n.cfg.Token)
+    }
+    return sendNotification("ntfy", req)
+}
+
+// gotifyNotifier sends events to a Gotify server.
+type gotifyNotifier struct {
+    cfg NotificationConfig
+}
+
+func (n gotifyNotifier) Notify(ev Event) error {
+    msg := map[string]any{"title": notificationTitle, "message": ev.Text(), "priority": n.cfg.Priority}
+    if len(ev.Links) > 0 {
+        msg["extras"] = map[string]any{"client::notification": map[string]any{"click": map[string]string{"url": ev.Links[0].URL}}}
+    }
+    body, err := json.Marshal(msg)
+    if err != nil {
+        return err
+    }
+    req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(n.cfg.URL, "/")+"/message", bytes.NewReader(body))
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Content-Type", "application/json")
+    req.Header.Set("X-Gotify-Key", n.cfg.Token)
+    return sendNotification("gotify", req)
+}
+
+// postNotification POSTs payload as JSON to a notification service.
+func postNotification(service, u string, payload any) error {
+    body, err := json.Marshal(payload)
+    if err != nil {
+        return err
+    }
+    req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(body))
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Content-Type", "application/json")
+    return sendNotification(service, req)
+}
+
+// sendNotification sends a request to a notification service and expects
+// a 2xx response.
+func sendNotification(service string, req *http.Request) error {
+    client := &http.Client{Timeout: notifyTimeout, Transport: notifyTransport}
+    resp, err := client.Do(req)
+    if err != nil {
+        // Webhook URLs are credentials; keep them out of error messages.
+        if uerr, ok := err.(*url.Error); ok {
+            err = uerr.Err
+        }
+        return fmt.Errorf("%s: %v", service, err)
+    }
+    defer closeBody(resp)
+    if resp.StatusCode/100 != 2 {
+        return fmt.Errorf("%s: status code %d", service, resp.StatusCode)
+    }
+    return nil
+}
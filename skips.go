@@ -0,0 +1,61 @@
+package main
+
+import (
+    "expvar"
+    "fmt"
+    "log/slog"
+    "sort"
+    "strings"
+    "time"
+)
+
+// SkipReason is why a claimable mission was not claimed.
+type SkipReason string
+
+const (
+    SkipAlreadyClaimed SkipReason = "already_claimed"
+    SkipCampaignCap    SkipReason = "campaign_cap"
+    SkipTargetCap      SkipReason = "target_cap"
+    SkipClaimLimit     SkipReason = "claim_limit"
+    SkipPaused         SkipReason = "paused"
+    SkipRoleGate       SkipReason = "role_gate"
+    SkipWorkload       SkipReason = "workload"
+    SkipDryRun         SkipReason = "dry_run"
+    SkipRule           SkipReason = "rule"
+    SkipQuietHours     SkipReason = "quiet_hours"
+    SkipWebhook        SkipReason = "webhook"
+    SkipRepublished    SkipReason = "republished"
+    SkipAvailability   SkipReason = "availability"
+    SkipForbidden      SkipReason = "forbidden"
+)
+
+// skippedTasks counts skipped missions per reason for the lifetime of the
+// process; each mission is counted once per reason and summary window.
+var skippedTasks = expvar.NewMap("skipped_tasks")
+
+// skipCounts is the number of distinct missions skipped per reason.
+type skipCounts map[SkipReason]int
+
+// String formats the counts as "reason: n, ..." sorted by reason.
+func (c skipCounts) String() string {
+    reasons := make([]string, 0, len(c))
+    for r, n := range c {
+        reasons = append(reasons, fmt.Sprintf("%s: %d", r, n))
+    }
+    sort.Strings(reasons)
+    return strings.Join(reasons, ", ")
+}
+
+// summarizeSkips logs, and notifies about, the missions skipped in every
+// interval, so filtering never silently hides missions the user wanted.
+func (b *bot) summarizeSkips(interval time.Duration) {
+    for {
+        time.Sleep(interval)
+        counts := b.state.TakeSkips()
+        if len(counts) == 0 {
+            continue
+        }
+        slog.Info("skipped missions", "window", interval, "counts", counts.String())
+        notify(EventSkipSummary, "Missions not claimed in the last %s: %s", interval, counts)
+    }
+}
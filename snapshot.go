@@ -0,0 +1,170 @@
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "flag"
+    "fmt"
+    "log/slog"
+    "os"
+    "time"
+)
+
+// snapshotVersion is the format version of state snapshots.
+const snapshotVersion = 1
+
+// encryptedSnapshotMagic prefixes snapshots written with a passphrase.
+var encryptedSnapshotMagic = []byte("MBSTATE-AESGCM1\n")
+
+// portableState is a snapshot of everything needed to move the bot to another
+// machine: the database (claims, timings, known targets, announcements,
+// reminders) and the config file.
+type portableState struct {
+    Version    int             `json:"version"`
+    ExportedAt time.Time       `json:"exportedAt"`
+    Database   storeData       `json:"database"`
+    Config     json.RawMessage `json:"config,omitempty"`
+}
+
+// runState implements the "state export" and "state import" subcommands.
+func runState(args []string) error {
+    usage := fmt.Errorf("usage: %s state export -db <file> [-config <file>] -out <snapshot>\n       %s state import -in <snapshot> -db <file> [-config-out <file>] [-force]", os.Args[0], os.Args[0])
+    if len(args) == 0 {
+        return usage
+    }
+    switch args[0] {
+    case "export":
+        return runStateExport(args[1:])
+    case "import":
+        return runStateImport(args[1:])
+    default:
+        return usage
+    }
+}
+
+// runStateExport writes a snapshot of the database and config file. With a
+// database passphrase the snapshot is encrypted with it. Including the
+// config, which holds credentials (the Telegram bot token, webhook URLs,
+// login details), requires a passphrase.
+func runStateExport(args []string) error {
+    fs := flag.NewFlagSet("state export", flag.ExitOnError)
+    dbFlag := fs.String("db", "", "Database file to export")
+    passphraseFlag := fs.String("db-passphrase-file", "", "File containing the database passphrase; also encrypts the snapshot")
+    configFlag := fs.String("config", "", "Config file to include (requires a passphrase, as it holds credentials)")
+    outFlag := fs.String("out", "", "Snapshot file to write")
+    fs.Parse(args)
+    if *dbFlag == "" || *outFlag == "" {
+        fs.Usage()
+        return fmt.Errorf("-db and -out are required")
+    }
+
+    passphrase, err := readPassphrase(*passphraseFlag)
+    if err != nil {
+        return err
+    }
+    if *configFlag != "" && len(passphrase) == 0 {
+        return fmt.Errorf("-config requires a passphrase (-db-passphrase-file or MISSION_BOT_DB_PASSPHRASE): the config holds credentials and is only exported encrypted")
+    }
+    db, err := openStore(*dbFlag, passphrase)
+    if err != nil {
+        return err
+    }
+    snap := portableState{Version: snapshotVersion, ExportedAt: time.Now().UTC(), Database: db.Data()}
+    if *configFlag != "" {
+        cfg, err := os.ReadFile(*configFlag)
+        if err != nil {
+            return err
+        }
+        if !json.Valid(cfg) {
+            return fmt.Errorf("config %s is not valid JSON", *configFlag)
+        }
+        snap.Config = cfg
+    }
+
+    raw, err := json.Marshal(snap)
+    if err != nil {
+        return err
+    }
+    if len(passphrase) > 0 {
+        if raw, err = encryptStore(raw, passphrase); err != nil {
+            return err
+        }
+        raw = append(append([]byte(nil), encryptedSnapshotMagic...), raw...)
+    } else {
+        slog.Warn("snapshot is not encrypted; it contains confidential mission data")
+    }
+    if err := os.WriteFile(*outFlag, raw, 0600); err != nil {
+        return err
+    }
+    slog.Info("state exported", "out", *outFlag, "claims", len(snap.Database.Claims), "config", snap.Config != nil)
+    return nil
+}
+
+// runStateImport restores a snapshot into a database (encrypted with the
+// passphrase, if any) and, optionally, a config file.
+func runStateImport(args []string) error {
+    fs := flag.NewFlagSet("state import", flag.ExitOnError)
+    inFlag := fs.String("in", "", "Snapshot file to import")
+    dbFlag := fs.String("db", "", "Database file to write")
+    passphraseFlag := fs.String("db-passphrase-file", "", "File containing the passphrase of the snapshot and the new database")
+    configOutFlag := fs.String("config-out", "", "Write the snapshot's config to this file")
+    forceFlag := fs.Bool("force", false, "Overwrite existing files")
+    fs.Parse(args)
+    if *inFlag == "" || *dbFlag == "" {
+        fs.Usage()
+        return fmt.Errorf("-in and -db are required")
+    }
+
+    passphrase, err := readPassphrase(*passphraseFlag)
+    if err != nil {
+        return err
+    }
+    raw, err := os.ReadFile(*inFlag)
+    if err != nil {
+        return err
+    }
+    if bytes.HasPrefix(raw, encryptedSnapshotMagic) {
+        if len(passphrase) == 0 {
+            return fmt.Errorf("snapshot %s is encrypted; a passphrase is required", *inFlag)
+        }
+        if raw, err = decryptStore(raw[len(encryptedSnapshotMagic):], passphrase); err != nil {
+            return fmt.Errorf("opening snapshot %s: %v", *inFlag, err)
+        }
+    }
+    var snap portableState
+    if err := json.Unmarshal(raw, &snap); err != nil {
+        return fmt.Errorf("parsing snapshot %s: %v", *inFlag, err)
+    }
+    if snap.Version != snapshotVersion {
+        return fmt.Errorf("snapshot %s has unsupported version %d", *inFlag, snap.Version)
+    }
+
+    for _, path := range []string{*dbFlag, *configOutFlag} {
+        if path == "" || *forceFlag {
+            continue
+        }
+        if _, err := os.Stat(path); err == nil {
+            return fmt.Errorf("%s already exists; use -force to overwrite it", path)
+        } else if !errors.Is(err, os.ErrNotExist) {
+            return err
+        }
+    }
+
+    db := &store{path: *dbFlag}
+    if len(passphrase) > 0 {
+        if db.key, err = newStoreKey(passphrase); err != nil {
+            return err
+        }
+    }
+    if err := db.Replace(snap.Database); err != nil {
+        return err
+    }
+    if *configOutFlag != "" && snap.Config != nil {
+        if err := os.WriteFile(*configOutFlag, snap.Config, 0600); err != nil {
+            return err
+        }
+    }
+    slog.Info("state imported", "db", *dbFlag, "claims", len(snap.Database.Claims), "exported_at", snap.ExportedAt)
+    return nil
+}
@@ -0,0 +1,11 @@
+//go:build darwin
+
+package main
+
+// soundCommand plays a sound with afplay.
+func soundCommand(sound string) ([]string, []string) {
+    if sound == defaultSound {
+        sound = "/System/Library/Sounds/Glass.aiff"
+    }
+    return []string{"afplay", sound}, nil
+}
@@ -0,0 +1,8 @@
+//go:build !unix && !windows
+
+package main
+
+// soundCommand is unsupported on this platform.
+func soundCommand(sound string) ([]string, []string) {
+    return nil, nil
+}
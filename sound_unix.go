@@ -0,0 +1,15 @@
+//go:build unix && !darwin
+
+package main
+
+// freedesktopSound is the freedesktop sound theme's "complete" sound,
+// installed with most desktops.
+const freedesktopSound = "/usr/share/sounds/freedesktop/stereo/complete.oga"
+
+// soundCommand plays a sound through PulseAudio or PipeWire with paplay.
+func soundCommand(sound string) ([]string, []string) {
+    if sound == defaultSound {
+        sound = freedesktopSound
+    }
+    return []string{"paplay", sound}, nil
+}
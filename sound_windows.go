@@ -0,0 +1,16 @@
+//go:build windows
+
+package main
+
+// playSound plays the WAV file named by the environment, so the path needs
+// no quoting.
+const playSound = `(New-Object Media.SoundPlayer $env:MISSION_BOT_SOUND).PlaySync()`
+
+// soundCommand plays a WAV file (by default the system's notification
+// sound) from PowerShell and waits until it has finished.
+func soundCommand(sound string) ([]string, []string) {
+    if sound == defaultSound {
+        sound = `C:\Windows\Media\Windows Notify System Generic.wav`
+    }
+    return []string{"powershell", "-NoProfile", "-NonInteractive", "-Command", playSound}, []string{"MISSION_BOT_SOUND=" + sound}
+}
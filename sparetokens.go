@@ -0,0 +1,124 @@
+package main
+
+import (
+    "bufio"
+    "bytes"
+    "log/slog"
+    "os"
+    "strings"
+    "sync"
+    "time"
+)
+
+// spareTokens are further session tokens of the same account, read from a
+// file with one token per line. When the current token expires or is
+// rejected, the bot switches to the next usable spare before asking for a
+// new token.
+type spareTokens struct {
+    path string
+
+    mu     sync.Mutex
+    tokens []string
+    // used are the tokens that were rejected or ran out; they are never
+    // picked again.
+    used map[string]bool
+}
+
+// loadSpareTokens reads the spare tokens file.
+func loadSpareTokens(path string) (*spareTokens, error) {
+    s := &spareTokens{path: path, used: map[string]bool{}}
+    tokens, err := readSpareTokens(path)
+    if err != nil {
+        return nil, err
+    }
+    s.tokens = tokens
+    return s, nil
+}
+
+// readSpareTokens returns the tokens in path, skipping blank lines and
+// lines starting with #.
+func readSpareTokens(path string) ([]string, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    var tokens []string
+    sc := bufio.NewScanner(bytes.NewReader(data))
+    for sc.Scan() {
+        line := strings.TrimSpace(sc.Text())
+        if line != "" && !strings.HasPrefix(line, "#") {
+            tokens = append(tokens, line)
+        }
+    }
+    return tokens, sc.Err()
+}
+
+// Next retires old and returns the first spare that hasn't been used and
+// has more than grace left before it expires. The file is read again first,
+// so tokens added while the bot runs are picked up. A nil spareTokens has
+// no tokens.
+func (s *spareTokens) Next(old string, grace time.Duration) (string, bool) {
+    if s == nil {
+        return "", false
+    }
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.used[old] = true
+    if tokens, err := readSpareTokens(s.path); err != nil {
+        slog.Warn("failed to reread spare tokens", "path", s.path, "err", err)
+    } else {
+        s.tokens = tokens
+    }
+    for _, t := range s.tokens {
+        if s.used[t] {
+            continue
+        }
+        if exp, ok := tokenExpiry(t); ok && time.Until(exp) <= grace {
+            s.used[t] = true
+            continue
+        }
+        return t, true
+    }
+    return "", false
+}
+
+// Left returns how many spares could still be switched to.
+func (s *spareTokens) Left() int {
+    if s == nil {
+        return 0
+    }
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    n := 0
+    for _, t := range s.tokens {
+        if exp, ok := tokenExpiry(t); !s.used[t] && (!ok || time.Now().Before(exp)) {
+            n++
+        }
+    }
+    return n
+}
+
+// rotateToken switches from old to the next spare token, if there is one,
+// or else to a token from an automatic login (-auto-login), and shares it
+// with all loops. If another loop already replaced old, that token is kept.
+func (b *bot) rotateToken(old, reason string) (string, bool) {
+    b.rotateMu.Lock()
+    defer b.rotateMu.Unlock()
+    if current := b.tokens.Get(); current != old {
+        return current, true
+    }
+    token, ok := b.spareTokens.Next(old, b.tokenGrace)
+    if !ok {
+        if token, ok = b.autoLogin(); ok {
+            b.tokens.Set(token)
+            slog.Info("logged in for a new token", "reason", reason)
+            notify(EventTokenRotated, "Session token %s; logged in for a new one.", reason)
+        }
+        return token, ok
+    }
+    b.tokens.Set(token)
+    left := b.spareTokens.Left() - 1 // not counting the new current token
+    slog.Info("switched to a spare token", "reason", reason, "spares_left", left)
+    notify(EventTokenRotated, "Session token %s; switched to a spare token (%d more left).", reason, left)
+    return token, true
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "io/fs"
+    "log/slog"
+    "net"
+    "net/url"
+    "slices"
+    "time"
+)
+
+// startupStages are the stages of the bot's startup, in order. Each must
+// succeed before the next one begins.
+var startupStages = []string{"config", "storage", "auth", "notifiers", "pollers"}
+
+// Failed startup stages are retried this many times in total, waiting
+// startupBaseDelay before the second attempt and doubling the wait after that.
+const (
+    startupAttempts  = 5
+    startupBaseDelay = 2 * time.Second
+)
+
+// permanentError marks a startup failure that retrying cannot fix, such as
+// an invalid setting.
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// permanent marks err as not worth retrying.
+func permanent(err error) error {
+    if err == nil {
+        return nil
+    }
+    return permanentError{err}
+}
+
+// unlessIO marks err as permanent unless it is a file system error other
+// than a missing file, which may be transient (a file locked or being
+// rewritten, a network mount not yet up).
+func unlessIO(err error) error {
+    var pathErr *fs.PathError
+    if err == nil || errors.As(err, &pathErr) && !errors.Is(err, fs.ErrNotExist) {
+        return err
+    }
+    return permanent(err)
+}
+
+// runStage runs one startup stage, logging its progress, and retries it
+// with backoff unless it fails permanently.
+func runStage(name string, fn func() error) error {
+    step := fmt.Sprintf("%d/%d", slices.Index(startupStages, name)+1, len(startupStages))
+    slog.Info("startup stage", "step", step, "stage", name)
+    start := time.Now()
+    for attempt := 1; ; attempt++ {
+        err := fn()
+        if err == nil {
+            slog.Info("startup stage done", "step", step, "stage", name, "took", time.Since(start).Round(time.Millisecond))
+            return nil
+        }
+        var perm permanentError
+        if errors.As(err, &perm) || attempt >= startupAttempts {
+            return err
+        }
+        delay := startupBaseDelay << (attempt - 1)
+        slog.Warn("startup stage failed, retrying", "stage", name, "attempt", attempt, "wait", delay, "err", err)
+        time.Sleep(delay)
+    }
+}
+
+// checkNotificationChannels resolves the host of every notification channel
+// that has one, so DNS problems show up at startup rather than with the
+// first claim.
+func checkNotificationChannels(channels []notificationChannel) error {
+    var errs []error
+    for _, c := range channels {
+        if c.Endpoint == "" {
+            continue
+        }
+        u, err := url.Parse(c.Endpoint)
+        if err != nil {
+            errs = append(errs, permanent(fmt.Errorf("%s: %v", c.Name, err)))
+            continue
+        }
+        if _, err := net.LookupHost(u.Hostname()); err != nil {
+            errs = append(errs, fmt.Errorf("%s: %v", c.Name, err))
+        }
+    }
+    return errors.Join(errs...)
+}
@@ -0,0 +1,453 @@
+package main
+
+import (
+    "fmt"
+    "slices"
+    "strings"
+    "sync"
+    "time"
+)
+
+// Limits on how much history the in-memory state keeps for display.
+const (
+    maxRecentClaims = 20
+    maxLogTail      = 200
+    maxHookRuns     = 20
+    maxRecentErrors = 20
+)
+
+// bot holds everything the polling loops share.
+type bot struct {
+    tokens        TokenProvider
+    tokenSocket   string
+    tokenFile     string
+    tokenListener string
+    // spareTokens are switched to before asking for a new token (nil = none).
+    spareTokens *spareTokens
+    // autoLoginEnabled logs in with the keychain's credentials for a new
+    // token once the spares are used up; loginFailedAt throttles retries.
+    autoLoginEnabled bool
+    loginFailedAt    time.Time
+    rotateMu         sync.Mutex
+    // promptMu lets only one loop at a time ask for a new token; the others
+    // wait and take the token it got.
+    promptMu sync.Mutex
+    // cfg and quietHours are replaced on reload; read them with config()
+    // and quiet().
+    cfgMu sync.Mutex
+    cfg   Config
+    // quietHours stop claiming during their windows (nil = none).
+    quietHours *quietHours
+    knownSlugs *sync.Map
+    state      *botState
+    store      *store
+    hooks      *hookSupervisor
+    dryRun     bool
+
+    // dailyCaps pause claiming for the rest of the day once reached.
+    dailyCaps dailyCaps
+    // maxPerCampaign caps claims per campaign UID (0 = unlimited).
+    maxPerCampaign int
+    // claimLimit is the researcher's concurrent claim limit (0 = not enforced).
+    claimLimit int
+    // verifyClaims re-checks every successful claim against the claimed list.
+    verifyClaims bool
+    // markViewed marks every claimed mission as viewed right after the claim.
+    markViewed bool
+    // maxTaskPages caps the task pages read per poll (0 = no limit).
+    maxTaskPages int
+    // missionDir receives the details of every claimed mission (empty = off).
+    missionDir string
+    // claimPipeline is how many claim requests may be in flight at once.
+    claimPipeline int
+    // deadline ends a bounded run (zero = run until stopped).
+    deadline time.Time
+    // once runs a single poll cycle and target check ("once").
+    once bool
+
+    // Loop timing; every interval is randomized by up to jitter (a fraction).
+    taskInterval   time.Duration
+    claimDelay     time.Duration
+    targetInterval time.Duration
+    jitter         float64
+
+    // forbiddenCooldown pauses claiming after a 403 streak (0 = stop the bot instead).
+    forbiddenCooldown time.Duration
+    // tokenGrace stops claims and signups this long before the token
+    // expires, leaving only read-only polling (0 = never).
+    tokenGrace time.Duration
+    // skipRepublished are the outcomes of earlier claims whose
+    // re-published missions are not claimed again.
+    skipRepublished []string
+
+    // approvals are the target signups waiting for the user's decision.
+    approvals *signupApprovals
+
+    // hotTasks carries missions found by hot target pollers to mainLoop.
+    hotTasks chan []Task
+    // pollTasks and pollTargets wake the polling loops for an immediate poll.
+    pollTasks   chan struct{}
+    pollTargets chan struct{}
+
+    // claimRules decide which missions are claimed; reloaded with the config.
+    claimRules *ruleSet
+
+    // targetCache holds target details and scopes for enrichment.
+    targetCache *targetCache
+
+    // Per-category acceptance rates used by the claim strategy, owned by mainLoop.
+    categoryRates   map[string]float64
+    categoryRatesAt time.Time
+    // availabilityNotified are the missions already reported as outside
+    // the availability, owned by mainLoop.
+    availabilityNotified map[string]bool
+}
+
+// claimedTask is a successfully claimed mission and when it was claimed.
+type claimedTask struct {
+    Task Task
+    At   time.Time
+}
+
+// botState is the live view of what the bot is doing, shared between the
+// polling loops and whatever displays it. All methods are safe for concurrent use.
+type botState struct {
+    mu       sync.Mutex
+    pause    *pauseInfo
+    pending  []Task
+    lastPoll time.Time
+    // lastTargetPoll is when unregistered targets were last listed.
+    lastTargetPoll time.Time
+    claimed        []claimedTask
+    targets        []Target
+    tokenExpiry    time.Time
+    logTail        []string
+    hookRuns       []hookRun
+    latency        latencyReport
+    session        sessionStats
+    // recentErrors are the latest warnings and errors logged.
+    recentErrors []string
+    // signupsPaused stops target signups; targets are still listed.
+    signupsPaused bool
+    workload      workloadForecast
+    // skipped holds the IDs of missions skipped per reason in the current
+    // summary window.
+    skipped map[SkipReason]map[string]bool
+}
+
+// Paused returns the active pause, or nil while claiming.
+func (s *botState) Paused() *pauseInfo {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if s.pause == nil {
+        return nil
+    }
+    p := *s.pause
+    return &p
+}
+
+// Pause stops claiming for the given reason until resume happens. An existing
+// pause is replaced. The user is notified.
+func (s *botState) Pause(reason PauseReason, resume ResumeCondition, until time.Time, detail string) {
+    p := &pauseInfo{Reason: reason, Resume: resume, Since: time.Now(), Until: until, Detail: detail}
+    s.mu.Lock()
+    s.pause = p
+    s.mu.Unlock()
+    notify(EventPaused, "Claiming %s.", p)
+}
+
+// Resume ends the active pause, if any, and notifies the user.
+func (s *botState) Resume(why string) {
+    s.mu.Lock()
+    p := s.pause
+    s.pause = nil
+    s.mu.Unlock()
+    if p != nil {
+        notify(EventResumed, "Claiming resumed (%s, paused for %s).", why, time.Since(p.Since).Round(time.Second))
+    }
+}
+
+// ResumeIfDue ends a timer pause whose time has come.
+func (s *botState) ResumeIfDue() {
+    if p := s.Paused(); p != nil && p.Resume == ResumeTimer && !time.Now().Before(p.Until) {
+        s.Resume("pause timer elapsed")
+    }
+}
+
+// ResumeIfHealthy ends a pause waiting for the platform to respond again.
+func (s *botState) ResumeIfHealthy() {
+    if p := s.Paused(); p != nil && p.Resume == ResumeEndpointHealthy {
+        s.Resume("platform is responding again")
+    }
+}
+
+// ResumeIfTokenRefreshed ends a pause waiting for a fresh session token.
+func (s *botState) ResumeIfTokenRefreshed() {
+    if p := s.Paused(); p != nil && p.Resume == ResumeTokenRefreshed {
+        s.Resume("fresh session token")
+    }
+}
+
+// SetSignupsPaused pauses or resumes target signups and notifies the user
+// of a change.
+func (s *botState) SetSignupsPaused(paused bool) {
+    s.mu.Lock()
+    changed := s.signupsPaused != paused
+    s.signupsPaused = paused
+    s.mu.Unlock()
+    if changed && paused {
+        notify(EventPaused, "Target signups paused.")
+    } else if changed {
+        notify(EventResumed, "Target signups resumed.")
+    }
+}
+
+// SignupsPaused reports whether target signups are paused.
+func (s *botState) SignupsPaused() bool {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return s.signupsPaused
+}
+
+// AddPending adds missions found between polls (by a hot target poller) to
+// those of the latest poll.
+func (s *botState) AddPending(tasks []Task) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    for _, t := range tasks {
+        if !slices.ContainsFunc(s.pending, func(p Task) bool { return p.ID == t.ID }) {
+            s.pending = append(s.pending, t)
+        }
+    }
+}
+
+// SetPending records the missions returned by the latest poll.
+func (s *botState) SetPending(tasks []Task) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.pending = tasks
+    s.lastPoll = time.Now()
+}
+
+// AddError records a logged warning or error, keeping only the most recent ones.
+func (s *botState) AddError(at time.Time, line string) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.recentErrors = append(s.recentErrors, at.Format("15:04:05")+" "+line)
+    if len(s.recentErrors) > maxRecentErrors {
+        s.recentErrors = s.recentErrors[len(s.recentErrors)-maxRecentErrors:]
+    }
+}
+
+// AddClaimed records a successful claim, keeping only the most recent ones.
+func (s *botState) AddClaimed(task Task) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.claimed = append(s.claimed, claimedTask{Task: task, At: time.Now()})
+    if len(s.claimed) > maxRecentClaims {
+        s.claimed = s.claimed[len(s.claimed)-maxRecentClaims:]
+    }
+    s.session.Claims++
+    if s.session.Payouts == nil {
+        s.session.Payouts = map[string]float64{}
+    }
+    s.session.Payouts[task.Payout.Currency] += task.Payout.Amount
+}
+
+// RecordSkips counts tasks as skipped for reason. A mission seen again in
+// later polls of the same window is only counted once.
+func (s *botState) RecordSkips(reason SkipReason, tasks []Task) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if s.skipped == nil {
+        s.skipped = map[SkipReason]map[string]bool{}
+    }
+    if s.skipped[reason] == nil {
+        s.skipped[reason] = map[string]bool{}
+    }
+    for _, t := range tasks {
+        if !s.skipped[reason][t.ID] {
+            s.skipped[reason][t.ID] = true
+            skippedTasks.Add(string(reason), 1)
+        }
+    }
+}
+
+// skipCountsLocked returns the distinct skipped missions per reason. s.mu must be held.
+func (s *botState) skipCountsLocked() skipCounts {
+    counts := skipCounts{}
+    for reason, ids := range s.skipped {
+        counts[reason] = len(ids)
+    }
+    return counts
+}
+
+// TakeSkips returns the skip counts of the current window and starts a new one.
+func (s *botState) TakeSkips() skipCounts {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    counts := s.skipCountsLocked()
+    s.skipped = nil
+    return counts
+}
+
+// AddSignup counts a successful target signup.
+func (s *botState) AddSignup() {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.session.Signups++
+}
+
+// StartSession resets the session statistics.
+func (s *botState) StartSession(start time.Time) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.session = sessionStats{Start: start}
+}
+
+// Session returns a copy of the session statistics.
+func (s *botState) Session() sessionStats {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    stats := s.session
+    stats.Payouts = make(map[string]float64, len(s.session.Payouts))
+    for c, v := range s.session.Payouts {
+        stats.Payouts[c] = v
+    }
+    return stats
+}
+
+// SetTargets records the unregistered targets returned by the latest poll.
+func (s *botState) SetTargets(targets []Target) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.targets = targets
+    s.lastTargetPoll = time.Now()
+}
+
+// SetTokenExpiry records when the current token expires (zero if unknown).
+func (s *botState) SetTokenExpiry(t time.Time) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.tokenExpiry = t
+}
+
+// SetWorkload records the latest workload forecast.
+func (s *botState) SetWorkload(f workloadForecast) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.workload = f
+}
+
+// SetLatency records the latest latency report.
+func (s *botState) SetLatency(r latencyReport) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.latency = r
+}
+
+// UpdateHookRun records the latest status of a hook run.
+func (s *botState) UpdateHookRun(run hookRun) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    for i := range s.hookRuns {
+        if s.hookRuns[i].ID == run.ID {
+            s.hookRuns[i] = run
+            return
+        }
+    }
+    s.hookRuns = append(s.hookRuns, run)
+    if len(s.hookRuns) > maxHookRuns {
+        s.hookRuns = s.hookRuns[len(s.hookRuns)-maxHookRuns:]
+    }
+}
+
+// Write implements io.Writer so the standard logger can feed the log tail.
+func (s *botState) Write(p []byte) (int, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+        s.logTail = append(s.logTail, line)
+    }
+    if len(s.logTail) > maxLogTail {
+        s.logTail = s.logTail[len(s.logTail)-maxLogTail:]
+    }
+    return len(p), nil
+}
+
+// stateSnapshot is a consistent copy of botState for rendering.
+type stateSnapshot struct {
+    Pause          *pauseInfo
+    SignupsPaused  bool
+    Pending        []Task
+    LastPoll       time.Time
+    LastTargetPoll time.Time
+    Claimed        []claimedTask
+    Targets        []Target
+    TokenExpiry    time.Time
+    LogTail        []string
+    HookRuns       []hookRun
+    Latency        latencyReport
+    Skipped        skipCounts
+    RecentErrors   []string
+    Workload       workloadForecast
+}
+
+// Snapshot returns a copy of the current state.
+func (s *botState) Snapshot() stateSnapshot {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return stateSnapshot{
+        Pause:          s.pause,
+        SignupsPaused:  s.signupsPaused,
+        Pending:        append([]Task(nil), s.pending...),
+        LastPoll:       s.lastPoll,
+        LastTargetPoll: s.lastTargetPoll,
+        Claimed:        append([]claimedTask(nil), s.claimed...),
+        Targets:        append([]Target(nil), s.targets...),
+        TokenExpiry:    s.tokenExpiry,
+        LogTail:        append([]string(nil), s.logTail...),
+        HookRuns:       append([]hookRun(nil), s.hookRuns...),
+        Latency:        s.latency,
+        Skipped:        s.skipCountsLocked(),
+        RecentErrors:   append([]string(nil), s.recentErrors...),
+        Workload:       s.workload,
+    }
+}
+
+// StatusText summarizes the snapshot for status replies.
+func (s stateSnapshot) StatusText() string {
+    var b strings.Builder
+    if s.Pause != nil {
+        fmt.Fprintf(&b, "Claiming %s.\n", s.Pause)
+    } else {
+        b.WriteString("Claiming is running.\n")
+    }
+    fmt.Fprintf(&b, "Pending missions: %d\n", len(s.Pending))
+    fmt.Fprintf(&b, "Recently claimed: %d\n", len(s.Claimed))
+    if n := len(s.Claimed); n > 0 {
+        last := s.Claimed[n-1]
+        fmt.Fprintf(&b, "Last claim: %s (%s) at %s\n", last.Task.Title, last.Task.Payout, last.At.Format("15:04"))
+    }
+    fmt.Fprintf(&b, "Unregistered targets: %d\n", len(s.Targets))
+    if s.SignupsPaused {
+        b.WriteString("Target signups are paused.\n")
+    }
+    if s.TokenExpiry.IsZero() {
+        b.WriteString("Token expiry: unknown")
+    } else if left := time.Until(s.TokenExpiry); left > 0 {
+        fmt.Fprintf(&b, "Token expires in %s", left.Round(time.Minute))
+    } else {
+        b.WriteString("Token has EXPIRED")
+    }
+    if s.Latency.Samples > 0 {
+        fmt.Fprintf(&b, "\nLatency: %s", s.Latency)
+    }
+    if s.Workload.Horizon > 0 {
+        fmt.Fprintf(&b, "\nWorkload: %s", s.Workload)
+    }
+    if len(s.Skipped) > 0 {
+        fmt.Fprintf(&b, "\nSkipped missions: %s", s.Skipped)
+    }
+    return b.String()
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+    "bufio"
+    _ "embed"
+    "encoding/json"
+    "fmt"
+    "html/template"
+    "io"
+    "maps"
+    "os"
+    "slices"
+    "strings"
+    "time"
+)
+
+// statusPageHTML is the template of "status -html".
+//
+//go:embed statuspage.html
+var statusPageHTML string
+
+var statusPageTemplate = template.Must(template.New("status").Funcs(template.FuncMap{
+    "time": func(t time.Time) string { return t.Local().Format("2006-01-02 15:04") },
+}).Parse(statusPageHTML))
+
+// Limits of the lists on the status page.
+const (
+    statusPageClaims = 20
+    statusPageEvents = 50
+)
+
+// statusPage is the data shown by the status page.
+type statusPage struct {
+    Generated   time.Time
+    TokenExpiry time.Time
+    TokenRoles  []string
+    Claimed     []statusMission
+    // Stats and RecentClaims come from the database (-db), Events from the
+    // audit log (-audit-log).
+    Stats        []statusStat
+    RecentClaims []claimRecord
+    Events       []statusEvent
+}
+
+// statusMission is a claimed mission with its deadline.
+type statusMission struct {
+    Task Task
+    Due  time.Time
+}
+
+// statusStat is the number of claims and their payouts in a period.
+type statusStat struct {
+    Period  string
+    Claims  int
+    Payouts string
+}
+
+// statusEvent is a notification event read from the audit log.
+type statusEvent struct {
+    Time    time.Time
+    Type    string
+    Message string
+}
+
+// newStatusPage collects the token's state and claimed missions.
+func newStatusPage(token string, claimed []Task, now time.Time) statusPage {
+    p := statusPage{Generated: now}
+    p.TokenExpiry, _ = tokenExpiry(token)
+    if claims, err := decodeTokenClaims(token); err == nil {
+        p.TokenRoles = tokenRoles(claims)
+    }
+    for _, t := range claimed {
+        due, _ := missionDeadline(t)
+        p.Claimed = append(p.Claimed, statusMission{Task: t, Due: due})
+    }
+    return p
+}
+
+// addClaimStats adds claim counts and payouts per period, and the latest claims.
+func (p *statusPage) addClaimStats(claims []claimRecord) {
+    today := startOfDay(p.Generated, time.Local)
+    periods := []struct {
+        name  string
+        since time.Time
+    }{
+        {"Today", today},
+        {"Last 7 days", today.AddDate(0, 0, -6)},
+        {"Last 30 days", today.AddDate(0, 0, -29)},
+        {"All time", time.Time{}},
+    }
+    for _, period := range periods {
+        stat := statusStat{Period: period.name}
+        payouts := map[string]float64{}
+        for _, c := range claims {
+            if !c.ClaimedAt.Before(period.since) {
+                stat.Claims++
+                payouts[c.Task.Payout.Currency] += c.Task.Payout.Amount
+            }
+        }
+        var totals []string
+        for _, currency := range slices.Sorted(maps.Keys(payouts)) {
+            totals = append(totals, TaskPayout{Amount: payouts[currency], Currency: currency}.String())
+        }
+        stat.Payouts = strings.Join(totals, ", ")
+        p.Stats = append(p.Stats, stat)
+    }
+    recent := claims[max(len(claims)-statusPageClaims, 0):]
+    for i := len(recent) - 1; i >= 0; i-- {
+        p.RecentClaims = append(p.RecentClaims, recent[i])
+    }
+}
+
+// addAuditEvents adds the latest notification events from an audit log.
+func (p *statusPage) addAuditEvents(r io.Reader) error {
+    sc := bufio.NewScanner(r)
+    sc.Buffer(make([]byte, 64<<10), 1<<20)
+    var events []statusEvent
+    for sc.Scan() {
+        var rec auditRecord
+        if err := json.Unmarshal(sc.Bytes(), &rec); err != nil || rec.Action != AuditEvent {
+            continue
+        }
+        ev := statusEvent{Time: rec.Time}
+        ev.Type, _ = rec.Fields["event"].(string)
+        ev.Message, _ = rec.Fields["message"].(string)
+        events = append(events, ev)
+        if len(events) > statusPageEvents {
+            events = events[1:]
+        }
+    }
+    slices.Reverse(events)
+    p.Events = events
+    return sc.Err()
+}
+
+// writeStatusPage renders the status page for the token and its claimed
+// missions, with database statistics and audit log events if given.
+func writeStatusPage(w io.Writer, page statusPage, db *store, auditPath string) error {
+    if db != nil {
+        page.addClaimStats(db.Data().Claims)
+    }
+    if auditPath != "" {
+        f, err := os.Open(auditPath)
+        if err != nil {
+            return err
+        }
+        defer f.Close()
+        if err := page.addAuditEvents(f); err != nil {
+            return fmt.Errorf("reading %s: %v", auditPath, err)
+        }
+    }
+    return statusPageTemplate.Execute(w, page)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+    "fmt"
+    "log/slog"
+    "os"
+    "sync"
+)
+
+// StatusPolicy is what to do when an endpoint answers with a status code the
+// bot does not know how to handle.
+type StatusPolicy string
+
+// Unknown status policies.
+const (
+    // StatusError logs the error and carries on (the default).
+    StatusError StatusPolicy = "error"
+    // StatusRetry retries the request like a 429, using the endpoint's retry policy.
+    StatusRetry StatusPolicy = "retry"
+    // StatusFatal stops the bot.
+    StatusFatal StatusPolicy = "fatal"
+    // StatusNotify sends a notification and skips the request.
+    StatusNotify StatusPolicy = "notify"
+)
+
+// knownStatuses are the response codes each endpoint's caller handles explicitly.
+var knownStatuses = map[string][]int{
+    "tasks":          {200, 401, 429, 503},
+    "activity":       {200, 401, 429, 503},
+    "claim":          {201, 401, 403, 412},
+    "mark_viewed":    {200, 201, 204, 401, 429, 503},
+    "mission_detail": {200, 401, 429, 503},
+    "release":        {200, 201, 401, 412, 429, 503},
+    "targets":        {200, 401, 429, 503},
+    "signup":         {200, 401, 429, 503},
+    "claimed_tasks":  {200, 401, 429, 503},
+    "category_stats": {200, 401, 429, 503},
+    "announcements":  {200, 401, 429, 503},
+    "target_detail":  {200, 401, 429, 503},
+    "target_scope":   {200, 401, 429, 503},
+}
+
+// statusPolicies maps endpoint names (or "default") to policies, from the
+// config file. It is replaced on reload, so access is guarded by
+// statusPoliciesMu.
+var (
+    statusPoliciesMu sync.RWMutex
+    statusPolicies   = map[string]StatusPolicy{}
+)
+
+// setStatusPolicies replaces the configured policies.
+func setStatusPolicies(policies map[string]StatusPolicy) {
+    if policies == nil {
+        policies = map[string]StatusPolicy{}
+    }
+    statusPoliciesMu.Lock()
+    statusPolicies = policies
+    statusPoliciesMu.Unlock()
+}
+
+// validateStatusPolicies checks the configured policies.
+func validateStatusPolicies(policies map[string]StatusPolicy) error {
+    for endpoint, p := range policies {
+        if _, ok := knownStatuses[endpoint]; !ok && endpoint != "default" {
+            return fmt.Errorf("status_policies: unknown endpoint %q", endpoint)
+        }
+        switch p {
+        case StatusError, StatusRetry, StatusFatal, StatusNotify:
+        default:
+            return fmt.Errorf("status_policies: invalid policy %q for %s (want error, retry, fatal or notify)", p, endpoint)
+        }
+    }
+    return nil
+}
+
+// statusPolicyFor returns the policy for an endpoint.
+func statusPolicyFor(endpoint string) StatusPolicy {
+    statusPoliciesMu.RLock()
+    defer statusPoliciesMu.RUnlock()
+    if p, ok := statusPolicies[endpoint]; ok {
+        return p
+    }
+    if p, ok := statusPolicies["default"]; ok {
+        return p
+    }
+    return StatusError
+}
+
+// isKnownStatus reports whether the endpoint's caller handles code.
+func isKnownStatus(endpoint string, code int) bool {
+    for _, c := range knownStatuses[endpoint] {
+        if c == code {
+            return true
+        }
+    }
+    return false
+}
+
+// shouldRetry reports whether a response is retried by doWithRetry.
+func shouldRetry(endpoint string, code int) bool {
+    if isKnownStatus(endpoint, code) {
+        return retryableStatus(code)
+    }
+    return statusPolicyFor(endpoint) == StatusRetry
+}
+
+// unexpectedStatus applies the endpoint's policy to a response code nobody
+// handles, and returns err for the caller to report as before.
+func unexpectedStatus(endpoint string, code int, err error) error {
+    if isKnownStatus(endpoint, code) {
+        return err
+    }
+    journal.RecordStatus(endpoint, code)
+    switch statusPolicyFor(endpoint) {
+    case StatusFatal:
+        slog.Error("unexpected status code, stopping the bot", "endpoint", endpoint, "status", code)
+        notifyEvent(Event{Type: EventBotStopped, Message: fmt.Sprintf("Bot stopped: %s answered with unexpected status code %d.", endpoint, code), Status: code})
+        flushNotifications()
+        os.Exit(1)
+    case StatusNotify:
+        notifyEvent(Event{Type: EventUnexpectedStatus, Message: fmt.Sprintf("%s answered with unexpected status code %d; skipped.", endpoint, code), Status: code})
+    }
+    return err
+}
@@ -0,0 +1,648 @@
+package main
+
+import (
+    "bytes"
+    "crypto/aes"
+    "crypto/cipher"
+    "crypto/pbkdf2"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "maps"
+    "os"
+    "path/filepath"
+    "slices"
+    "sync"
+    "time"
+)
+
+// encryptedMagic prefixes database files written with a passphrase.
+var encryptedMagic = []byte("MBDB-AESGCM1\n")
+
+// Key derivation parameters for encrypted databases.
+const (
+    kdfIterations = 600000
+    kdfSaltSize   = 16
+)
+
+// storeData is everything persisted in the local database.
+type storeData struct {
+    Claims        []claimRecord  `json:"claims"`
+    ClaimTimings  []claimTiming  `json:"claimTimings"`
+    Announcements []announcement `json:"announcements"`
+    KnownSlugs    []string       `json:"knownSlugs"`
+    // DeadlineReminders is the highest reminder threshold sent per claimed task ID.
+    DeadlineReminders map[string]int `json:"deadlineReminders"`
+    // Discovered is when each mission (by hashed task ID) was first seen,
+    // for the claim funnel.
+    Discovered map[string]time.Time `json:"discovered,omitempty"`
+    // Forbidden are the missions whose claim was answered with 403.
+    Forbidden []forbiddenRecord `json:"forbidden,omitempty"`
+    // ActivitySyncedAt is the time of the newest activity feed entry
+    // reconciled with the audit log.
+    ActivitySyncedAt time.Time `json:"activitySyncedAt,omitempty"`
+}
+
+// forbiddenRecord is a mission the platform refused to let the researcher
+// claim (403), typically because its campaign needs a clearance.
+type forbiddenRecord struct {
+    TaskID      string    `json:"taskId"`
+    CampaignUid string    `json:"campaignUid"`
+    At          time.Time `json:"at"`
+}
+
+// claimRecord is a mission the bot claimed.
+type claimRecord struct {
+    Task      Task      `json:"task"`
+    ClaimedAt time.Time `json:"claimedAt"`
+    // CompletedAt is when the mission left the claimed list before its
+    // deadline, i.e. was submitted (or released outside the bot).
+    CompletedAt time.Time `json:"completedAt,omitempty"`
+    // ReleasedAt is when the mission was released through the bot.
+    ReleasedAt time.Time `json:"releasedAt,omitempty"`
+    // ContentHash identifies re-publications of the mission.
+    ContentHash string `json:"contentHash,omitempty"`
+}
+
+// Deadline returns when the claimed mission is due, if its completion time is known.
+func (c claimRecord) Deadline() (time.Time, bool) {
+    if c.Task.MaxCompletionTimeInSecs <= 0 {
+        return time.Time{}, false
+    }
+    return c.ClaimedAt.Add(time.Duration(c.Task.MaxCompletionTimeInSecs) * time.Second), true
+}
+
+// strictRetention is how long claims are kept in strict mode.
+const strictRetention = 7 * 24 * time.Hour
+
+// discoveredRetention is how long first sightings of missions are kept.
+const discoveredRetention = 180 * 24 * time.Hour
+
+// discoveredSaveInterval is how often new sightings of missions alone cause
+// the database to be written; in between they are saved with other changes.
+const discoveredSaveInterval = time.Minute
+
+// forbiddenRetention is how long a 403 keeps a mission's campaign from
+// being attempted, in case a clearance is granted later.
+const forbiddenRetention = 7 * 24 * time.Hour
+
+// store is the local database of mission history. It is kept in memory and
+// written to path as a single JSON document after every change. With a
+// passphrase, the document is encrypted with AES-256-GCM. An empty path keeps
+// the data in memory only.
+type store struct {
+    path string
+    // key encrypts every save; it is derived once, when the store is
+    // opened, and nil without a passphrase.
+    key *storeKey
+
+    mu   sync.Mutex
+    data storeData
+    // strict drops mission titles and codenames from new claims and
+    // prunes claims older than strictRetention on every save.
+    strict bool
+    // discoveredSaved is when new sightings were last written, and
+    // unsaved whether some were recorded since without a write.
+    discoveredSaved time.Time
+    unsaved         bool
+}
+
+// openStore loads the database at path, creating it on first save. A plaintext
+// database opened with a passphrase is encrypted the next time it is saved.
+// The key is derived here, from the file's salt or a fresh one, and reused
+// by every save.
+func openStore(path string, passphrase []byte) (*store, error) {
+    s := &store{path: path}
+    if path == "" {
+        return s, nil
+    }
+
+    raw, err := os.ReadFile(path)
+    if errors.Is(err, os.ErrNotExist) {
+        raw = nil
+    } else if err != nil {
+        return nil, err
+    }
+
+    if bytes.HasPrefix(raw, encryptedMagic) {
+        if len(passphrase) == 0 {
+            return nil, fmt.Errorf("database %s is encrypted; a passphrase is required", path)
+        }
+        if raw, s.key, err = openSealed(raw[len(encryptedMagic):], passphrase); err != nil {
+            return nil, fmt.Errorf("opening database %s: %v", path, err)
+        }
+    } else if len(passphrase) > 0 {
+        if s.key, err = newStoreKey(passphrase); err != nil {
+            return nil, err
+        }
+    }
+    if raw == nil {
+        return s, nil
+    }
+
+    if err := json.Unmarshal(raw, &s.data); err != nil {
+        return nil, fmt.Errorf("parsing database %s: %v", path, err)
+    }
+    return s, nil
+}
+
+// AddClaim records a claimed mission.
+func (s *store) AddClaim(task Task) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    hash := missionContentHash(task)
+    if s.strict {
+        task = redactTask(task)
+    }
+    s.data.Claims = append(s.data.Claims, claimRecord{Task: task, ClaimedAt: time.Now(), ContentHash: hash})
+    return s.saveLocked()
+}
+
+// Republished returns the latest finished claim of another mission with the
+// same content as task.
+func (s *store) Republished(task Task) (republished, bool) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    hash := missionContentHash(task)
+    var latest republished
+    for _, c := range s.data.Claims {
+        if c.ContentHash != hash || c.Task.ID == task.ID {
+            continue
+        }
+        switch {
+        case !c.ReleasedAt.IsZero() && c.ReleasedAt.After(latest.At):
+            latest = republished{Outcome: OutcomeReleased, At: c.ReleasedAt}
+        case !c.CompletedAt.IsZero() && c.CompletedAt.After(latest.At):
+            latest = republished{Outcome: OutcomeCompleted, At: c.CompletedAt}
+        }
+    }
+    return latest, !latest.At.IsZero()
+}
+
+// MarkReleased records that a claimed mission was released.
+func (s *store) MarkReleased(taskID string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    for i, c := range s.data.Claims {
+        if c.Task.ID == taskID && c.ReleasedAt.IsZero() {
+            s.data.Claims[i].ReleasedAt = time.Now()
+            return s.saveLocked()
+        }
+    }
+    return nil
+}
+
+// redactTask keeps only the identifiers, category and payout of a task.
+func redactTask(task Task) Task {
+    return Task{
+        ID:              task.ID,
+        CampaignUid:     task.CampaignUid,
+        ListingUid:      task.ListingUid,
+        OrganizationUid: task.OrganizationUid,
+        Category:        task.Category,
+        Payout:          task.Payout,
+    }
+}
+
+// EnableStrict switches the store to strict mode and immediately rewrites it
+// with existing claims redacted and pruned to the retention period.
+func (s *store) EnableStrict() error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.strict = true
+    for i := range s.data.Claims {
+        s.data.Claims[i].Task = redactTask(s.data.Claims[i].Task)
+    }
+    return s.saveLocked()
+}
+
+// HasClaimed reports whether the task was claimed before.
+func (s *store) HasClaimed(taskID string) bool {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    for _, c := range s.data.Claims {
+        if c.Task.ID == taskID {
+            return true
+        }
+    }
+    return false
+}
+
+// CampaignClaims returns how many tasks of the campaign have been claimed.
+func (s *store) CampaignClaims(campaignUid string) int {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    n := 0
+    for _, c := range s.data.Claims {
+        if c.Task.CampaignUid == campaignUid {
+            n++
+        }
+    }
+    return n
+}
+
+// ClaimsSince returns how many missions were claimed since t and their
+// total payout amount.
+func (s *store) ClaimsSince(t time.Time) (int, float64) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    var n int
+    var payout float64
+    for _, c := range s.data.Claims {
+        if !c.ClaimedAt.Before(t) {
+            n++
+            payout += c.Task.Payout.Amount
+        }
+    }
+    return n, payout
+}
+
+// TargetClaimsSince returns how many missions of the target (listing UID)
+// were claimed since t.
+func (s *store) TargetClaimsSince(listingUid string, t time.Time) int {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    n := 0
+    for _, c := range s.data.Claims {
+        if c.Task.ListingUid == listingUid && !c.ClaimedAt.Before(t) {
+            n++
+        }
+    }
+    return n
+}
+
+// AddForbidden records a mission whose claim was answered with 403 and
+// forgets records older than forbiddenRetention.
+func (s *store) AddForbidden(task Task) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    now := time.Now()
+    s.data.Forbidden = slices.DeleteFunc(s.data.Forbidden, func(f forbiddenRecord) bool {
+        return f.TaskID == task.ID || now.Sub(f.At) > forbiddenRetention
+    })
+    s.data.Forbidden = append(s.data.Forbidden, forbiddenRecord{TaskID: task.ID, CampaignUid: task.CampaignUid, At: now})
+    return s.saveLocked()
+}
+
+// Forbidden returns the 403 record of the mission, or of another mission
+// of its campaign, within forbiddenRetention.
+func (s *store) Forbidden(task Task) (forbiddenRecord, bool) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    for _, f := range slices.Backward(s.data.Forbidden) {
+        if time.Since(f.At) > forbiddenRetention {
+            continue
+        }
+        if f.TaskID == task.ID || (f.CampaignUid != "" && f.CampaignUid == task.CampaignUid) {
+            return f, true
+        }
+    }
+    return forbiddenRecord{}, false
+}
+
+// ForgetForbidden removes the 403 records of these missions.
+func (s *store) ForgetForbidden(taskIDs []string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.data.Forbidden = slices.DeleteFunc(s.data.Forbidden, func(f forbiddenRecord) bool {
+        return slices.Contains(taskIDs, f.TaskID)
+    })
+    return s.saveLocked()
+}
+
+// AddClaimTiming records the timing of a claim attempt.
+func (s *store) AddClaimTiming(t claimTiming) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.data.ClaimTimings = append(s.data.ClaimTimings, t)
+    return s.saveLocked()
+}
+
+// ClaimTimings returns a copy of all recorded claim timings.
+func (s *store) ClaimTimings() []claimTiming {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return append([]claimTiming(nil), s.data.ClaimTimings...)
+}
+
+// AddDiscovered records when missions not seen before were first seen and
+// forgets sightings older than discoveredRetention. The sightings are
+// written at most every discoveredSaveInterval, unless another change
+// writes them first; Flush writes the rest.
+func (s *store) AddDiscovered(tasks []Task) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    now := time.Now()
+    changed := false
+    for _, t := range tasks {
+        h := anonymizeID(t.ID)
+        if _, ok := s.data.Discovered[h]; ok {
+            continue
+        }
+        if s.data.Discovered == nil {
+            s.data.Discovered = map[string]time.Time{}
+        }
+        s.data.Discovered[h] = now
+        changed = true
+    }
+    if !changed {
+        return nil
+    }
+    maps.DeleteFunc(s.data.Discovered, func(_ string, seen time.Time) bool {
+        return now.Sub(seen) > discoveredRetention
+    })
+    if now.Sub(s.discoveredSaved) < discoveredSaveInterval {
+        s.unsaved = true
+        return nil
+    }
+    s.discoveredSaved = now
+    return s.saveLocked()
+}
+
+// Flush writes changes whose write was deferred, such as new sightings.
+func (s *store) Flush() error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if !s.unsaved {
+        return nil
+    }
+    return s.saveLocked()
+}
+
+// AddKnownSlug records a target slug the bot attempted to sign up for.
+func (s *store) AddKnownSlug(slug string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if slices.Contains(s.data.KnownSlugs, slug) {
+        return nil
+    }
+    s.data.KnownSlugs = append(s.data.KnownSlugs, slug)
+    return s.saveLocked()
+}
+
+// KnownSlugs returns a copy of the recorded target slugs.
+func (s *store) KnownSlugs() []string {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return append([]string(nil), s.data.KnownSlugs...)
+}
+
+// DeadlineReminder returns the highest reminder threshold sent for a task.
+func (s *store) DeadlineReminder(taskID string) int {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return s.data.DeadlineReminders[taskID]
+}
+
+// SetDeadlineReminder records that the reminder at threshold was sent.
+func (s *store) SetDeadlineReminder(taskID string, threshold int) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if s.data.DeadlineReminders == nil {
+        s.data.DeadlineReminders = map[string]int{}
+    }
+    s.data.DeadlineReminders[taskID] = threshold
+    return s.saveLocked()
+}
+
+// PruneDeadlineReminders forgets the reminders of tasks no longer claimed.
+func (s *store) PruneDeadlineReminders(active []string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    pruned := false
+    for id := range s.data.DeadlineReminders {
+        if !slices.Contains(active, id) {
+            delete(s.data.DeadlineReminders, id)
+            pruned = true
+        }
+    }
+    if !pruned {
+        return nil
+    }
+    return s.saveLocked()
+}
+
+// MarkCompleted marks the claims still within their deadline that are no
+// longer among the active (claimed) task IDs as completed now.
+func (s *store) MarkCompleted(active []string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    now := time.Now()
+    changed := false
+    for i, c := range s.data.Claims {
+        deadline, ok := c.Deadline()
+        if !c.CompletedAt.IsZero() || !c.ReleasedAt.IsZero() || !ok || now.After(deadline) || slices.Contains(active, c.Task.ID) {
+            continue
+        }
+        s.data.Claims[i].CompletedAt = now
+        changed = true
+    }
+    if !changed {
+        return nil
+    }
+    return s.saveLocked()
+}
+
+// AddAnnouncements stores the announcements not seen before and returns them.
+func (s *store) AddAnnouncements(items []announcement) ([]announcement, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    seen := make(map[string]bool, len(s.data.Announcements))
+    for _, a := range s.data.Announcements {
+        seen[a.ID] = true
+    }
+    var added []announcement
+    for _, a := range items {
+        if a.ID == "" || seen[a.ID] {
+            continue
+        }
+        seen[a.ID] = true
+        added = append(added, a)
+    }
+    if len(added) == 0 {
+        return nil, nil
+    }
+    s.data.Announcements = append(s.data.Announcements, added...)
+    return added, s.saveLocked()
+}
+
+// AnnouncementCount returns how many announcements are stored.
+func (s *store) AnnouncementCount() int {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return len(s.data.Announcements)
+}
+
+// ActivityCursor returns the time of the newest reconciled activity entry.
+func (s *store) ActivityCursor() time.Time {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return s.data.ActivitySyncedAt
+}
+
+// SetActivityCursor records the time of the newest reconciled activity entry.
+func (s *store) SetActivityCursor(t time.Time) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.data.ActivitySyncedAt = t
+    return s.saveLocked()
+}
+
+// Data returns everything in the database. The result shares memory with
+// the store and must not be modified.
+func (s *store) Data() storeData {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return s.data
+}
+
+// Replace replaces the whole database content and saves it.
+func (s *store) Replace(data storeData) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.data = data
+    return s.saveLocked()
+}
+
+// saveLocked writes the database atomically. s.mu must be held.
+func (s *store) saveLocked() error {
+    if s.strict {
+        cutoff := time.Now().Add(-strictRetention)
+        s.data.Claims = slices.DeleteFunc(s.data.Claims, func(c claimRecord) bool {
+            return c.ClaimedAt.Before(cutoff)
+        })
+    }
+    if s.path == "" {
+        return nil
+    }
+    raw, err := json.Marshal(s.data)
+    if err != nil {
+        return err
+    }
+    if s.key != nil {
+        if raw, err = s.key.seal(raw); err != nil {
+            return err
+        }
+        raw = append(append([]byte(nil), encryptedMagic...), raw...)
+    }
+
+    tmp, err := os.CreateTemp(filepath.Dir(s.path), ".mission-bot-db-*")
+    if err != nil {
+        return err
+    }
+    defer os.Remove(tmp.Name())
+    if _, err := tmp.Write(raw); err != nil {
+        tmp.Close()
+        return err
+    }
+    if err := tmp.Close(); err != nil {
+        return err
+    }
+    if err := os.Chmod(tmp.Name(), 0600); err != nil {
+        return err
+    }
+    if err := os.Rename(tmp.Name(), s.path); err != nil {
+        return err
+    }
+    s.unsaved = false
+    return nil
+}
+
+// storeKey is a cipher derived from a passphrase with one salt. The key
+// derivation is slow on purpose, so it is done once per salt and every
+// seal only draws a fresh nonce.
+type storeKey struct {
+    salt []byte
+    gcm  cipher.AEAD
+}
+
+// newStoreKey derives a key from passphrase with a fresh salt.
+func newStoreKey(passphrase []byte) (*storeKey, error) {
+    salt := make([]byte, kdfSaltSize)
+    if _, err := rand.Read(salt); err != nil {
+        return nil, err
+    }
+    gcm, err := storeCipher(passphrase, salt)
+    if err != nil {
+        return nil, err
+    }
+    return &storeKey{salt: salt, gcm: gcm}, nil
+}
+
+// seal encrypts plaintext as salt || nonce || ciphertext.
+func (k *storeKey) seal(plaintext []byte) ([]byte, error) {
+    nonce := make([]byte, k.gcm.NonceSize())
+    if _, err := rand.Read(nonce); err != nil {
+        return nil, err
+    }
+    out := append(append([]byte(nil), k.salt...), nonce...)
+    return k.gcm.Seal(out, nonce, plaintext, nil), nil
+}
+
+// encryptStore seals plaintext as salt || nonce || ciphertext with a key
+// of its own.
+func encryptStore(plaintext, passphrase []byte) ([]byte, error) {
+    k, err := newStoreKey(passphrase)
+    if err != nil {
+        return nil, err
+    }
+    return k.seal(plaintext)
+}
+
+// decryptStore reverses encryptStore.
+func decryptStore(sealed, passphrase []byte) ([]byte, error) {
+    plaintext, _, err := openSealed(sealed, passphrase)
+    return plaintext, err
+}
+
+// openSealed decrypts what a storeKey sealed and returns the key, so later
+// seals can reuse its salt without deriving it again.
+func openSealed(sealed, passphrase []byte) ([]byte, *storeKey, error) {
+    if len(sealed) < kdfSaltSize {
+        return nil, nil, fmt.Errorf("encrypted database is truncated")
+    }
+    salt, rest := sealed[:kdfSaltSize], sealed[kdfSaltSize:]
+    gcm, err := storeCipher(passphrase, salt)
+    if err != nil {
+        return nil, nil, err
+    }
+    if len(rest) < gcm.NonceSize() {
+        return nil, nil, fmt.Errorf("encrypted database is truncated")
+    }
+    plaintext, err := gcm.Open(nil, rest[:gcm.NonceSize()], rest[gcm.NonceSize():], nil)
+    if err != nil {
+        return nil, nil, fmt.Errorf("wrong passphrase or corrupted database")
+    }
+    return plaintext, &storeKey{salt: bytes.Clone(salt), gcm: gcm}, nil
+}
+
+// storeCipher derives the AES-256-GCM cipher for a passphrase and salt.
+func storeCipher(passphrase, salt []byte) (cipher.AEAD, error) {
+    key, err := pbkdf2.Key(sha256.New, string(passphrase), salt, kdfIterations, 32)
+    if err != nil {
+        return nil, err
+    }
+    block, err := aes.NewCipher(key)
+    if err != nil {
+        return nil, err
+    }
+    return cipher.NewGCM(block)
+}
+
+// readPassphrase returns the database passphrase from the given file, falling
+// back to the MISSION_BOT_DB_PASSPHRASE environment variable. No passphrase
+// means the database is stored unencrypted.
+func readPassphrase(path string) ([]byte, error) {
+    if path != "" {
+        data, err := os.ReadFile(path)
+        if err != nil {
+            return nil, err
+        }
+        passphrase := bytes.TrimSpace(data)
+        registerSecret(string(passphrase))
+        return passphrase, nil
+    }
+    return []byte(os.Getenv("MISSION_BOT_DB_PASSPHRASE")), nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+    "bytes"
+    "os"
+    "path/filepath"
+    "slices"
+    "testing"
+)
+
+func TestEncryptedStoreDerivesKeyOnce(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "bot.db")
+    passphrase := []byte("correct horse")
+    s, err := openStore(path, passphrase)
+    if err != nil {
+        t.Fatal(err)
+    }
+    sealed := func() []byte {
+        t.Helper()
+        raw, err := os.ReadFile(path)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if !bytes.HasPrefix(raw, encryptedMagic) {
+            t.Fatalf("database isn't encrypted: %q", raw)
+        }
+        return raw[len(encryptedMagic):]
+    }
+
+    if err := s.AddKnownSlug("a"); err != nil {
+        t.Fatal(err)
+    }
+    first := sealed()
+    for _, slug := range []string{"b", "c", "d", "e"} {
+        if err := s.AddKnownSlug(slug); err != nil {
+            t.Fatal(err)
+        }
+    }
+    second := sealed()
+    salt := kdfSaltSize
+    nonce := salt + s.key.gcm.NonceSize()
+    if !bytes.Equal(first[:salt], second[:salt]) {
+        t.Error("saves use different salts, want the one derived at open")
+    }
+    if bytes.Equal(first[salt:nonce], second[salt:nonce]) {
+        t.Error("saves reuse a nonce")
+    }
+
+    reopened, err := openStore(path, passphrase)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got := reopened.KnownSlugs(); !slices.Equal(got, []string{"a", "b", "c", "d", "e"}) {
+        t.Errorf("known slugs %v after reopening, want [a b c d e]", got)
+    }
+    if !bytes.Equal(reopened.key.salt, first[:salt]) {
+        t.Error("reopened store derived a new salt, want the file's")
+    }
+    if _, err := openStore(path, []byte("wrong")); err == nil {
+        t.Error("opened with the wrong passphrase")
+    }
+    if _, err := openStore(path, nil); err == nil {
+        t.Error("opened without a passphrase")
+    }
+}
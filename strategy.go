@@ -0,0 +1,125 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "log/slog"
+    "net/http"
+    "sort"
+    "strings"
+    "time"
+)
+
+// categoryStatsEndpoint returns the researcher's mission review outcomes per category.
+var categoryStatsEndpoint = "https://platform.synack.com/api/tasks/v1/researcher/statistics"
+
+// categoryStatsMaxAge is how long fetched category statistics are reused.
+const categoryStatsMaxAge = time.Hour
+
+// categoryStat is the number of approved and rejected missions in one category.
+type categoryStat struct {
+    Category string `json:"category"`
+    Approved int    `json:"approved"`
+    Rejected int    `json:"rejected"`
+}
+
+// getCategoryStats retrieves the researcher's per-category mission statistics.
+func getCategoryStats(token string) ([]categoryStat, error) {
+    resp, err := doWithRetry("category_stats", defaultRetryPolicy, func() (*http.Request, error) {
+        return newAPIRequest("GET", categoryStatsEndpoint, token, nil)
+    })
+    if err != nil {
+        return nil, err
+    }
+    defer closeBody(resp)
+    logResponse("category_stats", resp)
+
+    switch resp.StatusCode {
+    case http.StatusOK:
+        var stats []categoryStat
+        if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+            return nil, err
+        }
+        return stats, nil
+    case http.StatusUnauthorized:
+        return nil, fmt.Errorf("unauthorized (401)")
+    default:
+        return nil, unexpectedStatus("category_stats", resp.StatusCode, fmt.Errorf("failed to retrieve category statistics, status code: %d", resp.StatusCode))
+    }
+}
+
+// acceptanceRates turns statistics into approval rates per lower-cased category,
+// skipping categories with fewer than minReviewed reviewed missions.
+func acceptanceRates(stats []categoryStat, minReviewed int) map[string]float64 {
+    rates := map[string]float64{}
+    for _, s := range stats {
+        reviewed := s.Approved + s.Rejected
+        if reviewed == 0 || reviewed < minReviewed {
+            continue
+        }
+        rates[strings.ToLower(s.Category)] = float64(s.Approved) / float64(reviewed)
+    }
+    return rates
+}
+
+// orderTasks decides the order in which tasks are claimed. Tasks in categories
+// whose personal acceptance rate is below the configured minimum are moved to
+// the back. Within that, the configured orders are applied in turn; ties keep
+// the platform's order.
+func orderTasks(tasks []Task, cfg StrategyConfig, rates map[string]float64) []Task {
+    ordered := append([]Task(nil), tasks...)
+    low := func(t Task) bool {
+        if len(rates) == 0 || cfg.MinAcceptanceRate <= 0 {
+            return false
+        }
+        rate, ok := rates[strings.ToLower(t.Category)]
+        return ok && rate < cfg.MinAcceptanceRate
+    }
+    preferred := func(t Task) bool {
+        return containsFold(cfg.PreferTargets, t.ListingCodename) || containsFold(cfg.PreferTargets, t.ListingUid)
+    }
+    sort.SliceStable(ordered, func(i, j int) bool {
+        a, b := ordered[i], ordered[j]
+        if low(a) != low(b) {
+            return !low(a)
+        }
+        for _, o := range cfg.Order {
+            switch o {
+            case "payout":
+                if a.Payout.Amount != b.Payout.Amount {
+                    return a.Payout.Amount > b.Payout.Amount
+                }
+            case "shortest":
+                if a.MaxCompletionTimeInSecs != b.MaxCompletionTimeInSecs {
+                    return a.MaxCompletionTimeInSecs < b.MaxCompletionTimeInSecs
+                }
+            case "targets":
+                if preferred(a) != preferred(b) {
+                    return preferred(a)
+                }
+            case "newest":
+                if !a.PublishedOn.Equal(b.PublishedOn.Time) {
+                    return a.PublishedOn.After(b.PublishedOn.Time)
+                }
+            }
+        }
+        return false
+    })
+    return ordered
+}
+
+// refreshCategoryRates fetches category statistics when enabled and stale.
+// Failures keep the previous rates.
+func (b *bot) refreshCategoryRates(token string) {
+    if !b.config().Strategy.UseCategoryStats || time.Since(b.categoryRatesAt) < categoryStatsMaxAge {
+        return
+    }
+    b.categoryRatesAt = time.Now()
+    stats, err := getCategoryStats(token)
+    if err != nil {
+        slog.Warn("failed to refresh category statistics", "endpoint", "category_stats", "err", err)
+        return
+    }
+    b.categoryRates = acceptanceRates(stats, b.config().Strategy.MinReviewed)
+    slog.Debug("refreshed category acceptance rates", "rates", b.categoryRates)
+}
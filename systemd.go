@@ -0,0 +1,50 @@
+package main
+
+import (
+    "log/slog"
+    "net"
+    "os"
+    "slices"
+    "strconv"
+    "strings"
+)
+
+// sdNotify sends a state change ("READY=1", "WATCHDOG=1") to systemd when
+// the bot runs as a Type=notify service, and does nothing otherwise.
+func sdNotify(state string) {
+    addr := os.Getenv("NOTIFY_SOCKET")
+    if addr == "" {
+        return
+    }
+    if strings.HasPrefix(addr, "@") {
+        addr = "\x00" + addr[1:] // abstract socket
+    }
+    conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: addr, Net: "unixgram"})
+    if err != nil {
+        slog.Warn("failed to notify systemd", "state", state, "err", err)
+        return
+    }
+    defer conn.Close()
+    if _, err := conn.Write([]byte(state)); err != nil {
+        slog.Warn("failed to notify systemd", "state", state, "err", err)
+    }
+}
+
+// sdWatchdog reports whether systemd expects this process to pet its
+// watchdog (WatchdogSec= in the unit).
+func sdWatchdog() bool {
+    usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+    if err != nil || usec <= 0 {
+        return false
+    }
+    pid := os.Getenv("WATCHDOG_PID")
+    return pid == "" || pid == strconv.Itoa(os.Getpid())
+}
+
+// sdChildEnv returns the environment for the account processes, without
+// systemd's notification variables: only the supervisor talks to systemd.
+func sdChildEnv() []string {
+    return slices.DeleteFunc(os.Environ(), func(kv string) bool {
+        return strings.HasPrefix(kv, "NOTIFY_SOCKET=") || strings.HasPrefix(kv, "WATCHDOG_USEC=") || strings.HasPrefix(kv, "WATCHDOG_PID=")
+    })
+}
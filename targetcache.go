@@ -0,0 +1,236 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "log/slog"
+    "net/http"
+    "slices"
+    "strings"
+    "sync"
+    "time"
+)
+
+// Target detail and scope endpoints, formatted with the target slug (the
+// listing UID of its missions).
+var (
+    targetDetailEndpoint = "https://platform.synack.com/api/targets/%s"
+    targetScopeEndpoint  = "https://platform.synack.com/api/targets/%s/scope"
+)
+
+// Default lifetimes of cached target details and scopes.
+const (
+    defaultTargetDetailTTL = 6 * time.Hour
+    defaultTargetScopeTTL  = 30 * time.Minute
+)
+
+// targetDetail describes a target, registered or not.
+type targetDetail struct {
+    Slug           string         `json:"slug"`
+    Codename       string         `json:"codename"`
+    OrganizationID string         `json:"organization_id"`
+    Category       TargetCategory `json:"category"`
+    Description    string         `json:"description"`
+}
+
+// scopeAsset is one in-scope asset of a target.
+type scopeAsset struct {
+    Location string `json:"location"`
+    Type     string `json:"type"`
+}
+
+// getTargetDetail retrieves a target's details.
+func getTargetDetail(token, slug string) (targetDetail, error) {
+    var detail targetDetail
+    err := getTargetResource("target_detail", fmt.Sprintf(targetDetailEndpoint, slug), token, slug, &detail)
+    return detail, err
+}
+
+// getTargetScope retrieves a target's in-scope assets. Overlapping calls
+// for the same target share one request.
+func getTargetScope(token, slug string) ([]scopeAsset, error) {
+    url := fmt.Sprintf(targetScopeEndpoint, slug)
+    return sharedRead("target_scope", url, token, func() ([]scopeAsset, error) {
+        var scope []scopeAsset
+        err := getTargetResource("target_scope", url, token, slug, &scope)
+        return scope, err
+    })
+}
+
+// getTargetResource fetches one of the target endpoints and decodes it into v.
+func getTargetResource(endpoint, url, token, slug string, v any) error {
+    resp, err := doWithRetry(endpoint, defaultRetryPolicy, func() (*http.Request, error) {
+        return newAPIRequest("GET", url, token, nil)
+    })
+    if err != nil {
+        return err
+    }
+    defer closeBody(resp)
+    logResponse(endpoint, resp, "slug", slug)
+
+    switch resp.StatusCode {
+    case http.StatusOK:
+        return json.NewDecoder(resp.Body).Decode(v)
+    case http.StatusUnauthorized:
+        return fmt.Errorf("unauthorized (401)")
+    default:
+        return unexpectedStatus(endpoint, resp.StatusCode, fmt.Errorf("failed to retrieve target %s, status code: %d", slug, resp.StatusCode))
+    }
+}
+
+// cachedValue is a cached response and when it was fetched.
+type cachedValue[T any] struct {
+    value   T
+    fetched time.Time
+}
+
+// targetCache is a read-through cache of target details and scopes, so
+// enrichment doesn't spend the request budget shared with claiming. Entries
+// expire after their TTL and are dropped early when the target's scope is
+// seen to change. All methods are safe for concurrent use.
+type targetCache struct {
+    detailTTL time.Duration
+    scopeTTL  time.Duration
+
+    mu       sync.Mutex
+    details  map[string]cachedValue[targetDetail]
+    scopes   map[string]cachedValue[[]scopeAsset]
+    fetching map[string]bool // details being prefetched
+}
+
+// newTargetCache returns an empty cache with the configured TTLs.
+func newTargetCache(cfg TargetCacheConfig) *targetCache {
+    c := &targetCache{
+        detailTTL: cfg.DetailTTL.Duration,
+        scopeTTL:  cfg.ScopeTTL.Duration,
+        details:   map[string]cachedValue[targetDetail]{},
+        scopes:    map[string]cachedValue[[]scopeAsset]{},
+        fetching:  map[string]bool{},
+    }
+    if c.detailTTL == 0 {
+        c.detailTTL = defaultTargetDetailTTL
+    }
+    if c.scopeTTL == 0 {
+        c.scopeTTL = defaultTargetScopeTTL
+    }
+    return c
+}
+
+// Detail returns the target's details, fetching them when not cached.
+func (c *targetCache) Detail(token, slug string) (targetDetail, error) {
+    c.mu.Lock()
+    e, ok := c.details[slug]
+    c.mu.Unlock()
+    if ok && time.Since(e.fetched) < c.detailTTL {
+        return e.value, nil
+    }
+    detail, err := getTargetDetail(token, slug)
+    if err != nil {
+        return targetDetail{}, err
+    }
+    c.mu.Lock()
+    c.details[slug] = cachedValue[targetDetail]{value: detail, fetched: time.Now()}
+    c.mu.Unlock()
+    return detail, nil
+}
+
+// CachedDetail returns the target's details only when they are cached and
+// fresh; it never makes a request.
+func (c *targetCache) CachedDetail(slug string) (targetDetail, bool) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    e, ok := c.details[slug]
+    if !ok || time.Since(e.fetched) >= c.detailTTL {
+        return targetDetail{}, false
+    }
+    return e.value, true
+}
+
+// PrefetchDetail fetches the target's details in the background unless a
+// fetch for it is already running.
+func (c *targetCache) PrefetchDetail(token, slug string) {
+    c.mu.Lock()
+    if c.fetching[slug] {
+        c.mu.Unlock()
+        return
+    }
+    c.fetching[slug] = true
+    c.mu.Unlock()
+    goSafe(func() {
+        defer func() {
+            c.mu.Lock()
+            delete(c.fetching, slug)
+            c.mu.Unlock()
+        }()
+        if _, err := c.Detail(token, slug); err != nil {
+            slog.Warn("failed to prefetch target details", "slug", slug, "err", err)
+        }
+    })
+}
+
+// Scope returns the target's in-scope assets, fetching them when not cached.
+// When a refetch finds the scope changed, the user is notified and the
+// target's details are refetched on next use as well.
+func (c *targetCache) Scope(token, slug string) ([]scopeAsset, error) {
+    c.mu.Lock()
+    e, ok := c.scopes[slug]
+    c.mu.Unlock()
+    if ok && time.Since(e.fetched) < c.scopeTTL {
+        return e.value, nil
+    }
+    scope, err := getTargetScope(token, slug)
+    if err != nil {
+        return nil, err
+    }
+    c.mu.Lock()
+    c.scopes[slug] = cachedValue[[]scopeAsset]{value: scope, fetched: time.Now()}
+    c.mu.Unlock()
+
+    if ok {
+        if added, removed := scopeDiff(e.value, scope); added+removed > 0 {
+            c.mu.Lock()
+            delete(c.details, slug)
+            c.mu.Unlock()
+            notify(EventScopeChanged, "Scope of target %s changed: %d assets added, %d removed.", slug, added, removed)
+        }
+    }
+    return scope, nil
+}
+
+// Invalidate drops everything cached about the target.
+func (c *targetCache) Invalidate(slug string) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    delete(c.details, slug)
+    delete(c.scopes, slug)
+}
+
+// InvalidateMentioned drops every cached target whose codename appears in
+// text, such as an announcement of a scope change.
+func (c *targetCache) InvalidateMentioned(text string) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    text = strings.ToLower(text)
+    for slug, e := range c.details {
+        if e.value.Codename != "" && strings.Contains(text, strings.ToLower(e.value.Codename)) {
+            slog.Debug("target mentioned in announcement, dropping cached details", "slug", slug)
+            delete(c.details, slug)
+            delete(c.scopes, slug)
+        }
+    }
+}
+
+// scopeDiff counts the assets added to and removed from a scope.
+func scopeDiff(before, after []scopeAsset) (added, removed int) {
+    for _, a := range after {
+        if !slices.Contains(before, a) {
+            added++
+        }
+    }
+    for _, a := range before {
+        if !slices.Contains(after, a) {
+            removed++
+        }
+    }
+    return added, removed
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+    "errors"
+    "net/http"
+    "slices"
+    "sync"
+    "testing"
+    "time"
+)
+
+func TestCheckTargetsSignsUpForNewTargets(t *testing.T) {
+    fp := newFakePlatform(t, "token")
+    fp.AddTargets(Target{Slug: "a"}, Target{Slug: "b"})
+    rec := recordNotifications(t)
+    b := newTestBot(t, "token")
+
+    if err := b.checkTargets("token", true); err != nil {
+        t.Fatal(err)
+    }
+
+    if got := fp.SignedUp(); !slices.Equal(got, []string{"a", "b"}) {
+        t.Errorf("signed up for %v, want [a b]", got)
+    }
+    if got := b.store.KnownSlugs(); !slices.Equal(got, []string{"a", "b"}) {
+        t.Errorf("known slugs %v, want [a b]", got)
+    }
+    if !slices.Contains(rec.Types(), EventSignup) {
+        t.Errorf("events %v, want a %s", rec.Types(), EventSignup)
+    }
+}
+
+func TestCheckTargetsRetriesFailedSignupsAfterRestart(t *testing.T) {
+    fp := newFakePlatform(t, "token")
+    fp.AddTargets(Target{Slug: "a"})
+    // Every attempt of the signup's retry policy fails.
+    fp.Fail("signup", http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+    recordNotifications(t)
+    b := newTestBot(t, "token")
+
+    if err := b.checkTargets("token", true); err != nil {
+        t.Fatal(err)
+    }
+    if got := fp.SignedUp(); len(got) > 0 {
+        t.Fatalf("signed up for %v despite 503", got)
+    }
+    if got := b.store.KnownSlugs(); len(got) > 0 {
+        t.Errorf("known slugs %v after a 503 signup, want none", got)
+    }
+
+    // A restarted bot loads the known slugs from the database.
+    b.knownSlugs = &sync.Map{}
+    for _, slug := range b.store.KnownSlugs() {
+        b.knownSlugs.Store(slug, true)
+    }
+    if err := b.checkTargets("token", true); err != nil {
+        t.Fatal(err)
+    }
+    if got := fp.SignedUp(); !slices.Equal(got, []string{"a"}) {
+        t.Errorf("signed up for %v after restart, want [a]", got)
+    }
+}
+
+func TestCheckTargetsRemembersRefusedSignups(t *testing.T) {
+    fp := newFakePlatform(t, "token")
+    fp.AddTargets(Target{Slug: "a"})
+    fp.Fail("signup", http.StatusForbidden)
+    recordNotifications(t)
+    b := newTestBot(t, "token")
+
+    if err := b.checkTargets("token", true); err != nil {
+        t.Fatal(err)
+    }
+    if got := b.store.KnownSlugs(); !slices.Equal(got, []string{"a"}) {
+        t.Errorf("known slugs %v after a refused signup, want [a]", got)
+    }
+}
+
+func TestCheckTargetsReconsidersFilteredTargetsAfterReload(t *testing.T) {
+    fp := newFakePlatform(t, "token")
+    fp.AddTargets(Target{Slug: "a"}, Target{Slug: "b"})
+    recordNotifications(t)
+    b := newTestBot(t, "token")
+    b.cfg.Targets = TargetFilter{DenySlugs: []string{"b"}}
+
+    if err := b.checkTargets("token", true); err != nil {
+        t.Fatal(err)
+    }
+    if got := fp.SignedUp(); !slices.Equal(got, []string{"a"}) {
+        t.Fatalf("signed up for %v, want [a]", got)
+    }
+
+    // A reload drops the denylist.
+    b.cfg.Targets = TargetFilter{}
+    if err := b.checkTargets("token", true); err != nil {
+        t.Fatal(err)
+    }
+    if got := fp.SignedUp(); !slices.Equal(got, []string{"a", "b"}) {
+        t.Errorf("signed up for %v after the reload, want [a b]", got)
+    }
+}
+
+func TestCheckTargetsLeavesTargetsAloneWhileSignupsPaused(t *testing.T) {
+    fp := newFakePlatform(t, "token")
+    fp.AddTargets(Target{Slug: "a"})
+    recordNotifications(t)
+    b := newTestBot(t, "token")
+    b.state.SetSignupsPaused(true)
+
+    if err := b.checkTargets("token", true); err != nil {
+        t.Fatal(err)
+    }
+    if n := fp.Requests("signup"); n > 0 {
+        t.Errorf("%d signup requests while paused", n)
+    }
+}
+
+func TestMainLoopSkipsByTargetCategoryOnceCached(t *testing.T) {
+    web, mobile := testTask("web"), testTask("mobile")
+    web.ListingUid, mobile.ListingUid = "web-target", "mobile-target"
+    fp := newFakePlatform(t, "token", web, mobile)
+    fp.AddTargets(
+        Target{Slug: "web-target", Category: TargetCategory{Name: "Web Application"}},
+        Target{Slug: "mobile-target", Category: TargetCategory{Name: "Mobile"}},
+    )
+    recordNotifications(t)
+    b := newTestBot(t, "token")
+    rules, err := compileClaimRules([]ClaimRule{{Name: "no mobile", If: `target.category == "Mobile"`, Then: "skip"}})
+    if err != nil {
+        t.Fatal(err)
+    }
+    b.claimRules.Set(rules)
+
+    runFor(b, 200*time.Millisecond)
+
+    // Both are skipped until their target's details are cached, then only
+    // the mobile one.
+    if got := fp.Claimed(); !slices.Equal(got, []string{"web"}) {
+        t.Errorf("claimed %v, want [web]", got)
+    }
+    if n := fp.Requests("target_detail"); n != 2 {
+        t.Errorf("%d target detail requests, want one per target", n)
+    }
+}
+
+func TestDecideSignupWaitsWhileSignupsPaused(t *testing.T) {
+    fp := newFakePlatform(t, "token")
+    fp.AddTargets(Target{Slug: "a", Codename: "ALPHA"})
+    recordNotifications(t)
+    b := newTestBot(t, "token")
+    b.approvals.Add(pendingSignup{Target: Target{Slug: "a", Codename: "ALPHA"}, Asked: time.Now(), Expires: time.Now().Add(time.Hour)})
+    b.state.SetSignupsPaused(true)
+
+    if _, err := b.decideSignup("a", true); !errors.Is(err, errSignupBlocked) {
+        t.Fatalf("decideSignup() error = %v, want %v", err, errSignupBlocked)
+    }
+    if n := fp.Requests("signup"); n > 0 {
+        t.Fatalf("%d signup requests while paused", n)
+    }
+
+    b.state.SetSignupsPaused(false)
+    if _, err := b.decideSignup("a", true); err != nil {
+        t.Fatalf("decideSignup() after resuming: %v", err)
+    }
+    if got := fp.SignedUp(); !slices.Equal(got, []string{"a"}) {
+        t.Errorf("signed up for %v, want [a]", got)
+    }
+}
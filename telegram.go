@@ -0,0 +1,259 @@
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "log/slog"
+    "net/http"
+    "net/url"
+    "strings"
+    "time"
+)
+
+// telegramAPI is the Telegram Bot API base URL.
+var telegramAPI = "https://api.telegram.org"
+
+// telegramPollTimeout is the long-polling timeout for getUpdates.
+const telegramPollTimeout = 50 * time.Second
+
+// telegramNotifier sends events to a Telegram chat.
+type telegramNotifier struct {
+    cfg TelegramConfig
+}
+
+func (n telegramNotifier) Notify(ev Event) error {
+    if ev.Type == EventSignupApproval && ev.Target != "" {
+        return telegramAskSignup(n.cfg, ev.Message, ev.Target, ev.Links)
+    }
+    if len(ev.Links) > 0 {
+        return telegramCall(n.cfg, "sendMessage", map[string]interface{}{
+            "chat_id":                  n.cfg.ChatID,
+            "text":                     ev.Message,
+            "disable_web_page_preview": true,
+            "reply_markup":             map[string]interface{}{"inline_keyboard": [][]map[string]string{linkButtons(ev.Links)}},
+        }, nil)
+    }
+    return telegramSend(n.cfg, ev.Message)
+}
+
+// telegramCall invokes a Bot API method with a JSON payload and decodes the result.
+func telegramCall(cfg TelegramConfig, method string, payload interface{}, result interface{}) error {
+    body, err := json.Marshal(payload)
+    if err != nil {
+        return err
+    }
+    u := fmt.Sprintf("%s/bot%s/%s", telegramAPI, cfg.BotToken, method)
+    client := &http.Client{Timeout: telegramPollTimeout + 10*time.Second, Transport: notifyTransport}
+    resp, err := client.Post(u, "application/json", bytes.NewReader(body))
+    if err != nil {
+        // The URL contains the bot token; keep it out of error messages.
+        if uerr, ok := err.(*url.Error); ok {
+            err = uerr.Err
+        }
+        return fmt.Errorf("telegram %s: %v", method, err)
+    }
+    defer closeBody(resp)
+
+    var envelope struct {
+        OK          bool            `json:"ok"`
+        Description string          `json:"description"`
+        Result      json.RawMessage `json:"result"`
+    }
+    if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+        return fmt.Errorf("telegram %s: status code %d", method, resp.StatusCode)
+    }
+    if !envelope.OK {
+        return fmt.Errorf("telegram %s: %s", method, envelope.Description)
+    }
+    if result != nil {
+        return json.Unmarshal(envelope.Result, result)
+    }
+    return nil
+}
+
+// telegramSend posts a text message to the configured chat.
+func telegramSend(cfg TelegramConfig, text string) error {
+    return telegramCall(cfg, "sendMessage", map[string]interface{}{
+        "chat_id":                  cfg.ChatID,
+        "text":                     text,
+        "disable_web_page_preview": true,
+    }, nil)
+}
+
+// Callback data of the signup approval buttons: prefix, "approve" or "deny", slug.
+const signupCallbackPrefix = "signup:"
+
+// telegramAskSignup sends a signup approval request with approve/deny buttons
+// and, below them, buttons opening the target's links.
+func telegramAskSignup(cfg TelegramConfig, text, slug string, links []Link) error {
+    keyboard := [][]map[string]string{{
+        {"text": "Approve", "callback_data": signupCallbackPrefix + "approve:" + slug},
+        {"text": "Deny", "callback_data": signupCallbackPrefix + "deny:" + slug},
+    }}
+    if len(links) > 0 {
+        keyboard = append(keyboard, linkButtons(links))
+    }
+    return telegramCall(cfg, "sendMessage", map[string]interface{}{
+        "chat_id":                  cfg.ChatID,
+        "text":                     text,
+        "disable_web_page_preview": true,
+        "reply_markup":             map[string]interface{}{"inline_keyboard": keyboard},
+    }, nil)
+}
+
+// linkButtons returns a keyboard row of buttons opening the links.
+func linkButtons(links []Link) []map[string]string {
+    var row []map[string]string
+    for _, l := range links {
+        row = append(row, map[string]string{"text": "Open " + l.Name, "url": l.URL})
+    }
+    return row
+}
+
+// telegramMessage is the part of a Telegram message the bot reads.
+type telegramMessage struct {
+    MessageID int64  `json:"message_id"`
+    Text      string `json:"text"`
+    Chat      struct {
+        ID int64 `json:"id"`
+    } `json:"chat"`
+}
+
+// telegramUpdate is the part of a Telegram update the bot reads.
+type telegramUpdate struct {
+    UpdateID int64            `json:"update_id"`
+    Message  *telegramMessage `json:"message"`
+    // CallbackQuery is a press of an inline keyboard button.
+    CallbackQuery *struct {
+        ID      string           `json:"id"`
+        Data    string           `json:"data"`
+        Message *telegramMessage `json:"message"`
+    } `json:"callback_query"`
+}
+
+// runTelegramControl long-polls the Telegram bot for commands from the
+// configured chat: /pause, /resume, /status, /token <jwt>, /release,
+// /approve and /deny, and for presses of the signup approval buttons.
+// Messages from any other chat are ignored.
+func (b *bot) runTelegramControl() {
+    cfg := b.config().Telegram
+    var offset int64
+    for {
+        var updates []telegramUpdate
+        err := telegramCall(cfg, "getUpdates", map[string]interface{}{
+            "offset":          offset,
+            "timeout":         int(telegramPollTimeout.Seconds()),
+            "allowed_updates": []string{"message", "callback_query"},
+        }, &updates)
+        if err != nil {
+            slog.Warn("telegram polling failed", "err", err)
+            time.Sleep(30 * time.Second)
+            continue
+        }
+        for _, u := range updates {
+            offset = u.UpdateID + 1
+            if q := u.CallbackQuery; q != nil {
+                if q.Message != nil && q.Message.Chat.ID == cfg.ChatID {
+                    b.answerTelegramButton(q.ID, q.Data)
+                }
+                continue
+            }
+            if u.Message == nil || u.Message.Chat.ID != cfg.ChatID {
+                continue
+            }
+            reply := b.handleChatCommand(u.Message.Text)
+            if strings.HasPrefix(u.Message.Text, "/token") {
+                // Don't leave the session token in the chat history.
+                if err := telegramCall(cfg, "deleteMessage", map[string]interface{}{
+                    "chat_id":    cfg.ChatID,
+                    "message_id": u.Message.MessageID,
+                }, nil); err != nil {
+                    slog.Warn("failed to delete telegram message containing the token", "err", err)
+                }
+            }
+            if reply != "" {
+                if err := telegramSend(cfg, reply); err != nil {
+                    slog.Warn("failed to answer telegram command", "err", err)
+                }
+            }
+        }
+    }
+}
+
+// answerTelegramButton acts on a signup approval button and answers it.
+func (b *bot) answerTelegramButton(queryID, data string) {
+    reply := "Unknown action."
+    if rest, ok := strings.CutPrefix(data, signupCallbackPrefix); ok {
+        if action, slug, ok := strings.Cut(rest, ":"); ok {
+            reply = b.chatSignupDecision(slug, action == "approve")
+        }
+    }
+    if err := telegramCall(b.config().Telegram, "answerCallbackQuery", map[string]interface{}{
+        "callback_query_id": queryID,
+    }, nil); err != nil {
+        slog.Warn("failed to answer telegram button", "err", err)
+    }
+    if reply != "" {
+        if err := telegramSend(b.config().Telegram, reply); err != nil {
+            slog.Warn("failed to answer telegram button", "err", err)
+        }
+    }
+}
+
+// chatSignupDecision decides a pending signup and returns the reply.
+func (b *bot) chatSignupDecision(slug string, approve bool) string {
+    reply, err := b.decideSignup(slug, approve)
+    if err != nil {
+        return fmt.Sprintf("Failed: %v", err)
+    }
+    return reply
+}
+
+// handleChatCommand executes a control command and returns the reply.
+func (b *bot) handleChatCommand(text string) string {
+    cmd, arg, _ := strings.Cut(strings.TrimSpace(text), " ")
+    // Commands may be addressed as /cmd@botname in groups.
+    cmd, _, _ = strings.Cut(cmd, "@")
+    switch cmd {
+    case "/pause":
+        b.state.Pause(PauseManual, ResumeManual, time.Time{}, "paused via Telegram")
+        return ""
+    case "/resume":
+        if b.state.Paused() == nil {
+            return "Claiming is not paused."
+        }
+        b.state.Resume("resumed via Telegram")
+        return ""
+    case "/status":
+        return b.state.Snapshot().StatusText()
+    case "/token":
+        token := strings.TrimSpace(arg)
+        if token == "" {
+            return "Usage: /token <jwt>"
+        }
+        b.tokens.Set(token)
+        if exp, ok := tokenExpiry(token); ok {
+            return fmt.Sprintf("Token updated, expires in %s.", time.Until(exp).Round(time.Minute))
+        }
+        return "Token updated."
+    case "/release":
+        id := strings.TrimSpace(arg)
+        if id == "" {
+            return "Usage: /release <task id>"
+        }
+        task, err := b.releaseClaimed(id)
+        if err != nil {
+            return fmt.Sprintf("Release failed: %v", err)
+        }
+        return fmt.Sprintf("Released %s (%s).", task.Title, task.ID)
+    case "/approve", "/deny":
+        slug := strings.TrimSpace(arg)
+        if slug == "" {
+            return "Usage: " + cmd + " <target slug>"
+        }
+        return b.chatSignupDecision(slug, cmd == "/approve")
+    default:
+        return "Commands: /pause, /resume, /status, /token <jwt>, /release <task id>, /approve <slug>, /deny <slug>"
+    }
+}
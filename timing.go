@@ -0,0 +1,80 @@
+package main
+
+import (
+    "encoding/json"
+    "net/http"
+    "slices"
+    "sync"
+    "time"
+)
+
+// Poll cycle phases that are timed.
+const (
+    PhaseFetch  = "fetch"  // task poll requests, including retries
+    PhaseDecode = "decode" // reading and decoding task responses
+    PhaseDecide = "decide" // capacity check, category rates and ordering
+    PhaseClaim  = "claim"  // one claim request
+    PhaseCycle  = "cycle"  // a whole poll cycle, without the sleep
+)
+
+// timingSamples is how many recent samples each phase keeps.
+const timingSamples = 200
+
+// phaseTimer keeps recent durations per poll cycle phase, so latency
+// regressions can be diagnosed on a running bot.
+type phaseTimer struct {
+    mu      sync.Mutex
+    samples map[string][]time.Duration
+}
+
+// timings collects the poll cycle phase durations.
+var timings = &phaseTimer{samples: map[string][]time.Duration{}}
+
+// Observe records one duration of phase.
+func (t *phaseTimer) Observe(phase string, d time.Duration) {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    s := append(t.samples[phase], d)
+    if len(s) > timingSamples {
+        s = s[len(s)-timingSamples:]
+    }
+    t.samples[phase] = s
+}
+
+// Since records the time elapsed since start for phase.
+func (t *phaseTimer) Since(phase string, start time.Time) {
+    t.Observe(phase, time.Since(start))
+}
+
+// phaseStats summarizes the recent durations of one phase in milliseconds.
+type phaseStats struct {
+    Count int     `json:"count"`
+    P50   float64 `json:"p50_ms"`
+    P95   float64 `json:"p95_ms"`
+    Max   float64 `json:"max_ms"`
+}
+
+// Stats returns the summary of every phase.
+func (t *phaseTimer) Stats() map[string]phaseStats {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    stats := make(map[string]phaseStats, len(t.samples))
+    for phase, s := range t.samples {
+        sorted := slices.Clone(s)
+        slices.Sort(sorted)
+        ms := func(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 }
+        stats[phase] = phaseStats{
+            Count: len(sorted),
+            P50:   ms(sorted[len(sorted)/2]),
+            P95:   ms(sorted[len(sorted)*95/100]),
+            Max:   ms(sorted[len(sorted)-1]),
+        }
+    }
+    return stats
+}
+
+// ServeHTTP serves the phase statistics as JSON.
+func (t *phaseTimer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(t.Stats())
+}
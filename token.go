@@ -0,0 +1,198 @@
+package main
+
+import (
+    "bytes"
+    "fmt"
+    "log/slog"
+    "os"
+    "sync"
+    "time"
+)
+
+// tokenFilePollInterval is how often the token file is checked for changes.
+const tokenFilePollInterval = 2 * time.Second
+
+// tokenExpiryCheckInterval is how often the current token's lifetime is checked.
+const tokenExpiryCheckInterval = 30 * time.Second
+
+// TokenProvider holds the current session token shared by all loops.
+// Anything that obtains a fresh token (prompt, token daemon, chat command,
+// token file) sets it here and every loop picks it up on its next
+// iteration; nothing hands tokens from one loop to another.
+type TokenProvider interface {
+    // Get returns the current token.
+    Get() string
+    // Set replaces the token and wakes everyone waiting for a new one.
+    Set(token string)
+    // WaitChange blocks until the token differs from old and returns it.
+    WaitChange(old string) string
+}
+
+// tokenStore is the in-memory TokenProvider. Reads don't block each other;
+// waiting for a change is a condition variable, so a new token never has to
+// be received by anyone to be stored.
+type tokenStore struct {
+    mu    sync.RWMutex
+    cond  *sync.Cond
+    token string
+}
+
+// newTokenStore returns a store holding token.
+func newTokenStore(token string) *tokenStore {
+    s := &tokenStore{token: token}
+    s.cond = sync.NewCond(&s.mu)
+    return s
+}
+
+// Get returns the current token.
+func (s *tokenStore) Get() string {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    return s.token
+}
+
+// Set replaces the token and wakes everyone waiting for a new one.
+func (s *tokenStore) Set(token string) {
+    s.mu.Lock()
+    s.token = token
+    s.mu.Unlock()
+    s.cond.Broadcast()
+}
+
+// WaitChange blocks until the token differs from old and returns it.
+func (s *tokenStore) WaitChange(old string) string {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    for s.token == old {
+        s.cond.Wait()
+    }
+    return s.token
+}
+
+// readTokenFile returns the token stored in path, ignoring surrounding whitespace.
+func readTokenFile(path string) (string, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return "", err
+    }
+    token := string(bytes.TrimSpace(data))
+    if token == "" {
+        return "", fmt.Errorf("token file %s is empty", path)
+    }
+    return token, nil
+}
+
+// watchTokenFile checks the token file every few seconds and stores its token
+// whenever the content changes, so a freshly pasted JWT is used without a
+// restart. Unreadable or empty files are skipped until they are valid again.
+func watchTokenFile(path string, tokens TokenProvider) {
+    var lastMod time.Time
+    for {
+        time.Sleep(tokenFilePollInterval)
+        info, err := os.Stat(path)
+        if err != nil || info.ModTime().Equal(lastMod) {
+            continue
+        }
+        lastMod = info.ModTime()
+        token, err := readTokenFile(path)
+        if err != nil {
+            slog.Warn("ignoring token file", "path", path, "err", err)
+            continue
+        }
+        if token != tokens.Get() {
+            tokens.Set(token)
+            slog.Info("loaded new token from file", "path", path)
+        }
+    }
+}
+
+// watchTokenExpiry logs the lifetime of every new token and notifies once per
+// token when it has less than lead left, so it can be refreshed before claims
+// start failing with 401. A lead of zero only logs.
+func watchTokenExpiry(tokens TokenProvider, lead time.Duration) {
+    var current string
+    var exp time.Time
+    var warned bool
+    for {
+        if token := tokens.Get(); token != current {
+            current, warned = token, false
+            var ok bool
+            exp, ok = tokenExpiry(token)
+            if ok {
+                slog.Info("token lifetime", "expires_at", exp.Format(time.RFC3339), "remaining", time.Until(exp).Round(time.Second))
+            } else {
+                slog.Warn("token has no readable exp claim; expiry warnings disabled for it")
+            }
+        }
+        if !exp.IsZero() && !warned && lead > 0 {
+            if left := time.Until(exp); left <= lead && left > 0 {
+                notify(EventTokenExpiring, "Session token expires in %s (at %s). Refresh it to keep claiming.", left.Round(time.Minute), exp.Format("15:04"))
+                warned = true
+            }
+        }
+        time.Sleep(tokenExpiryCheckInterval)
+    }
+}
+
+// tokenSource obtains a new token once the platform rejected the current
+// one. Sources that deliver tokens on their own (token file, browser
+// extension, chat command) set them in the TokenProvider and only wait.
+type tokenSource interface {
+    // Name identifies the source in logs.
+    Name() string
+    // Refresh returns a token other than old, blocking until there is one.
+    Refresh(old string) string
+}
+
+// tokenSource returns where the bot gets a new token from: the token daemon
+// when one is configured, the token file when one is watched, the browser
+// extension when the token listener runs, a Telegram /token command when
+// chat control is enabled, otherwise a prompt.
+func (b *bot) tokenSource() tokenSource {
+    switch {
+    case b.tokenSocket != "":
+        return socketTokenSource{socket: b.tokenSocket}
+    case b.tokenFile != "":
+        return pushedTokenSource{name: "file", tokens: b.tokens, notice: fmt.Sprintf("Paste a new token into %s to continue.", b.tokenFile)}
+    case b.tokenListener != "":
+        return pushedTokenSource{name: "listener", tokens: b.tokens, notice: "Log in to the platform in your browser to push a new token."}
+    case b.config().Telegram.BotToken != "":
+        return pushedTokenSource{name: "telegram", tokens: b.tokens, notice: "Send /token <jwt> to continue."}
+    }
+    return promptTokenSource{}
+}
+
+// socketTokenSource asks the token daemon (-token-socket).
+type socketTokenSource struct {
+    socket string
+}
+
+func (s socketTokenSource) Name() string { return "socket" }
+
+func (s socketTokenSource) Refresh(old string) string {
+    return awaitSocketToken(s.socket, old)
+}
+
+// pushedTokenSource notifies the user how to provide a token and waits
+// for it to be set by whatever receives it.
+type pushedTokenSource struct {
+    name   string
+    tokens TokenProvider
+    notice string
+}
+
+func (s pushedTokenSource) Name() string { return s.name }
+
+func (s pushedTokenSource) Refresh(old string) string {
+    notify(EventTokenExpired, "Session token expired or invalid. %s", s.notice)
+    return s.tokens.WaitChange(old)
+}
+
+// promptTokenSource asks on the terminal, or in the TUI.
+type promptTokenSource struct{}
+
+func (promptTokenSource) Name() string { return "prompt" }
+
+func (promptTokenSource) Refresh(old string) string {
+    return promptToken()
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+    "bufio"
+    "errors"
+    "flag"
+    "fmt"
+    "log/slog"
+    "net"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+)
+
+// The token daemon protocol is line based. A client sends one command per
+// connection and reads one reply line:
+//
+//	GET            -> the current token
+//	INVALID <tok>  -> OK; the daemon refreshes the token if tok is still current
+const (
+    tokenCmdGet     = "GET"
+    tokenCmdInvalid = "INVALID"
+)
+
+// Timings of the token daemon.
+const (
+    tokenCheckInterval  = time.Minute
+    tokenExpiryLeadTime = 2 * time.Minute
+    tokenSocketWait     = 5 * time.Second
+)
+
+// tokenDaemon keeps a single fresh token and serves it to bot instances.
+// New tokens come from the same sources a bot uses: spare tokens, an
+// automatic login, the keychain and the token file, and only without any
+// of these from a prompt.
+type tokenDaemon struct {
+    tokens    *tokenStore
+    refresh   chan struct{}
+    tokenFile string
+    spares    *spareTokens
+    keychain  bool
+    autoLogin bool
+}
+
+// runTokenDaemon implements the token-daemon subcommand.
+func runTokenDaemon(args []string) error {
+    fs := flag.NewFlagSet("token-daemon", flag.ExitOnError)
+    tokenFlag := fs.String("t", "", "Initial session token (taken from the other sources, or prompted for, if empty)")
+    socketFlag := fs.String("socket", defaultTokenSocket(), "Unix socket to serve the token on")
+    tokenFileFlag := fs.String("token-file", "", "File to read the token from; reloaded when it changes")
+    spareTokensFlag := fs.String("spare-tokens", "", "File of further tokens of the account, one per line, switched to when the current one runs out")
+    keychainFlag := fs.Bool("keychain", false, "Load the token from the OS keychain and save new tokens to it")
+    autoLoginFlag := fs.Bool("auto-login", false, "Log in with the credentials saved by \"keychain set-login\" whenever a new token is needed")
+    accountFlag := fs.String("account", "", "Use the keychain entries of this account of the config's accounts list")
+    fs.Parse(args)
+    accountName = *accountFlag
+
+    d := &tokenDaemon{refresh: make(chan struct{}, 1), tokenFile: *tokenFileFlag, keychain: *keychainFlag, autoLogin: *autoLoginFlag}
+    if *spareTokensFlag != "" {
+        spares, err := loadSpareTokens(*spareTokensFlag)
+        if err != nil {
+            return fmt.Errorf("reading spare tokens: %w", err)
+        }
+        d.spares = spares
+    }
+    // The store and the file watcher exist from the start, so a token file
+    // that is empty or unreadable for now is waited for.
+    d.tokens = newTokenStore(*tokenFlag)
+    if d.tokenFile != "" {
+        if d.tokens.Get() == "" {
+            if token, err := readTokenFile(d.tokenFile); err != nil {
+                slog.Warn("token file not usable yet", "path", d.tokenFile, "err", err)
+            } else {
+                d.tokens.Set(token)
+            }
+        }
+        goSafe(func() { watchTokenFile(d.tokenFile, d.tokens) })
+    }
+    if d.tokens.Get() == "" && d.keychain {
+        if token, err := loadKeychainToken(); err == nil {
+            d.tokens.Set(token)
+        }
+    }
+    if d.tokens.Get() == "" {
+        if token := d.next(""); token != d.tokens.Get() {
+            d.tokens.Set(token)
+        }
+    }
+
+    ln, err := listenTokenSocket(*socketFlag)
+    if err != nil {
+        return err
+    }
+    defer ln.Close()
+    slog.Info("token daemon listening", "socket", *socketFlag)
+
+    if d.keychain {
+        goSafe(func() { syncTokensToKeychain(d.tokens) })
+    }
+    goSafe(func() { d.maintain() })
+    for {
+        conn, err := ln.Accept()
+        if err != nil {
+            return err
+        }
+        goSafe(func() { d.serve(conn) })
+    }
+}
+
+// listenTokenSocket listens on the Unix socket at path, replacing a stale
+// socket from a previous run, which would make Listen fail. The socket is
+// created accessible to this user only, and so is the directory of the
+// default socket under the shared temporary directory.
+func listenTokenSocket(path string) (net.Listener, error) {
+    if dir := filepath.Dir(path); dir == privateSocketDir() {
+        if err := preparePrivateDir(dir); err != nil {
+            return nil, fmt.Errorf("%w or pass -socket", err)
+        }
+    }
+    if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+        return nil, err
+    }
+    ln, err := listenPrivateUnix(path)
+    if err != nil {
+        return nil, err
+    }
+    if err := os.Chmod(path, 0600); err != nil {
+        ln.Close()
+        return nil, err
+    }
+    return ln, nil
+}
+
+// maintain refreshes the token when a client reports it invalid or it is about to expire.
+func (d *tokenDaemon) maintain() {
+    ticker := time.NewTicker(tokenCheckInterval)
+    defer ticker.Stop()
+    for {
+        old := d.tokens.Get()
+        select {
+        case <-d.refresh:
+        case <-ticker.C:
+            exp, ok := tokenExpiry(old)
+            if !ok || time.Until(exp) > tokenExpiryLeadTime {
+                continue
+            }
+        }
+        token := d.next(old)
+        if token == "" || token == d.tokens.Get() {
+            continue
+        }
+        d.tokens.Set(token)
+        slog.Info("token refreshed; serving new token")
+    }
+}
+
+// next returns a token other than old: a usable spare, one from an
+// automatic login, or one saved in the keychain since old. Failing those,
+// it waits for the token file to change, or prompts when no source works
+// without a terminal. Other sources are retried every loginRetryInterval.
+func (d *tokenDaemon) next(old string) string {
+    for {
+        if token, ok := d.spares.Next(old, tokenExpiryLeadTime); ok {
+            slog.Info("switched to a spare token", "spares_left", d.spares.Left()-1)
+            return token
+        }
+        if d.autoLogin {
+            creds, err := loadLoginCredentials()
+            if err == nil {
+                var token string
+                if token, err = login(creds); err == nil {
+                    slog.Info("logged in for a new token")
+                    return token
+                }
+            }
+            slog.Error("automatic login failed", "err", err)
+        }
+        if d.keychain {
+            if token, err := loadKeychainToken(); err == nil && token != old && !tokenExpiring(token) {
+                slog.Info("loaded new token from keychain")
+                return token
+            }
+        }
+        switch {
+        case d.tokenFile != "":
+            slog.Info("waiting for a new token in the token file", "path", d.tokenFile)
+            return d.tokens.WaitChange(old)
+        case d.tokenFile == "" && d.spares == nil && !d.autoLogin && !d.keychain:
+            return promptToken()
+        }
+        slog.Warn("no new token yet", "retry_in", loginRetryInterval)
+        time.Sleep(loginRetryInterval)
+    }
+}
+
+// tokenExpiring reports whether token expires within tokenExpiryLeadTime.
+func tokenExpiring(token string) bool {
+    exp, ok := tokenExpiry(token)
+    return ok && time.Until(exp) <= tokenExpiryLeadTime
+}
+
+// serve answers a single client command.
+func (d *tokenDaemon) serve(conn net.Conn) {
+    defer conn.Close()
+    conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+    line, err := bufio.NewReader(conn).ReadString('\n')
+    if err != nil {
+        return
+    }
+    cmd, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+
+    current := d.tokens.Get()
+
+    switch cmd {
+    case tokenCmdGet:
+        fmt.Fprintln(conn, current)
+    case tokenCmdInvalid:
+        if arg == current {
+            select {
+            case d.refresh <- struct{}{}:
+            default: // refresh already pending
+            }
+        }
+        fmt.Fprintln(conn, "OK")
+    default:
+        fmt.Fprintln(conn, "ERR unknown command")
+    }
+}
+
+// defaultTokenSocket is the socket path used when none is given: in the
+// user's runtime directory, or else in privateSocketDir.
+func defaultTokenSocket() string {
+    const name = "synack-mission-bot-token.sock"
+    if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+        return filepath.Join(dir, name)
+    }
+    return filepath.Join(privateSocketDir(), name)
+}
+
+// privateSocketDir is the user's own directory for the default socket in
+// the shared temporary directory.
+func privateSocketDir() string {
+    return filepath.Join(os.TempDir(), fmt.Sprintf("synack-mission-bot-%d", os.Getuid()))
+}
+
+// tokenSocketRequest sends one command to the token daemon and returns its reply.
+func tokenSocketRequest(socket, command string) (string, error) {
+    conn, err := net.DialTimeout("unix", socket, 5*time.Second)
+    if err != nil {
+        return "", err
+    }
+    defer conn.Close()
+    conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+    if _, err := fmt.Fprintln(conn, command); err != nil {
+        return "", err
+    }
+    reply, err := bufio.NewReader(conn).ReadString('\n')
+    if err != nil {
+        return "", err
+    }
+    return strings.TrimSpace(reply), nil
+}
+
+// fetchSocketToken gets the current token from the token daemon.
+func fetchSocketToken(socket string) (string, error) {
+    token, err := tokenSocketRequest(socket, tokenCmdGet)
+    if err != nil {
+        return "", fmt.Errorf("fetching token from %s: %v", socket, err)
+    }
+    if token == "" {
+        return "", fmt.Errorf("token daemon at %s has no token", socket)
+    }
+    return token, nil
+}
+
+// awaitSocketToken reports old as invalid to the token daemon and waits until
+// it serves a different token.
+func awaitSocketToken(socket, old string) string {
+    if _, err := tokenSocketRequest(socket, tokenCmdInvalid+" "+old); err != nil {
+        slog.Warn("failed to report invalid token to token daemon", "socket", socket, "err", err)
+    }
+    slog.Info("waiting for a fresh token from the token daemon", "socket", socket)
+    for {
+        token, err := fetchSocketToken(socket)
+        if err == nil && token != old {
+            return token
+        }
+        if err != nil {
+            slog.Debug("token daemon not ready", "err", err)
+        }
+        time.Sleep(tokenSocketWait)
+    }
+}
@@ -0,0 +1,20 @@
+//go:build !unix
+
+package main
+
+import (
+    "net"
+    "os"
+)
+
+// preparePrivateDir creates dir; the user's temporary directory isn't
+// shared here.
+func preparePrivateDir(dir string) error {
+    return os.MkdirAll(dir, 0700)
+}
+
+// listenPrivateUnix listens on a Unix socket; without a umask, access is
+// limited by the directory's permissions.
+func listenPrivateUnix(path string) (net.Listener, error) {
+    return net.Listen("unix", path)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+    "encoding/base64"
+    "os"
+    "path/filepath"
+    "runtime"
+    "testing"
+    "time"
+)
+
+func TestTokenDaemonRefreshesFromSpareTokens(t *testing.T) {
+    payload := base64.RawURLEncoding.EncodeToString([]byte(`{"exp":4102444800}`))
+    spare := "eyJhbGciOiJIUzI1NiJ9." + payload + ".spare"
+    path := filepath.Join(t.TempDir(), "spares")
+    if err := os.WriteFile(path, []byte(spare+"\n"), 0600); err != nil {
+        t.Fatal(err)
+    }
+    spares, err := loadSpareTokens(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    d := &tokenDaemon{tokens: newTokenStore("old"), spares: spares}
+    // With a usable spare, next must not prompt on stdin.
+    if got := d.next("old"); got != spare {
+        t.Errorf("next() = %q, want the spare token", got)
+    }
+}
+
+func TestTokenDaemonWaitsForTokenFile(t *testing.T) {
+    // The token file was empty at startup; the watcher sets its token later.
+    d := &tokenDaemon{tokens: newTokenStore(""), tokenFile: "token.txt"}
+    go func() {
+        time.Sleep(10 * time.Millisecond)
+        d.tokens.Set("from-file")
+    }()
+    if got := d.next(""); got != "from-file" {
+        t.Errorf("next() = %q, want the token from the file", got)
+    }
+}
+
+func TestTokenSocketIsPrivate(t *testing.T) {
+    if runtime.GOOS == "windows" {
+        t.Skip("no Unix permissions")
+    }
+    path := filepath.Join(t.TempDir(), "token.sock")
+    ln, err := listenTokenSocket(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer ln.Close()
+    info, err := os.Stat(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if perm := info.Mode().Perm(); perm&0077 != 0 {
+        t.Errorf("socket mode %v, want it private", perm)
+    }
+}
@@ -0,0 +1,37 @@
+//go:build unix
+
+package main
+
+import (
+    "errors"
+    "fmt"
+    "net"
+    "os"
+    "syscall"
+)
+
+// preparePrivateDir creates dir for this user only, or checks that an
+// existing one is a directory of this user's that nobody else can use, so
+// another user can't read or replace what is kept in it.
+func preparePrivateDir(dir string) error {
+    if err := os.Mkdir(dir, 0700); err != nil && !errors.Is(err, os.ErrExist) {
+        return err
+    }
+    info, err := os.Lstat(dir)
+    if err != nil {
+        return err
+    }
+    st, ok := info.Sys().(*syscall.Stat_t)
+    if !info.IsDir() || !ok || int(st.Uid) != os.Getuid() || info.Mode().Perm()&0077 != 0 {
+        return fmt.Errorf("%s is not a private directory of this user; remove it", dir)
+    }
+    return nil
+}
+
+// listenPrivateUnix listens on a Unix socket created with mode 0600, so
+// there is no moment in which other users can connect to it.
+func listenPrivateUnix(path string) (net.Listener, error) {
+    old := syscall.Umask(0177)
+    defer syscall.Umask(old)
+    return net.Listen("unix", path)
+}
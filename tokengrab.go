@@ -0,0 +1,236 @@
+package main
+
+import (
+    "bytes"
+    "encoding/binary"
+    "errors"
+    "flag"
+    "fmt"
+    "io/fs"
+    "log/slog"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strings"
+    "time"
+)
+
+// Browser profile files that may hold the platform's session storage:
+// Chrome's LevelDB session and local storage, and Firefox's session restore
+// files and storage databases. Of LevelDB, the write-ahead .log files keep
+// values uncompressed; the .ldb tables are scanned too, as a token moves
+// there once LevelDB compacts the log. Their blocks are often stored
+// uncompressed, and Snappy keeps text without repetitions like a JWT's
+// claims as literals, so the token is usually still readable; one split by
+// compression simply doesn't match.
+var (
+    chromeStorageDirs   = []string{"Session Storage", filepath.Join("Local Storage", "leveldb")}
+    firefoxStorageFiles = []string{
+        filepath.Join("sessionstore-backups", "recovery.jsonlz4"),
+        filepath.Join("sessionstore-backups", "recovery.baklz4"),
+        "sessionstore.jsonlz4",
+        "webappsstore.sqlite",
+        filepath.Join("storage", "default", "https+++platform.synack.com", "ls", "data.sqlite"),
+    }
+)
+
+// jwtPattern matches a JWT (header and claims are base64url JSON objects).
+var jwtPattern = regexp.MustCompile(`eyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)
+
+// grabContext is how far before a JWT the platform's name must appear for
+// it to count as a Synack token rather than some other site's.
+const grabContext = 1024
+
+// mozLz4Magic starts Firefox's LZ4 compressed JSON files.
+var mozLz4Magic = []byte("mozLz40\x00")
+
+// maxMozLz4Size bounds the decompressed size a mozLz4 header may claim,
+// far above any real session file, so a corrupt header can't make the
+// bot allocate gigabytes.
+const maxMozLz4Size = 256 << 20
+
+// runTokenGrab implements "token grab": it finds the newest unexpired
+// session token in a local browser profile and prints it, writes it to a
+// file (for a bot watching it with -token-file) or saves it to the keychain.
+func runTokenGrab(args []string) error {
+    fs := flag.NewFlagSet("token grab", flag.ExitOnError)
+    profileFlag := fs.String("profile", "", "Chrome or Firefox profile directory (e.g. ~/.config/google-chrome/Default)")
+    outFlag := fs.String("out", "", "Write the token to this file (e.g. the bot's -token-file) instead of stdout")
+    keychainFlag := fs.Bool("keychain", false, "Save the token to the OS keychain instead of printing it")
+    accountFlag := fs.String("account", "", "With -keychain, save the token of this account of the config's accounts list")
+    fs.Parse(args)
+    if *profileFlag == "" {
+        fs.Usage()
+        return fmt.Errorf("-profile is required")
+    }
+
+    token, err := grabToken(*profileFlag)
+    if err != nil {
+        return err
+    }
+    exp, _ := tokenExpiry(token)
+    switch {
+    case *keychainFlag:
+        entry := keychainEntry(keychainAccount, *accountFlag)
+        if err := keychainSet(entry, token); err != nil {
+            return err
+        }
+        slog.Info("token saved to keychain", "service", keychainService, "entry", entry, "expires_at", exp.Format(time.RFC3339))
+    case *outFlag != "":
+        if err := os.WriteFile(*outFlag, []byte(token+"\n"), 0600); err != nil {
+            return err
+        }
+        slog.Info("token written", "path", *outFlag, "expires_at", exp.Format(time.RFC3339))
+    default:
+        fmt.Println(token)
+    }
+    return nil
+}
+
+// grabToken returns the unexpired Synack session token with the latest
+// expiry found in a browser profile.
+func grabToken(profile string) (string, error) {
+    files, err := profileStorageFiles(profile)
+    if err != nil {
+        return "", err
+    }
+    if len(files) == 0 {
+        return "", fmt.Errorf("%s does not look like a Chrome or Firefox profile (no session or local storage found)", profile)
+    }
+    var best string
+    var bestExp time.Time
+    var expired int
+    for _, name := range files {
+        data, err := readProfileFile(name)
+        if err != nil {
+            slog.Debug("skipping profile file", "path", name, "err", err)
+            continue
+        }
+        for _, token := range synackTokens(data) {
+            exp, ok := tokenExpiry(token)
+            if !ok {
+                continue
+            }
+            if time.Now().After(exp) {
+                expired++
+                continue
+            }
+            if exp.After(bestExp) {
+                best, bestExp = token, exp
+            }
+        }
+    }
+    if best == "" {
+        if expired > 0 {
+            return "", fmt.Errorf("only expired session tokens found in %s; log in to the platform in the browser first", profile)
+        }
+        return "", fmt.Errorf("no session token found in %s; log in to the platform in the browser first", profile)
+    }
+    return best, nil
+}
+
+// profileStorageFiles lists the storage files of a Chrome or Firefox
+// profile that exist.
+func profileStorageFiles(profile string) ([]string, error) {
+    if _, err := os.Stat(profile); err != nil {
+        return nil, err
+    }
+    var files []string
+    for _, dir := range chromeStorageDirs {
+        filepath.WalkDir(filepath.Join(profile, dir), func(p string, d fs.DirEntry, err error) error {
+            if err == nil && d.Type().IsRegular() && (strings.HasSuffix(p, ".log") || strings.HasSuffix(p, ".ldb")) {
+                files = append(files, p)
+            }
+            return nil
+        })
+    }
+    for _, f := range firefoxStorageFiles {
+        if _, err := os.Stat(filepath.Join(profile, f)); err == nil {
+            files = append(files, filepath.Join(profile, f))
+        }
+    }
+    return files, nil
+}
+
+// readProfileFile reads a storage file, decompressing Firefox's mozLz4 files.
+func readProfileFile(name string) ([]byte, error) {
+    data, err := os.ReadFile(name)
+    if err != nil || !bytes.HasPrefix(data, mozLz4Magic) {
+        return data, err
+    }
+    if len(data) < len(mozLz4Magic)+4 {
+        return nil, errors.New("truncated mozLz4 file")
+    }
+    size := binary.LittleEndian.Uint32(data[len(mozLz4Magic):])
+    if size > maxMozLz4Size {
+        return nil, fmt.Errorf("mozLz4 file claims %d bytes, more than the %d allowed", size, maxMozLz4Size)
+    }
+    return lz4Block(data[len(mozLz4Magic)+4:], int(size))
+}
+
+// synackTokens returns the JWTs in data that are stored under a Synack key
+// or origin.
+func synackTokens(data []byte) []string {
+    var tokens []string
+    for _, m := range jwtPattern.FindAllIndex(data, -1) {
+        before := data[max(0, m[0]-grabContext):m[0]]
+        if bytes.Contains(bytes.ToLower(before), []byte("synack")) {
+            tokens = append(tokens, string(data[m[0]:m[1]]))
+        }
+    }
+    return tokens
+}
+
+// lz4Block decompresses a raw LZ4 block of at most size bytes.
+func lz4Block(src []byte, size int) ([]byte, error) {
+    errCorrupt := errors.New("corrupt LZ4 block")
+    dst := make([]byte, 0, size)
+    // length reads a length that continues in further bytes while they are 255.
+    length := func(i, n int) (int, int, error) {
+        if n != 15 {
+            return i, n, nil
+        }
+        for {
+            if i >= len(src) {
+                return i, 0, errCorrupt
+            }
+            b := src[i]
+            i++
+            n += int(b)
+            if b != 255 {
+                return i, n, nil
+            }
+        }
+    }
+    for i := 0; i < len(src); {
+        token := src[i]
+        var lit, match int
+        var err error
+        i, lit, err = length(i+1, int(token>>4))
+        if err != nil || i+lit > len(src) || len(dst)+lit > size {
+            return nil, errCorrupt
+        }
+        dst = append(dst, src[i:i+lit]...)
+        i += lit
+        if i == len(src) {
+            break
+        }
+        if i+2 > len(src) {
+            return nil, errCorrupt
+        }
+        offset := int(src[i]) | int(src[i+1])<<8
+        i += 2
+        i, match, err = length(i, int(token&15))
+        if err != nil || offset == 0 || offset > len(dst) || len(dst)+match+4 > size {
+            return nil, errCorrupt
+        }
+        start := len(dst) - offset
+        for k := range match + 4 { // copies may overlap the output they extend
+            dst = append(dst, dst[start+k])
+        }
+        if i == len(src) {
+            break
+        }
+    }
+    return dst, nil
+}
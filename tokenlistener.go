@@ -0,0 +1,249 @@
+package main
+
+import (
+    "crypto/subtle"
+    "encoding/json"
+    "fmt"
+    "io"
+    "log/slog"
+    "net"
+    "net/http"
+    "os"
+    "strings"
+    "time"
+)
+
+// maxTokenBody limits the size of a pushed token.
+const maxTokenBody = 16 << 10
+
+// tokenListener accepts fresh tokens pushed by a browser extension or
+// userscript over a local HTTP endpoint and streams the bot's notifications
+// back to it:
+//
+//	POST /token   body: the raw JWT, or {"token": "<jwt>"}
+//	GET /events   server-sent "notification" events
+//
+// Every request must name a loopback host, so a web page can't reach it
+// through DNS rebinding, and carry the listener secret as "Authorization:
+// Bearer <secret>" or, for an EventSource that can't set headers, as the
+// "secret" query parameter. Requests carrying an Origin header are only
+// accepted from browser extensions, so ordinary web pages can't push tokens
+// of their own or read the notifications.
+type tokenListener struct {
+    tokens TokenProvider
+    events *eventHub
+    secret string
+}
+
+// readListenerSecret reads the token listener secret from path, falling
+// back to the MISSION_BOT_LISTENER_SECRET environment variable.
+func readListenerSecret(path string) (string, error) {
+    if path != "" {
+        data, err := os.ReadFile(path)
+        if err != nil {
+            return "", err
+        }
+        secret := strings.TrimSpace(string(data))
+        registerSecret(secret)
+        return secret, nil
+    }
+    return os.Getenv("MISSION_BOT_LISTENER_SECRET"), nil
+}
+
+// extensionEvents fans notifications out to the token listener's event
+// streams.
+var extensionEvents = &eventHub{subs: map[chan Event]struct{}{}}
+
+// runTokenListener serves the token endpoints on ln until the listener fails.
+func runTokenListener(ln net.Listener, tokens TokenProvider, events *eventHub, secret string) {
+    addr := ln.Addr().String()
+    if tcp, ok := ln.Addr().(*net.TCPAddr); ok && !tcp.IP.IsLoopback() {
+        slog.Warn("token listener is not bound to a loopback address; requests naming other hosts are refused", "addr", addr)
+    }
+    l := &tokenListener{tokens: tokens, events: events, secret: secret}
+    mux := http.NewServeMux()
+    mux.Handle("/token", l)
+    mux.HandleFunc("/events", l.serveEvents)
+    srv := &http.Server{
+        Handler:           mux,
+        ReadHeaderTimeout: 10 * time.Second,
+        ErrorLog:          redactedErrorLog(),
+    }
+    slog.Info("token listener started", "addr", addr)
+    if err := srv.Serve(ln); err != nil {
+        slog.Error("token listener stopped", "addr", addr, "err", err)
+    }
+}
+
+// allow checks a request's host, origin and, except for CORS preflights,
+// which browsers send without credentials, the secret. It reports whether
+// the request may proceed.
+func (l *tokenListener) allow(w http.ResponseWriter, r *http.Request, method string) bool {
+    if !isLoopbackHost(r.Host) {
+        http.Error(w, "host not allowed", http.StatusForbidden)
+        return false
+    }
+    if !allowOrigin(w, r, method) {
+        return false
+    }
+    if r.Method == http.MethodOptions {
+        return true
+    }
+    got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+    if !ok {
+        got = r.URL.Query().Get("secret")
+    }
+    if got == "" || subtle.ConstantTimeCompare([]byte(got), []byte(l.secret)) != 1 {
+        http.Error(w, "unauthorized", http.StatusUnauthorized)
+        return false
+    }
+    return true
+}
+
+// isLoopbackHost reports whether a request's Host header names localhost
+// or a loopback address.
+func isLoopbackHost(host string) bool {
+    if h, _, err := net.SplitHostPort(host); err == nil {
+        host = h
+    }
+    host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+    if strings.EqualFold(host, "localhost") {
+        return true
+    }
+    ip := net.ParseIP(host)
+    return ip != nil && ip.IsLoopback()
+}
+
+// allowOrigin rejects requests from web pages and sets the CORS headers for
+// browser extensions allowed to use method. It reports whether the request
+// may proceed.
+func allowOrigin(w http.ResponseWriter, r *http.Request, method string) bool {
+    origin := r.Header.Get("Origin")
+    if origin != "" && !isExtensionOrigin(origin) {
+        http.Error(w, "origin not allowed", http.StatusForbidden)
+        return false
+    }
+    if origin != "" {
+        w.Header().Set("Access-Control-Allow-Origin", origin)
+        w.Header().Set("Access-Control-Allow-Methods", method)
+        w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+    }
+    return true
+}
+
+// ServeHTTP implements http.Handler.
+func (l *tokenListener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    if !l.allow(w, r, http.MethodPost) {
+        return
+    }
+    switch r.Method {
+    case http.MethodOptions:
+        w.WriteHeader(http.StatusNoContent)
+        return
+    case http.MethodPost:
+    default:
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    body, err := io.ReadAll(io.LimitReader(r.Body, maxTokenBody))
+    if err != nil {
+        http.Error(w, "failed to read body", http.StatusBadRequest)
+        return
+    }
+    token := strings.TrimSpace(string(body))
+    if strings.HasPrefix(token, "{") {
+        var payload struct {
+            Token string `json:"token"`
+        }
+        if err := json.Unmarshal(body, &payload); err != nil {
+            http.Error(w, "invalid JSON", http.StatusBadRequest)
+            return
+        }
+        token = strings.TrimSpace(payload.Token)
+    }
+    token = strings.TrimPrefix(token, "Bearer ")
+    if _, err := decodeTokenClaims(token); err != nil {
+        slog.Warn("rejected pushed token", "remote", r.RemoteAddr, "err", err)
+        http.Error(w, "not a valid JWT", http.StatusBadRequest)
+        return
+    }
+
+    if token != l.tokens.Get() {
+        l.tokens.Set(token)
+        slog.Info("received new token from token listener", "remote", r.RemoteAddr)
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// extensionNotification is a notification as the token listener streams it.
+type extensionNotification struct {
+    Type    string            `json:"type"`
+    Message string            `json:"message"`
+    Time    time.Time         `json:"time"`
+    TaskID  string            `json:"taskId,omitempty"`
+    Target  string            `json:"target,omitempty"`
+    Links   map[string]string `json:"links,omitempty"`
+}
+
+// newExtensionNotification converts ev for the extension.
+func newExtensionNotification(ev Event) extensionNotification {
+    n := extensionNotification{Type: ev.Type, Message: ev.Message, Time: ev.Time, Target: ev.Target, Links: ev.linkMap()}
+    if ev.Task != nil {
+        n.TaskID = ev.Task.ID
+        if n.Target == "" {
+            n.Target = ev.Task.ListingCodename
+        }
+    }
+    return n
+}
+
+// serveEvents streams every notification (claims, signups, token expiry...)
+// as server-sent "notification" events, so the extension can show them in
+// the browser.
+func (l *tokenListener) serveEvents(w http.ResponseWriter, r *http.Request) {
+    if !l.allow(w, r, http.MethodGet) {
+        return
+    }
+    if r.Method != http.MethodGet {
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    flusher, ok := w.(http.Flusher)
+    if !ok {
+        http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("Content-Type", "text/event-stream")
+    w.Header().Set("Cache-Control", "no-cache")
+    events, unsubscribe := l.events.subscribe()
+    defer unsubscribe()
+    // An initial comment tells the client the stream is open.
+    fmt.Fprint(w, ": connected\n\n")
+    flusher.Flush()
+    for {
+        select {
+        case <-r.Context().Done():
+            return
+        case ev := <-events:
+            data, err := json.Marshal(newExtensionNotification(ev))
+            if err != nil {
+                continue
+            }
+            if _, err := fmt.Fprintf(w, "event: notification\ndata: %s\n\n", data); err != nil {
+                return
+            }
+            flusher.Flush()
+        }
+    }
+}
+
+// isExtensionOrigin reports whether origin belongs to a browser extension.
+func isExtensionOrigin(origin string) bool {
+    for _, scheme := range []string{"chrome-extension://", "moz-extension://", "safari-web-extension://"} {
+        if strings.HasPrefix(origin, scheme) {
+            return true
+        }
+    }
+    return false
+}
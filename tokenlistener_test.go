@@ -0,0 +1,65 @@
+package main
+
+import (
+    "encoding/base64"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+)
+
+func TestTokenListenerRequiresLoopbackHostAndSecret(t *testing.T) {
+    tokens := newTokenStore("old")
+    l := &tokenListener{tokens: tokens, events: &eventHub{subs: map[chan Event]struct{}{}}, secret: "listener-secret"}
+    payload := base64.RawURLEncoding.EncodeToString([]byte(`{"exp":4102444800}`))
+    jwt := "eyJhbGciOiJIUzI1NiJ9." + payload + ".sig"
+
+    tests := []struct {
+        name   string
+        method string
+        host   string
+        origin string
+        auth   string
+        want   int
+    }{
+        {name: "extension with the secret", method: "POST", host: "127.0.0.1:8777", origin: "chrome-extension://abc", auth: "Bearer listener-secret", want: http.StatusNoContent},
+        {name: "localhost", method: "POST", host: "localhost:8777", auth: "Bearer listener-secret", want: http.StatusNoContent},
+        {name: "IPv6 loopback", method: "POST", host: "[::1]:8777", auth: "Bearer listener-secret", want: http.StatusNoContent},
+        {name: "no secret", method: "POST", host: "127.0.0.1:8777", want: http.StatusUnauthorized},
+        {name: "wrong secret", method: "POST", host: "127.0.0.1:8777", auth: "Bearer guess", want: http.StatusUnauthorized},
+        {name: "rebound host", method: "POST", host: "attacker.example:8777", auth: "Bearer listener-secret", want: http.StatusForbidden},
+        {name: "web page", method: "POST", host: "127.0.0.1:8777", origin: "https://attacker.example", auth: "Bearer listener-secret", want: http.StatusForbidden},
+        {name: "preflight without credentials", method: "OPTIONS", host: "127.0.0.1:8777", origin: "chrome-extension://abc", want: http.StatusNoContent},
+    }
+    for _, tt := range tests {
+        r := httptest.NewRequest(tt.method, "/token", strings.NewReader(jwt))
+        r.Host = tt.host
+        if tt.origin != "" {
+            r.Header.Set("Origin", tt.origin)
+        }
+        if tt.auth != "" {
+            r.Header.Set("Authorization", tt.auth)
+        }
+        w := httptest.NewRecorder()
+        l.ServeHTTP(w, r)
+        if w.Code != tt.want {
+            t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.want)
+        }
+    }
+
+    for _, tt := range []struct {
+        name, host, query string
+        want              int
+    }{
+        {"no secret", "127.0.0.1:8777", "", http.StatusUnauthorized},
+        {"rebound host", "attacker.example", "?secret=listener-secret", http.StatusForbidden},
+    } {
+        r := httptest.NewRequest("GET", "/events"+tt.query, nil)
+        r.Host = tt.host
+        w := httptest.NewRecorder()
+        l.serveEvents(w, r)
+        if w.Code != tt.want {
+            t.Errorf("events, %s: status %d, want %d", tt.name, w.Code, tt.want)
+        }
+    }
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+    "bufio"
+    "crypto/tls"
+    "crypto/x509"
+    "flag"
+    "fmt"
+    "log/slog"
+    "net/http"
+    "os"
+    "runtime"
+    "strings"
+    "time"
+)
+
+// clipboardCommands read the clipboard's text, per GOOS; the first one
+// available is used.
+var clipboardCommands = map[string][][]string{
+    "darwin":  {{"pbpaste"}},
+    "windows": {{"powershell", "-NoProfile", "-Command", "Get-Clipboard"}},
+    "linux":   {{"wl-paste", "--no-newline"}, {"xclip", "-selection", "clipboard", "-o"}, {"xsel", "--clipboard", "--output"}},
+}
+
+// readClipboard returns the text on the clipboard.
+func readClipboard() (string, error) {
+    var errs []string
+    for _, cmd := range clipboardCommands[runtime.GOOS] {
+        text, err := runKeychainTool("", cmd[0], cmd[1:]...)
+        if err == nil {
+            return text, nil
+        }
+        errs = append(errs, err.Error())
+    }
+    if len(errs) == 0 {
+        return "", fmt.Errorf("reading the clipboard is not supported on %s", runtime.GOOS)
+    }
+    return "", fmt.Errorf("reading the clipboard: %s", strings.Join(errs, "; "))
+}
+
+// runToken implements the "token" subcommand. "token push" sends a session
+// token to the control API of a bot running elsewhere, e.g. on a VPS.
+func runToken(args []string) error {
+    if len(args) > 0 && args[0] == "grab" {
+        return runTokenGrab(args[1:])
+    }
+    if len(args) == 0 || args[0] != "push" {
+        return fmt.Errorf("usage: %s token push -host <host:port> [-clipboard] | token grab -profile <dir>", os.Args[0])
+    }
+    fs := flag.NewFlagSet("token push", flag.ExitOnError)
+    hostFlag := fs.String("host", "", "Address of the remote bot's control API (host:port)")
+    controlTokenFlag := fs.String("control-token-file", "", "File containing the remote control API token (or MISSION_BOT_CONTROL_TOKEN)")
+    clipboardFlag := fs.Bool("clipboard", false, "Take the session token from the clipboard instead of stdin")
+    caFlag := fs.String("ca-file", "", "PEM certificate to trust for the control API (e.g. its self-signed certificate)")
+    plainFlag := fs.Bool("plain-http", false, "Use plain HTTP, e.g. through an SSH tunnel to a loopback control API")
+    fs.Parse(args[1:])
+    if *hostFlag == "" {
+        fs.Usage()
+        return fmt.Errorf("-host is required")
+    }
+    controlToken, err := readControlToken(*controlTokenFlag)
+    if err != nil {
+        return err
+    }
+    if controlToken == "" {
+        return fmt.Errorf("a control token is required in -control-token-file or MISSION_BOT_CONTROL_TOKEN")
+    }
+
+    var token string
+    if *clipboardFlag {
+        if token, err = readClipboard(); err != nil {
+            return err
+        }
+    } else {
+        fmt.Fprint(os.Stderr, "Paste your session token:\n> ")
+        line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+        if strings.TrimSpace(line) == "" && err != nil {
+            return err
+        }
+        token = line
+    }
+    token = strings.TrimSpace(token)
+    if _, err := decodeTokenClaims(token); err != nil {
+        return fmt.Errorf("not a session token: %v", err)
+    }
+    if exp, ok := tokenExpiry(token); ok && time.Now().After(exp) {
+        return fmt.Errorf("the token expired at %s; copy a fresh one", exp.Local().Format("15:04"))
+    }
+
+    transport := &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: &tls.Config{MinVersion: tls.VersionTLS12}}
+    if *caFlag != "" {
+        pem, err := os.ReadFile(*caFlag)
+        if err != nil {
+            return err
+        }
+        pool := x509.NewCertPool()
+        if !pool.AppendCertsFromPEM(pem) {
+            return fmt.Errorf("no certificates found in %s", *caFlag)
+        }
+        transport.TLSClientConfig.RootCAs = pool
+    }
+    scheme := "https"
+    if *plainFlag {
+        scheme = "http"
+    }
+    req, err := http.NewRequest("POST", scheme+"://"+*hostFlag+"/token", strings.NewReader(token))
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Authorization", "Bearer "+controlToken)
+    req.Header.Set("Content-Type", "text/plain")
+    req.Header.Set(controlHeader, "1")
+    client := &http.Client{Transport: transport, Timeout: 30 * time.Second}
+    resp, err := client.Do(req)
+    if err != nil {
+        return err
+    }
+    defer closeBody(resp)
+    if resp.StatusCode != http.StatusNoContent {
+        return fmt.Errorf("control API answered %s", resp.Status)
+    }
+    slog.Info("token pushed", "host", *hostFlag)
+    return nil
+}
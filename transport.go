@@ -0,0 +1,177 @@
+package main
+
+import (
+    "crypto/sha256"
+    "crypto/tls"
+    "crypto/x509"
+    "encoding/base64"
+    "encoding/hex"
+    "fmt"
+    "io"
+    "net"
+    "net/http"
+    "net/url"
+    "os"
+    "slices"
+    "strings"
+    "sync"
+    "time"
+)
+
+// Connection settings of the shared HTTP client. Claiming is a race, so
+// connections to the platform are kept warm rather than re-handshaking.
+const (
+    requestTimeout      = 30 * time.Second
+    dialTimeout         = 10 * time.Second
+    tlsHandshakeTimeout = 10 * time.Second
+    idleConnTimeout     = 5 * time.Minute
+    maxIdleConnsPerHost = 8
+    maxDrainBytes       = 64 << 10
+)
+
+var (
+    sharedClient     *http.Client
+    sharedClientOnce sync.Once
+)
+
+// globalHTTPClient returns the HTTP client shared by all requests. It is built
+// on first use, so TLS and proxy settings must be configured before that.
+func globalHTTPClient() *http.Client {
+    sharedClientOnce.Do(func() {
+        sharedClient = newHTTPClient()
+    })
+    return sharedClient
+}
+
+// newHTTPClient builds a pooling client with keep-alives and HTTP/2.
+func newHTTPClient() *http.Client {
+    dialer := &net.Dialer{Timeout: dialTimeout, KeepAlive: 30 * time.Second}
+    tr := &http.Transport{
+        Proxy:               proxyFunc,
+        DialContext:         dialer.DialContext,
+        TLSClientConfig:     tlsConfig.Clone(),
+        TLSHandshakeTimeout: tlsHandshakeTimeout,
+        // A custom TLS config disables HTTP/2 unless explicitly requested.
+        ForceAttemptHTTP2:   true,
+        MaxIdleConns:        4 * maxIdleConnsPerHost,
+        MaxIdleConnsPerHost: maxIdleConnsPerHost,
+        IdleConnTimeout:     idleConnTimeout,
+    }
+    return &http.Client{Transport: tr, Timeout: requestTimeout}
+}
+
+// closeBody drains and closes a response body so the connection can be reused.
+func closeBody(resp *http.Response) {
+    io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainBytes))
+    resp.Body.Close()
+}
+
+// proxyFunc selects the proxy for each request. By default the standard
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables are honored.
+var proxyFunc = http.ProxyFromEnvironment
+
+// configureProxy routes all traffic through the given proxy URL. Supported
+// schemes are http, https, socks5 and socks5h (DNS resolved by the proxy).
+// An empty value keeps the environment based default.
+func configureProxy(raw string) error {
+    if raw == "" {
+        return nil
+    }
+    u, err := url.Parse(raw)
+    if err != nil {
+        return fmt.Errorf("invalid proxy URL %q: %v", raw, err)
+    }
+    switch u.Scheme {
+    case "http", "https", "socks5", "socks5h":
+    default:
+        return fmt.Errorf("unsupported proxy scheme %q (want http, https, socks5 or socks5h)", u.Scheme)
+    }
+    proxyFunc = http.ProxyURL(u)
+    return nil
+}
+
+// tlsConfig is used by every connection to the platform. It verifies
+// certificates against the system roots unless configured otherwise.
+var tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+
+// configureTLS sets up certificate verification. caBundle adds a PEM file of
+// trusted CAs (e.g. an intercepting proxy's CA) to the system roots, pins is a
+// comma separated list of SHA-256 SubjectPublicKeyInfo hashes (base64 or hex)
+// of which one must appear in the server's chain, and insecure disables
+// certificate verification entirely.
+func configureTLS(insecure bool, caBundle, pins string) error {
+    cfg := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: insecure}
+
+    if caBundle != "" {
+        pem, err := os.ReadFile(caBundle)
+        if err != nil {
+            return err
+        }
+        roots, err := x509.SystemCertPool()
+        if err != nil {
+            roots = x509.NewCertPool()
+        }
+        if !roots.AppendCertsFromPEM(pem) {
+            return fmt.Errorf("no certificates found in %s", caBundle)
+        }
+        cfg.RootCAs = roots
+    }
+
+    if pins != "" {
+        var pinned [][]byte
+        for _, p := range strings.Split(pins, ",") {
+            pin, err := decodePin(strings.TrimSpace(p))
+            if err != nil {
+                return err
+            }
+            pinned = append(pinned, pin)
+        }
+        cfg.VerifyConnection = verifyPins(pinned, insecure)
+    }
+
+    tlsConfig = cfg
+    return nil
+}
+
+// verifyPins returns a VerifyConnection check requiring a pinned key in
+// the verified chain. The certificates the server sent beyond the leaf
+// prove nothing on their own: anyone can append a public pinned
+// certificate to a chain of theirs. Without verification (-insecure), only
+// the leaf's key counts.
+func verifyPins(pinned [][]byte, insecure bool) func(tls.ConnectionState) error {
+    matches := func(cert *x509.Certificate) bool {
+        sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+        for _, pin := range pinned {
+            if string(sum[:]) == string(pin) {
+                return true
+            }
+        }
+        return false
+    }
+    return func(cs tls.ConnectionState) error {
+        if insecure {
+            if len(cs.PeerCertificates) > 0 && matches(cs.PeerCertificates[0]) {
+                return nil
+            }
+            return fmt.Errorf("the certificate of %s doesn't match a pinned key", cs.ServerName)
+        }
+        for _, chain := range cs.VerifiedChains {
+            if slices.ContainsFunc(chain, matches) {
+                return nil
+            }
+        }
+        return fmt.Errorf("no certificate in the verified chain for %s matches a pinned key", cs.ServerName)
+    }
+}
+
+// decodePin parses a SHA-256 pin given in base64 (HPKP style) or hex.
+func decodePin(s string) ([]byte, error) {
+    s = strings.TrimPrefix(s, "sha256/")
+    if b, err := hex.DecodeString(s); err == nil && len(b) == sha256.Size {
+        return b, nil
+    }
+    if b, err := base64.StdEncoding.DecodeString(s); err == nil && len(b) == sha256.Size {
+        return b, nil
+    }
+    return nil, fmt.Errorf("invalid SHA-256 pin %q", s)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+    "crypto/sha256"
+    "crypto/tls"
+    "crypto/x509"
+    "testing"
+)
+
+func TestVerifyPinsIgnoresAppendedCertificates(t *testing.T) {
+    pinnedCert := &x509.Certificate{RawSubjectPublicKeyInfo: []byte("platform intermediate key")}
+    leaf := &x509.Certificate{RawSubjectPublicKeyInfo: []byte("platform leaf key")}
+    forgedLeaf := &x509.Certificate{RawSubjectPublicKeyInfo: []byte("attacker leaf key")}
+    forgedRoot := &x509.Certificate{RawSubjectPublicKeyInfo: []byte("attacker root key")}
+    sum := sha256.Sum256(pinnedCert.RawSubjectPublicKeyInfo)
+    pinned := [][]byte{sum[:]}
+
+    genuine := tls.ConnectionState{
+        ServerName:       "platform.synack.com",
+        PeerCertificates: []*x509.Certificate{leaf, pinnedCert},
+        VerifiedChains:   [][]*x509.Certificate{{leaf, pinnedCert}},
+    }
+    // The attacker's leaf verifies against a root they control, and the
+    // public pinned certificate is appended to the chain it sends.
+    forged := tls.ConnectionState{
+        ServerName:       "platform.synack.com",
+        PeerCertificates: []*x509.Certificate{forgedLeaf, pinnedCert},
+        VerifiedChains:   [][]*x509.Certificate{{forgedLeaf, forgedRoot}},
+    }
+    if err := verifyPins(pinned, false)(genuine); err != nil {
+        t.Errorf("genuine chain: %v", err)
+    }
+    if err := verifyPins(pinned, false)(forged); err == nil {
+        t.Error("forged chain with the pinned certificate appended passed")
+    }
+
+    // Without verification, only the leaf counts.
+    if err := verifyPins(pinned, true)(forged); err == nil {
+        t.Error("-insecure: forged chain with the pinned certificate appended passed")
+    }
+    pinnedLeaf := tls.ConnectionState{PeerCertificates: []*x509.Certificate{pinnedCert}}
+    if err := verifyPins(pinned, true)(pinnedLeaf); err != nil {
+        t.Errorf("-insecure: pinned leaf: %v", err)
+    }
+}
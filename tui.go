@@ -0,0 +1,225 @@
+package main
+
+import (
+    "fmt"
+    "os"
+    "os/exec"
+    "strings"
+    "sync"
+    "time"
+)
+
+// Rows shown per dashboard pane.
+const (
+    tuiPaneRows = 8
+    tuiLogRows  = 10
+)
+
+// tui is a terminal dashboard showing the bot state, refreshed every second.
+// Keys: p pauses/resumes claiming, q quits. While a token prompt is active,
+// typed characters are collected into the new token instead.
+type tui struct {
+    state *botState
+
+    mu       sync.Mutex
+    prompt   bool
+    line     []byte
+    lineDone chan string
+}
+
+// activeTUI is set while the dashboard owns the terminal, so token prompts are
+// routed through it instead of reading stdin directly.
+var activeTUI *tui
+
+// runTUI takes over the terminal until the user quits or done is closed.
+func runTUI(state *botState, done <-chan struct{}) {
+    t := &tui{state: state, lineDone: make(chan string)}
+    restore := setTerminalCbreak()
+    defer restore()
+
+    activeTUI = t
+    defer func() { activeTUI = nil }()
+
+    quit := make(chan struct{})
+    goSafe(func() { t.readKeys(quit) })
+
+    ticker := time.NewTicker(time.Second)
+    defer ticker.Stop()
+    for {
+        t.render()
+        select {
+        case <-quit:
+            return
+        case <-done:
+            t.render()
+            return
+        case <-ticker.C:
+        }
+    }
+}
+
+// setTerminalCbreak switches the terminal to unbuffered, no-echo input so single
+// key presses are delivered. It returns a func restoring the previous mode. On
+// systems without stty, keys need to be followed by Enter.
+func setTerminalCbreak() func() {
+    saved, err := stty("-g")
+    if err != nil {
+        return func() {}
+    }
+    if _, err := stty("-icanon", "-echo", "min", "1"); err != nil {
+        return func() {}
+    }
+    return func() {
+        stty(strings.TrimSpace(saved))
+        fmt.Print("\033[?25h")
+    }
+}
+
+// stty runs stty against the controlling terminal.
+func stty(args ...string) (string, error) {
+    cmd := exec.Command("stty", args...)
+    cmd.Stdin = os.Stdin
+    out, err := cmd.Output()
+    return string(out), err
+}
+
+// readKeys handles key presses until q is pressed.
+func (t *tui) readKeys(quit chan<- struct{}) {
+    buf := make([]byte, 1)
+    for {
+        if n, err := os.Stdin.Read(buf); err != nil || n == 0 {
+            return
+        }
+        c := buf[0]
+
+        t.mu.Lock()
+        if t.prompt {
+            switch c {
+            case '\n', '\r':
+                line := string(t.line)
+                t.prompt, t.line = false, nil
+                t.mu.Unlock()
+                t.lineDone <- strings.TrimSpace(line)
+                continue
+            case 127, 8:
+                if len(t.line) > 0 {
+                    t.line = t.line[:len(t.line)-1]
+                }
+            default:
+                t.line = append(t.line, c)
+            }
+            t.mu.Unlock()
+            continue
+        }
+        t.mu.Unlock()
+
+        switch c {
+        case 'p', 'P':
+            if t.state.Paused() != nil {
+                t.state.Resume("resumed from the dashboard")
+            } else {
+                t.state.Pause(PauseManual, ResumeManual, time.Time{}, "paused from the dashboard")
+            }
+            t.render()
+        case 'q', 'Q':
+            close(quit)
+            return
+        }
+    }
+}
+
+// promptToken asks for a new token inside the dashboard and waits for it.
+func (t *tui) promptToken() string {
+    t.mu.Lock()
+    t.prompt, t.line = true, nil
+    t.mu.Unlock()
+    t.render()
+    return <-t.lineDone
+}
+
+// render redraws the whole dashboard.
+func (t *tui) render() {
+    snap := t.state.Snapshot()
+    t.mu.Lock()
+    prompting, typed := t.prompt, len(t.line)
+    t.mu.Unlock()
+
+    var b strings.Builder
+    b.WriteString("\033[?25l\033[H\033[2J")
+
+    status := "RUNNING"
+    if snap.Pause != nil {
+        status = "PAUSED" + strings.TrimPrefix(snap.Pause.String(), "paused")
+    }
+    expiry := "unknown"
+    if !snap.TokenExpiry.IsZero() {
+        if left := time.Until(snap.TokenExpiry); left > 0 {
+            expiry = "in " + left.Round(time.Second).String()
+        } else {
+            expiry = "EXPIRED"
+        }
+    }
+    fmt.Fprintf(&b, "Synack Mission Bot   claiming: %s   token expires: %s\r\n", status, expiry)
+    if snap.Latency.Samples > 0 {
+        fmt.Fprintf(&b, "Latency: %s\r\n", snap.Latency)
+    }
+
+    fmt.Fprintf(&b, "\r\n── Pending missions (%d) ──\r\n", len(snap.Pending))
+    for i, task := range snap.Pending {
+        if i == tuiPaneRows {
+            fmt.Fprintf(&b, "  … %d more\r\n", len(snap.Pending)-i)
+            break
+        }
+        fmt.Fprintf(&b, "  %-8s %-20s %s\r\n", task.Payout, task.ListingCodename, task.Title)
+    }
+
+    fmt.Fprintf(&b, "\r\n── Recently claimed ──\r\n")
+    for i := len(snap.Claimed) - 1; i >= 0 && len(snap.Claimed)-i <= tuiPaneRows; i-- {
+        c := snap.Claimed[i]
+        fmt.Fprintf(&b, "  %s  %-8s %-20s %s\r\n", c.At.Format("15:04:05"), c.Task.Payout, c.Task.ListingCodename, c.Task.Title)
+    }
+
+    fmt.Fprintf(&b, "\r\n── Unregistered targets (%d) ──\r\n", len(snap.Targets))
+    for i, target := range snap.Targets {
+        if i == tuiPaneRows {
+            fmt.Fprintf(&b, "  … %d more\r\n", len(snap.Targets)-i)
+            break
+        }
+        fmt.Fprintf(&b, "  %-20s %-20s %s\r\n", target.Codename, target.Slug, target.Category.Name)
+    }
+
+    if len(snap.HookRuns) > 0 {
+        fmt.Fprintf(&b, "\r\n── Hooks ──\r\n")
+        runs := snap.HookRuns
+        if len(runs) > tuiPaneRows {
+            runs = runs[len(runs)-tuiPaneRows:]
+        }
+        for _, r := range runs {
+            elapsed := ""
+            if !r.Started.IsZero() {
+                end := r.Finished
+                if end.IsZero() {
+                    end = time.Now()
+                }
+                elapsed = end.Sub(r.Started).Round(time.Second).String()
+            }
+            fmt.Fprintf(&b, "  %-10s %-16s %-20s try %d  %s\r\n", r.Status, r.Hook, r.Subject, r.Attempt, elapsed)
+        }
+    }
+
+    fmt.Fprintf(&b, "\r\n── Log ──\r\n")
+    logTail := snap.LogTail
+    if len(logTail) > tuiLogRows {
+        logTail = logTail[len(logTail)-tuiLogRows:]
+    }
+    for _, line := range logTail {
+        fmt.Fprintf(&b, "  %s\r\n", line)
+    }
+
+    if prompting {
+        fmt.Fprintf(&b, "\r\nToken expired or invalid. Paste a new token and press Enter: %s", strings.Repeat("*", typed))
+    } else {
+        b.WriteString("\r\n[p] pause/resume claiming   [q] quit")
+    }
+    fmt.Print(b.String())
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "log/slog"
+    "net/http"
+)
+
+// taskTransitionsURL returns the transitions endpoint of a task, used for
+// claiming and for marking the task as viewed.
+func taskTransitionsURL(task Task) string {
+    return fmt.Sprintf(
+        "https://platform.synack.com/api/tasks/v1/organizations/%s/listings/%s/campaigns/%s/tasks/%s/transitions",
+        task.OrganizationUid, task.ListingUid, task.CampaignUid, task.ID,
+    )
+}
+
+// markTaskViewed marks a claimed task as viewed (acknowledged), as the web
+// client does when a mission is opened.
+func markTaskViewed(token string, task Task) error {
+    resp, err := doWithRetry("mark_viewed", defaultRetryPolicy, func() (*http.Request, error) {
+        return newAPIRequest("POST", taskTransitionsURL(task), token, []byte(`{"type": "VIEW"}`))
+    })
+    if err != nil {
+        return err
+    }
+    defer closeBody(resp)
+    logResponse("mark_viewed", resp, "task_id", task.ID)
+
+    switch resp.StatusCode {
+    case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+        return nil
+    case http.StatusUnauthorized:
+        return fmt.Errorf("unauthorized (401)")
+    default:
+        return unexpectedStatus("mark_viewed", resp.StatusCode, fmt.Errorf("failed to mark task as viewed, status code: %d", resp.StatusCode))
+    }
+}
+
+// runMarkViewed implements the "mark-viewed" subcommand, which marks a single
+// task as viewed for scripted workflows.
+func runMarkViewed(args []string) error {
+    fs := flag.NewFlagSet("mark-viewed", flag.ExitOnError)
+    tokenFlag := fs.String("t", "", "Session token for authentication")
+    orgFlag := fs.String("org", "", "Organization UID of the task")
+    listingFlag := fs.String("listing", "", "Listing UID of the task")
+    campaignFlag := fs.String("campaign", "", "Campaign UID of the task")
+    taskFlag := fs.String("task", "", "Task ID")
+    fs.Parse(args)
+    if *tokenFlag == "" || *orgFlag == "" || *listingFlag == "" || *campaignFlag == "" || *taskFlag == "" {
+        fs.Usage()
+        return fmt.Errorf("-t, -org, -listing, -campaign and -task are required")
+    }
+
+    task := Task{
+        ID:              *taskFlag,
+        OrganizationUid: *orgFlag,
+        ListingUid:      *listingFlag,
+        CampaignUid:     *campaignFlag,
+    }
+    if err := markTaskViewed(*tokenFlag, task); err != nil {
+        return err
+    }
+    slog.Info("task marked as viewed", "task_id", task.ID)
+    return nil
+}
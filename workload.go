@@ -0,0 +1,168 @@
+package main
+
+import (
+    "fmt"
+    "log/slog"
+    "time"
+)
+
+// Workload forecast defaults.
+const (
+    defaultWorkloadEstimate = 2 * time.Hour
+    defaultWorkloadHorizon  = 72 * time.Hour
+)
+
+// WorkloadConfig estimates the work the claimed missions still need, for
+// the workload forecast and, with a capacity, to stop claiming missions
+// that couldn't be completed in time anyway.
+type WorkloadConfig struct {
+    // Estimates is the expected effort of a mission per category, e.g.
+    // {"Web": "3h"}; other categories take DefaultEstimate (default 2h).
+    Estimates       map[string]Duration `json:"estimates"`
+    DefaultEstimate Duration            `json:"default_estimate"`
+    // Horizon is the period the forecast covers (default 72h).
+    Horizon Duration `json:"horizon"`
+    // Capacity, if set, is the most work the forecast may hold: missions
+    // that would exceed it are not claimed (skip reason "workload").
+    Capacity Duration `json:"capacity"`
+}
+
+// validate checks the workload settings.
+func (c WorkloadConfig) validate() error {
+    for category, d := range c.Estimates {
+        if d.Duration <= 0 {
+            return fmt.Errorf("workload.estimates.%s must be positive", category)
+        }
+    }
+    if c.DefaultEstimate.Duration < 0 || c.Horizon.Duration < 0 || c.Capacity.Duration < 0 {
+        return fmt.Errorf("workload durations must not be negative")
+    }
+    return nil
+}
+
+// estimate returns the expected effort of a mission.
+func (c WorkloadConfig) estimate(task Task) time.Duration {
+    if d, ok := c.Estimates[task.Category]; ok {
+        return d.Duration
+    }
+    if c.DefaultEstimate.Duration > 0 {
+        return c.DefaultEstimate.Duration
+    }
+    return defaultWorkloadEstimate
+}
+
+// enabled reports whether a workload section is configured.
+func (c WorkloadConfig) enabled() bool {
+    return len(c.Estimates) > 0 || c.DefaultEstimate.Duration > 0 || c.Horizon.Duration > 0 || c.Capacity.Duration > 0
+}
+
+// horizon returns the period the forecast covers.
+func (c WorkloadConfig) horizon() time.Duration {
+    if c.Horizon.Duration > 0 {
+        return c.Horizon.Duration
+    }
+    return defaultWorkloadHorizon
+}
+
+// workShare returns how much of a mission's effort falls into the horizon,
+// assuming the work is spread evenly until its deadline: all of it when
+// the deadline is within the horizon (or unknown), a share otherwise.
+func (c WorkloadConfig) workShare(task Task, deadline time.Time, known bool, now time.Time) time.Duration {
+    work := c.estimate(task)
+    if !known {
+        return work
+    }
+    left := deadline.Sub(now)
+    if left <= 0 {
+        return 0
+    }
+    if h := c.horizon(); left > h {
+        return time.Duration(float64(work) * float64(h) / float64(left))
+    }
+    return work
+}
+
+// workloadForecast is the committed work within the forecast horizon.
+type workloadForecast struct {
+    Work     time.Duration
+    Horizon  time.Duration
+    Missions int
+}
+
+// forecast estimates the work the claimed missions need within the horizon.
+func (c WorkloadConfig) forecast(claimed []Task, now time.Time) workloadForecast {
+    f := workloadForecast{Horizon: c.horizon()}
+    for _, task := range claimed {
+        deadline, known := missionDeadline(task)
+        if share := c.workShare(task, deadline, known, now); share > 0 {
+            f.Work += share
+            f.Missions++
+        }
+    }
+    return f
+}
+
+// String formats the forecast, e.g. "14h of committed work in the next 3
+// days (5 missions)".
+func (f workloadForecast) String() string {
+    return fmt.Sprintf("%s of committed work in the next %s (%d missions)", formatWorkHours(f.Work), formatHorizon(f.Horizon), f.Missions)
+}
+
+// formatWorkHours formats work in hours, to the half hour.
+func formatWorkHours(d time.Duration) string {
+    return fmt.Sprintf("%gh", float64(d.Round(30*time.Minute))/float64(time.Hour))
+}
+
+// formatHorizon formats the horizon in days when it is a whole number of
+// them.
+func formatHorizon(d time.Duration) string {
+    switch {
+    case d == 24*time.Hour:
+        return "day"
+    case d%(24*time.Hour) == 0:
+        return fmt.Sprintf("%d days", d/(24*time.Hour))
+    }
+    return d.String()
+}
+
+// dropOverCapacity updates the workload forecast shown in status replies and
+// removes the missions that would take it past the configured capacity,
+// best ranked missions first. claimed are the claimed missions if this cycle
+// already fetched them; otherwise they are fetched, only when workload is
+// configured. If that fails, the missions are kept.
+func (b *bot) dropOverCapacity(token string, claimed, tasks []Task) []Task {
+    cfg := b.config().Workload
+    if !cfg.enabled() || len(tasks) == 0 {
+        return tasks
+    }
+    if claimed == nil {
+        var err error
+        if claimed, err = getClaimedTasks(token); err != nil {
+            slog.Warn("failed to check the workload", "endpoint", "claimed_tasks", "err", err)
+            return tasks
+        }
+    }
+    now := time.Now()
+    forecast := cfg.forecast(claimed, now)
+    b.state.SetWorkload(forecast)
+    if cfg.Capacity.Duration <= 0 {
+        return tasks
+    }
+    kept := tasks[:0:0]
+    for _, task := range tasks {
+        var share time.Duration
+        if task.MaxCompletionTimeInSecs > 0 {
+            share = cfg.workShare(task, now.Add(time.Duration(task.MaxCompletionTimeInSecs)*time.Second), true, now)
+        } else {
+            share = cfg.workShare(task, time.Time{}, false, now)
+        }
+        if forecast.Work+share > cfg.Capacity.Duration {
+            slog.Info("skipping task, workload capacity reached", "task_id", task.ID, "title", task.Title, "forecast", formatWorkHours(forecast.Work), "task_work", formatWorkHours(share), "capacity", formatWorkHours(cfg.Capacity.Duration))
+            b.state.RecordSkips(SkipWorkload, []Task{task})
+            continue
+        }
+        forecast.Work += share
+        kept = append(kept, task)
+    }
+    return kept
+}